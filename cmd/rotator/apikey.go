@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/app"
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/logger"
+	"github.com/FedoseevAlex/banner-rotation/internal/storage"
+	"github.com/google/uuid"
+)
+
+func runAPIKey(args []string, stdout, stderr io.Writer) error {
+	var (
+		configPath string
+		name       string
+		role       string
+	)
+	fs := newFlagSet("apikey", `Usage: rotator apikey [flags] create|list|revoke [key_id]
+
+Manage API keys in the database from config. It is the way to create
+the first admin key, the rest can be managed through /api-keys endpoints.
+
+  create  create key with -name and -role and print it
+  list    list keys which are not revoked
+  revoke  revoke key with given id
+
+Roles are serve (choose banners, register clicks and conversions),
+analyst (read entities and statistics) and admin (everything).
+The key is printed only once, the database keeps its hash.
+
+Flags:`, stderr)
+	fs.StringVar(&configPath, "config", "", "Path to config file.")
+	fs.StringVar(&name, "name", "", "Name of created key, e.g. owner of the key.")
+	fs.StringVar(&role, "role", "", "Role of created key: serve, analyst or admin.")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usagef("apikey expects one of create, list or revoke")
+	}
+
+	command := fs.Arg(0)
+	var keyID uuid.UUID
+	switch command {
+	case "create":
+		if fs.NArg() != 1 || name == "" || role == "" {
+			return usagef("apikey create expects -name and -role")
+		}
+	case "list":
+		if fs.NArg() != 1 {
+			return usagef("apikey list expects no arguments")
+		}
+	case "revoke":
+		if fs.NArg() != 2 {
+			return usagef("apikey revoke expects key id")
+		}
+		var err error
+		keyID, err = uuid.Parse(fs.Arg(1))
+		if err != nil {
+			return usagef("invalid key id: %v", err)
+		}
+	default:
+		return usagef("unknown apikey command %q", command)
+	}
+
+	cfg, err := config.ReadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	store := storage.New(cfg.Storage.DBConnectionString)
+	err = store.Connect()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	application := &app.App{Storage: store, Log: logger.New(cfg.Log.Level, cfg.Log.File)}
+	ctx := context.Background()
+
+	switch command {
+	case "create":
+		key, secret, err := application.CreateAPIKey(ctx, name, role)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(stdout, "id:   %s\nrole: %s\nkey:  %s\n", key.ID, key.Role, secret)
+		return err
+	case "list":
+		keys, err := application.ListAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join([]string{"ID", "NAME", "ROLE", "CREATED"}, "\t"))
+		for _, key := range keys {
+			fmt.Fprintln(tw, strings.Join([]string{
+				key.ID.String(),
+				key.Name,
+				key.Role,
+				key.CreatedAt.Format(time.RFC3339),
+			}, "\t"))
+		}
+		return tw.Flush()
+	default:
+		return application.RevokeAPIKey(ctx, keyID)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/app"
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/logger"
+	"github.com/FedoseevAlex/banner-rotation/internal/rotators"
+	"github.com/FedoseevAlex/banner-rotation/internal/server"
+	"github.com/FedoseevAlex/banner-rotation/internal/storage"
+	"github.com/FedoseevAlex/banner-rotation/internal/tracing"
+)
+
+func runCheckConfig(args []string, stdout, stderr io.Writer) error {
+	var (
+		configPath string
+		connect    bool
+	)
+	fs := newFlagSet("check-config", `Usage: rotator check-config [flags]
+
+Read config file and report values the server would fail on or ignore.
+With -connect also check that the database is reachable.
+
+Flags:`, stderr)
+	fs.StringVar(&configPath, "config", "", "Path to config file.")
+	fs.BoolVar(&connect, "connect", false, "Connect to the database from config.")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usagef("check-config expects no arguments")
+	}
+
+	cfg, err := config.ReadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	problems := checkConfig(cfg)
+	if connect && cfg.Storage.DBConnectionString != "" {
+		store := storage.New(cfg.Storage.DBConnectionString)
+		err = store.Connect()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("storage: failed to connect: %v", err))
+		} else {
+			store.Close()
+		}
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintln(stderr, problem)
+		}
+		return fmt.Errorf("config %s has %d problems", configPath, len(problems))
+	}
+
+	_, err = fmt.Fprintf(stdout, "config %s is valid\n", configPath)
+	return err
+}
+
+// checkConfig returns description of every invalid config value.
+func checkConfig(cfg config.Config) []string {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if _, err := time.ParseDuration(cfg.Server.Timeout); err != nil {
+		problem("server.timeout: %v", err)
+	}
+	if port, err := strconv.Atoi(cfg.Server.Port); err != nil || port <= 0 || port > 65535 {
+		problem("server.port: %q is not a port number", cfg.Server.Port)
+	}
+	if _, err := parseShutdownTimeout(cfg.Server); err != nil {
+		problem("server.shutdown_timeout: %v", err)
+	}
+	if (cfg.Server.Admin.User == "") != (cfg.Server.Admin.Password == "") {
+		problem("server.admin: both user and password must be set to enable dashboard")
+	}
+	switch cfg.Server.RateLimit.Backend {
+	case "", "memory", "postgres":
+	default:
+		problem("server.rate_limit.backend: unknown backend %q", cfg.Server.RateLimit.Backend)
+	}
+	if err := server.CheckRateLimits(cfg.Server.RateLimit); err != nil {
+		problem("server.rate_limit.%v", err)
+	}
+	switch cfg.Server.Idempotency.Backend {
+	case "", "memory", "postgres":
+	default:
+		problem("server.idempotency.backend: unknown backend %q", cfg.Server.Idempotency.Backend)
+	}
+	if err := server.CheckIdempotencyTTL(cfg.Server.Idempotency.TTL); err != nil {
+		problem("server.idempotency.ttl: %v", err)
+	}
+
+	if cfg.Storage.DBConnectionString == "" {
+		problem("storage.db_connection_string: must be set")
+	}
+
+	switch cfg.Exposures.Backend {
+	case "", "memory", "postgres":
+	default:
+		problem("exposures.backend: unknown backend %q", cfg.Exposures.Backend)
+	}
+
+	if _, err := app.NewClickValidation(cfg.Clicks); err != nil {
+		problem("clicks.%v", err)
+	}
+
+	if _, err := rotators.New(cfg.Rotator); err != nil {
+		problem("rotator.algorithm: %v", err)
+	}
+
+	if err := tracing.CheckExporter(cfg.Tracing.Exporter); err != nil {
+		problem("tracing.exporter: %v", err)
+	}
+
+	if cfg.Log.File == "" {
+		problem("log.file: must be set")
+	}
+	if err := logger.CheckLevel(cfg.Log.Level); err != nil {
+		problem("log.level: %v", err)
+	}
+
+	return problems
+}
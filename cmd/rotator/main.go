@@ -1,40 +1,133 @@
+// Command rotator serves banner rotation API and runs maintenance tasks.
 package main
 
 import (
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"io"
+	"os"
 
-	"github.com/FedoseevAlex/banner-rotation/internal/app"
-	"github.com/FedoseevAlex/banner-rotation/internal/config"
-	"github.com/FedoseevAlex/banner-rotation/internal/server"
+	"github.com/FedoseevAlex/banner-rotation/internal/common"
 )
 
-var configPath string
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+const usage = `Usage: rotator <command> [flags]
+
+Commands:
+  serve         start HTTP API server
+  version       print build information
+  migrate       apply or roll back database migrations
+  check-config  validate config file
+  simulate      replay traffic through a rotator and report regret
+  reconcile     compare rotation counters with logged events
+  apikey        create, list and revoke API keys
+
+Run "rotator <command> -h" for command flags.
+Exit code is 0 on success, 1 on failure and 2 on malformed command line.
+`
+
+// commands run subcommand with arguments following its name.
+var commands = map[string]func(args []string, stdout, stderr io.Writer) error{
+	"serve":        runServe,
+	"version":      runVersion,
+	"migrate":      runMigrate,
+	"check-config": runCheckConfig,
+	"simulate":     runSimulate,
+	"reconcile":    runReconcile,
+	"apikey":       runAPIKey,
+}
 
-func init() {
-	flag.StringVar(&configPath, "config", "", "Path to config file.")
+// usageError is returned when command line is malformed.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...interface{}) error {
+	return usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// parseFlags parses command flags. Flag set reports malformed flags
+// itself so usage error is returned without message.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return usageError{}
+	}
+	return err
 }
 
 func main() {
-	flag.Parse()
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
 
-	cfg, err := config.ReadConfig(configPath)
-	if err != nil {
-		log.Fatal(err)
+	name, args := args[0], args[1:]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
 	}
 
-	application, err := app.New(cfg)
-	if err != nil {
-		log.Fatal(err)
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", name, usage)
+		return exitUsage
 	}
 
-	srv, err := server.NewServer(application, cfg.Server)
-	if err != nil {
-		log.Fatal(err)
+	err := command(args, stdout, stderr)
+	var usageErr usageError
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, &usageErr):
+		if usageErr.msg != "" {
+			fmt.Fprintf(stderr, "%s\nRun \"rotator %s -h\" for usage.\n", usageErr.msg, name)
+		}
+		return exitUsage
+	case err != nil:
+		fmt.Fprintln(stderr, err)
+		return exitError
 	}
+	return exitOK
+}
 
-	err = srv.Start()
-	if err != nil {
-		log.Fatal(err)
+// newFlagSet creates flag set of command which prints help
+// with description followed by flag defaults.
+func newFlagSet(name, help string, output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), help)
+		fs.PrintDefaults()
 	}
+	return fs
+}
+
+func runVersion(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("version", `Usage: rotator version
+
+Print release, build date and git hash of the binary as JSON.`, stderr)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usagef("version expects no arguments")
+	}
+
+	return common.PrintVersion(stdout)
 }
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	validConfig := `
+[server]
+port = "8080"
+timeout = "10s"
+
+[storage]
+db_connection_string = "postgres://localhost/bannerrotation"
+
+[log]
+file = "rotator.log"
+level = "info"
+`
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	err := ioutil.WriteFile(configPath, []byte(validConfig), 0o600)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{name: "no command", args: nil, code: exitUsage, stderr: "Usage: rotator <command>"},
+		{name: "unknown command", args: []string{"start"}, code: exitUsage, stderr: `unknown command "start"`},
+		{name: "help", args: []string{"help"}, code: exitOK, stdout: "check-config"},
+		{name: "command help", args: []string{"migrate", "-h"}, code: exitOK, stderr: "up|down|status"},
+		{name: "unknown flag", args: []string{"serve", "-port", "80"}, code: exitUsage, stderr: "flag provided but not defined"},
+		{name: "unexpected argument", args: []string{"version", "now"}, code: exitUsage, stderr: "version expects no arguments"},
+		{name: "version", args: []string{"version"}, code: exitOK, stdout: `"GitHash"`},
+		{name: "valid config", args: []string{"check-config", "-config", configPath}, code: exitOK, stdout: "is valid"},
+		{name: "missing config", args: []string{"check-config", "-config", filepath.Join(dir, "none.toml")}, code: exitError},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run("check "+tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tc.args, &stdout, &stderr)
+			require.Equal(t, tc.code, code, stderr.String())
+			require.Contains(t, stdout.String(), tc.stdout)
+			require.Contains(t, stderr.String(), tc.stderr)
+		})
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	cfg := config.Config{
+		Server: config.Server{
+			Port:            "http",
+			Timeout:         "10",
+			ShutdownTimeout: "soon",
+			Admin:           config.Admin{User: "admin"},
+			RateLimit: config.RateLimit{
+				Backend: "redis",
+				Click:   config.Limit{Requests: 10, Per: "second"},
+			},
+			Idempotency: config.Idempotency{Backend: "redis", TTL: "day"},
+		},
+		Exposures: config.Exposures{Backend: "redis"},
+		Clicks:    config.Clicks{MinDelay: "half a second"},
+		Rotator:   config.Rotator{Algorithm: "epsilon-greedy"},
+		Tracing:   config.Tracing{Exporter: "jaeger"},
+		Log:       config.Logger{File: "rotator.log", Level: "verbose"},
+	}
+
+	problems := checkConfig(cfg)
+	require.Len(t, problems, 14)
+	require.Contains(t, problems[0], "server.timeout")
+	require.Contains(t, problems[1], "server.port")
+	require.Contains(t, problems[2], "server.shutdown_timeout")
+	require.Contains(t, problems[3], "server.admin")
+	require.Contains(t, problems[4], "server.rate_limit.backend")
+	require.Contains(t, problems[5], "server.rate_limit.click")
+	require.Contains(t, problems[6], "server.idempotency.backend")
+	require.Contains(t, problems[7], "server.idempotency.ttl")
+	require.Contains(t, problems[8], "storage.db_connection_string")
+	require.Contains(t, problems[9], "exposures.backend")
+	require.Contains(t, problems[10], "clicks.min_delay")
+	require.Contains(t, problems[11], "rotator.algorithm")
+	require.Contains(t, problems[12], "tracing.exporter")
+	require.Contains(t, problems[13], "log.level")
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"io"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/storage"
+)
+
+func runMigrate(args []string, stdout, stderr io.Writer) error {
+	var configPath string
+	fs := newFlagSet("migrate", `Usage: rotator migrate [flags] up|down|status
+
+Apply migrations embedded into the binary to the database from config.
+
+  up      apply all pending migrations
+  down    roll back the latest applied migration
+  status  list applied and pending migrations
+
+Flags:`, stderr)
+	fs.StringVar(&configPath, "config", "", "Path to config file.")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("migrate expects one of up, down or status")
+	}
+
+	cfg, err := config.ReadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	store := storage.New(cfg.Storage.DBConnectionString)
+
+	var migrate func(ctx context.Context, w io.Writer) error
+	switch command := fs.Arg(0); command {
+	case "up":
+		migrate = store.MigrateUp
+	case "down":
+		migrate = store.MigrateDown
+	case "status":
+		migrate = store.MigrationStatus
+	default:
+		return usagef("unknown migrate command %q", command)
+	}
+
+	err = store.Connect()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return migrate(context.Background(), stdout)
+}
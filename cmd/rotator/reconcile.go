@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/storage"
+)
+
+func runReconcile(args []string, stdout, stderr io.Writer) error {
+	var (
+		configPath string
+		apply      bool
+	)
+	fs := newFlagSet("reconcile", `Usage: rotator reconcile [flags]
+
+Compare shows, clicks, conversions and revenue stored in rotations
+with amounts of their logged events and list rotations which differ.
+With -apply counters are set to amounts of events.
+
+Imported catalogue statistics have no logged events,
+they are remembered on import and added to the amounts of events.
+
+Flags:`, stderr)
+	fs.StringVar(&configPath, "config", "", "Path to config file.")
+	fs.BoolVar(&apply, "apply", false, "Set counters to amounts of logged events.")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usagef("reconcile expects no arguments")
+	}
+
+	cfg, err := config.ReadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	store := storage.New(cfg.Storage.DBConnectionString)
+	err = store.Connect()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	drifts, err := store.ReconcileCounters(context.Background(), apply)
+	if err != nil {
+		return err
+	}
+
+	if len(drifts) == 0 {
+		_, err = fmt.Fprintln(stdout, "rotation counters match logged events")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"BANNER", "SLOT", "GROUP", "SHOWS", "CLICKS", "CONVERSIONS", "REVENUE"}, "\t"))
+	for _, d := range drifts {
+		fmt.Fprintln(tw, strings.Join([]string{
+			d.BannerID.String(),
+			d.SlotID.String(),
+			d.GroupID.String(),
+			strconv.Itoa(d.Shows) + " -> " + strconv.Itoa(d.EventShows),
+			strconv.Itoa(d.Clicks) + " -> " + strconv.Itoa(d.EventClicks),
+			strconv.Itoa(d.Conversions) + " -> " + strconv.Itoa(d.EventConversions),
+			strconv.FormatFloat(d.Revenue, 'f', -1, 64) + " -> " + strconv.FormatFloat(d.EventRevenue, 'f', -1, 64),
+		}, "\t"))
+	}
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	if apply {
+		_, err = fmt.Fprintf(stdout, "%d rotations updated\n", len(drifts))
+	} else {
+		_, err = fmt.Fprintf(stdout, "%d rotations differ, run with -apply to fix them\n", len(drifts))
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/app"
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/server"
+	"github.com/FedoseevAlex/banner-rotation/internal/tracing"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+const defaultShutdownTimeout = 30 * time.Second
+
+// tracingFlushTimeout limits time spent sending buffered spans on exit.
+const tracingFlushTimeout = 5 * time.Second
+
+func runServe(args []string, _, stderr io.Writer) error {
+	var configPath string
+	fs := newFlagSet("serve", `Usage: rotator serve [flags]
+
+Start HTTP API server. Pending migrations are applied first
+if storage auto_migrate is set in config.
+
+On SIGINT or SIGTERM server stops accepting connections and waits
+for in-flight requests during server shutdown_timeout, then closes
+the database. Second signal terminates server at once.
+
+Flags:`, stderr)
+	fs.StringVar(&configPath, "config", "", "Path to config file.")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usagef("serve expects no arguments")
+	}
+
+	cfg, err := config.ReadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	shutdownTimeout, err := parseShutdownTimeout(cfg.Server)
+	if err != nil {
+		return err
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer cancel()
+		shutdownTracing(ctx) //nolint:errcheck
+	}()
+
+	application, err := app.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	srv, err := server.NewServer(application, cfg.Server)
+	if err != nil {
+		application.Close()
+		return err
+	}
+
+	return serve(srv, application, shutdownTimeout, application.Log)
+}
+
+func parseShutdownTimeout(cfg config.Server) (time.Duration, error) {
+	if cfg.ShutdownTimeout == "" {
+		return defaultShutdownTimeout, nil
+	}
+	return time.ParseDuration(cfg.ShutdownTimeout)
+}
+
+// serve runs server until SIGINT or SIGTERM. Then server is stopped
+// within shutdownTimeout and closer is closed even if requests did not finish.
+func serve(srv *server.Server, closer io.Closer, shutdownTimeout time.Duration, log types.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Start()
+	}()
+
+	select {
+	case err := <-served:
+		// Server failed to listen
+		closer.Close()
+		return err
+	case <-ctx.Done():
+	}
+	// Restore default handling so that second signal terminates process
+	stop()
+
+	log.Info(
+		"shutting down",
+		types.LogFields{"timeout": shutdownTimeout.String()},
+	)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	stopErr := srv.Stop(shutdownCtx)
+	if stopErr != nil {
+		log.Error(
+			"failed to drain in-flight requests",
+			types.LogFields{"error": stopErr},
+		)
+	}
+
+	closeErr := closer.Close()
+	if closeErr != nil {
+		log.Error(
+			"failed to close storage",
+			types.LogFields{"error": closeErr},
+		)
+	}
+
+	if stopErr != nil {
+		return stopErr
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	log.Info("server stopped", types.LogFields{})
+	return nil
+}
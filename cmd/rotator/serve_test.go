@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/logger"
+	"github.com/FedoseevAlex/banner-rotation/internal/server"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const serveHelperEnvVar = "ROTATOR_SERVE_HELPER"
+
+// slowApp answers banner choice after delay. It reports start of request
+// and closing of storage to w, so parent process can follow shutdown.
+type slowApp struct {
+	types.Application
+	delay time.Duration
+	w     io.Writer
+}
+
+func (sa *slowApp) GetLogger(string) types.Logger {
+	return logger.New("error", os.DevNull)
+}
+
+func (sa *slowApp) ChooseBanner(_ context.Context, slotID, groupID uuid.UUID, _ types.Visitor) (types.Impression, error) {
+	fmt.Fprintln(sa.w, "request started")
+	time.Sleep(sa.delay)
+	return types.Impression{
+		ImpressionID: uuid.New(),
+		Rotation:     types.Rotation{BannerID: uuid.New(), SlotID: slotID, GroupID: groupID},
+	}, nil
+}
+
+func (sa *slowApp) Close() error {
+	fmt.Fprintln(sa.w, "storage closed")
+	return nil
+}
+
+// TestServeHelperProcess is the server run by TestServeSignals in a subprocess.
+func TestServeHelperProcess(t *testing.T) {
+	if os.Getenv(serveHelperEnvVar) != "1" {
+		t.Skip("helper process of TestServeSignals")
+	}
+
+	delay, err := time.ParseDuration(os.Getenv("ROTATOR_DELAY"))
+	require.NoError(t, err)
+	shutdownTimeout, err := time.ParseDuration(os.Getenv("ROTATOR_SHUTDOWN_TIMEOUT"))
+	require.NoError(t, err)
+
+	application := &slowApp{delay: delay, w: os.Stdout}
+	srv, err := server.NewServer(application, config.Server{
+		Host:        "127.0.0.1",
+		Port:        os.Getenv("ROTATOR_PORT"),
+		Timeout:     "10s",
+		DisableAuth: true,
+	})
+	require.NoError(t, err)
+
+	err = serve(srv, application, shutdownTimeout, application.GetLogger(""))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitError)
+	}
+	os.Exit(exitOK)
+}
+
+func freePort(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	return port
+}
+
+func TestServeSignals(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping TestServeSignals in short mode")
+	}
+
+	tests := []struct {
+		name            string
+		signal          os.Signal
+		delay           time.Duration
+		shutdownTimeout time.Duration
+		drained         bool
+	}{
+		{
+			name:            "in-flight request is drained on SIGTERM",
+			signal:          syscall.SIGTERM,
+			delay:           500 * time.Millisecond,
+			shutdownTimeout: 5 * time.Second,
+			drained:         true,
+		},
+		{
+			name:            "in-flight request is drained on SIGINT",
+			signal:          os.Interrupt,
+			delay:           500 * time.Millisecond,
+			shutdownTimeout: 5 * time.Second,
+			drained:         true,
+		},
+		{
+			name:            "in-flight request is dropped after deadline",
+			signal:          syscall.SIGTERM,
+			delay:           5 * time.Second,
+			shutdownTimeout: 200 * time.Millisecond,
+			drained:         false,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run("check "+tc.name, func(t *testing.T) {
+			port := freePort(t)
+
+			cmd := exec.Command(os.Args[0], "-test.run=^TestServeHelperProcess$")
+			cmd.Env = append(
+				os.Environ(),
+				serveHelperEnvVar+"=1",
+				"ROTATOR_PORT="+port,
+				"ROTATOR_DELAY="+tc.delay.String(),
+				"ROTATOR_SHUTDOWN_TIMEOUT="+tc.shutdownTimeout.String(),
+			)
+			stdout, err := cmd.StdoutPipe()
+			require.NoError(t, err)
+			require.NoError(t, cmd.Start())
+			defer cmd.Process.Kill() //nolint:errcheck
+
+			lines := make(chan string)
+			go func() {
+				scanner := bufio.NewScanner(stdout)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+				close(lines)
+			}()
+
+			addr := "http://127.0.0.1:" + port
+			require.Eventually(t, func() bool {
+				response, err := http.Get(addr + "/version")
+				if err != nil {
+					return false
+				}
+				response.Body.Close()
+				return response.StatusCode == http.StatusOK
+			}, 10*time.Second, 20*time.Millisecond, "server did not start")
+
+			responses := make(chan error, 1)
+			go func() {
+				response, err := http.Get(fmt.Sprintf("%s/group/%s/slots/%s/banner", addr, uuid.New(), uuid.New()))
+				if err == nil {
+					response.Body.Close()
+					if response.StatusCode != http.StatusOK {
+						err = fmt.Errorf("unexpected status %d", response.StatusCode)
+					}
+				}
+				responses <- err
+			}()
+
+			require.Equal(t, "request started", <-lines)
+			require.NoError(t, cmd.Process.Signal(tc.signal))
+
+			err = <-responses
+			var output []string
+			for line := range lines {
+				output = append(output, line)
+			}
+			waitErr := cmd.Wait()
+
+			require.Contains(t, output, "storage closed")
+			if tc.drained {
+				require.NoError(t, err)
+				require.NoError(t, waitErr)
+			} else {
+				require.Error(t, err)
+				var exitErr *exec.ExitError
+				require.ErrorAs(t, waitErr, &exitErr)
+				require.Equal(t, exitError, exitErr.ExitCode())
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/rotators"
+	"github.com/FedoseevAlex/banner-rotation/internal/simulation"
+	"github.com/FedoseevAlex/banner-rotation/internal/storage"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+type simulateOptions struct {
+	configPath   string
+	algorithm    string
+	scenarioPath string
+	ctrs         string
+	steps        int
+	seed         int64
+	reportEvery  int
+	slotID       string
+	groupID      string
+	from         string
+	to           string
+	replay       bool
+	format       string
+	output       string
+}
+
+func simulateFlags(opts *simulateOptions, output io.Writer) *flag.FlagSet {
+	fs := newFlagSet("simulate", `Usage: rotator simulate [flags]
+
+Replay traffic through a rotator and report cumulative regret,
+share of shows given to the best banner and CTR over time.
+
+Traffic is either synthetic (-scenario or -ctrs) or taken from logged
+events of a slot and group (-slot, -group, -from, -to and -config).
+Logged events are replayed as is with -replay, otherwise synthetic traffic
+with per-banner CTRs observed in the log is generated.
+
+Flags:`, output)
+
+	fs.StringVar(&opts.configPath, "config", "", "Path to config file. Required for logged events.")
+	fs.StringVar(&opts.algorithm, "rotator", "ucb1", "Rotator algorithm to simulate.")
+	fs.StringVar(&opts.scenarioPath, "scenario", "", "Path to JSON file with synthetic scenario.")
+	fs.StringVar(&opts.ctrs, "ctrs", "", "Comma separated true CTRs of synthetic banners, e.g. 0.01,0.05.")
+	fs.IntVar(&opts.steps, "steps", 10000, "Amount of synthetic shows.")
+	fs.Int64Var(&opts.seed, "seed", 1, "Seed of synthetic clicks.")
+	fs.IntVar(&opts.reportEvery, "every", 100, "Amount of shows between points of report series.")
+	fs.StringVar(&opts.slotID, "slot", "", "Slot of logged events.")
+	fs.StringVar(&opts.groupID, "group", "", "Group of logged events.")
+	fs.StringVar(&opts.from, "from", "", "Start of logged events time range in RFC3339. Defaults to 30 days ago.")
+	fs.StringVar(&opts.to, "to", "", "End of logged events time range in RFC3339. Defaults to now.")
+	fs.BoolVar(&opts.replay, "replay", false, "Replay logged events instead of generating synthetic traffic.")
+	fs.StringVar(&opts.format, "format", "json", "Report format: json or csv.")
+	fs.StringVar(&opts.output, "output", "", "Path to report file. Defaults to stdout.")
+
+	return fs
+}
+
+func runSimulate(args []string, stdout, stderr io.Writer) error {
+	opts := simulateOptions{}
+	fs := simulateFlags(&opts, stderr)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usagef("simulate expects no arguments")
+	}
+
+	if opts.format != "json" && opts.format != "csv" {
+		return usagef("unknown report format %q", opts.format)
+	}
+
+	rotator, err := rotators.New(config.Rotator{Algorithm: opts.algorithm})
+	if err != nil {
+		return err
+	}
+
+	var report simulation.Report
+	switch {
+	case opts.scenarioPath != "" || opts.ctrs != "":
+		scenario, err := loadScenario(opts)
+		if err != nil {
+			return err
+		}
+		report, err = simulation.Run(rotator, scenario)
+		if err != nil {
+			return err
+		}
+	case opts.slotID != "" && opts.groupID != "":
+		events, err := loadEvents(opts)
+		if err != nil {
+			return err
+		}
+
+		if opts.replay {
+			report, err = simulation.Replay(rotator, events, opts.reportEvery)
+		} else {
+			scenario := simulation.ScenarioFromEvents(events, opts.steps, opts.seed)
+			scenario.ReportEvery = opts.reportEvery
+			report, err = simulation.Run(rotator, scenario)
+		}
+		if err != nil {
+			return err
+		}
+	default:
+		return usagef("either synthetic scenario or slot and group of logged events must be set")
+	}
+
+	out := stdout
+	if opts.output != "" {
+		file, err := os.Create(opts.output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if opts.format == "csv" {
+		return report.WriteCSV(out)
+	}
+	return report.WriteJSON(out)
+}
+
+func loadScenario(opts simulateOptions) (simulation.Scenario, error) {
+	scenario := simulation.Scenario{Steps: opts.steps, Seed: opts.seed, ReportEvery: opts.reportEvery}
+
+	if opts.scenarioPath != "" {
+		data, err := ioutil.ReadFile(opts.scenarioPath)
+		if err != nil {
+			return scenario, err
+		}
+		err = json.Unmarshal(data, &scenario)
+		return scenario, err
+	}
+
+	for _, rawCTR := range strings.Split(opts.ctrs, ",") {
+		ctr, err := strconv.ParseFloat(strings.TrimSpace(rawCTR), 64)
+		if err != nil {
+			return scenario, fmt.Errorf("failed to parse ctr: %w", err)
+		}
+		scenario.Arms = append(scenario.Arms, simulation.Arm{BannerID: uuid.New(), CTR: ctr})
+	}
+
+	return scenario, nil
+}
+
+func loadEvents(opts simulateOptions) ([]types.RotationEvent, error) {
+	slotID, err := uuid.Parse(opts.slotID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse slot uuid: %w", err)
+	}
+
+	groupID, err := uuid.Parse(opts.groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse group uuid: %w", err)
+	}
+
+	to := time.Now()
+	if opts.to != "" {
+		to, err = time.Parse(time.RFC3339, opts.to)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse end of time range: %w", err)
+		}
+	}
+
+	from := to.AddDate(0, 0, -30)
+	if opts.from != "" {
+		from, err = time.Parse(time.RFC3339, opts.from)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse start of time range: %w", err)
+		}
+	}
+
+	cfg, err := config.ReadConfig(opts.configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	store := storage.New(cfg.Storage.DBConnectionString)
+	err = store.Connect()
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	return store.GetEvents(context.Background(), slotID, groupID, from, to)
+}
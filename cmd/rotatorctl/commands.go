@@ -0,0 +1,511 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/catalogue"
+	"github.com/FedoseevAlex/banner-rotation/internal/client"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+type controller struct {
+	client *client.Client
+	out    printer
+	// in is read by commands given "-" instead of file name
+	in io.Reader
+}
+
+func (c *controller) dispatch(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return usagef("command is required")
+	}
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "banner":
+		return c.entity(ctx, "banner", args, entityOps{
+			create: func(ctx context.Context, description string) (interface{}, uuid.UUID, string, error) {
+				banner, err := c.client.AddBanner(ctx, description)
+				return banner, banner.ID, banner.Description, err
+			},
+			get: func(ctx context.Context, id uuid.UUID) (interface{}, uuid.UUID, string, error) {
+				banner, err := c.client.GetBanner(ctx, id)
+				return banner, banner.ID, banner.Description, err
+			},
+			list: func(ctx context.Context) (interface{}, [][]string, error) {
+				banners, err := c.client.ListBanners(ctx)
+				rows := make([][]string, 0, len(banners))
+				for _, banner := range banners {
+					rows = append(rows, []string{banner.ID.String(), banner.Description})
+				}
+				return banners, rows, err
+			},
+			remove: c.client.DeleteBanner,
+		})
+	case "slot":
+		return c.entity(ctx, "slot", args, entityOps{
+			create: func(ctx context.Context, description string) (interface{}, uuid.UUID, string, error) {
+				slot, err := c.client.AddSlot(ctx, description)
+				return slot, slot.ID, slot.Description, err
+			},
+			get: func(ctx context.Context, id uuid.UUID) (interface{}, uuid.UUID, string, error) {
+				slot, err := c.client.GetSlot(ctx, id)
+				return slot, slot.ID, slot.Description, err
+			},
+			list: func(ctx context.Context) (interface{}, [][]string, error) {
+				slots, err := c.client.ListSlots(ctx)
+				rows := make([][]string, 0, len(slots))
+				for _, slot := range slots {
+					rows = append(rows, []string{slot.ID.String(), slot.Description})
+				}
+				return slots, rows, err
+			},
+			remove: c.client.DeleteSlot,
+		})
+	case "group":
+		return c.entity(ctx, "group", args, entityOps{
+			create: func(ctx context.Context, description string) (interface{}, uuid.UUID, string, error) {
+				group, err := c.client.AddGroup(ctx, description)
+				return group, group.ID, group.Description, err
+			},
+			get: func(ctx context.Context, id uuid.UUID) (interface{}, uuid.UUID, string, error) {
+				group, err := c.client.GetGroup(ctx, id)
+				return group, group.ID, group.Description, err
+			},
+			list: func(ctx context.Context) (interface{}, [][]string, error) {
+				groups, err := c.client.ListGroups(ctx)
+				rows := make([][]string, 0, len(groups))
+				for _, group := range groups {
+					rows = append(rows, []string{group.ID.String(), group.Description})
+				}
+				return groups, rows, err
+			},
+			remove: c.client.DeleteGroup,
+		})
+	case "rotation":
+		return c.rotation(ctx, args)
+	case "choose":
+		return c.choose(ctx, args)
+	case "click":
+		return c.click(ctx, args)
+	case "stats":
+		return c.stats(ctx, args)
+	case "export":
+		return c.exportCatalogue(ctx, args)
+	case "import":
+		return c.importCatalogue(ctx, args)
+	case "apikey":
+		return c.apiKey(ctx, args)
+	default:
+		return usagef("unknown command %q", command)
+	}
+}
+
+// entityOps are API calls for banners, slots or groups. Create and get
+// return response along with identifier and description to print.
+type entityOps struct {
+	create func(ctx context.Context, description string) (interface{}, uuid.UUID, string, error)
+	get    func(ctx context.Context, id uuid.UUID) (interface{}, uuid.UUID, string, error)
+	list   func(ctx context.Context) (interface{}, [][]string, error)
+	remove func(ctx context.Context, id uuid.UUID) error
+}
+
+var entityHeader = []string{"ID", "DESCRIPTION"}
+
+func (c *controller) entity(ctx context.Context, name string, args []string, ops entityOps) error {
+	if len(args) == 0 {
+		return usagef("%s action is required", name)
+	}
+
+	action, args := args[0], args[1:]
+	switch action {
+	case "create":
+		if len(args) != 1 {
+			return usagef("%s create expects description", name)
+		}
+		value, id, description, err := ops.create(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		return c.out.print(value, entityHeader, [][]string{{id.String(), description}})
+	case "get":
+		id, err := parseIDs(name+" get", args, name+"_id")
+		if err != nil {
+			return err
+		}
+		value, gotID, description, err := ops.get(ctx, id[0])
+		if err != nil {
+			return err
+		}
+		return c.out.print(value, entityHeader, [][]string{{gotID.String(), description}})
+	case "list":
+		if len(args) != 0 {
+			return usagef("%s list expects no arguments", name)
+		}
+		value, rows, err := ops.list(ctx)
+		if err != nil {
+			return err
+		}
+		return c.out.print(value, entityHeader, rows)
+	case "delete":
+		id, err := parseIDs(name+" delete", args, name+"_id")
+		if err != nil {
+			return err
+		}
+		err = ops.remove(ctx, id[0])
+		if err != nil {
+			return err
+		}
+		return c.out.done(fmt.Sprintf("%s %s deleted", name, id[0]))
+	default:
+		return usagef("unknown %s action %q", name, action)
+	}
+}
+
+var rotationHeader = []string{"BANNER", "SLOT", "GROUP", "STATE", "SHOWS", "CLICKS", "CTR"}
+
+func rotationRow(rotation types.Rotation) []string {
+	var ctr float64
+	if rotation.Shows > 0 {
+		ctr = float64(rotation.Clicks) / float64(rotation.Shows)
+	}
+	return []string{
+		rotation.BannerID.String(),
+		rotation.SlotID.String(),
+		rotation.GroupID.String(),
+		rotation.State,
+		strconv.Itoa(rotation.Shows),
+		strconv.Itoa(rotation.Clicks),
+		fmt.Sprintf("%.4f", ctr),
+	}
+}
+
+func (c *controller) rotation(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return usagef("rotation action is required")
+	}
+
+	action, args := args[0], args[1:]
+	if action == "list" {
+		if len(args) != 0 {
+			return usagef("rotation list expects no arguments")
+		}
+		rotations, err := c.client.ListRotations(ctx)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(rotations))
+		for _, rotation := range rotations {
+			rows = append(rows, rotationRow(rotation))
+		}
+		return c.out.print(rotations, rotationHeader, rows)
+	}
+
+	ids, err := parseIDs("rotation "+action, args, "banner_id", "slot_id", "group_id")
+	if err != nil {
+		return err
+	}
+	bannerID, slotID, groupID := ids[0], ids[1], ids[2]
+
+	var rotation types.Rotation
+	switch action {
+	case "create":
+		rotation, err = c.client.AddRotation(ctx, bannerID, slotID, groupID)
+	case "get":
+		rotation, err = c.client.GetRotation(ctx, bannerID, slotID, groupID)
+	case "delete":
+		err = c.client.DeleteRotation(ctx, bannerID, slotID, groupID)
+		if err != nil {
+			return err
+		}
+		return c.out.done(fmt.Sprintf("rotation of banner %s in slot %s for group %s deleted", bannerID, slotID, groupID))
+	default:
+		return usagef("unknown rotation action %q", action)
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.print(rotation, rotationHeader, [][]string{rotationRow(rotation)})
+}
+
+func (c *controller) choose(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("choose", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	visitorID := flags.String("visitor", "", "Visitor identifier.")
+	err := flags.Parse(args)
+	if err != nil {
+		return usagef("choose: %v", err)
+	}
+
+	ids, err := parseIDs("choose", flags.Args(), "slot_id", "group_id")
+	if err != nil {
+		return err
+	}
+
+	impression, err := c.client.ChooseBanner(ctx, ids[0], ids[1], types.Visitor{ID: *visitorID})
+	if err != nil {
+		return err
+	}
+
+	return c.out.print(
+		impression,
+		[]string{"IMPRESSION", "BANNER", "SLOT", "GROUP", "ARM", "PROPENSITY"},
+		[][]string{{
+			impression.ImpressionID.String(),
+			impression.BannerID.String(),
+			impression.SlotID.String(),
+			impression.GroupID.String(),
+			impression.Arm,
+			strconv.FormatFloat(impression.Propensity, 'f', -1, 64),
+		}},
+	)
+}
+
+func (c *controller) click(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("click", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	visitorID := flags.String("visitor", "", "Visitor identifier.")
+	rawImpressionID := flags.String("impression", "", "Impression identifier returned by choose.")
+	err := flags.Parse(args)
+	if err != nil {
+		return usagef("click: %v", err)
+	}
+
+	ids, err := parseIDs("click", flags.Args(), "banner_id", "slot_id", "group_id")
+	if err != nil {
+		return err
+	}
+
+	var impressionID uuid.UUID
+	if *rawImpressionID != "" {
+		impressionID, err = uuid.Parse(*rawImpressionID)
+		if err != nil {
+			return usagef("click: invalid impression id: %v", err)
+		}
+	}
+
+	err = c.client.RegisterClick(ctx, types.Click{
+		ImpressionID: impressionID,
+		BannerID:     ids[0],
+		SlotID:       ids[1],
+		GroupID:      ids[2],
+		Visitor:      types.Visitor{ID: *visitorID},
+	})
+	if err != nil {
+		return err
+	}
+	return c.out.done("click registered")
+}
+
+func (c *controller) stats(ctx context.Context, args []string) error {
+	ids, err := parseIDs("stats", args, "banner_id", "slot_id", "group_id")
+	if err != nil {
+		return err
+	}
+
+	events, err := c.client.GetStats(ctx, ids[0], ids[1], ids[2])
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(events))
+	for _, event := range events {
+		impressionID := ""
+		if event.ImpressionID != uuid.Nil {
+			impressionID = event.ImpressionID.String()
+		}
+		rows = append(rows, []string{
+			event.Timestamp.Format(time.RFC3339),
+			event.Type,
+			impressionID,
+			strconv.FormatFloat(event.Value, 'f', -1, 64),
+		})
+	}
+	return c.out.print(events, []string{"TIME", "TYPE", "IMPRESSION", "VALUE"}, rows)
+}
+
+// catalogueFormat returns format given by flag or guessed
+// from file extension. JSON is used by default.
+func catalogueFormat(command, format, file string) (string, error) {
+	if format == "" {
+		format = catalogue.FormatJSON
+		if strings.EqualFold(filepath.Ext(file), ".csv") {
+			format = catalogue.FormatCSV
+		}
+	}
+	if format != catalogue.FormatJSON && format != catalogue.FormatCSV {
+		return "", usagef("%s: unknown format %q", command, format)
+	}
+	return format, nil
+}
+
+func (c *controller) exportCatalogue(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	format := flags.String("format", "", "Catalogue format: json or csv. Guessed from file extension by default.")
+	file := flags.String("file", "", "File to write catalogue to. Standard output by default.")
+	err := flags.Parse(args)
+	if err != nil {
+		return usagef("export: %v", err)
+	}
+	if flags.NArg() != 0 {
+		return usagef("export expects no arguments")
+	}
+
+	*format, err = catalogueFormat("export", *format, *file)
+	if err != nil {
+		return err
+	}
+
+	data, err := c.client.ExportCatalogue(ctx, *format)
+	if err != nil {
+		return err
+	}
+
+	if *file == "" {
+		_, err = c.out.w.Write(data)
+		return err
+	}
+
+	err = ioutil.WriteFile(*file, data, 0o600)
+	if err != nil {
+		return err
+	}
+	return c.out.done("catalogue exported to " + *file)
+}
+
+var importHeader = []string{"DRY RUN", "BANNERS", "SLOTS", "GROUPS", "RULES", "ROTATIONS"}
+
+func (c *controller) importCatalogue(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	format := flags.String("format", "", "Catalogue format: json or csv. Guessed from file extension by default.")
+	dryRun := flags.Bool("dry-run", false, "Check catalogue without importing it.")
+	err := flags.Parse(args)
+	if err != nil {
+		return usagef("import: %v", err)
+	}
+	if flags.NArg() != 1 {
+		return usagef("import expects file name or - for standard input")
+	}
+
+	file := flags.Arg(0)
+	*format, err = catalogueFormat("import", *format, file)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if file == "-" {
+		data, err = ioutil.ReadAll(c.in)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+
+	report, err := c.client.ImportCatalogue(ctx, *format, data, *dryRun)
+	if len(report.Conflicts) > 0 {
+		rows := make([][]string, 0, len(report.Conflicts))
+		for _, conflict := range report.Conflicts {
+			rows = append(rows, []string{conflict.Kind, conflict.ID, conflict.Reason})
+		}
+		printErr := c.out.print(report, []string{"KIND", "ID", "REASON"}, rows)
+		if printErr != nil {
+			return printErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.out.print(report, importHeader, [][]string{{
+		strconv.FormatBool(report.DryRun),
+		strconv.Itoa(report.Banners),
+		strconv.Itoa(report.Slots),
+		strconv.Itoa(report.Groups),
+		strconv.Itoa(report.Rules),
+		strconv.Itoa(report.Rotations),
+	}})
+}
+
+// parseIDs parses exactly len(names) uuid arguments of command.
+func parseIDs(command string, args []string, names ...string) ([]uuid.UUID, error) {
+	if len(args) != len(names) {
+		return nil, usagef("%s expects %d arguments: %v", command, len(names), names)
+	}
+
+	ids := make([]uuid.UUID, 0, len(args))
+	for i, arg := range args {
+		id, err := uuid.Parse(arg)
+		if err != nil {
+			return nil, usagef("%s: invalid %s: %v", command, names[i], err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+var apiKeyHeader = []string{"ID", "NAME", "ROLE", "CREATED"}
+
+func apiKeyRow(key types.APIKey) []string {
+	return []string{key.ID.String(), key.Name, key.Role, key.CreatedAt.Format(time.RFC3339)}
+}
+
+func (c *controller) apiKey(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return usagef("apikey action is required")
+	}
+
+	action, args := args[0], args[1:]
+	switch action {
+	case "create":
+		if len(args) != 2 {
+			return usagef("apikey create expects name and role")
+		}
+		key, secret, err := c.client.CreateAPIKey(ctx, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		created := struct {
+			types.APIKey
+			Key string
+		}{APIKey: key, Key: secret}
+		return c.out.print(created, append(apiKeyHeader, "KEY"), [][]string{append(apiKeyRow(key), secret)})
+	case "list":
+		if len(args) != 0 {
+			return usagef("apikey list expects no arguments")
+		}
+		keys, err := c.client.ListAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(keys))
+		for _, key := range keys {
+			rows = append(rows, apiKeyRow(key))
+		}
+		return c.out.print(keys, apiKeyHeader, rows)
+	case "revoke":
+		ids, err := parseIDs("apikey revoke", args, "key_id")
+		if err != nil {
+			return err
+		}
+		err = c.client.RevokeAPIKey(ctx, ids[0])
+		if err != nil {
+			return err
+		}
+		return c.out.done(fmt.Sprintf("API key %s revoked", ids[0]))
+	default:
+		return usagef("unknown apikey action %q", action)
+	}
+}
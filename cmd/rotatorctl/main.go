@@ -0,0 +1,132 @@
+// Command rotatorctl manages banner rotation service through its HTTP API.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/client"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+const defaultAddr = "http://localhost:8080"
+
+// addrEnvVar overrides default server address.
+const addrEnvVar = "ROTATOR_ADDR"
+
+// apiKeyEnvVar holds API key to authenticate requests with.
+const apiKeyEnvVar = "ROTATOR_API_KEY"
+
+const usage = `Usage: rotatorctl [flags] <command> [arguments]
+
+Commands:
+  banner create <description>
+  banner get|delete <banner_id>
+  banner list
+  slot create <description>
+  slot get|delete <slot_id>
+  slot list
+  group create <description>
+  group get|delete <group_id>
+  group list
+  rotation create|get|delete <banner_id> <slot_id> <group_id>
+  rotation list
+  choose [-visitor id] <slot_id> <group_id>
+  click [-visitor id] [-impression id] <banner_id> <slot_id> <group_id>
+  stats <banner_id> <slot_id> <group_id>
+  export [-format json|csv] [-file path]
+  import [-format json|csv] [-dry-run] <file|->
+  apikey create <name> <serve|analyst|admin>
+  apikey list
+  apikey revoke <key_id>
+
+Flags:
+`
+
+// usageError is returned when command line is malformed.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...interface{}) error {
+	return usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("rotatorctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	addr := os.Getenv(addrEnvVar)
+	if addr == "" {
+		addr = defaultAddr
+	}
+
+	var (
+		output  string
+		timeout time.Duration
+		apiKey  string
+	)
+	flags.StringVar(&addr, "addr", addr, "Server address. Defaults to "+addrEnvVar+" env var.")
+	flags.StringVar(&apiKey, "key", os.Getenv(apiKeyEnvVar), "API key. Defaults to "+apiKeyEnvVar+" env var.")
+	flags.StringVar(&output, "output", formatTable, "Output format: table or json.")
+	flags.DurationVar(&timeout, "timeout", 10*time.Second, "Request timeout.")
+
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+
+	if output != formatTable && output != formatJSON {
+		fmt.Fprintf(stderr, "unknown output format %q\n", output)
+		return exitUsage
+	}
+
+	apiClient := client.New(addr, timeout)
+	apiClient.SetAPIKey(apiKey)
+
+	ctl := &controller{
+		client: apiClient,
+		out:    printer{format: output, w: stdout},
+		in:     os.Stdin,
+	}
+
+	err = ctl.dispatch(context.Background(), flags.Args())
+	var usageErr usageError
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, &usageErr):
+		fmt.Fprintln(stderr, err)
+		flags.Usage()
+		return exitUsage
+	case err != nil:
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// printer writes API responses either as JSON or as aligned table.
+type printer struct {
+	format string
+	w      io.Writer
+}
+
+// print writes value as JSON or header and rows as table.
+func (p printer) print(value interface{}, header []string, rows [][]string) error {
+	if p.format == formatJSON {
+		encoder := json.NewEncoder(p.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// done reports success of command without response body.
+func (p printer) done(msg string) error {
+	if p.format == formatJSON {
+		return p.print(map[string]string{"Result": msg}, nil, nil)
+	}
+	_, err := fmt.Fprintln(p.w, msg)
+	return err
+}
@@ -5,17 +5,21 @@ go 1.16
 require (
 	github.com/BurntSushi/toml v0.3.1
 	github.com/cockroachdb/apd v1.1.0 // indirect
-	github.com/go-sql-driver/mysql v1.6.0 // indirect
-	github.com/google/uuid v1.2.0
+	github.com/gofrs/uuid v4.4.0+incompatible // indirect
+	github.com/google/uuid v1.3.0
 	github.com/jackc/fake v0.0.0-20150926172116-812a484cc733 // indirect
 	github.com/jackc/pgx v3.6.2+incompatible
 	github.com/jmoiron/sqlx v1.3.3
 	github.com/julienschmidt/httprouter v1.3.0
-	github.com/lib/pq v1.10.0 // indirect
-	github.com/mattn/go-sqlite3 v1.14.7 // indirect
 	github.com/pkg/errors v0.9.1
+	github.com/pressly/goose/v3 v3.5.3
+	github.com/prometheus/client_golang v1.11.0
 	github.com/rs/zerolog v1.21.0
 	github.com/shopspring/decimal v1.2.0 // indirect
 	github.com/stretchr/testify v1.7.0
-	golang.org/x/crypto v0.0.0-20210415154028-4f45737414dc // indirect
+	go.opentelemetry.io/otel v1.4.1
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.4.1
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.4.1
+	go.opentelemetry.io/otel/sdk v1.4.1
+	go.opentelemetry.io/otel/trace v1.4.1
 )
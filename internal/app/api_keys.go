@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/auth"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+// CreateAPIKey generates API key with role. Secret is returned only
+// here as storage keeps hash of it.
+func (a *App) CreateAPIKey(ctx context.Context, name, role string) (types.APIKey, string, error) {
+	err := auth.CheckRole(role)
+	if err != nil {
+		return types.APIKey{}, "", err
+	}
+
+	keyID, err := uuid.NewRandom()
+	if err != nil {
+		a.Log.Error(
+			"failed to create random uuid for API key",
+			types.LogFields{"error": err},
+		)
+		return types.APIKey{}, "", err
+	}
+
+	secret, err := auth.NewKey()
+	if err != nil {
+		a.Log.Error(
+			"failed to generate API key",
+			types.LogFields{"error": err},
+		)
+		return types.APIKey{}, "", err
+	}
+
+	key := types.APIKey{
+		ID:        keyID,
+		Name:      name,
+		Role:      role,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	err = a.Storage.AddAPIKey(ctx, key, auth.Hash(secret))
+	if err != nil {
+		a.Log.Error(
+			"failed to add API key",
+			types.LogFields{"error": err},
+		)
+		return types.APIKey{}, "", err
+	}
+
+	a.Log.Info(
+		"API key created",
+		types.LogFields{
+			"key_id": keyID.String(),
+			"name":   name,
+			"role":   role,
+		},
+	)
+
+	return key, secret, nil
+}
+
+func (a *App) ListAPIKeys(ctx context.Context) ([]types.APIKey, error) {
+	keys, err := a.Storage.GetAllAPIKeys(ctx)
+	if err != nil {
+		a.Log.Error(
+			"failed to list API keys",
+			types.LogFields{"error": err},
+		)
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (a *App) RevokeAPIKey(ctx context.Context, keyID uuid.UUID) error {
+	err := a.Storage.DeleteAPIKey(ctx, keyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = types.ErrNotFound
+	}
+	if err != nil {
+		a.Log.Error(
+			"failed to revoke API key",
+			types.LogFields{
+				"error":  err,
+				"key_id": keyID.String(),
+			},
+		)
+		return err
+	}
+
+	a.Log.Info(
+		"API key revoked",
+		types.LogFields{"key_id": keyID.String()},
+	)
+	return nil
+}
+
+// Authenticate finds API key by its secret.
+func (a *App) Authenticate(ctx context.Context, secret string) (types.APIKey, error) {
+	if secret == "" {
+		return types.APIKey{}, types.ErrInvalidAPIKey
+	}
+
+	key, err := a.Storage.GetAPIKeyByHash(ctx, auth.Hash(secret))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return types.APIKey{}, types.ErrInvalidAPIKey
+	case err != nil:
+		a.Log.Error(
+			"failed to get API key",
+			types.LogFields{"error": err},
+		)
+		return types.APIKey{}, err
+	}
+
+	return key, nil
+}
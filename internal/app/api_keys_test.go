@@ -0,0 +1,86 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/logger"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// keyStorage keeps API keys in memory by hash.
+type keyStorage struct {
+	types.Storager
+	keys map[string]types.APIKey
+}
+
+func (ks *keyStorage) AddAPIKey(_ context.Context, key types.APIKey, keyHash string) error {
+	ks.keys[keyHash] = key
+	return nil
+}
+
+func (ks *keyStorage) GetAPIKeyByHash(_ context.Context, keyHash string) (types.APIKey, error) {
+	key, ok := ks.keys[keyHash]
+	if !ok {
+		return types.APIKey{}, sql.ErrNoRows
+	}
+	return key, nil
+}
+
+func (ks *keyStorage) DeleteAPIKey(_ context.Context, keyID uuid.UUID) error {
+	for keyHash, key := range ks.keys {
+		if key.ID == keyID {
+			delete(ks.keys, keyHash)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func TestAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	store := &keyStorage{keys: make(map[string]types.APIKey)}
+	application := &App{Storage: store, Log: logger.New("error", os.DevNull)}
+
+	key, secret, err := application.CreateAPIKey(ctx, "frontend", types.RoleServe)
+	require.NoError(t, err)
+	require.Equal(t, "frontend", key.Name)
+	require.Equal(t, types.RoleServe, key.Role)
+
+	t.Run("check secret is not stored", func(t *testing.T) {
+		_, ok := store.keys[secret]
+		require.False(t, ok)
+	})
+
+	t.Run("check authentication", func(t *testing.T) {
+		authenticated, err := application.Authenticate(ctx, secret)
+		require.NoError(t, err)
+		require.Equal(t, key, authenticated)
+
+		_, err = application.Authenticate(ctx, secret+"x")
+		require.ErrorIs(t, err, types.ErrInvalidAPIKey)
+
+		_, err = application.Authenticate(ctx, "")
+		require.ErrorIs(t, err, types.ErrInvalidAPIKey)
+	})
+
+	t.Run("check unknown role", func(t *testing.T) {
+		_, _, err := application.CreateAPIKey(ctx, "root", "root")
+		require.ErrorIs(t, err, types.ErrUnknownRole)
+	})
+
+	t.Run("check revoked key", func(t *testing.T) {
+		err := application.RevokeAPIKey(ctx, key.ID)
+		require.NoError(t, err)
+
+		_, err = application.Authenticate(ctx, secret)
+		require.ErrorIs(t, err, types.ErrInvalidAPIKey)
+
+		err = application.RevokeAPIKey(ctx, key.ID)
+		require.ErrorIs(t, err, types.ErrNotFound)
+	})
+}
@@ -2,13 +2,29 @@ package app
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"time"
 
 	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/evaluation"
+	"github.com/FedoseevAlex/banner-rotation/internal/features"
 	"github.com/FedoseevAlex/banner-rotation/internal/logger"
-	"github.com/FedoseevAlex/banner-rotation/internal/rotators/mab"
+	"github.com/FedoseevAlex/banner-rotation/internal/metrics"
+	"github.com/FedoseevAlex/banner-rotation/internal/rotators"
+	"github.com/FedoseevAlex/banner-rotation/internal/rules"
 	"github.com/FedoseevAlex/banner-rotation/internal/storage"
+	"github.com/FedoseevAlex/banner-rotation/internal/storage/memory"
+	"github.com/FedoseevAlex/banner-rotation/internal/tracing"
 	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/FedoseevAlex/banner-rotation/internal/uplift"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func New(config config.Config) (*App, error) {
@@ -26,18 +42,147 @@ func New(config config.Config) (*App, error) {
 		return nil, err
 	}
 
-	rotator := &mab.MultiArmedBandit{}
+	if config.Storage.AutoMigrate {
+		// Goose reports every applied migration, result is logged below
+		err = store.MigrateUp(context.Background(), ioutil.Discard)
+		if err != nil {
+			log.Error(
+				"failed to apply migrations",
+				types.LogFields{
+					"error": err,
+				},
+			)
+			return nil, err
+		}
+		log.Info(
+			"migrations applied",
+			types.LogFields{},
+		)
+	}
+
+	err = metrics.RegisterDBStats(store.Stats)
+	if err != nil {
+		log.Error(
+			"failed to register connection pool metrics",
+			types.LogFields{
+				"error": err,
+			},
+		)
+		return nil, err
+	}
+
+	var exposures types.ExposureStorer
+	switch config.Exposures.Backend {
+	case "", "memory":
+		exposures = memory.NewExposureStore()
+	case "postgres":
+		exposures = store
+	default:
+		err = fmt.Errorf("unknown exposures backend %q", config.Exposures.Backend)
+		log.Error(
+			"failed to create exposures storage",
+			types.LogFields{
+				"error": err,
+			},
+		)
+		return nil, err
+	}
+
+	var rateLimiter types.RateLimiter
+	switch config.Server.RateLimit.Backend {
+	case "", "memory":
+		rateLimiter = memory.NewRateLimiter()
+	case "postgres":
+		rateLimiter = store
+	default:
+		err = fmt.Errorf("unknown rate limit backend %q", config.Server.RateLimit.Backend)
+		log.Error(
+			"failed to create rate limiter",
+			types.LogFields{
+				"error": err,
+			},
+		)
+		return nil, err
+	}
+
+	var idempotency types.IdempotencyStorer
+	switch config.Server.Idempotency.Backend {
+	case "", "memory":
+		idempotency = memory.NewIdempotencyStore()
+	case "postgres":
+		idempotency = store
+	default:
+		err = fmt.Errorf("unknown idempotency backend %q", config.Server.Idempotency.Backend)
+		log.Error(
+			"failed to create idempotency storage",
+			types.LogFields{
+				"error": err,
+			},
+		)
+		return nil, err
+	}
+
+	clickValidation, err := NewClickValidation(config.Clicks)
+	if err != nil {
+		log.Error(
+			"failed to parse click validation config",
+			types.LogFields{
+				"error": err,
+			},
+		)
+		return nil, err
+	}
+
+	rotator, err := rotators.New(config.Rotator)
+	if err != nil {
+		log.Error(
+			"failed to create rotator",
+			types.LogFields{
+				"error": err,
+			},
+		)
+		return nil, err
+	}
+
 	log.Debug(
 		"Application created successfully",
 		types.LogFields{},
 	)
-	return &App{Rotator: rotator, Storage: store, Log: log}, nil
+	return &App{
+		Rotator:         rotator,
+		Storage:         metrics.InstrumentStorage(store),
+		Exposures:       exposures,
+		RateLimiter:     rateLimiter,
+		Idempotency:     idempotency,
+		ClickValidation: clickValidation,
+		Log:             log,
+		random:          rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec
+	}, nil
 }
 
 type App struct {
-	Rotator types.Rotator
-	Storage types.Storager
-	Log     types.Logger
+	Rotator     types.Rotator
+	Storage     types.Storager
+	Exposures   types.ExposureStorer
+	RateLimiter types.RateLimiter
+	Idempotency types.IdempotencyStorer
+	// ClickValidation lists checks clicks must pass to be registered
+	ClickValidation types.ClickValidation
+	Log             types.Logger
+
+	// rotatorMu guards Rotator as it keeps state between Load and Rotate calls
+	// and random used to choose holdout shows. It is never held during
+	// storage queries so that choices for different slots do not wait for each other.
+	rotatorMu sync.Mutex
+	random    *rand.Rand
+	// consumptions caches budget consumption of campaigns as it is checked on every choice
+	consumptions consumptionCache
+}
+
+// Close closes storage. Shows, clicks and rotator states are written
+// to storage before requests complete, so nothing is left to flush.
+func (a *App) Close() error {
+	return a.Storage.Close()
 }
 
 func (a *App) AddBanner(ctx context.Context, description string) (types.Banner, error) {
@@ -156,6 +301,24 @@ func (a *App) GetSlot(ctx context.Context, slotID uuid.UUID) (types.Slot, error)
 	return slot, nil
 }
 
+func (a *App) SetSlotSettings(ctx context.Context, slotID uuid.UUID, settings types.SlotSettings) error {
+	err := a.Storage.SetSlotSettings(ctx, slotID, settings)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = types.ErrNotFound
+	}
+	if err != nil {
+		a.Log.Error(
+			"failed to set slot settings",
+			types.LogFields{
+				"error":   err,
+				"slot_id": slotID.String(),
+			},
+		)
+		return err
+	}
+	return nil
+}
+
 func (a *App) AddGroup(ctx context.Context, description string) (types.Group, error) {
 	groupID, err := uuid.NewRandom()
 	if err != nil {
@@ -214,6 +377,102 @@ func (a *App) GetGroup(ctx context.Context, groupID uuid.UUID) (types.Group, err
 	return group, nil
 }
 
+func (a *App) AddGroupRule(ctx context.Context, rule types.GroupRule) (types.GroupRule, error) {
+	rule, err := a.Storage.AddGroupRule(ctx, rule)
+	if err != nil {
+		a.Log.Error(
+			"failed to add group rule",
+			types.LogFields{
+				"error":    err,
+				"group_id": rule.GroupID.String(),
+			},
+		)
+		return types.GroupRule{}, err
+	}
+
+	a.Log.Trace(
+		"add group rule",
+		types.LogFields{
+			"rule_id":  rule.ID,
+			"group_id": rule.GroupID.String(),
+		},
+	)
+
+	return rule, nil
+}
+
+func (a *App) GetGroupRules(ctx context.Context, groupID uuid.UUID) ([]types.GroupRule, error) {
+	groupRules, err := a.Storage.GetGroupRules(ctx, groupID)
+	if err != nil {
+		a.Log.Error(
+			"failed to get group rules from database",
+			types.LogFields{
+				"error":    err,
+				"group_id": groupID.String(),
+			},
+		)
+		return nil, err
+	}
+	return groupRules, nil
+}
+
+func (a *App) DeleteGroupRule(ctx context.Context, groupID uuid.UUID, ruleID int) error {
+	err := a.Storage.DeleteGroupRule(ctx, groupID, ruleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = types.ErrNotFound
+	}
+	if err != nil {
+		a.Log.Error(
+			"failed to delete group rule",
+			types.LogFields{
+				"error":    err,
+				"group_id": groupID.String(),
+				"rule_id":  ruleID,
+			},
+		)
+		return err
+	}
+	return nil
+}
+
+// ResolveGroup finds group for visitor by rules of all groups.
+func (a *App) ResolveGroup(ctx context.Context, visitor types.Visitor) (types.Group, error) {
+	ctx, span := tracing.Start(ctx, "App.ResolveGroup")
+	defer span.End()
+
+	groupRules, err := a.Storage.GetAllGroupRules(ctx)
+	if err != nil {
+		a.Log.Error(
+			"failed to get group rules from database",
+			types.LogFields{"error": err},
+		)
+		return types.Group{}, err
+	}
+
+	rule, ok := rules.Resolve(groupRules, visitor.Attributes)
+	if !ok {
+		a.Log.Debug(
+			"no group matches visitor",
+			types.LogFields{
+				"visitor_id": visitor.ID,
+				"attributes": visitor.Attributes,
+			},
+		)
+		return types.Group{}, types.ErrNoGroupMatched
+	}
+
+	a.Log.Debug(
+		"group resolved",
+		types.LogFields{
+			"visitor_id": visitor.ID,
+			"rule_id":    rule.ID,
+			"group_id":   rule.GroupID.String(),
+		},
+	)
+
+	return a.GetGroup(ctx, rule.GroupID)
+}
+
 func (a *App) AddRotation(ctx context.Context, bannerID, slotID, groupID uuid.UUID) (types.Rotation, error) {
 	rotation, err := a.Storage.AddRotation(ctx, bannerID, slotID, groupID)
 	if err != nil {
@@ -265,29 +524,186 @@ func (a *App) DeleteRotation(ctx context.Context, bannerID, slotID, groupID uuid
 	return nil
 }
 
-func (a *App) RegisterClick(ctx context.Context, bannerID, slotID, groupID uuid.UUID) error {
-	err := a.Storage.AddClick(ctx, bannerID, slotID, groupID)
+func (a *App) SetFrequencyCap(
+	ctx context.Context,
+	bannerID, slotID, groupID uuid.UUID,
+	frequencyCap types.FrequencyCap,
+) error {
+	err := a.Storage.SetFrequencyCap(ctx, bannerID, slotID, groupID, frequencyCap)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = types.ErrNotFound
+	}
 	if err != nil {
 		a.Log.Error(
-			"failed to register click for rotation",
+			"failed to set frequency cap for rotation",
+			types.LogFields{
+				"error":     err,
+				"banner_id": bannerID.String(),
+				"slot_id":   slotID.String(),
+				"group_id":  groupID.String(),
+			},
+		)
+		return err
+	}
+	return nil
+}
+
+func (a *App) SetRotationState(ctx context.Context, bannerID, slotID, groupID uuid.UUID, state string) error {
+	err := a.Storage.SetRotationState(ctx, bannerID, slotID, groupID, state)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = types.ErrNotFound
+	}
+	if err != nil {
+		a.Log.Error(
+			"failed to set rotation state",
 			types.LogFields{
 				"error":     err,
 				"banner_id": bannerID.String(),
 				"slot_id":   slotID.String(),
 				"group_id":  groupID.String(),
+				"state":     state,
+			},
+		)
+		return err
+	}
+	return nil
+}
+
+func (a *App) SetRotationOverride(
+	ctx context.Context,
+	bannerID, slotID, groupID uuid.UUID,
+	override types.RotationOverride,
+) error {
+	err := a.Storage.SetRotationOverride(ctx, bannerID, slotID, groupID, override)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = types.ErrNotFound
+	}
+	if err != nil {
+		a.Log.Error(
+			"failed to set rotation override",
+			types.LogFields{
+				"error":     err,
+				"banner_id": bannerID.String(),
+				"slot_id":   slotID.String(),
+				"group_id":  groupID.String(),
+			},
+		)
+		return err
+	}
+	return nil
+}
+
+func (a *App) RegisterClick(ctx context.Context, click types.Click) error {
+	ctx, span := tracing.Start(
+		ctx,
+		"App.RegisterClick",
+		attribute.String("slot_id", click.SlotID.String()),
+		attribute.String("group_id", click.GroupID.String()),
+	)
+	defer span.End()
+
+	reason, err := a.validateClick(ctx, click)
+	if err != nil {
+		a.Log.Error(
+			"failed to validate click",
+			types.LogFields{
+				"error":         err,
+				"impression_id": click.ImpressionID.String(),
+				"banner_id":     click.BannerID.String(),
+				"slot_id":       click.SlotID.String(),
+				"group_id":      click.GroupID.String(),
+			},
+		)
+		return err
+	}
+	if reason != "" {
+		return a.rejectClick(ctx, click, reason)
+	}
+
+	err = a.Storage.AddClick(ctx, click, a.ClickValidation.DuplicateWindow)
+	if errors.Is(err, types.ErrDuplicateClick) {
+		return a.rejectClick(ctx, click, types.RejectReasonDuplicate)
+	}
+	if err != nil {
+		a.Log.Error(
+			"failed to register click for rotation",
+			types.LogFields{
+				"error":         err,
+				"impression_id": click.ImpressionID.String(),
+				"banner_id":     click.BannerID.String(),
+				"slot_id":       click.SlotID.String(),
+				"group_id":      click.GroupID.String(),
+			},
+		)
+		return err
+	}
+	metrics.Clicks.WithLabelValues(click.SlotID.String(), click.GroupID.String()).Inc()
+
+	contextual, ok := a.Rotator.(types.ContextualRotator)
+	if !ok {
+		return nil
+	}
+
+	// Visitor features are not stored along with shows, so they are taken
+	// from the click request. It is expected to carry the same attributes as the show request.
+	rotation := types.Rotation{BannerID: click.BannerID, SlotID: click.SlotID, GroupID: click.GroupID}
+	x := features.Extract(click.Visitor, time.Now())
+
+	return a.updateRotatorState(ctx, contextual, click.SlotID, click.GroupID, func() {
+		contextual.Reward(rotation, x, 1)
+	})
+}
+
+func (a *App) RegisterConversion(ctx context.Context, conversion types.Conversion) error {
+	ctx, span := tracing.Start(ctx, "App.RegisterConversion")
+	defer span.End()
+
+	err := a.Storage.AddConversion(ctx, conversion)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = types.ErrNoImpression
+	}
+	if err != nil {
+		a.Log.Error(
+			"failed to register conversion",
+			types.LogFields{
+				"error":         err,
+				"impression_id": conversion.ImpressionID.String(),
+				"value":         conversion.Value,
 			},
 		)
 		return err
 	}
+
+	a.Log.Trace(
+		"conversion registered",
+		types.LogFields{
+			"impression_id": conversion.ImpressionID.String(),
+			"value":         conversion.Value,
+		},
+	)
+
 	return nil
 }
 
-func (a *App) ChooseBanner(ctx context.Context, slotID, groupID uuid.UUID) (types.Rotation, error) {
+func (a *App) ChooseBanner(
+	ctx context.Context,
+	slotID, groupID uuid.UUID,
+	visitor types.Visitor,
+) (types.Impression, error) {
+	ctx, span := tracing.Start(
+		ctx,
+		"App.ChooseBanner",
+		attribute.String("slot_id", slotID.String()),
+		attribute.String("group_id", groupID.String()),
+	)
+	defer span.End()
+
 	a.Log.Debug(
 		"choose banner",
 		types.LogFields{
-			"slot_id":  slotID.String(),
-			"group_id": groupID.String(),
+			"slot_id":    slotID.String(),
+			"group_id":   groupID.String(),
+			"visitor_id": visitor.ID,
 		},
 	)
 
@@ -299,7 +715,7 @@ func (a *App) ChooseBanner(ctx context.Context, slotID, groupID uuid.UUID) (type
 				"error": err,
 			},
 		)
-		return types.Rotation{}, err
+		return types.Impression{}, err
 	}
 
 	rotations, err := a.Storage.GetAllRotations(ctx)
@@ -310,19 +726,56 @@ func (a *App) ChooseBanner(ctx context.Context, slotID, groupID uuid.UUID) (type
 				"error": err,
 			},
 		)
-		return types.Rotation{}, err
+		return types.Impression{}, err
+	}
+
+	rotations = filterRotations(rotations, slotID, groupID)
+	rotations, err = a.skipOverBudgetRotations(ctx, rotations)
+	if err != nil {
+		return types.Impression{}, err
+	}
+
+	var picked choice
+	for {
+		picked, err = a.pickRotation(ctx, rotations, trials, slotID, groupID, visitor)
+		if err != nil {
+			return types.Impression{}, err
+		}
+
+		var reserved bool
+		reserved, err = a.addExposure(ctx, picked.rotation, visitor)
+		if err != nil {
+			return types.Impression{}, err
+		}
+		if reserved {
+			break
+		}
+		// Concurrent request took the last show allowed by frequency cap,
+		// so the choice is made again without the rotation
+		rotations = withoutRotation(rotations, picked.rotation)
+	}
+	rotationToShow := picked.rotation
+
+	impressionID, err := uuid.NewRandom()
+	if err != nil {
+		a.Log.Error(
+			"failed to create random uuid for impression",
+			types.LogFields{"error": err},
+		)
+		a.removeExposure(ctx, rotationToShow, visitor)
+		return types.Impression{}, err
 	}
 
-	a.Rotator.Load(rotations, trials)
-	rotationToShow := a.Rotator.Rotate()
+	impression := types.Impression{
+		ImpressionID: impressionID,
+		Rotation:     rotationToShow,
+		VisitorID:    visitor.ID,
+		Propensity:   picked.propensity,
+		Arm:          picked.arm,
+	}
 
 	// Register show for rotation
-	err = a.Storage.AddShow(
-		ctx,
-		rotationToShow.BannerID,
-		rotationToShow.SlotID,
-		rotationToShow.GroupID,
-	)
+	err = a.Storage.AddShow(ctx, impression)
 	if err != nil {
 		a.Log.Error(
 			"failed to register show for rotation",
@@ -333,21 +786,480 @@ func (a *App) ChooseBanner(ctx context.Context, slotID, groupID uuid.UUID) (type
 				"group_id":  rotationToShow.GroupID,
 			},
 		)
-		return types.Rotation{}, err
+		a.removeExposure(ctx, rotationToShow, visitor)
+		return types.Impression{}, err
 	}
+	metrics.Shows.WithLabelValues(rotationToShow.SlotID.String(), rotationToShow.GroupID.String()).Inc()
 
 	a.Log.Debug(
 		"rotation to show has been chosen",
 		types.LogFields{
-			"banner_id": rotationToShow.BannerID,
-			"slot_id":   rotationToShow.SlotID,
-			"group_id":  rotationToShow.GroupID,
-			"shows":     rotationToShow.Shows,
-			"clicks":    rotationToShow.Clicks,
+			"impression_id": impressionID.String(),
+			"banner_id":     rotationToShow.BannerID,
+			"slot_id":       rotationToShow.SlotID,
+			"group_id":      rotationToShow.GroupID,
+			"shows":         rotationToShow.Shows,
+			"clicks":        rotationToShow.Clicks,
 		},
 	)
 
-	return rotationToShow, nil
+	return impression, nil
+}
+
+func (a *App) ChooseBannerForVisitor(
+	ctx context.Context,
+	slotID uuid.UUID,
+	visitor types.Visitor,
+) (types.Impression, error) {
+	ctx, span := tracing.Start(ctx, "App.ChooseBannerForVisitor", attribute.String("slot_id", slotID.String()))
+	defer span.End()
+
+	group, err := a.ResolveGroup(ctx, visitor)
+	if err != nil {
+		return types.Impression{}, err
+	}
+
+	return a.ChooseBanner(ctx, slotID, group.ID, visitor)
+}
+
+// addExposure remembers show of frequency capped rotation to visitor before
+// the show is registered. It returns false if visitor has reached the cap meanwhile.
+func (a *App) addExposure(ctx context.Context, rotation types.Rotation, visitor types.Visitor) (bool, error) {
+	if visitor.ID == "" || rotation.Cap.MaxShows <= 0 {
+		return true, nil
+	}
+
+	added, err := a.Exposures.AddExposure(
+		ctx,
+		visitor.ID,
+		rotation.BannerID,
+		rotation.SlotID,
+		rotation.GroupID,
+		rotation.Cap,
+	)
+	if err != nil {
+		a.Log.Error(
+			"failed to register exposure for visitor",
+			types.LogFields{
+				"error":      err,
+				"visitor_id": visitor.ID,
+				"banner_id":  rotation.BannerID,
+				"slot_id":    rotation.SlotID,
+				"group_id":   rotation.GroupID,
+			},
+		)
+	}
+	return added, err
+}
+
+// removeExposure releases exposure reserved by addExposure for show which
+// failed to be registered. Failure is only logged as the show failed anyway.
+func (a *App) removeExposure(ctx context.Context, rotation types.Rotation, visitor types.Visitor) {
+	if visitor.ID == "" || rotation.Cap.MaxShows <= 0 {
+		return
+	}
+
+	err := a.Exposures.RemoveExposure(ctx, visitor.ID, rotation.BannerID, rotation.SlotID, rotation.GroupID)
+	if err != nil {
+		a.Log.Error(
+			"failed to remove exposure of unregistered show",
+			types.LogFields{
+				"error":      err,
+				"visitor_id": visitor.ID,
+				"banner_id":  rotation.BannerID,
+				"slot_id":    rotation.SlotID,
+				"group_id":   rotation.GroupID,
+			},
+		)
+	}
+}
+
+// withoutRotation returns rotations except the given one.
+func withoutRotation(rotations []types.Rotation, excluded types.Rotation) []types.Rotation {
+	left := make([]types.Rotation, 0, len(rotations))
+	for _, rotation := range rotations {
+		if rotation.BannerID != excluded.BannerID || rotation.SlotID != excluded.SlotID ||
+			rotation.GroupID != excluded.GroupID {
+			left = append(left, rotation)
+		}
+	}
+	return left
+}
+
+// choice is rotation chosen to show along with probability
+// of the choice and holdout experiment arm it was made in.
+type choice struct {
+	rotation   types.Rotation
+	propensity float64
+	arm        string
+}
+
+// pickRotation chooses rotation to show from slot rotations.
+// Sticky assignment is preferred, then rotation overrides. Otherwise
+// the choice is left to the rotator or made uniformly at random for holdout shows.
+func (a *App) pickRotation(
+	ctx context.Context,
+	rotations []types.Rotation,
+	trials int64,
+	slotID, groupID uuid.UUID,
+	visitor types.Visitor,
+) (choice, error) {
+	logFields := types.LogFields{
+		"slot_id":    slotID.String(),
+		"group_id":   groupID.String(),
+		"visitor_id": visitor.ID,
+	}
+
+	if len(rotations) == 0 {
+		a.Log.Debug("no rotations to choose from", logFields)
+		return choice{}, types.ErrNoRotations
+	}
+
+	slot, err := a.Storage.GetSlot(ctx, slotID)
+	if err != nil {
+		logFields["error"] = err
+		a.Log.Error("failed to get slot from database", logFields)
+		return choice{}, err
+	}
+
+	sticky := slot.Settings.Sticky && visitor.ID != ""
+	var holdout bool
+	if sticky {
+		// Visitor keeps the same banner, so the whole visitor is either in holdout or not
+		holdout = visitorInHoldout(visitor.ID, slotID, slot.Settings.HoldoutPercent)
+
+		rotation, found, err := a.assignedRotation(ctx, rotations, slotID, groupID, visitor)
+		if err != nil {
+			return choice{}, err
+		}
+		if found {
+			picked := choice{rotation: rotation, propensity: 1, arm: armOf(holdout)}
+			return picked, a.observeShow(ctx, rotation, visitor)
+		}
+	}
+
+	allowed, err := a.skipCappedRotations(ctx, rotations, visitor)
+	if err != nil {
+		return choice{}, err
+	}
+
+	if len(allowed) == 0 {
+		a.Log.Debug("no rotations to choose from", logFields)
+		return choice{}, types.ErrNoRotations
+	}
+
+	var picked choice
+	if rotation, ok := overriddenRotation(rotations, allowed); ok {
+		picked = choice{rotation: rotation, propensity: 1, arm: types.ArmOverride}
+		err = a.observeShow(ctx, rotation, visitor)
+	} else {
+		picked, err = a.rotate(ctx, allowed, trials, visitor, slot.Settings.HoldoutPercent, sticky, holdout)
+	}
+	if err != nil {
+		return choice{}, err
+	}
+
+	if sticky {
+		err = a.Storage.SaveAssignment(
+			ctx,
+			visitor.ID,
+			picked.rotation.BannerID,
+			slotID,
+			groupID,
+			slot.Settings.StickyTTL,
+		)
+		if err != nil {
+			logFields["error"] = err
+			logFields["banner_id"] = picked.rotation.BannerID
+			a.Log.Error("failed to save sticky assignment", logFields)
+			return choice{}, err
+		}
+	}
+
+	return picked, nil
+}
+
+// rotate leaves the choice of rotation to the rotator.
+// Holdout shows are chosen uniformly at random instead. Unless holdout is
+// decided by caller, show goes to holdout with probability holdoutPercent.
+// Contextual rotator also learns that the rotation was shown to the visitor.
+func (a *App) rotate(
+	ctx context.Context,
+	rotations []types.Rotation,
+	trials int64,
+	visitor types.Visitor,
+	holdoutPercent float64,
+	holdoutDecided, holdout bool,
+) (choice, error) {
+	ctx, span := tracing.Start(ctx, "App.rotate", attribute.Int("rotations", len(rotations)))
+	defer span.End()
+
+	// pick makes the choice given the rotator's one. Caller must hold rotatorMu.
+	pick := func(optimal types.Rotation) choice {
+		if !holdoutDecided {
+			holdout = a.random.Float64()*100 < holdoutPercent
+		}
+
+		shown := optimal
+		if holdout {
+			shown = rotations[a.random.Intn(len(rotations))]
+		}
+		return choice{
+			rotation:   shown,
+			propensity: propensity(shown, optimal, len(rotations), holdoutPercent),
+			arm:        armOf(holdout),
+		}
+	}
+
+	contextual, ok := a.Rotator.(types.ContextualRotator)
+	if !ok {
+		a.rotatorMu.Lock()
+		defer a.rotatorMu.Unlock()
+
+		begin := time.Now()
+		a.Rotator.Load(rotations, trials)
+		optimal := a.Rotator.Rotate()
+		metrics.ObserveDecision(begin)
+		return pick(optimal), nil
+	}
+
+	var (
+		picked  choice
+		x       = features.Extract(visitor, time.Now())
+		slotID  = rotations[0].SlotID
+		groupID = rotations[0].GroupID
+	)
+
+	err := a.updateRotatorState(ctx, contextual, slotID, groupID, func() {
+		begin := time.Now()
+		contextual.Load(rotations, trials)
+		optimal := contextual.RotateContext(x)
+		metrics.ObserveDecision(begin)
+		picked = pick(optimal)
+		contextual.Observe(picked.rotation, x)
+	})
+
+	return picked, err
+}
+
+// propensity is probability of shown rotation to be chosen when
+// holdoutPercent of shows are chosen uniformly at random among n rotations
+// and the rest are given to the optimal rotation.
+func propensity(shown, optimal types.Rotation, n int, holdoutPercent float64) float64 {
+	holdoutShare := holdoutPercent / 100
+	p := holdoutShare / float64(n)
+	if shown.BannerID == optimal.BannerID {
+		p += 1 - holdoutShare
+	}
+	return p
+}
+
+func armOf(holdout bool) string {
+	if holdout {
+		return types.ArmHoldout
+	}
+	return types.ArmOptimised
+}
+
+// visitorInHoldout deterministically puts holdoutPercent of visitors of slot to holdout.
+func visitorInHoldout(visitorID string, slotID uuid.UUID, holdoutPercent float64) bool {
+	if holdoutPercent <= 0 {
+		return false
+	}
+
+	hash := fnv.New64a()
+	_, _ = hash.Write(slotID[:])
+	_, _ = hash.Write([]byte(visitorID))
+
+	return float64(hash.Sum64()%10000)/100 < holdoutPercent
+}
+
+// observeShow lets contextual rotator learn about show it has not chosen itself.
+func (a *App) observeShow(ctx context.Context, rotation types.Rotation, visitor types.Visitor) error {
+	contextual, ok := a.Rotator.(types.ContextualRotator)
+	if !ok {
+		return nil
+	}
+
+	x := features.Extract(visitor, time.Now())
+
+	return a.updateRotatorState(ctx, contextual, rotation.SlotID, rotation.GroupID, func() {
+		contextual.Observe(rotation, x)
+	})
+}
+
+// updateRotatorState applies update to learned state of rotator for slot and
+// group and saves the state back. Storage keeps the state of slot and group
+// locked meanwhile and rotatorMu is held only while rotator works with the state,
+// so caller must not hold rotatorMu.
+func (a *App) updateRotatorState(
+	ctx context.Context,
+	rotator types.ContextualRotator,
+	slotID, groupID uuid.UUID,
+	update func(),
+) error {
+	err := a.Storage.UpdateRotatorState(ctx, slotID, groupID, func(state []byte) ([]byte, error) {
+		a.rotatorMu.Lock()
+		defer a.rotatorMu.Unlock()
+
+		a.restoreRotatorState(rotator, state, slotID, groupID)
+		update()
+		return rotator.MarshalState()
+	})
+	if err != nil {
+		a.Log.Error(
+			"failed to update rotator state",
+			types.LogFields{
+				"error":    err,
+				"slot_id":  slotID.String(),
+				"group_id": groupID.String(),
+			},
+		)
+		return err
+	}
+
+	return nil
+}
+
+// rotatorState fetches learned state of rotator for slot and group.
+// Nothing is fetched unless rotator is contextual.
+func (a *App) rotatorState(ctx context.Context, slotID, groupID uuid.UUID) ([]byte, error) {
+	if _, ok := a.Rotator.(types.ContextualRotator); !ok {
+		return nil, nil
+	}
+
+	state, err := a.Storage.GetRotatorState(ctx, slotID, groupID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		a.Log.Error(
+			"failed to get rotator state",
+			types.LogFields{
+				"error":    err,
+				"slot_id":  slotID.String(),
+				"group_id": groupID.String(),
+			},
+		)
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// restoreRotatorState makes rotator use learned state of slot and group.
+// Caller must hold rotatorMu.
+func (a *App) restoreRotatorState(
+	rotator types.ContextualRotator,
+	state []byte,
+	slotID, groupID uuid.UUID,
+) {
+	err := rotator.UnmarshalState(state)
+	if err != nil {
+		// Incompatible state is dropped and learning starts over
+		a.Log.Warn(
+			"failed to restore rotator state",
+			types.LogFields{
+				"error":    err,
+				"slot_id":  slotID.String(),
+				"group_id": groupID.String(),
+			},
+		)
+		_ = rotator.UnmarshalState(nil)
+	}
+}
+
+// assignedRotation looks for rotation previously assigned to visitor.
+// Assignment is ignored if its rotation is no longer available.
+func (a *App) assignedRotation(
+	ctx context.Context,
+	rotations []types.Rotation,
+	slotID, groupID uuid.UUID,
+	visitor types.Visitor,
+) (types.Rotation, bool, error) {
+	bannerID, err := a.Storage.GetAssignment(ctx, visitor.ID, slotID, groupID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return types.Rotation{}, false, nil
+	case err != nil:
+		a.Log.Error(
+			"failed to get sticky assignment",
+			types.LogFields{
+				"error":      err,
+				"visitor_id": visitor.ID,
+				"slot_id":    slotID.String(),
+				"group_id":   groupID.String(),
+			},
+		)
+		return types.Rotation{}, false, err
+	}
+
+	for _, rotation := range rotations {
+		if rotation.BannerID == bannerID {
+			return rotation, true, nil
+		}
+	}
+
+	return types.Rotation{}, false, nil
+}
+
+// filterRotations leaves only active rotations which belong to given slot and group.
+func filterRotations(rotations []types.Rotation, slotID, groupID uuid.UUID) []types.Rotation {
+	filtered := make([]types.Rotation, 0, len(rotations))
+	for _, rotation := range rotations {
+		if rotation.State == types.RotationStatePaused {
+			continue
+		}
+		if rotation.SlotID == slotID && rotation.GroupID == groupID {
+			filtered = append(filtered, rotation)
+		}
+	}
+	return filtered
+}
+
+// skipCappedRotations drops rotations which visitor has already seen
+// as many times as their frequency cap allows.
+func (a *App) skipCappedRotations(
+	ctx context.Context,
+	rotations []types.Rotation,
+	visitor types.Visitor,
+) ([]types.Rotation, error) {
+	if visitor.ID == "" {
+		return rotations, nil
+	}
+
+	allowed := make([]types.Rotation, 0, len(rotations))
+	for _, rotation := range rotations {
+		if rotation.Cap.MaxShows <= 0 {
+			allowed = append(allowed, rotation)
+			continue
+		}
+
+		exposures, err := a.Exposures.CountExposures(
+			ctx,
+			visitor.ID,
+			rotation.BannerID,
+			rotation.SlotID,
+			rotation.GroupID,
+			rotation.Cap.Window,
+		)
+		if err != nil {
+			a.Log.Error(
+				"failed to count visitor exposures",
+				types.LogFields{
+					"error":      err,
+					"visitor_id": visitor.ID,
+					"banner_id":  rotation.BannerID,
+					"slot_id":    rotation.SlotID,
+					"group_id":   rotation.GroupID,
+				},
+			)
+			return nil, err
+		}
+
+		if exposures < rotation.Cap.MaxShows {
+			allowed = append(allowed, rotation)
+		}
+	}
+
+	return allowed, nil
 }
 
 func (a *App) GetStats(ctx context.Context, bannerID, slotID, groupID uuid.UUID) ([]types.Event, error) {
@@ -377,6 +1289,88 @@ func (a *App) GetStats(ctx context.Context, bannerID, slotID, groupID uuid.UUID)
 	return events, nil
 }
 
+// GetHoldoutReport compares CTR of shows chosen by rotator against
+// holdout shows chosen at random in slot during time range.
+func (a *App) GetHoldoutReport(
+	ctx context.Context,
+	slotID uuid.UUID,
+	from, to time.Time,
+) (types.HoldoutReport, error) {
+	armsStats, err := a.Storage.GetArmStats(ctx, slotID, from, to)
+	if err != nil {
+		a.Log.Error(
+			"failed to get holdout experiment stats",
+			types.LogFields{
+				"error":   err,
+				"slot_id": slotID.String(),
+			},
+		)
+		return types.HoldoutReport{}, err
+	}
+
+	var holdout, optimised types.ArmStats
+	for _, armStats := range armsStats {
+		switch armStats.Arm {
+		case types.ArmHoldout:
+			holdout = armStats
+		case types.ArmOptimised:
+			optimised = armStats
+		}
+	}
+
+	report := uplift.Compare(holdout, optimised)
+	report.SlotID = slotID
+	report.From = from
+	report.To = to
+
+	return report, nil
+}
+
+// EvaluateRotators estimates CTR which rotators of given algorithms
+// would have had on shows of slot and group logged in time range.
+func (a *App) EvaluateRotators(
+	ctx context.Context,
+	slotID, groupID uuid.UUID,
+	from, to time.Time,
+	algorithms []string,
+) ([]types.PolicyEstimate, error) {
+	logFields := types.LogFields{
+		"slot_id":  slotID.String(),
+		"group_id": groupID.String(),
+		"from":     from,
+		"to":       to,
+	}
+
+	events, err := a.Storage.GetEvents(ctx, slotID, groupID, from, to)
+	if err != nil {
+		logFields["error"] = err
+		a.Log.Error("failed to get logged events", logFields)
+		return nil, err
+	}
+
+	estimates := make([]types.PolicyEstimate, 0, len(algorithms))
+	for _, algorithm := range algorithms {
+		rotator, err := rotators.New(config.Rotator{Algorithm: algorithm})
+		if err != nil {
+			logFields["error"] = err
+			a.Log.Error("failed to create rotator to evaluate", logFields)
+			return nil, err
+		}
+
+		estimate, err := evaluation.Evaluate(rotator, events)
+		if err != nil {
+			logFields["error"] = err
+			a.Log.Error("failed to evaluate rotator", logFields)
+			return nil, err
+		}
+		estimate.Rotator = algorithm
+
+		estimates = append(estimates, estimate)
+	}
+
+	return estimates, nil
+}
+
 func (a *App) GetLogger(name string) types.Logger {
 	return a.Log.ChildLogger(name)
 }
@@ -0,0 +1,388 @@
+package app
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/features"
+	"github.com/FedoseevAlex/banner-rotation/internal/metrics"
+	"github.com/FedoseevAlex/banner-rotation/internal/tracing"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// pageSlot is a slot of page along with rotations it may show.
+type pageSlot struct {
+	slotID     uuid.UUID
+	settings   types.SlotSettings
+	candidates []types.Rotation
+	// forced is a choice made by sticky assignment or rotation overrides
+	forced   *choice
+	assigned bool
+	// holdout is decided by visitor for sticky slots and drawn at random for the rest
+	holdout        bool
+	holdoutDecided bool
+	// state is learned state of contextual rotator for the slot
+	state  []byte
+	scores []types.RotationScore
+}
+
+// ChooseBanners chooses banners for several slots of a page so that
+// no banner is shown twice. Sticky assignments and rotation overrides
+// are honoured first, then banners are given to slots greedily by rotator
+// scores, holdout slots get random banners from the rest.
+// Slots which are left without a banner are omitted from result.
+func (a *App) ChooseBanners(
+	ctx context.Context,
+	slotIDs []uuid.UUID,
+	groupID uuid.UUID,
+	visitor types.Visitor,
+) ([]types.Impression, error) {
+	ctx, span := tracing.Start(
+		ctx,
+		"App.ChooseBanners",
+		attribute.String("group_id", groupID.String()),
+		attribute.Int("slots", len(slotIDs)),
+	)
+	defer span.End()
+
+	logFields := types.LogFields{
+		"slot_ids":   slotIDs,
+		"group_id":   groupID.String(),
+		"visitor_id": visitor.ID,
+	}
+	a.Log.Debug("choose banners for page", logFields)
+
+	trials, err := a.Storage.GetTotalShows(ctx)
+	if err != nil {
+		logFields["error"] = err
+		a.Log.Error("failed to fetch total trials from db", logFields)
+		return nil, err
+	}
+
+	rotations, err := a.Storage.GetAllRotations(ctx)
+	if err != nil {
+		logFields["error"] = err
+		a.Log.Error("failed to fetch all rotations from db", logFields)
+		return nil, err
+	}
+
+	slots := make([]pageSlot, 0, len(slotIDs))
+	for _, slotID := range slotIDs {
+		slot, err := a.preparePageSlot(ctx, rotations, slotID, groupID, visitor)
+		if err != nil {
+			return nil, err
+		}
+		if len(slot.candidates) > 0 {
+			slot.state, err = a.rotatorState(ctx, slotID, groupID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		slots = append(slots, slot)
+	}
+
+	x := features.Extract(visitor, time.Now())
+
+	a.rotatorMu.Lock()
+	for i := range slots {
+		if !slots[i].holdoutDecided {
+			slots[i].holdout = a.random.Float64()*100 < slots[i].settings.HoldoutPercent
+		}
+	}
+	a.scorePageSlots(slots, trials, groupID, x)
+	choices := assignPageSlots(slots, a.random)
+	a.rotatorMu.Unlock()
+
+	var (
+		impressions = make([]types.Impression, 0, len(slots))
+		// shown are slots of impressions
+		shown []int
+	)
+	// releaseExposures frees exposures reserved for shows which are not registered
+	releaseExposures := func() {
+		for _, impression := range impressions {
+			a.removeExposure(ctx, impression.Rotation, visitor)
+		}
+	}
+	for i, picked := range choices {
+		if picked == nil {
+			continue
+		}
+
+		// Slot is left empty if visitor has reached frequency cap meanwhile
+		reserved, err := a.addExposure(ctx, picked.rotation, visitor)
+		if err != nil {
+			releaseExposures()
+			return nil, err
+		}
+		if !reserved {
+			continue
+		}
+
+		impressionID, err := uuid.NewRandom()
+		if err != nil {
+			a.Log.Error(
+				"failed to create random uuid for impression",
+				types.LogFields{"error": err},
+			)
+			a.removeExposure(ctx, picked.rotation, visitor)
+			releaseExposures()
+			return nil, err
+		}
+
+		impressions = append(impressions, types.Impression{
+			ImpressionID: impressionID,
+			Rotation:     picked.rotation,
+			VisitorID:    visitor.ID,
+			Propensity:   picked.propensity,
+			Arm:          picked.arm,
+		})
+		shown = append(shown, i)
+	}
+
+	if len(impressions) == 0 {
+		a.Log.Debug("no rotations to choose from", logFields)
+		return nil, types.ErrNoRotations
+	}
+
+	// Shows are registered before rotator and sticky assignments learn about
+	// them, so that nothing is remembered of a page which failed to be shown
+	err = a.Storage.AddShows(ctx, impressions)
+	if err != nil {
+		logFields["error"] = err
+		a.Log.Error("failed to register shows for rotations", logFields)
+		releaseExposures()
+		return nil, err
+	}
+	for _, impression := range impressions {
+		metrics.Shows.WithLabelValues(impression.SlotID.String(), impression.GroupID.String()).Inc()
+	}
+
+	for _, i := range shown {
+		err = a.rememberPageChoice(ctx, slots[i], groupID, *choices[i], visitor, x)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return impressions, nil
+}
+
+// preparePageSlot finds rotations slot may show and choice forced by
+// sticky assignment or rotation overrides. Holdout of slots which are not
+// sticky is left to be drawn under rotatorMu.
+func (a *App) preparePageSlot(
+	ctx context.Context,
+	rotations []types.Rotation,
+	slotID, groupID uuid.UUID,
+	visitor types.Visitor,
+) (pageSlot, error) {
+	page := pageSlot{slotID: slotID}
+
+	candidates := filterRotations(rotations, slotID, groupID)
+	candidates, err := a.skipOverBudgetRotations(ctx, candidates)
+	if err != nil || len(candidates) == 0 {
+		return page, err
+	}
+
+	slot, err := a.Storage.GetSlot(ctx, slotID)
+	if err != nil {
+		a.Log.Error(
+			"failed to get slot from database",
+			types.LogFields{
+				"error":   err,
+				"slot_id": slotID.String(),
+			},
+		)
+		return page, err
+	}
+	page.settings = slot.Settings
+
+	// Assigned banner is shown regardless of cap, yet slot falls back
+	// to allowed banners only if other slot of the page takes it
+	allowed, err := a.skipCappedRotations(ctx, candidates, visitor)
+	if err != nil {
+		return page, err
+	}
+	page.candidates = allowed
+
+	sticky := slot.Settings.Sticky && visitor.ID != ""
+	if sticky {
+		page.holdout = visitorInHoldout(visitor.ID, slotID, slot.Settings.HoldoutPercent)
+		page.holdoutDecided = true
+
+		rotation, found, err := a.assignedRotation(ctx, candidates, slotID, groupID, visitor)
+		if err != nil {
+			return page, err
+		}
+		if found {
+			page.forced = &choice{rotation: rotation, propensity: 1, arm: armOf(page.holdout)}
+			page.assigned = true
+			return page, nil
+		}
+	}
+
+	if rotation, ok := overriddenRotation(candidates, allowed); ok {
+		page.forced = &choice{rotation: rotation, propensity: 1, arm: types.ArmOverride}
+	}
+
+	return page, nil
+}
+
+// scorePageSlots asks rotator for scores of slots candidates. Caller must hold rotatorMu.
+func (a *App) scorePageSlots(
+	slots []pageSlot,
+	trials int64,
+	groupID uuid.UUID,
+	x []float64,
+) {
+	for i := range slots {
+		if len(slots[i].candidates) == 0 {
+			continue
+		}
+
+		slots[i].scores = a.rotatorScores(slots[i].candidates, trials, slots[i].state, slots[i].slotID, groupID, x)
+	}
+}
+
+// rememberPageChoice lets contextual rotator learn about the show
+// and saves sticky assignment.
+func (a *App) rememberPageChoice(
+	ctx context.Context,
+	slot pageSlot,
+	groupID uuid.UUID,
+	picked choice,
+	visitor types.Visitor,
+	x []float64,
+) error {
+	if contextual, ok := a.Rotator.(types.ContextualRotator); ok {
+		err := a.updateRotatorState(ctx, contextual, slot.slotID, groupID, func() {
+			contextual.Observe(picked.rotation, x)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !slot.settings.Sticky || visitor.ID == "" || slot.assigned {
+		return nil
+	}
+
+	err := a.Storage.SaveAssignment(
+		ctx,
+		visitor.ID,
+		picked.rotation.BannerID,
+		slot.slotID,
+		groupID,
+		slot.settings.StickyTTL,
+	)
+	if err != nil {
+		a.Log.Error(
+			"failed to save sticky assignment",
+			types.LogFields{
+				"error":      err,
+				"visitor_id": visitor.ID,
+				"banner_id":  picked.rotation.BannerID,
+				"slot_id":    slot.slotID.String(),
+				"group_id":   groupID.String(),
+			},
+		)
+	}
+	return err
+}
+
+// assignPageSlots chooses banner for every slot so that no banner repeats.
+// Forced choices go first in order of slots. Then the pair of slot and banner
+// with the highest score among optimised slots is taken until no pairs are left.
+// Holdout slots get random banners among the rest. Propensities account for
+// banners taken by other slots of the page.
+// Result holds choice for every slot or nil if slot is left without banner.
+func assignPageSlots(slots []pageSlot, random *rand.Rand) []*choice {
+	var (
+		choices = make([]*choice, len(slots))
+		used    = make(map[uuid.UUID]bool)
+	)
+
+	for i, slot := range slots {
+		if slot.forced != nil && !used[slot.forced.rotation.BannerID] {
+			choices[i] = slot.forced
+			used[slot.forced.rotation.BannerID] = true
+		}
+	}
+
+	type pair struct {
+		slot  int
+		score types.RotationScore
+	}
+	var pairs []pair
+	for i, slot := range slots {
+		if choices[i] != nil || slot.holdout {
+			continue
+		}
+		for _, score := range slot.scores {
+			pairs = append(pairs, pair{slot: i, score: score})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].score.Score > pairs[j].score.Score
+	})
+
+	for _, p := range pairs {
+		bannerID := p.score.Rotation.BannerID
+		if choices[p.slot] != nil || used[bannerID] {
+			continue
+		}
+
+		// Pairs go by score, so banner is the best of those left free for the slot
+		slot := slots[p.slot]
+		free := 0
+		for _, score := range slot.scores {
+			if !used[score.Rotation.BannerID] {
+				free++
+			}
+		}
+		choices[p.slot] = &choice{
+			rotation:   p.score.Rotation,
+			propensity: propensity(p.score.Rotation, p.score.Rotation, free, slot.settings.HoldoutPercent),
+			arm:        types.ArmOptimised,
+		}
+		used[bannerID] = true
+	}
+
+	for i, slot := range slots {
+		if choices[i] != nil || !slot.holdout {
+			continue
+		}
+
+		var (
+			free    []types.Rotation
+			optimal types.RotationScore
+		)
+		for _, score := range slot.scores {
+			if used[score.Rotation.BannerID] {
+				continue
+			}
+			free = append(free, score.Rotation)
+			if len(free) == 1 || score.Score > optimal.Score {
+				optimal = score
+			}
+		}
+		if len(free) == 0 {
+			continue
+		}
+
+		shown := free[random.Intn(len(free))]
+		choices[i] = &choice{
+			rotation:   shown,
+			propensity: propensity(shown, optimal.Rotation, len(free), slot.settings.HoldoutPercent),
+			arm:        types.ArmHoldout,
+		}
+		used[shown.BannerID] = true
+	}
+
+	return choices
+}
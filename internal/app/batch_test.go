@@ -0,0 +1,284 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/logger"
+	"github.com/FedoseevAlex/banner-rotation/internal/rotators/mab"
+	"github.com/FedoseevAlex/banner-rotation/internal/storage/memory"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssignPageSlots(t *testing.T) {
+	banners := []types.Rotation{
+		{BannerID: uuid.New()},
+		{BannerID: uuid.New()},
+		{BannerID: uuid.New()},
+	}
+
+	// scoresOf gives every banner the score from the list
+	scoresOf := func(scores ...float64) []types.RotationScore {
+		result := make([]types.RotationScore, 0, len(scores))
+		for i, score := range scores {
+			result = append(result, types.RotationScore{Rotation: banners[i], Score: score})
+		}
+		return result
+	}
+
+	random := rand.New(rand.NewSource(1))
+
+	t.Run("check best banner goes to the slot it scores most in", func(t *testing.T) {
+		slots := []pageSlot{
+			{scores: scoresOf(0.5, 0.4, 0.1)},
+			{scores: scoresOf(0.9, 0.2, 0.1)},
+		}
+
+		choices := assignPageSlots(slots, random)
+		require.Equal(t, banners[1].BannerID, choices[0].rotation.BannerID)
+		require.Equal(t, banners[0].BannerID, choices[1].rotation.BannerID)
+		require.Equal(t, types.ArmOptimised, choices[0].arm)
+		require.Equal(t, 1.0, choices[0].propensity)
+	})
+
+	t.Run("check propensity counts only banners left free", func(t *testing.T) {
+		slots := []pageSlot{
+			{scores: scoresOf(0.5, 0.4, 0.1), settings: types.SlotSettings{HoldoutPercent: 30}},
+			{scores: scoresOf(0.9, 0.2, 0.1)},
+		}
+
+		choices := assignPageSlots(slots, random)
+		require.Equal(t, banners[1].BannerID, choices[0].rotation.BannerID)
+		// Holdout would pick one of two banners left free by the other slot
+		require.InDelta(t, 0.7+0.3/2, choices[0].propensity, 1e-9)
+	})
+
+	t.Run("check forced choice goes first", func(t *testing.T) {
+		slots := []pageSlot{
+			{scores: scoresOf(0.9, 0.2, 0.1)},
+			{
+				scores: scoresOf(0.1, 0.2, 0.3),
+				forced: &choice{rotation: banners[0], propensity: 1, arm: types.ArmOverride},
+			},
+		}
+
+		choices := assignPageSlots(slots, random)
+		require.Equal(t, banners[1].BannerID, choices[0].rotation.BannerID)
+		require.Equal(t, banners[0].BannerID, choices[1].rotation.BannerID)
+		require.Equal(t, types.ArmOverride, choices[1].arm)
+	})
+
+	t.Run("check banners never repeat", func(t *testing.T) {
+		slots := []pageSlot{
+			{scores: scoresOf(0.9, 0.2)},
+			{scores: scoresOf(0.9, 0.2)},
+			{scores: scoresOf(0.9, 0.2)},
+		}
+
+		choices := assignPageSlots(slots, random)
+		require.NotNil(t, choices[0])
+		require.NotNil(t, choices[1])
+		require.Nil(t, choices[2])
+		require.NotEqual(t, choices[0].rotation.BannerID, choices[1].rotation.BannerID)
+	})
+
+	t.Run("check holdout slot gets banner left by others", func(t *testing.T) {
+		slots := []pageSlot{
+			{
+				scores:   scoresOf(0.9, 0.2),
+				holdout:  true,
+				settings: types.SlotSettings{HoldoutPercent: 10},
+			},
+			{scores: scoresOf(0.9, 0.2)},
+		}
+
+		choices := assignPageSlots(slots, random)
+		require.Equal(t, banners[0].BannerID, choices[1].rotation.BannerID)
+		require.Equal(t, banners[1].BannerID, choices[0].rotation.BannerID)
+		require.Equal(t, types.ArmHoldout, choices[0].arm)
+		// The only free banner is also the best of free ones
+		require.InDelta(t, 1.0, choices[0].propensity, 1e-9)
+	})
+}
+
+func TestChooseBannersConcurrently(t *testing.T) {
+	ctx := context.Background()
+	slotID, groupID := uuid.New(), uuid.New()
+
+	store := &showStorage{
+		fakeStorage: fakeStorage{
+			rotations: []types.Rotation{
+				{BannerID: uuid.New(), SlotID: slotID, GroupID: groupID, State: types.RotationStateActive},
+				{BannerID: uuid.New(), SlotID: slotID, GroupID: groupID, State: types.RotationStateActive},
+			},
+			slot: types.Slot{ID: slotID, Settings: types.SlotSettings{HoldoutPercent: 50}},
+		},
+		shows: make(map[uuid.UUID]int),
+	}
+	a := &App{
+		Rotator: &mab.MultiArmedBandit{},
+		Storage: store,
+		Log:     logger.New("error", os.DevNull),
+		random:  rand.New(rand.NewSource(1)),
+	}
+
+	// Holdout of every page is drawn at random, run with -race to check it is guarded
+	t.Run("check concurrent pages get banners", func(t *testing.T) {
+		const requests = 10
+		errs := make([]error, requests)
+		var wg sync.WaitGroup
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				visitor := types.Visitor{ID: "visitor-" + strconv.Itoa(i)}
+				_, errs[i] = a.ChooseBanners(ctx, []uuid.UUID{slotID}, groupID, visitor)
+			}(i)
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			require.NoError(t, err)
+		}
+		var shows int
+		for _, count := range store.shows {
+			shows += count
+		}
+		require.Equal(t, requests, shows)
+	})
+}
+
+// stickyStorage assigns the banner to every visitor.
+type stickyStorage struct {
+	fakeStorage
+	assigned uuid.UUID
+}
+
+func (ss *stickyStorage) GetAssignment(context.Context, string, uuid.UUID, uuid.UUID) (uuid.UUID, error) {
+	return ss.assigned, nil
+}
+
+func TestPreparePageSlotSticky(t *testing.T) {
+	ctx := context.Background()
+	slotID, groupID := uuid.New(), uuid.New()
+	visitor := types.Visitor{ID: "visitor"}
+	frequencyCap := types.FrequencyCap{MaxShows: 1, Window: time.Hour}
+
+	newRotation := func() types.Rotation {
+		return types.Rotation{
+			BannerID: uuid.New(),
+			SlotID:   slotID,
+			GroupID:  groupID,
+			State:    types.RotationStateActive,
+			Cap:      frequencyCap,
+		}
+	}
+	assigned, capped, allowed := newRotation(), newRotation(), newRotation()
+
+	exposures := memory.NewExposureStore()
+	for _, rotation := range []types.Rotation{assigned, capped} {
+		_, err := exposures.AddExposure(ctx, visitor.ID, rotation.BannerID, slotID, groupID, frequencyCap)
+		require.NoError(t, err)
+	}
+
+	a := &App{
+		Storage: &stickyStorage{
+			fakeStorage: fakeStorage{
+				rotations: []types.Rotation{assigned, capped, allowed},
+				slot:      types.Slot{ID: slotID, Settings: types.SlotSettings{Sticky: true}},
+			},
+			assigned: assigned.BannerID,
+		},
+		Exposures: exposures,
+		Log:       logger.New("error", os.DevNull),
+	}
+
+	t.Run("check assigned banner is forced and only allowed ones are candidates", func(t *testing.T) {
+		page, err := a.preparePageSlot(ctx, []types.Rotation{assigned, capped, allowed}, slotID, groupID, visitor)
+		require.NoError(t, err)
+		require.True(t, page.assigned)
+		require.Equal(t, assigned.BannerID, page.forced.rotation.BannerID)
+		require.Equal(t, []types.Rotation{allowed}, page.candidates)
+
+		// Other slot of the page takes assigned banner first
+		other := pageSlot{forced: &choice{rotation: assigned, propensity: 1, arm: types.ArmOverride}}
+		page.scores = []types.RotationScore{{Rotation: allowed, Score: 1}}
+		choices := assignPageSlots([]pageSlot{other, page}, rand.New(rand.NewSource(1)))
+		require.Equal(t, allowed.BannerID, choices[1].rotation.BannerID)
+	})
+}
+
+// assignmentStorage has no sticky assignments and counts saved ones.
+type assignmentStorage struct {
+	showStorage
+	saved int
+}
+
+func (as *assignmentStorage) GetAssignment(context.Context, string, uuid.UUID, uuid.UUID) (uuid.UUID, error) {
+	return uuid.Nil, sql.ErrNoRows
+}
+
+func (as *assignmentStorage) SaveAssignment(context.Context, string, uuid.UUID, uuid.UUID, uuid.UUID, time.Duration) error {
+	as.saved++
+	return nil
+}
+
+func TestChooseBannersFailedShows(t *testing.T) {
+	ctx := context.Background()
+	slotID, groupID := uuid.New(), uuid.New()
+	visitor := types.Visitor{ID: "visitor"}
+	frequencyCap := types.FrequencyCap{MaxShows: 1, Window: time.Hour}
+
+	rotation := types.Rotation{
+		BannerID: uuid.New(),
+		SlotID:   slotID,
+		GroupID:  groupID,
+		State:    types.RotationStateActive,
+		Cap:      frequencyCap,
+	}
+	store := &assignmentStorage{
+		showStorage: showStorage{
+			fakeStorage: fakeStorage{
+				rotations: []types.Rotation{rotation},
+				slot:      types.Slot{ID: slotID, Settings: types.SlotSettings{Sticky: true, StickyTTL: time.Hour}},
+			},
+			shows: make(map[uuid.UUID]int),
+			err:   errors.New("rotation was deleted"),
+		},
+	}
+	exposures := memory.NewExposureStore()
+	a := &App{
+		Rotator:   &mab.MultiArmedBandit{},
+		Storage:   store,
+		Exposures: exposures,
+		Log:       logger.New("error", os.DevNull),
+		random:    rand.New(rand.NewSource(1)),
+	}
+
+	t.Run("check nothing is remembered of failed page", func(t *testing.T) {
+		_, err := a.ChooseBanners(ctx, []uuid.UUID{slotID}, groupID, visitor)
+		require.Error(t, err)
+		require.Zero(t, store.saved)
+
+		count, err := exposures.CountExposures(ctx, visitor.ID, rotation.BannerID, slotID, groupID, time.Hour)
+		require.NoError(t, err)
+		require.Zero(t, count)
+	})
+
+	t.Run("check page is shown once storage recovers", func(t *testing.T) {
+		store.err = nil
+		impressions, err := a.ChooseBanners(ctx, []uuid.UUID{slotID}, groupID, visitor)
+		require.NoError(t, err)
+		require.Len(t, impressions, 1)
+		require.Equal(t, 1, store.saved)
+	})
+}
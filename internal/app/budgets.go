@@ -0,0 +1,122 @@
+package app
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+// consumptionTTL is how long budget consumption of campaign is reused by choices
+// of banners. Campaign may overspend its budget by shows made meanwhile.
+const consumptionTTL = time.Second
+
+// overBudget reports whether campaign has exhausted any of its total budgets
+// or is ahead of its daily budgets paced evenly across the day.
+func overBudget(consumption types.CampaignConsumption) bool {
+	budget := consumption.Budget
+	return exhausted(consumption.TotalShows, budget.TotalShows, 1) ||
+		exhausted(consumption.TotalClicks, budget.TotalClicks, 1) ||
+		exhausted(consumption.DailyShows, budget.DailyShows, consumption.DayElapsed) ||
+		exhausted(consumption.DailyClicks, budget.DailyClicks, consumption.DayElapsed)
+}
+
+// exhausted reports whether consumed amount has reached the part of budget.
+// Zero budget is not limited.
+func exhausted(consumed, budget int, part float64) bool {
+	if budget <= 0 {
+		return false
+	}
+	return float64(consumed) >= math.Ceil(float64(budget)*part)
+}
+
+// campaignsOf returns distinct campaigns of rotations.
+func campaignsOf(rotations []types.Rotation) []uuid.UUID {
+	var (
+		campaignIDs []uuid.UUID
+		seen        = make(map[uuid.UUID]bool)
+	)
+	for _, rotation := range rotations {
+		if rotation.CampaignID == uuid.Nil || seen[rotation.CampaignID] {
+			continue
+		}
+		seen[rotation.CampaignID] = true
+		campaignIDs = append(campaignIDs, rotation.CampaignID)
+	}
+	return campaignIDs
+}
+
+// consumptionCache keeps budget consumption of campaigns fetched recently.
+// Zero value is ready to use.
+type consumptionCache struct {
+	mu      sync.Mutex
+	fetched map[uuid.UUID]cachedConsumption
+}
+
+type cachedConsumption struct {
+	consumption types.CampaignConsumption
+	fetchedAt   time.Time
+}
+
+// lookup returns consumptions of campaigns fetched less than consumptionTTL ago
+// and campaigns which have to be fetched again.
+func (c *consumptionCache) lookup(
+	campaignIDs []uuid.UUID,
+	now time.Time,
+) (found []types.CampaignConsumption, missing []uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, campaignID := range campaignIDs {
+		cached, ok := c.fetched[campaignID]
+		if !ok || now.Sub(cached.fetchedAt) >= consumptionTTL {
+			missing = append(missing, campaignID)
+			continue
+		}
+		found = append(found, cached.consumption)
+	}
+	return found, missing
+}
+
+// store remembers fetched consumptions of campaigns. Campaigns missing from
+// consumptions are deleted, they are remembered as not limited.
+func (c *consumptionCache) store(
+	campaignIDs []uuid.UUID,
+	consumptions []types.CampaignConsumption,
+	now time.Time,
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.fetched == nil {
+		c.fetched = make(map[uuid.UUID]cachedConsumption)
+	}
+	for _, campaignID := range campaignIDs {
+		c.fetched[campaignID] = cachedConsumption{
+			consumption: types.CampaignConsumption{CampaignID: campaignID},
+			fetchedAt:   now,
+		}
+	}
+	for _, consumption := range consumptions {
+		c.fetched[consumption.CampaignID] = cachedConsumption{consumption: consumption, fetchedAt: now}
+	}
+}
+
+// dropOverBudget drops rotations of campaigns which are over budget.
+// Rotations out of campaigns and of unknown campaigns are kept.
+func dropOverBudget(rotations []types.Rotation, consumptions []types.CampaignConsumption) []types.Rotation {
+	over := make(map[uuid.UUID]bool, len(consumptions))
+	for _, consumption := range consumptions {
+		over[consumption.CampaignID] = overBudget(consumption)
+	}
+
+	kept := make([]types.Rotation, 0, len(rotations))
+	for _, rotation := range rotations {
+		if !over[rotation.CampaignID] {
+			kept = append(kept, rotation)
+		}
+	}
+	return kept
+}
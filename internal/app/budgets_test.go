@@ -0,0 +1,148 @@
+package app
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/logger"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverBudget(t *testing.T) {
+	testCases := []struct {
+		name        string
+		consumption types.CampaignConsumption
+		over        bool
+	}{
+		{
+			name:        "unlimited campaign",
+			consumption: types.CampaignConsumption{TotalShows: 1000, DailyShows: 1000, DayElapsed: 0.5},
+		},
+		{
+			name: "total shows budget exhausted",
+			consumption: types.CampaignConsumption{
+				Budget:     types.CampaignBudget{TotalShows: 100},
+				TotalShows: 100,
+			},
+			over: true,
+		},
+		{
+			name: "total clicks budget left",
+			consumption: types.CampaignConsumption{
+				Budget:      types.CampaignBudget{TotalClicks: 10},
+				TotalClicks: 9,
+			},
+		},
+		{
+			name: "ahead of daily shows pacing",
+			consumption: types.CampaignConsumption{
+				Budget:     types.CampaignBudget{DailyShows: 240},
+				DailyShows: 60,
+				DayElapsed: 0.25,
+			},
+			over: true,
+		},
+		{
+			name: "behind daily shows pacing",
+			consumption: types.CampaignConsumption{
+				Budget:     types.CampaignBudget{DailyShows: 240},
+				DailyShows: 59,
+				DayElapsed: 0.25,
+			},
+		},
+		{
+			name: "ahead of daily clicks pacing",
+			consumption: types.CampaignConsumption{
+				Budget:      types.CampaignBudget{DailyClicks: 10},
+				DailyClicks: 6,
+				DayElapsed:  0.5,
+			},
+			over: true,
+		},
+		{
+			name: "start of the day",
+			consumption: types.CampaignConsumption{
+				Budget:     types.CampaignBudget{DailyShows: 240},
+				DayElapsed: 0.0001,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.over, overBudget(tc.consumption))
+		})
+	}
+}
+
+func TestDropOverBudget(t *testing.T) {
+	exhaustedCampaign, activeCampaign := uuid.New(), uuid.New()
+	rotations := []types.Rotation{
+		{BannerID: uuid.New()},
+		{BannerID: uuid.New(), CampaignID: exhaustedCampaign},
+		{BannerID: uuid.New(), CampaignID: activeCampaign},
+	}
+	consumptions := []types.CampaignConsumption{
+		{CampaignID: exhaustedCampaign, Budget: types.CampaignBudget{TotalShows: 1}, TotalShows: 1},
+		{CampaignID: activeCampaign, Budget: types.CampaignBudget{TotalShows: 2}, TotalShows: 1},
+	}
+
+	require.Equal(t, []uuid.UUID{exhaustedCampaign, activeCampaign}, campaignsOf(rotations))
+	require.Equal(t, []types.Rotation{rotations[0], rotations[2]}, dropOverBudget(rotations, consumptions))
+}
+
+type consumptionStorage struct {
+	fakeStorage
+	consumptions []types.CampaignConsumption
+	queries      int
+}
+
+func (cs *consumptionStorage) GetCampaignsConsumption(
+	context.Context,
+	[]uuid.UUID,
+) ([]types.CampaignConsumption, error) {
+	cs.queries++
+	return cs.consumptions, nil
+}
+
+func TestSkipOverBudgetRotations(t *testing.T) {
+	exhaustedCampaign, deletedCampaign := uuid.New(), uuid.New()
+	rotations := []types.Rotation{
+		{BannerID: uuid.New()},
+		{BannerID: uuid.New(), CampaignID: exhaustedCampaign},
+		{BannerID: uuid.New(), CampaignID: deletedCampaign},
+	}
+	store := &consumptionStorage{
+		consumptions: []types.CampaignConsumption{
+			{CampaignID: exhaustedCampaign, Budget: types.CampaignBudget{TotalShows: 1}, TotalShows: 1},
+		},
+	}
+	a := &App{Storage: store, Log: logger.New("error", os.DevNull)}
+
+	t.Run("check consumption is fetched once in a while", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			kept, err := a.skipOverBudgetRotations(context.Background(), rotations)
+			require.NoError(t, err)
+			require.Equal(t, []types.Rotation{rotations[0], rotations[2]}, kept)
+		}
+		require.Equal(t, 1, store.queries)
+	})
+
+	t.Run("check rotations out of campaigns need no consumption", func(t *testing.T) {
+		kept, err := a.skipOverBudgetRotations(context.Background(), rotations[:1])
+		require.NoError(t, err)
+		require.Equal(t, rotations[:1], kept)
+		require.Equal(t, 1, store.queries)
+	})
+
+	t.Run("check stale consumption is fetched again", func(t *testing.T) {
+		found, missing := a.consumptions.lookup([]uuid.UUID{exhaustedCampaign}, time.Now().Add(consumptionTTL))
+		require.Empty(t, found)
+		require.Equal(t, []uuid.UUID{exhaustedCampaign}, missing)
+	})
+}
@@ -0,0 +1,209 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+func (a *App) SetBannerCampaign(ctx context.Context, bannerID, campaignID uuid.UUID) error {
+	err := a.Storage.SetBannerCampaign(ctx, bannerID, campaignID)
+	if err != nil {
+		a.Log.Error(
+			"failed to set banner campaign",
+			types.LogFields{
+				"error":       err,
+				"banner_id":   bannerID.String(),
+				"campaign_id": campaignID.String(),
+			},
+		)
+		return err
+	}
+	return nil
+}
+
+func (a *App) AddAdvertiser(ctx context.Context, description string) (types.Advertiser, error) {
+	advertiserID, err := uuid.NewRandom()
+	if err != nil {
+		a.Log.Error(
+			"failed to create random uuid for advertiser",
+			types.LogFields{"error": err},
+		)
+		return types.Advertiser{}, err
+	}
+
+	advertiser := types.Advertiser{
+		ID:          advertiserID,
+		Description: description,
+	}
+
+	err = a.Storage.AddAdvertiser(ctx, advertiser)
+	if err != nil {
+		a.Log.Error(
+			"failed to add advertiser",
+			types.LogFields{"error": err},
+		)
+		return types.Advertiser{}, err
+	}
+
+	a.Log.Trace(
+		"add advertiser",
+		types.LogFields{
+			"advertiser_id": advertiserID.String(),
+		},
+	)
+
+	return advertiser, nil
+}
+
+func (a *App) DeleteAdvertiser(ctx context.Context, advertiserID uuid.UUID) error {
+	err := a.Storage.DeleteAdvertiser(ctx, advertiserID)
+	if err != nil {
+		a.Log.Error(
+			"failed to delete advertiser",
+			types.LogFields{"error": err},
+		)
+		return err
+	}
+	return nil
+}
+
+func (a *App) GetAdvertiser(ctx context.Context, advertiserID uuid.UUID) (types.Advertiser, error) {
+	advertiser, err := a.Storage.GetAdvertiser(ctx, advertiserID)
+	if err != nil {
+		a.Log.Error(
+			"failed to get advertiser from database",
+			types.LogFields{"error": err},
+		)
+		return types.Advertiser{}, err
+	}
+	return advertiser, nil
+}
+
+func (a *App) AddCampaign(
+	ctx context.Context,
+	advertiserID uuid.UUID,
+	description string,
+	budget types.CampaignBudget,
+) (types.Campaign, error) {
+	campaignID, err := uuid.NewRandom()
+	if err != nil {
+		a.Log.Error(
+			"failed to create random uuid for campaign",
+			types.LogFields{"error": err},
+		)
+		return types.Campaign{}, err
+	}
+
+	campaign := types.Campaign{
+		ID:           campaignID,
+		AdvertiserID: advertiserID,
+		Description:  description,
+		Budget:       budget,
+	}
+
+	err = a.Storage.AddCampaign(ctx, campaign)
+	if err != nil {
+		a.Log.Error(
+			"failed to add campaign",
+			types.LogFields{
+				"error":         err,
+				"advertiser_id": advertiserID.String(),
+			},
+		)
+		return types.Campaign{}, err
+	}
+
+	a.Log.Trace(
+		"add campaign",
+		types.LogFields{
+			"campaign_id":   campaignID.String(),
+			"advertiser_id": advertiserID.String(),
+		},
+	)
+
+	return campaign, nil
+}
+
+func (a *App) DeleteCampaign(ctx context.Context, campaignID uuid.UUID) error {
+	err := a.Storage.DeleteCampaign(ctx, campaignID)
+	if err != nil {
+		a.Log.Error(
+			"failed to delete campaign",
+			types.LogFields{"error": err},
+		)
+		return err
+	}
+	return nil
+}
+
+func (a *App) GetCampaign(ctx context.Context, campaignID uuid.UUID) (types.Campaign, error) {
+	campaign, err := a.Storage.GetCampaign(ctx, campaignID)
+	if err != nil {
+		a.Log.Error(
+			"failed to get campaign from database",
+			types.LogFields{"error": err},
+		)
+		return types.Campaign{}, err
+	}
+	return campaign, nil
+}
+
+func (a *App) SetCampaignBudget(ctx context.Context, campaignID uuid.UUID, budget types.CampaignBudget) error {
+	err := a.Storage.SetCampaignBudget(ctx, campaignID, budget)
+	if err != nil {
+		a.Log.Error(
+			"failed to set campaign budget",
+			types.LogFields{
+				"error":       err,
+				"campaign_id": campaignID.String(),
+			},
+		)
+		return err
+	}
+	return nil
+}
+
+func (a *App) GetCampaignConsumption(ctx context.Context, campaignID uuid.UUID) (types.CampaignConsumption, error) {
+	consumption, err := a.Storage.GetCampaignConsumption(ctx, campaignID)
+	if err != nil {
+		a.Log.Error(
+			"failed to get campaign budget consumption",
+			types.LogFields{
+				"error":       err,
+				"campaign_id": campaignID.String(),
+			},
+		)
+		return types.CampaignConsumption{}, err
+	}
+	return consumption, nil
+}
+
+// skipOverBudgetRotations drops rotations which campaigns have exhausted
+// or are ahead of their budgets. Consumption of campaigns is fetched
+// at most once in consumptionTTL.
+func (a *App) skipOverBudgetRotations(ctx context.Context, rotations []types.Rotation) ([]types.Rotation, error) {
+	campaignIDs := campaignsOf(rotations)
+	if len(campaignIDs) == 0 {
+		return rotations, nil
+	}
+
+	now := time.Now()
+	consumptions, missing := a.consumptions.lookup(campaignIDs, now)
+	if len(missing) > 0 {
+		fetched, err := a.Storage.GetCampaignsConsumption(ctx, missing)
+		if err != nil {
+			a.Log.Error(
+				"failed to get campaigns budget consumption",
+				types.LogFields{"error": err},
+			)
+			return nil, err
+		}
+		a.consumptions.store(missing, fetched, now)
+		consumptions = append(consumptions, fetched...)
+	}
+
+	return dropOverBudget(rotations, consumptions), nil
+}
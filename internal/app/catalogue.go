@@ -0,0 +1,99 @@
+package app
+
+import (
+	"context"
+	"errors"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/catalogue"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+// ExportCatalogue collects banners, slots, groups with their rules and rotations
+// which are not deleted. Campaigns are not part of catalogue so banners and rotations
+// are exported without them. Rules are exported without ids as import assigns new ones.
+func (a *App) ExportCatalogue(ctx context.Context) (types.Catalogue, error) {
+	var (
+		exported types.Catalogue
+		err      error
+	)
+
+	exported.Banners, err = a.ListBanners(ctx)
+	if err != nil {
+		return types.Catalogue{}, err
+	}
+	for i := range exported.Banners {
+		exported.Banners[i].CampaignID = uuid.Nil
+	}
+
+	exported.Slots, err = a.ListSlots(ctx)
+	if err != nil {
+		return types.Catalogue{}, err
+	}
+
+	exported.Groups, err = a.ListGroups(ctx)
+	if err != nil {
+		return types.Catalogue{}, err
+	}
+
+	exported.Rules, err = a.Storage.GetAllGroupRules(ctx)
+	if err != nil {
+		a.Log.Error(
+			"failed to get group rules from database",
+			types.LogFields{"error": err},
+		)
+		return types.Catalogue{}, err
+	}
+	for i := range exported.Rules {
+		exported.Rules[i].ID = 0
+	}
+
+	exported.Rotations, err = a.ListRotations(ctx)
+	if err != nil {
+		return types.Catalogue{}, err
+	}
+	for i := range exported.Rotations {
+		exported.Rotations[i].CampaignID = uuid.Nil
+	}
+
+	return exported, nil
+}
+
+// ImportCatalogue checks catalogue itself and then imports it into storage.
+// Report lists conflicts when error is types.ErrCatalogueConflicts.
+func (a *App) ImportCatalogue(ctx context.Context, imported types.Catalogue, dryRun bool) (types.ImportReport, error) {
+	conflicts := catalogue.Validate(imported)
+	if len(conflicts) > 0 {
+		return types.ImportReport{DryRun: dryRun, Conflicts: conflicts}, types.ErrCatalogueConflicts
+	}
+
+	report, err := a.Storage.ImportCatalogue(ctx, imported, dryRun)
+	switch {
+	case errors.Is(err, types.ErrCatalogueConflicts):
+		a.Log.Info(
+			"catalogue conflicts with existing data",
+			types.LogFields{"conflicts": len(report.Conflicts)},
+		)
+		return report, err
+	case err != nil:
+		a.Log.Error(
+			"failed to import catalogue",
+			types.LogFields{"error": err},
+		)
+		return report, err
+	}
+
+	a.Log.Info(
+		"catalogue imported",
+		types.LogFields{
+			"dry_run":   dryRun,
+			"banners":   report.Banners,
+			"slots":     report.Slots,
+			"groups":    report.Groups,
+			"rules":     report.Rules,
+			"rotations": report.Rotations,
+		},
+	)
+
+	return report, nil
+}
@@ -0,0 +1,129 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/catalogue"
+	"github.com/FedoseevAlex/banner-rotation/internal/logger"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// catalogueStorage serves catalogue entries and remembers imported catalogue.
+type catalogueStorage struct {
+	types.Storager
+	banners   []types.Banner
+	slots     []types.Slot
+	groups    []types.Group
+	rules     []types.GroupRule
+	rotations []types.Rotation
+	imported  types.Catalogue
+}
+
+func (cs *catalogueStorage) GetAllBanners(context.Context) ([]types.Banner, error) {
+	return append([]types.Banner(nil), cs.banners...), nil
+}
+
+func (cs *catalogueStorage) GetAllSlots(context.Context) ([]types.Slot, error) {
+	return append([]types.Slot(nil), cs.slots...), nil
+}
+
+func (cs *catalogueStorage) GetAllGroups(context.Context) ([]types.Group, error) {
+	return append([]types.Group(nil), cs.groups...), nil
+}
+
+func (cs *catalogueStorage) GetAllGroupRules(context.Context) ([]types.GroupRule, error) {
+	return append([]types.GroupRule(nil), cs.rules...), nil
+}
+
+func (cs *catalogueStorage) GetAllRotations(context.Context) ([]types.Rotation, error) {
+	return append([]types.Rotation(nil), cs.rotations...), nil
+}
+
+func (cs *catalogueStorage) ImportCatalogue(
+	_ context.Context,
+	imported types.Catalogue,
+	dryRun bool,
+) (types.ImportReport, error) {
+	cs.imported = imported
+	return types.ImportReport{
+		DryRun:    dryRun,
+		Banners:   len(imported.Banners),
+		Slots:     len(imported.Slots),
+		Groups:    len(imported.Groups),
+		Rules:     len(imported.Rules),
+		Rotations: len(imported.Rotations),
+	}, nil
+}
+
+func TestCatalogueRoundTrip(t *testing.T) {
+	campaignID := uuid.New()
+	banner := types.Banner{ID: uuid.New(), Description: "Summer sale", CampaignID: campaignID}
+	slot := types.Slot{ID: uuid.New(), Description: "Top"}
+	group := types.Group{ID: uuid.New(), Description: "Teenagers"}
+	rule := types.GroupRule{
+		ID:         42,
+		GroupID:    group.ID,
+		Priority:   1,
+		MinAge:     13,
+		MaxAge:     19,
+		Device:     "mobile",
+		Attributes: map[string]string{"plan": "free"},
+	}
+	rotation := types.Rotation{
+		BannerID:   banner.ID,
+		SlotID:     slot.ID,
+		GroupID:    group.ID,
+		Shows:      10,
+		Clicks:     2,
+		Cap:        types.FrequencyCap{MaxShows: 3, Window: time.Hour},
+		State:      types.RotationStateActive,
+		CampaignID: campaignID,
+	}
+
+	source := &catalogueStorage{
+		banners:   []types.Banner{banner},
+		slots:     []types.Slot{slot},
+		groups:    []types.Group{group},
+		rules:     []types.GroupRule{rule},
+		rotations: []types.Rotation{rotation},
+	}
+	a := &App{Storage: source, Log: logger.New("error", os.DevNull)}
+
+	exported, err := a.ExportCatalogue(context.Background())
+	require.NoError(t, err)
+
+	t.Run("check campaigns and rule ids are not exported", func(t *testing.T) {
+		require.Equal(t, uuid.Nil, exported.Banners[0].CampaignID)
+		require.Equal(t, uuid.Nil, exported.Rotations[0].CampaignID)
+		require.Zero(t, exported.Rules[0].ID)
+		require.Equal(t, campaignID, source.banners[0].CampaignID, "storage data must stay intact")
+	})
+
+	for _, format := range []string{catalogue.FormatJSON, catalogue.FormatCSV} {
+		format := format
+		t.Run("check "+format+" import keeps group rules", func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, catalogue.Write(&buf, format, exported))
+			read, err := catalogue.Read(&buf, format)
+			require.NoError(t, err)
+
+			target := &catalogueStorage{}
+			b := &App{Storage: target, Log: logger.New("error", os.DevNull)}
+			report, err := b.ImportCatalogue(context.Background(), read, false)
+			require.NoError(t, err)
+			require.Equal(t, 1, report.Rules)
+
+			expectedRule := rule
+			expectedRule.ID = 0
+			require.Equal(t, []types.GroupRule{expectedRule}, target.imported.Rules)
+			require.Equal(t, []types.Banner{{ID: banner.ID, Description: banner.Description}}, target.imported.Banners)
+			require.Equal(t, exported, target.imported)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/metrics"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+// NewClickValidation parses click validation config.
+func NewClickValidation(cfg config.Clicks) (types.ClickValidation, error) {
+	validation := types.ClickValidation{
+		RequireShow:       cfg.RequireShow,
+		UserAgentDenylist: cfg.UserAgentDenylist,
+	}
+
+	var err error
+	if cfg.MinDelay != "" {
+		validation.MinDelay, err = time.ParseDuration(cfg.MinDelay)
+		if err != nil {
+			return types.ClickValidation{}, fmt.Errorf("min_delay: %w", err)
+		}
+	}
+	if cfg.DuplicateWindow != "" {
+		validation.DuplicateWindow, err = time.ParseDuration(cfg.DuplicateWindow)
+		if err != nil {
+			return types.ClickValidation{}, fmt.Errorf("duplicate_window: %w", err)
+		}
+	}
+
+	return validation, nil
+}
+
+// validateClick returns reason to reject click or empty string if click is valid.
+// Cheap checks go first, so that bots do not cost database queries.
+// Duplicates are checked by storage when click is registered.
+func (a *App) validateClick(ctx context.Context, click types.Click) (string, error) {
+	validation := a.ClickValidation
+
+	userAgent := strings.ToLower(click.UserAgent)
+	for _, bot := range validation.UserAgentDenylist {
+		if bot != "" && strings.Contains(userAgent, strings.ToLower(bot)) {
+			return types.RejectReasonBot, nil
+		}
+	}
+
+	if validation.RequireShow || validation.MinDelay > 0 {
+		sinceShow, err := a.Storage.SinceLastEvent(ctx, click, types.EventTypeShow)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// Delay is checked only for clicks with known show
+			if validation.RequireShow {
+				return types.RejectReasonNoShow, nil
+			}
+		case err != nil:
+			return "", err
+		case sinceShow < validation.MinDelay:
+			return types.RejectReasonTooFast, nil
+		}
+	}
+
+	return "", nil
+}
+
+// rejectClick keeps click which failed validation apart from events,
+// so it is neither counted in stats nor rewards rotator.
+func (a *App) rejectClick(ctx context.Context, click types.Click, reason string) error {
+	err := a.Storage.AddRejectedClick(ctx, click, reason)
+	if err != nil {
+		a.Log.Error(
+			"failed to store rejected click",
+			types.LogFields{
+				"error":    err,
+				"reason":   reason,
+				"slot_id":  click.SlotID.String(),
+				"group_id": click.GroupID.String(),
+			},
+		)
+		return err
+	}
+	metrics.RejectedClicks.WithLabelValues(reason).Inc()
+
+	a.Log.Debug(
+		"click rejected",
+		types.LogFields{
+			"reason":        reason,
+			"impression_id": click.ImpressionID.String(),
+			"banner_id":     click.BannerID.String(),
+			"slot_id":       click.SlotID.String(),
+			"group_id":      click.GroupID.String(),
+			"visitor_id":    click.Visitor.ID,
+			"user_agent":    click.UserAgent,
+			"ip":            click.IP,
+		},
+	)
+	return nil
+}
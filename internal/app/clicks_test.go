@@ -0,0 +1,167 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/logger"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// clickStorage reports time since the last events by type and keeps
+// registered and rejected clicks. Click is a duplicate if the last click
+// happened within the window.
+type clickStorage struct {
+	fakeStorage
+	since    map[string]time.Duration
+	clicks   []types.Click
+	rejected map[string]int
+}
+
+func (cs *clickStorage) SinceLastEvent(_ context.Context, _ types.Click, eventType string) (time.Duration, error) {
+	since, ok := cs.since[eventType]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return since, nil
+}
+
+func (cs *clickStorage) AddClick(_ context.Context, click types.Click, duplicateWindow time.Duration) error {
+	if since, ok := cs.since[types.EventTypeClick]; ok && since < duplicateWindow {
+		return types.ErrDuplicateClick
+	}
+	cs.clicks = append(cs.clicks, click)
+	return nil
+}
+
+func (cs *clickStorage) AddRejectedClick(_ context.Context, _ types.Click, reason string) error {
+	cs.rejected[reason]++
+	return nil
+}
+
+func TestRegisterClickValidation(t *testing.T) {
+	ctx := context.Background()
+	validation := types.ClickValidation{
+		RequireShow:       true,
+		MinDelay:          time.Second,
+		DuplicateWindow:   time.Hour,
+		UserAgentDenylist: []string{"bot", "Crawler"},
+	}
+	click := types.Click{
+		ImpressionID: uuid.New(),
+		BannerID:     uuid.New(),
+		SlotID:       uuid.New(),
+		GroupID:      uuid.New(),
+		UserAgent:    "Mozilla/5.0",
+	}
+
+	tests := []struct {
+		name       string
+		validation types.ClickValidation
+		since      map[string]time.Duration
+		userAgent  string
+		reason     string
+	}{
+		{
+			name:       "valid click",
+			validation: validation,
+			since:      map[string]time.Duration{types.EventTypeShow: time.Minute},
+		},
+		{
+			name:       "bot",
+			validation: validation,
+			since:      map[string]time.Duration{types.EventTypeShow: time.Minute},
+			userAgent:  "Mozilla/5.0 (compatible; SomeCRAWLER/1.0)",
+			reason:     types.RejectReasonBot,
+		},
+		{
+			name:       "no show",
+			validation: validation,
+			reason:     types.RejectReasonNoShow,
+		},
+		{
+			name:       "too fast",
+			validation: validation,
+			since:      map[string]time.Duration{types.EventTypeShow: 100 * time.Millisecond},
+			reason:     types.RejectReasonTooFast,
+		},
+		{
+			name:       "duplicate",
+			validation: validation,
+			since:      map[string]time.Duration{types.EventTypeShow: time.Minute, types.EventTypeClick: time.Minute},
+			reason:     types.RejectReasonDuplicate,
+		},
+		{
+			name:       "repeated click after window",
+			validation: validation,
+			since:      map[string]time.Duration{types.EventTypeShow: 2 * time.Hour, types.EventTypeClick: 2 * time.Hour},
+		},
+		{
+			name:       "delay without show",
+			validation: types.ClickValidation{MinDelay: time.Second},
+		},
+		{
+			name: "no validation",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run("check "+tc.name, func(t *testing.T) {
+			store := &clickStorage{since: tc.since, rejected: make(map[string]int)}
+			application := &App{
+				Storage:         store,
+				ClickValidation: tc.validation,
+				Log:             logger.New("error", os.DevNull),
+			}
+
+			tcClick := click
+			if tc.userAgent != "" {
+				tcClick.UserAgent = tc.userAgent
+			}
+
+			err := application.RegisterClick(ctx, tcClick)
+			require.NoError(t, err)
+
+			if tc.reason == "" {
+				require.Len(t, store.clicks, 1)
+				require.Empty(t, store.rejected)
+				return
+			}
+			require.Empty(t, store.clicks)
+			require.Equal(t, map[string]int{tc.reason: 1}, store.rejected)
+		})
+	}
+}
+
+func TestRegisterClickWithDefaultConfig(t *testing.T) {
+	cfg, err := config.ReadConfig("../../configs/config.toml")
+	require.NoError(t, err)
+	validation, err := NewClickValidation(cfg.Clicks)
+	require.NoError(t, err)
+
+	store := &clickStorage{rejected: make(map[string]int)}
+	application := &App{
+		Storage:         store,
+		ClickValidation: validation,
+		Log:             logger.New("error", os.DevNull),
+	}
+
+	// Click of baseline rotation as sent by example in README: no impression, no visitor
+	click := types.Click{
+		BannerID:  uuid.New(),
+		SlotID:    uuid.New(),
+		GroupID:   uuid.New(),
+		UserAgent: "curl/7.68.0",
+	}
+	err = application.RegisterClick(context.Background(), click)
+	require.NoError(t, err)
+	require.Len(t, store.clicks, 1)
+	require.Empty(t, store.rejected)
+}
@@ -0,0 +1,231 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/features"
+	"github.com/FedoseevAlex/banner-rotation/internal/tracing"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+// ExplainChoice goes through the same steps as ChooseBanner and tells
+// scores of slot rotations, why some of them are left out and which banner
+// would be chosen. Neither show nor sticky assignment is registered
+// and rotator state is not changed.
+func (a *App) ExplainChoice(
+	ctx context.Context,
+	slotID, groupID uuid.UUID,
+	visitor types.Visitor,
+) (types.ChoiceExplanation, error) {
+	ctx, span := tracing.Start(ctx, "App.ExplainChoice")
+	defer span.End()
+
+	logFields := types.LogFields{
+		"slot_id":    slotID.String(),
+		"group_id":   groupID.String(),
+		"visitor_id": visitor.ID,
+	}
+	a.Log.Debug("explain banner choice", logFields)
+
+	explanation := types.ChoiceExplanation{
+		SlotID:    slotID,
+		GroupID:   groupID,
+		VisitorID: visitor.ID,
+	}
+
+	trials, err := a.Storage.GetTotalShows(ctx)
+	if err != nil {
+		logFields["error"] = err
+		a.Log.Error("failed to fetch total trials from db", logFields)
+		return explanation, err
+	}
+	explanation.Trials = trials
+
+	rotations, err := a.Storage.GetAllRotations(ctx)
+	if err != nil {
+		logFields["error"] = err
+		a.Log.Error("failed to fetch all rotations from db", logFields)
+		return explanation, err
+	}
+
+	var slotRotations []types.Rotation
+	for _, rotation := range rotations {
+		if rotation.SlotID == slotID && rotation.GroupID == groupID {
+			slotRotations = append(slotRotations, rotation)
+		}
+	}
+	if len(slotRotations) == 0 {
+		return explanation, types.ErrNoRotations
+	}
+
+	exclusions := make(map[uuid.UUID][]string)
+	// exclude marks rotations which are not kept by a step of the choice
+	exclude := func(before, kept []types.Rotation, reason string) {
+		for _, rotation := range before {
+			if !containsBanner(kept, rotation.BannerID) {
+				exclusions[rotation.BannerID] = append(exclusions[rotation.BannerID], reason)
+			}
+		}
+	}
+
+	candidates := filterRotations(slotRotations, slotID, groupID)
+	exclude(slotRotations, candidates, types.ExclusionPaused)
+
+	withinBudget, err := a.skipOverBudgetRotations(ctx, candidates)
+	if err != nil {
+		return explanation, err
+	}
+	exclude(candidates, withinBudget, types.ExclusionOverBudget)
+	candidates = withinBudget
+
+	chosen, decided, err := a.explainOverrides(ctx, &explanation, candidates, exclude, visitor)
+	if err != nil {
+		return explanation, err
+	}
+
+	x := features.Extract(visitor, time.Now())
+
+	state, err := a.rotatorState(ctx, slotID, groupID)
+	if err != nil {
+		return explanation, err
+	}
+
+	a.rotatorMu.Lock()
+	defer a.rotatorMu.Unlock()
+
+	scores := a.rotatorScores(slotRotations, trials, state, slotID, groupID, x)
+
+	if !decided && len(chosen) > 0 {
+		explanation.ChosenBannerID = a.rotatorChoice(chosen, trials, state, slotID, groupID, x)
+	}
+
+	for i, rotation := range slotRotations {
+		explanation.Rotations = append(explanation.Rotations, types.RotationExplanation{
+			Rotation:   rotation,
+			Mean:       scores[i].Mean,
+			Bonus:      scores[i].Bonus,
+			Score:      scores[i].Score,
+			Exclusions: exclusions[rotation.BannerID],
+		})
+	}
+
+	return explanation, nil
+}
+
+// explainOverrides applies sticky assignment, frequency caps and rotation
+// overrides to candidates. It returns either the decided choice or
+// rotations left for the rotator to choose from.
+func (a *App) explainOverrides(
+	ctx context.Context,
+	explanation *types.ChoiceExplanation,
+	candidates []types.Rotation,
+	exclude func(before, kept []types.Rotation, reason string),
+	visitor types.Visitor,
+) ([]types.Rotation, bool, error) {
+	if len(candidates) == 0 {
+		return nil, false, nil
+	}
+
+	slot, err := a.Storage.GetSlot(ctx, explanation.SlotID)
+	if err != nil {
+		a.Log.Error(
+			"failed to get slot from database",
+			types.LogFields{
+				"error":   err,
+				"slot_id": explanation.SlotID.String(),
+			},
+		)
+		return nil, false, err
+	}
+	explanation.HoldoutPercent = slot.Settings.HoldoutPercent
+
+	if slot.Settings.Sticky && visitor.ID != "" {
+		rotation, found, err := a.assignedRotation(ctx, candidates, explanation.SlotID, explanation.GroupID, visitor)
+		if err != nil {
+			return nil, false, err
+		}
+		if found {
+			explanation.ChosenBannerID = rotation.BannerID
+			exclude(candidates, []types.Rotation{rotation}, types.ExclusionSticky)
+			return nil, true, nil
+		}
+	}
+
+	allowed, err := a.skipCappedRotations(ctx, candidates, visitor)
+	if err != nil {
+		return nil, false, err
+	}
+	exclude(candidates, allowed, types.ExclusionCapped)
+
+	if rotation, ok := overriddenRotation(candidates, allowed); ok {
+		explanation.ChosenBannerID = rotation.BannerID
+		exclude(allowed, []types.Rotation{rotation}, types.ExclusionOverridden)
+		return nil, true, nil
+	}
+
+	return allowed, false, nil
+}
+
+// rotatorScores returns scores of rotations in the same order. Contextual
+// rotator uses learned state of slot and group. Caller must hold rotatorMu.
+func (a *App) rotatorScores(
+	rotations []types.Rotation,
+	trials int64,
+	state []byte,
+	slotID, groupID uuid.UUID,
+	x []float64,
+) []types.RotationScore {
+	switch rotator := a.Rotator.(type) {
+	case types.ContextualRotator:
+		a.restoreRotatorState(rotator, state, slotID, groupID)
+		rotator.Load(rotations, trials)
+		return rotator.ScoresContext(x)
+	case types.ScoringRotator:
+		rotator.Load(rotations, trials)
+		return rotator.Scores()
+	default:
+		// Rotator without scores is explained by its choice only
+		a.Rotator.Load(rotations, trials)
+		chosen := a.Rotator.Rotate()
+		scores := make([]types.RotationScore, 0, len(rotations))
+		for _, rotation := range rotations {
+			score := types.RotationScore{Rotation: rotation}
+			if rotation.BannerID == chosen.BannerID {
+				score.Score = 1
+			}
+			scores = append(scores, score)
+		}
+		return scores
+	}
+}
+
+// rotatorChoice returns banner rotator would choose among rotations.
+// Caller must hold rotatorMu.
+func (a *App) rotatorChoice(
+	rotations []types.Rotation,
+	trials int64,
+	state []byte,
+	slotID, groupID uuid.UUID,
+	x []float64,
+) uuid.UUID {
+	contextual, ok := a.Rotator.(types.ContextualRotator)
+	if !ok {
+		a.Rotator.Load(rotations, trials)
+		return a.Rotator.Rotate().BannerID
+	}
+
+	a.restoreRotatorState(contextual, state, slotID, groupID)
+	contextual.Load(rotations, trials)
+	return contextual.RotateContext(x).BannerID
+}
+
+func containsBanner(rotations []types.Rotation, bannerID uuid.UUID) bool {
+	for _, rotation := range rotations {
+		if rotation.BannerID == bannerID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/logger"
+	"github.com/FedoseevAlex/banner-rotation/internal/metrics"
+	"github.com/FedoseevAlex/banner-rotation/internal/rotators/mab"
+	"github.com/FedoseevAlex/banner-rotation/internal/storage/memory"
+	"github.com/FedoseevAlex/banner-rotation/internal/tracing"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStorage serves rotations from memory. Calling methods it does not
+// implement panics, so unexpected storage access fails the test.
+type fakeStorage struct {
+	types.Storager
+	rotations []types.Rotation
+	slot      types.Slot
+}
+
+func (fs *fakeStorage) GetTotalShows(context.Context) (int64, error) {
+	var trials int64
+	for _, rotation := range fs.rotations {
+		trials += int64(rotation.Shows)
+	}
+	return trials, nil
+}
+
+func (fs *fakeStorage) GetAllRotations(context.Context) ([]types.Rotation, error) {
+	return fs.rotations, nil
+}
+
+func (fs *fakeStorage) GetSlot(context.Context, uuid.UUID) (types.Slot, error) {
+	return fs.slot, nil
+}
+
+func TestExplainChoice(t *testing.T) {
+	ctx := context.Background()
+	slotID, groupID := uuid.New(), uuid.New()
+
+	newRotation := func(shows, clicks int) types.Rotation {
+		return types.Rotation{
+			BannerID: uuid.New(),
+			SlotID:   slotID,
+			GroupID:  groupID,
+			Shows:    shows,
+			Clicks:   clicks,
+			State:    types.RotationStateActive,
+		}
+	}
+
+	paused := newRotation(10, 9)
+	paused.State = types.RotationStatePaused
+	best := newRotation(10, 5)
+	capped := newRotation(10, 8)
+	capped.Cap = types.FrequencyCap{MaxShows: 1, Window: time.Hour}
+	worst := newRotation(10, 1)
+	other := newRotation(100, 50)
+	other.SlotID = uuid.New()
+
+	store := &fakeStorage{
+		rotations: []types.Rotation{paused, best, capped, worst, other},
+		slot:      types.Slot{ID: slotID, Settings: types.SlotSettings{HoldoutPercent: 10}},
+	}
+	exposures := memory.NewExposureStore()
+	visitor := types.Visitor{ID: "visitor"}
+	_, err := exposures.AddExposure(ctx, visitor.ID, capped.BannerID, slotID, groupID, capped.Cap)
+	require.NoError(t, err)
+
+	a := &App{
+		Rotator:   &mab.MultiArmedBandit{},
+		Storage:   store,
+		Exposures: exposures,
+		Log:       logger.New("error", os.DevNull),
+	}
+
+	explanation, err := a.ExplainChoice(ctx, slotID, groupID, visitor)
+	require.NoError(t, err)
+
+	require.Equal(t, int64(140), explanation.Trials)
+	require.Equal(t, 10.0, explanation.HoldoutPercent)
+	require.Equal(t, best.BannerID, explanation.ChosenBannerID)
+	require.Len(t, explanation.Rotations, 4)
+
+	exclusions := make(map[uuid.UUID][]string)
+	for _, rotation := range explanation.Rotations {
+		exclusions[rotation.BannerID] = rotation.Exclusions
+
+		mean := float64(rotation.Clicks) / float64(rotation.Shows+1)
+		bonus := math.Sqrt(2 * math.Log(float64(explanation.Trials+1)) / float64(rotation.Shows+1))
+		require.InDelta(t, mean, rotation.Mean, 1e-9)
+		require.InDelta(t, bonus, rotation.Bonus, 1e-9)
+		require.InDelta(t, mean+bonus, rotation.Score, 1e-9)
+	}
+
+	require.Equal(t, []string{types.ExclusionPaused}, exclusions[paused.BannerID])
+	require.Equal(t, []string{types.ExclusionCapped}, exclusions[capped.BannerID])
+	require.Empty(t, exclusions[best.BannerID])
+	require.Empty(t, exclusions[worst.BannerID])
+
+	t.Run("check no rotations", func(t *testing.T) {
+		_, err := a.ExplainChoice(ctx, uuid.New(), groupID, visitor)
+		require.ErrorIs(t, err, types.ErrNoRotations)
+	})
+
+	t.Run("check storage spans are children of explain span", func(t *testing.T) {
+		exporter, restore := tracing.UseInMemory()
+		defer restore()
+
+		a.Storage = metrics.InstrumentStorage(store)
+		defer func() { a.Storage = store }()
+
+		_, err := a.ExplainChoice(ctx, slotID, groupID, visitor)
+		require.NoError(t, err)
+
+		spans := exporter.GetSpans()
+		names := make([]string, 0, len(spans))
+		for _, span := range spans {
+			names = append(names, span.Name)
+		}
+		require.Equal(t, []string{"Storage.GetTotalShows", "Storage.GetAllRotations", "Storage.GetSlot", "App.ExplainChoice"}, names)
+
+		root := spans[len(spans)-1]
+		for _, span := range spans[:len(spans)-1] {
+			require.Equal(t, root.SpanContext.TraceID(), span.SpanContext.TraceID())
+			require.Equal(t, root.SpanContext.SpanID(), span.Parent.SpanID())
+		}
+	})
+}
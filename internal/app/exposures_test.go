@@ -0,0 +1,119 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/logger"
+	"github.com/FedoseevAlex/banner-rotation/internal/rotators/mab"
+	"github.com/FedoseevAlex/banner-rotation/internal/storage/memory"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// showStorage counts registered shows by banner. Shows fail to be registered if err is set.
+type showStorage struct {
+	fakeStorage
+	mu    sync.Mutex
+	shows map[uuid.UUID]int
+	err   error
+}
+
+func (ss *showStorage) AddShow(_ context.Context, impression types.Impression) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.err != nil {
+		return ss.err
+	}
+	ss.shows[impression.BannerID]++
+	return nil
+}
+
+func (ss *showStorage) AddShows(ctx context.Context, impressions []types.Impression) error {
+	if ss.err != nil {
+		return ss.err
+	}
+	for _, impression := range impressions {
+		_ = ss.AddShow(ctx, impression)
+	}
+	return nil
+}
+
+func TestChooseBannerFrequencyCap(t *testing.T) {
+	ctx := context.Background()
+	slotID, groupID := uuid.New(), uuid.New()
+	frequencyCap := types.FrequencyCap{MaxShows: 1, Window: time.Hour}
+
+	newRotation := func(shows, clicks int) types.Rotation {
+		return types.Rotation{
+			BannerID: uuid.New(),
+			SlotID:   slotID,
+			GroupID:  groupID,
+			Shows:    shows,
+			Clicks:   clicks,
+			State:    types.RotationStateActive,
+			Cap:      frequencyCap,
+		}
+	}
+
+	store := &showStorage{
+		fakeStorage: fakeStorage{
+			rotations: []types.Rotation{newRotation(10, 5), newRotation(10, 1)},
+			slot:      types.Slot{ID: slotID},
+		},
+		shows: make(map[uuid.UUID]int),
+	}
+	a := &App{
+		Rotator:   &mab.MultiArmedBandit{},
+		Storage:   store,
+		Exposures: memory.NewExposureStore(),
+		Log:       logger.New("error", os.DevNull),
+		random:    rand.New(rand.NewSource(1)),
+	}
+
+	t.Run("check concurrent choices do not exceed cap", func(t *testing.T) {
+		const requests = 10
+		errs := make([]error, requests)
+		var wg sync.WaitGroup
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = a.ChooseBanner(ctx, slotID, groupID, types.Visitor{ID: "visitor"})
+			}(i)
+		}
+		wg.Wait()
+
+		var chosen int
+		for _, err := range errs {
+			if err == nil {
+				chosen++
+				continue
+			}
+			require.ErrorIs(t, err, types.ErrNoRotations)
+		}
+		require.Equal(t, 2, chosen)
+		for _, rotation := range store.rotations {
+			require.Equal(t, 1, store.shows[rotation.BannerID])
+		}
+	})
+
+	t.Run("check failed show does not count against cap", func(t *testing.T) {
+		visitor := types.Visitor{ID: "unlucky visitor"}
+		store.err = errors.New("connection lost")
+		_, err := a.ChooseBanner(ctx, slotID, groupID, visitor)
+		require.Error(t, err)
+		store.err = nil
+
+		for range store.rotations {
+			_, err := a.ChooseBanner(ctx, slotID, groupID, visitor)
+			require.NoError(t, err)
+		}
+	})
+}
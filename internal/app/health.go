@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+// readinessCheck returns error if dependency is not able to serve.
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (a *App) readinessChecks() []readinessCheck {
+	return []readinessCheck{
+		{name: "database", check: a.Storage.Ping},
+		{name: "migrations", check: func(ctx context.Context) error {
+			pending, err := a.Storage.PendingMigrations(ctx)
+			if err != nil {
+				return err
+			}
+			if pending > 0 {
+				return fmt.Errorf("%d migrations are not applied", pending)
+			}
+			return nil
+		}},
+	}
+}
+
+// Readiness runs all checks. Service is ready if every check passes.
+func (a *App) Readiness(ctx context.Context) types.Readiness {
+	checks := a.readinessChecks()
+
+	readiness := types.Readiness{
+		Ready:  true,
+		Checks: make([]types.HealthCheck, 0, len(checks)),
+	}
+	for _, c := range checks {
+		started := time.Now()
+		err := c.check(ctx)
+
+		result := types.HealthCheck{
+			Name:       c.name,
+			Status:     types.CheckStatusOK,
+			DurationMs: time.Since(started).Milliseconds(),
+		}
+		if err != nil {
+			result.Status = types.CheckStatusFailed
+			result.Error = err.Error()
+			readiness.Ready = false
+
+			a.Log.Warn(
+				"readiness check failed",
+				types.LogFields{"check": c.name, "error": err},
+			)
+		}
+		readiness.Checks = append(readiness.Checks, result)
+	}
+
+	return readiness
+}
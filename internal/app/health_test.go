@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/logger"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// healthStorage reports configured database state.
+type healthStorage struct {
+	fakeStorage
+	pingErr error
+	pending int
+}
+
+func (hs *healthStorage) Ping(context.Context) error {
+	return hs.pingErr
+}
+
+func (hs *healthStorage) PendingMigrations(context.Context) (int, error) {
+	if hs.pingErr != nil {
+		return 0, hs.pingErr
+	}
+	return hs.pending, nil
+}
+
+func TestReadiness(t *testing.T) {
+	ctx := context.Background()
+	store := &healthStorage{}
+	application := &App{Storage: store, Log: logger.New("error", os.DevNull)}
+
+	t.Run("check ready", func(t *testing.T) {
+		readiness := application.Readiness(ctx)
+		require.True(t, readiness.Ready)
+		require.Len(t, readiness.Checks, 2)
+		for _, check := range readiness.Checks {
+			require.Equal(t, types.CheckStatusOK, check.Status)
+			require.Empty(t, check.Error)
+		}
+	})
+
+	t.Run("check pending migrations", func(t *testing.T) {
+		store.pending = 2
+		defer func() { store.pending = 0 }()
+
+		readiness := application.Readiness(ctx)
+		require.False(t, readiness.Ready)
+		require.Equal(t, types.CheckStatusOK, readiness.Checks[0].Status)
+		require.Equal(t, "migrations", readiness.Checks[1].Name)
+		require.Equal(t, types.CheckStatusFailed, readiness.Checks[1].Status)
+		require.Equal(t, "2 migrations are not applied", readiness.Checks[1].Error)
+	})
+
+	t.Run("check database is down", func(t *testing.T) {
+		store.pingErr = errors.New("connection refused")
+		defer func() { store.pingErr = nil }()
+
+		readiness := application.Readiness(ctx)
+		require.False(t, readiness.Ready)
+		require.Equal(t, "database", readiness.Checks[0].Name)
+		require.Equal(t, "connection refused", readiness.Checks[0].Error)
+	})
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+func (a *App) BeginIdempotentRequest(
+	ctx context.Context,
+	key, bodyHash string,
+	lease time.Duration,
+) (*types.IdempotentResponse, bool, error) {
+	response, claimed, err := a.Idempotency.BeginRequest(ctx, key, bodyHash, lease)
+	switch {
+	case errors.Is(err, types.ErrIdempotencyKeyReused):
+		return nil, false, err
+	case err != nil:
+		a.Log.Error(
+			"failed to claim idempotency key",
+			types.LogFields{
+				"key":   key,
+				"error": err,
+			},
+		)
+		return nil, false, err
+	}
+
+	return response, claimed, nil
+}
+
+// CompleteIdempotentRequest keeps response to replay it to retries.
+// If it fails, retries are processed anew once lease of the key is over.
+func (a *App) CompleteIdempotentRequest(
+	ctx context.Context,
+	key string,
+	response types.IdempotentResponse,
+	ttl time.Duration,
+) error {
+	err := a.Idempotency.CompleteRequest(ctx, key, response, ttl)
+	if err != nil {
+		a.Log.Error(
+			"failed to store response to idempotent request",
+			types.LogFields{
+				"key":    key,
+				"status": response.Status,
+				"error":  err,
+			},
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (a *App) AbandonIdempotentRequest(ctx context.Context, key string) error {
+	err := a.Idempotency.AbandonRequest(ctx, key)
+	if err != nil {
+		a.Log.Error(
+			"failed to release idempotency key",
+			types.LogFields{
+				"key":   key,
+				"error": err,
+			},
+		)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"context"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+func (a *App) ListBanners(ctx context.Context) ([]types.Banner, error) {
+	banners, err := a.Storage.GetAllBanners(ctx)
+	if err != nil {
+		a.Log.Error(
+			"failed to get banners from database",
+			types.LogFields{"error": err},
+		)
+		return nil, err
+	}
+	return banners, nil
+}
+
+func (a *App) ListSlots(ctx context.Context) ([]types.Slot, error) {
+	slots, err := a.Storage.GetAllSlots(ctx)
+	if err != nil {
+		a.Log.Error(
+			"failed to get slots from database",
+			types.LogFields{"error": err},
+		)
+		return nil, err
+	}
+	return slots, nil
+}
+
+func (a *App) ListGroups(ctx context.Context) ([]types.Group, error) {
+	groups, err := a.Storage.GetAllGroups(ctx)
+	if err != nil {
+		a.Log.Error(
+			"failed to get groups from database",
+			types.LogFields{"error": err},
+		)
+		return nil, err
+	}
+	return groups, nil
+}
+
+func (a *App) ListRotations(ctx context.Context) ([]types.Rotation, error) {
+	rotations, err := a.Storage.GetAllRotations(ctx)
+	if err != nil {
+		a.Log.Error(
+			"failed to get rotations from database",
+			types.LogFields{"error": err},
+		)
+		return nil, err
+	}
+	return rotations, nil
+}
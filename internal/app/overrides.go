@@ -0,0 +1,55 @@
+package app
+
+import "github.com/FedoseevAlex/banner-rotation/internal/types"
+
+// overriddenRotation returns rotation forced by overrides among allowed rotations.
+// Pinned rotations go first, the least shown of them is chosen.
+// Otherwise rotation which share of all shows is the furthest below
+// its minimal share is chosen. Shares are counted over all rotations
+// of slot and group for the whole rotation history.
+func overriddenRotation(all, allowed []types.Rotation) (types.Rotation, bool) {
+	var (
+		pinned    types.Rotation
+		hasPinned bool
+	)
+	for _, rotation := range allowed {
+		if !rotation.Override.Pinned {
+			continue
+		}
+		if !hasPinned || rotation.Shows < pinned.Shows {
+			pinned = rotation
+			hasPinned = true
+		}
+	}
+	if hasPinned {
+		return pinned, true
+	}
+
+	var totalShows int
+	for _, rotation := range all {
+		totalShows += rotation.Shows
+	}
+
+	var (
+		boosted    types.Rotation
+		maxDeficit float64
+	)
+	for _, rotation := range allowed {
+		if rotation.Override.MinSharePercent <= 0 {
+			continue
+		}
+
+		var sharePercent float64
+		if totalShows > 0 {
+			sharePercent = float64(rotation.Shows) / float64(totalShows) * 100
+		}
+
+		deficit := rotation.Override.MinSharePercent - sharePercent
+		if deficit > maxDeficit {
+			boosted = rotation
+			maxDeficit = deficit
+		}
+	}
+
+	return boosted, maxDeficit > 0
+}
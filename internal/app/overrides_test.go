@@ -0,0 +1,66 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverriddenRotation(t *testing.T) {
+	newRotation := func(shows int, override types.RotationOverride) types.Rotation {
+		return types.Rotation{BannerID: uuid.New(), Shows: shows, Override: override}
+	}
+
+	t.Run("check no overrides", func(t *testing.T) {
+		rotations := []types.Rotation{newRotation(10, types.RotationOverride{}), newRotation(0, types.RotationOverride{})}
+
+		_, ok := overriddenRotation(rotations, rotations)
+		require.False(t, ok)
+	})
+
+	t.Run("check least shown pinned rotation wins", func(t *testing.T) {
+		rotations := []types.Rotation{
+			newRotation(5, types.RotationOverride{Pinned: true}),
+			newRotation(3, types.RotationOverride{Pinned: true}),
+			newRotation(0, types.RotationOverride{MinSharePercent: 50}),
+		}
+
+		rotation, ok := overriddenRotation(rotations, rotations)
+		require.True(t, ok)
+		require.Equal(t, rotations[1].BannerID, rotation.BannerID)
+	})
+
+	t.Run("check capped pinned rotation is skipped", func(t *testing.T) {
+		rotations := []types.Rotation{
+			newRotation(5, types.RotationOverride{Pinned: true}),
+			newRotation(5, types.RotationOverride{}),
+		}
+
+		_, ok := overriddenRotation(rotations, rotations[1:])
+		require.False(t, ok)
+	})
+
+	t.Run("check minimal share is kept", func(t *testing.T) {
+		rotations := []types.Rotation{
+			newRotation(0, types.RotationOverride{MinSharePercent: 20}),
+			newRotation(0, types.RotationOverride{}),
+		}
+
+		var boostedShows int
+		for i := 0; i < 1000; i++ {
+			rotation, ok := overriddenRotation(rotations, rotations)
+			if ok {
+				require.Equal(t, rotations[0].BannerID, rotation.BannerID)
+				rotations[0].Shows++
+				boostedShows++
+			} else {
+				// Bandit gives the rest of shows to the other banner
+				rotations[1].Shows++
+			}
+		}
+
+		require.InDelta(t, 200, boostedShows, 1)
+	})
+}
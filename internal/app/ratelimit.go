@@ -0,0 +1,26 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+// AllowRequest takes token from bucket of client. Requests are let through
+// if rate limiter fails, as limits must not take the service down with them.
+func (a *App) AllowRequest(ctx context.Context, key string, limit types.RateLimit) (time.Duration, error) {
+	retryAfter, err := a.RateLimiter.TakeToken(ctx, key, limit)
+	if err != nil {
+		a.Log.Warn(
+			"failed to take rate limit token, request is allowed",
+			types.LogFields{
+				"key":   key,
+				"error": err,
+			},
+		)
+		return 0, nil
+	}
+
+	return retryAfter, nil
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/logger"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRateLimiter returns configured result for every token.
+type fakeRateLimiter struct {
+	retryAfter time.Duration
+	err        error
+}
+
+func (fl *fakeRateLimiter) TakeToken(context.Context, string, types.RateLimit) (time.Duration, error) {
+	return fl.retryAfter, fl.err
+}
+
+func TestAllowRequest(t *testing.T) {
+	ctx := context.Background()
+	limiter := &fakeRateLimiter{}
+	application := &App{RateLimiter: limiter, Log: logger.New("error", os.DevNull)}
+	limit := types.RateLimit{Rate: 1, Burst: 1}
+
+	t.Run("check limited request is rejected", func(t *testing.T) {
+		limiter.retryAfter = time.Second
+		defer func() { limiter.retryAfter = 0 }()
+
+		retryAfter, err := application.AllowRequest(ctx, "client", limit)
+		require.NoError(t, err)
+		require.Equal(t, time.Second, retryAfter)
+	})
+
+	t.Run("check request is allowed if limiter fails", func(t *testing.T) {
+		limiter.retryAfter, limiter.err = time.Second, errors.New("database is down")
+		defer func() { limiter.retryAfter, limiter.err = 0, nil }()
+
+		retryAfter, err := application.AllowRequest(ctx, "client", limit)
+		require.NoError(t, err)
+		require.Zero(t, retryAfter)
+	})
+}
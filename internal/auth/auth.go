@@ -0,0 +1,50 @@
+// Package auth generates API keys and decides what their roles allow.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+// keyPrefix makes API keys easy to tell apart from other secrets.
+const keyPrefix = "rot_"
+
+// keyBytes is amount of random bytes in API key.
+const keyBytes = 32
+
+// NewKey generates random API key.
+func NewKey() (string, error) {
+	buf := make([]byte, keyBytes)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Hash returns hex encoded SHA-256 of key. Keys are random
+// and long enough for plain hash to be safe to store.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckRole reports whether role is known.
+func CheckRole(role string) error {
+	switch role {
+	case types.RoleServe, types.RoleAnalyst, types.RoleAdmin:
+		return nil
+	}
+	return fmt.Errorf("%w %q", types.ErrUnknownRole, role)
+}
+
+// Allows reports whether key of role may call endpoint which requires required role.
+// Admin is allowed everything, other roles are allowed only their own endpoints.
+func Allows(role, required string) bool {
+	return role == types.RoleAdmin || role == required
+}
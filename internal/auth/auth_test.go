@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKey(t *testing.T) {
+	first, err := NewKey()
+	require.NoError(t, err)
+	second, err := NewKey()
+	require.NoError(t, err)
+
+	require.True(t, strings.HasPrefix(first, keyPrefix))
+	require.NotEqual(t, first, second)
+	require.NotEqual(t, Hash(first), Hash(second))
+	require.Equal(t, Hash(first), Hash(first))
+	require.Len(t, Hash(first), 64)
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		role     string
+		required string
+		allowed  bool
+	}{
+		{role: types.RoleServe, required: types.RoleServe, allowed: true},
+		{role: types.RoleServe, required: types.RoleAnalyst, allowed: false},
+		{role: types.RoleServe, required: types.RoleAdmin, allowed: false},
+		{role: types.RoleAnalyst, required: types.RoleServe, allowed: false},
+		{role: types.RoleAnalyst, required: types.RoleAnalyst, allowed: true},
+		{role: types.RoleAnalyst, required: types.RoleAdmin, allowed: false},
+		{role: types.RoleAdmin, required: types.RoleServe, allowed: true},
+		{role: types.RoleAdmin, required: types.RoleAnalyst, allowed: true},
+		{role: types.RoleAdmin, required: types.RoleAdmin, allowed: true},
+	}
+
+	for _, tc := range tests {
+		require.Equal(t, tc.allowed, Allows(tc.role, tc.required), "%s calls %s endpoint", tc.role, tc.required)
+	}
+
+	require.NoError(t, CheckRole(types.RoleAnalyst))
+	require.ErrorIs(t, CheckRole("root"), types.ErrUnknownRole)
+}
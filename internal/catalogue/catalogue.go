@@ -0,0 +1,392 @@
+// Package catalogue encodes catalogue of banners, slots, groups, group rules
+// and rotations as JSON or CSV and checks it before import.
+package catalogue
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Kinds of catalogue entries.
+const (
+	KindBanner   = "banner"
+	KindSlot     = "slot"
+	KindGroup    = "group"
+	KindRule     = "rule"
+	KindRotation = "rotation"
+)
+
+var ErrUnknownFormat = errors.New("unknown catalogue format")
+
+// csvHeader lists columns of CSV catalogue. Every entry is a row
+// and columns which make no sense for its kind are left empty.
+var csvHeader = []string{
+	"kind", "id", "description",
+	"banner_id", "slot_id", "group_id",
+	"shows", "clicks", "conversions", "revenue",
+	"cap_max_shows", "cap_window", "state", "pinned", "min_share_percent",
+	"sticky", "sticky_ttl", "holdout_percent",
+	"priority", "min_age", "max_age", "gender", "locale", "device", "attributes",
+}
+
+// Write encodes catalogue in format.
+func Write(w io.Writer, format string, catalogue types.Catalogue) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(catalogue)
+	case FormatCSV:
+		return writeCSV(w, catalogue)
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+}
+
+// Read decodes catalogue in format.
+func Read(r io.Reader, format string) (types.Catalogue, error) {
+	switch format {
+	case FormatJSON:
+		var catalogue types.Catalogue
+		err := json.NewDecoder(r).Decode(&catalogue)
+		return catalogue, err
+	case FormatCSV:
+		return readCSV(r)
+	default:
+		return types.Catalogue{}, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+}
+
+func writeCSV(w io.Writer, catalogue types.Catalogue) error {
+	writer := csv.NewWriter(w)
+
+	records := [][]string{csvHeader}
+	for _, banner := range catalogue.Banners {
+		records = append(records, record(KindBanner, banner.ID.String(), banner.Description))
+	}
+	for _, slot := range catalogue.Slots {
+		row := record(KindSlot, slot.ID.String(), slot.Description)
+		row[15] = strconv.FormatBool(slot.Settings.Sticky)
+		row[16] = formatDuration(slot.Settings.StickyTTL)
+		row[17] = formatFloat(slot.Settings.HoldoutPercent)
+		records = append(records, row)
+	}
+	for _, group := range catalogue.Groups {
+		records = append(records, record(KindGroup, group.ID.String(), group.Description))
+	}
+	for _, rule := range catalogue.Rules {
+		row := record(KindRule, "", "")
+		row[5] = rule.GroupID.String()
+		row[18] = strconv.Itoa(rule.Priority)
+		row[19] = strconv.Itoa(rule.MinAge)
+		row[20] = strconv.Itoa(rule.MaxAge)
+		row[21] = rule.Gender
+		row[22] = rule.Locale
+		row[23] = rule.Device
+		if len(rule.Attributes) > 0 {
+			attributes, err := json.Marshal(rule.Attributes)
+			if err != nil {
+				return err
+			}
+			row[24] = string(attributes)
+		}
+		records = append(records, row)
+	}
+	for _, rotation := range catalogue.Rotations {
+		row := record(KindRotation, "", "")
+		row[3] = rotation.BannerID.String()
+		row[4] = rotation.SlotID.String()
+		row[5] = rotation.GroupID.String()
+		row[6] = strconv.Itoa(rotation.Shows)
+		row[7] = strconv.Itoa(rotation.Clicks)
+		row[8] = strconv.Itoa(rotation.Conversions)
+		row[9] = formatFloat(rotation.Revenue)
+		row[10] = strconv.Itoa(rotation.Cap.MaxShows)
+		row[11] = formatDuration(rotation.Cap.Window)
+		row[12] = rotation.State
+		row[13] = strconv.FormatBool(rotation.Override.Pinned)
+		row[14] = formatFloat(rotation.Override.MinSharePercent)
+		records = append(records, row)
+	}
+
+	err := writer.WriteAll(records)
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+func record(kind, id, description string) []string {
+	row := make([]string, len(csvHeader))
+	row[0], row[1], row[2] = kind, id, description
+	return row
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatDuration(value time.Duration) string {
+	if value == 0 {
+		return ""
+	}
+	return value.String()
+}
+
+// csvRow parses fields of a CSV catalogue row and remembers the first error.
+type csvRow struct {
+	line   int
+	fields []string
+	err    error
+}
+
+func (r *csvRow) fail(column int, err error) {
+	if r.err == nil {
+		r.err = fmt.Errorf("line %d, column %s: %w", r.line, csvHeader[column], err)
+	}
+}
+
+func (r *csvRow) uuid(column int) uuid.UUID {
+	id, err := uuid.Parse(r.fields[column])
+	if err != nil {
+		r.fail(column, err)
+	}
+	return id
+}
+
+func (r *csvRow) int(column int) int {
+	if r.fields[column] == "" {
+		return 0
+	}
+	value, err := strconv.Atoi(r.fields[column])
+	if err != nil {
+		r.fail(column, err)
+	}
+	return value
+}
+
+func (r *csvRow) float(column int) float64 {
+	if r.fields[column] == "" {
+		return 0
+	}
+	value, err := strconv.ParseFloat(r.fields[column], 64)
+	if err != nil {
+		r.fail(column, err)
+	}
+	return value
+}
+
+func (r *csvRow) bool(column int) bool {
+	if r.fields[column] == "" {
+		return false
+	}
+	value, err := strconv.ParseBool(r.fields[column])
+	if err != nil {
+		r.fail(column, err)
+	}
+	return value
+}
+
+func (r *csvRow) duration(column int) time.Duration {
+	if r.fields[column] == "" {
+		return 0
+	}
+	value, err := time.ParseDuration(r.fields[column])
+	if err != nil {
+		r.fail(column, err)
+	}
+	return value
+}
+
+func (r *csvRow) attributes(column int) map[string]string {
+	if r.fields[column] == "" {
+		return nil
+	}
+	var value map[string]string
+	err := json.Unmarshal([]byte(r.fields[column]), &value)
+	if err != nil {
+		r.fail(column, err)
+	}
+	return value
+}
+
+func readCSV(r io.Reader) (types.Catalogue, error) {
+	var catalogue types.Catalogue
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(csvHeader)
+
+	header, err := reader.Read()
+	if err != nil {
+		return catalogue, err
+	}
+	for i, column := range header {
+		if column != csvHeader[i] {
+			return catalogue, fmt.Errorf("unexpected column %q, expected %q", column, csvHeader[i])
+		}
+	}
+
+	for line := 2; ; line++ {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return catalogue, nil
+		}
+		if err != nil {
+			return catalogue, err
+		}
+
+		row := &csvRow{line: line, fields: fields}
+		switch fields[0] {
+		case KindBanner:
+			catalogue.Banners = append(catalogue.Banners, types.Banner{
+				ID:          row.uuid(1),
+				Description: fields[2],
+			})
+		case KindSlot:
+			catalogue.Slots = append(catalogue.Slots, types.Slot{
+				ID:          row.uuid(1),
+				Description: fields[2],
+				Settings: types.SlotSettings{
+					Sticky:         row.bool(15),
+					StickyTTL:      row.duration(16),
+					HoldoutPercent: row.float(17),
+				},
+			})
+		case KindGroup:
+			catalogue.Groups = append(catalogue.Groups, types.Group{
+				ID:          row.uuid(1),
+				Description: fields[2],
+			})
+		case KindRule:
+			catalogue.Rules = append(catalogue.Rules, types.GroupRule{
+				GroupID:    row.uuid(5),
+				Priority:   row.int(18),
+				MinAge:     row.int(19),
+				MaxAge:     row.int(20),
+				Gender:     fields[21],
+				Locale:     fields[22],
+				Device:     fields[23],
+				Attributes: row.attributes(24),
+			})
+		case KindRotation:
+			catalogue.Rotations = append(catalogue.Rotations, types.Rotation{
+				BannerID:    row.uuid(3),
+				SlotID:      row.uuid(4),
+				GroupID:     row.uuid(5),
+				Shows:       row.int(6),
+				Clicks:      row.int(7),
+				Conversions: row.int(8),
+				Revenue:     row.float(9),
+				Cap: types.FrequencyCap{
+					MaxShows: row.int(10),
+					Window:   row.duration(11),
+				},
+				State: fields[12],
+				Override: types.RotationOverride{
+					Pinned:          row.bool(13),
+					MinSharePercent: row.float(14),
+				},
+			})
+		default:
+			return catalogue, fmt.Errorf("line %d: unknown kind %q", line, fields[0])
+		}
+
+		if row.err != nil {
+			return catalogue, row.err
+		}
+	}
+}
+
+// RotationID identifies rotation in conflicts.
+func RotationID(rotation types.Rotation) string {
+	return fmt.Sprintf("%s/%s/%s", rotation.BannerID, rotation.SlotID, rotation.GroupID)
+}
+
+// RuleID identifies group rule in conflicts by its group and position
+// in catalogue as rules get new ids on import.
+func RuleID(index int, rule types.GroupRule) string {
+	return fmt.Sprintf("%s/%d", rule.GroupID, index)
+}
+
+// Validate finds entries which conflict with each other or are malformed.
+// Conflicts with existing data are found by storage on import.
+func Validate(catalogue types.Catalogue) []types.CatalogueConflict {
+	var conflicts []types.CatalogueConflict
+	conflict := func(kind, id, reason string) {
+		conflicts = append(conflicts, types.CatalogueConflict{Kind: kind, ID: id, Reason: reason})
+	}
+
+	seen := make(map[uuid.UUID]string)
+	checkID := func(kind string, id uuid.UUID) {
+		if id == uuid.Nil {
+			conflict(kind, id.String(), "empty id")
+			return
+		}
+		if previous, ok := seen[id]; ok {
+			conflict(kind, id.String(), "id is already used by "+previous)
+			return
+		}
+		seen[id] = kind
+	}
+
+	for _, banner := range catalogue.Banners {
+		checkID(KindBanner, banner.ID)
+	}
+	for _, slot := range catalogue.Slots {
+		checkID(KindSlot, slot.ID)
+		if slot.Settings.HoldoutPercent < 0 || slot.Settings.HoldoutPercent > 100 {
+			conflict(KindSlot, slot.ID.String(), "holdout percent must be between 0 and 100")
+		}
+	}
+	for _, group := range catalogue.Groups {
+		checkID(KindGroup, group.ID)
+	}
+
+	for i, rule := range catalogue.Rules {
+		id := RuleID(i, rule)
+		switch {
+		case rule.GroupID == uuid.Nil:
+			conflict(KindRule, id, "empty group id")
+		case rule.MinAge < 0 || rule.MaxAge < 0:
+			conflict(KindRule, id, "age bounds must not be negative")
+		case rule.MaxAge != 0 && rule.MinAge > rule.MaxAge:
+			conflict(KindRule, id, "minimal age must not exceed maximal age")
+		}
+	}
+
+	rotations := make(map[string]bool)
+	for _, rotation := range catalogue.Rotations {
+		id := RotationID(rotation)
+		if rotations[id] {
+			conflict(KindRotation, id, "rotation is listed more than once")
+			continue
+		}
+		rotations[id] = true
+
+		switch {
+		case rotation.Shows < 0 || rotation.Clicks < 0 || rotation.Conversions < 0:
+			conflict(KindRotation, id, "counters must not be negative")
+		case rotation.State != "" &&
+			rotation.State != types.RotationStateActive &&
+			rotation.State != types.RotationStatePaused:
+			conflict(KindRotation, id, fmt.Sprintf("unknown state %q", rotation.State))
+		case rotation.Override.MinSharePercent < 0 || rotation.Override.MinSharePercent > 100:
+			conflict(KindRotation, id, "minimal share percent must be between 0 and 100")
+		}
+	}
+
+	return conflicts
+}
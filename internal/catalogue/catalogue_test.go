@@ -0,0 +1,111 @@
+package catalogue
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func testCatalogue() types.Catalogue {
+	banner := types.Banner{ID: uuid.New(), Description: "Summer sale, 50% off"}
+	slot := types.Slot{
+		ID:          uuid.New(),
+		Description: "Top",
+		Settings:    types.SlotSettings{Sticky: true, StickyTTL: time.Hour, HoldoutPercent: 5},
+	}
+	group := types.Group{ID: uuid.New(), Description: "Teenagers"}
+
+	return types.Catalogue{
+		Banners: []types.Banner{banner},
+		Slots:   []types.Slot{slot},
+		Groups:  []types.Group{group},
+		Rules: []types.GroupRule{{
+			GroupID:    group.ID,
+			Priority:   1,
+			MinAge:     13,
+			MaxAge:     19,
+			Locale:     "en",
+			Attributes: map[string]string{"plan": "free"},
+		}},
+		Rotations: []types.Rotation{{
+			BannerID:    banner.ID,
+			SlotID:      slot.ID,
+			GroupID:     group.ID,
+			Shows:       100,
+			Clicks:      7,
+			Conversions: 2,
+			Revenue:     19.5,
+			Cap:         types.FrequencyCap{MaxShows: 3, Window: 24 * time.Hour},
+			State:       types.RotationStatePaused,
+			Override:    types.RotationOverride{MinSharePercent: 10},
+		}},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatJSON, FormatCSV} {
+		format := format
+		t.Run("check "+format+" round trip", func(t *testing.T) {
+			catalogue := testCatalogue()
+
+			var buf bytes.Buffer
+			err := Write(&buf, format, catalogue)
+			require.NoError(t, err)
+
+			read, err := Read(&buf, format)
+			require.NoError(t, err)
+			require.Equal(t, catalogue, read)
+		})
+	}
+
+	t.Run("check unknown format", func(t *testing.T) {
+		err := Write(&bytes.Buffer{}, "xml", testCatalogue())
+		require.ErrorIs(t, err, ErrUnknownFormat)
+	})
+}
+
+func TestReadCSVErrors(t *testing.T) {
+	header := strings.Join(csvHeader, ",") + "\n"
+	empty := strings.Repeat(",", len(csvHeader)-3)
+
+	t.Run("check unknown kind", func(t *testing.T) {
+		_, err := Read(strings.NewReader(header+"advert,,"+empty+"\n"), FormatCSV)
+		require.EqualError(t, err, `line 2: unknown kind "advert"`)
+	})
+
+	t.Run("check malformed uuid", func(t *testing.T) {
+		_, err := Read(strings.NewReader(header+"banner,not-uuid,Sale"+empty+"\n"), FormatCSV)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "line 2, column id")
+	})
+
+	t.Run("check unexpected header", func(t *testing.T) {
+		_, err := Read(strings.NewReader("type"+header[4:]), FormatCSV)
+		require.Error(t, err)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	catalogue := testCatalogue()
+	require.Empty(t, Validate(catalogue))
+
+	catalogue.Groups = append(catalogue.Groups, types.Group{ID: catalogue.Banners[0].ID})
+	catalogue.Rotations = append(catalogue.Rotations, catalogue.Rotations[0])
+	catalogue.Slots[0].Settings.HoldoutPercent = 150
+	catalogue.Rules[0].MinAge = 30
+
+	conflicts := Validate(catalogue)
+	require.Len(t, conflicts, 4)
+	require.Equal(t, KindSlot, conflicts[0].Kind)
+	require.Equal(t, KindGroup, conflicts[1].Kind)
+	require.Equal(t, "id is already used by banner", conflicts[1].Reason)
+	require.Equal(t, KindRule, conflicts[2].Kind)
+	require.Equal(t, RuleID(0, catalogue.Rules[0]), conflicts[2].ID)
+	require.Equal(t, KindRotation, conflicts[3].Kind)
+	require.Equal(t, RotationID(catalogue.Rotations[0]), conflicts[3].ID)
+}
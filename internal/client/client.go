@@ -0,0 +1,326 @@
+// Package client is a Go client of the banner rotation HTTP API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/catalogue"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+// Error is returned when API responds with unsuccessful status.
+type Error struct {
+	StatusCode int
+	// Msg and Reason are filled from bad request response if API sent one.
+	Msg    string
+	Reason string
+	Body   string
+}
+
+func (e *Error) Error() string {
+	if e.Msg != "" {
+		return fmt.Sprintf("%d %s: %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Msg, e.Reason)
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), strings.TrimSpace(e.Body))
+}
+
+type Client struct {
+	addr       string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// New creates client of API served at addr, e.g. "http://localhost:8080".
+func New(addr string, timeout time.Duration) *Client {
+	return &Client{
+		addr:       strings.TrimRight(addr, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// SetAPIKey makes client authenticate its requests with key.
+func (c *Client) SetAPIKey(key string) {
+	c.apiKey = key
+}
+
+// do sends request with JSON body if it is not nil and decodes JSON response into result if it is not nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, result interface{}) error {
+	var (
+		reader      io.Reader
+		contentType string
+	)
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	data, err := c.send(ctx, method, path, query, contentType, reader)
+	if err != nil {
+		return err
+	}
+
+	if result == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
+
+// send sends request with body of contentType if it is not nil and returns response body.
+// Unsuccessful response status is returned as *Error.
+func (c *Client) send(
+	ctx context.Context,
+	method, path string,
+	query url.Values,
+	contentType string,
+	body io.Reader,
+) ([]byte, error) {
+	target := c.addr + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if c.apiKey != "" {
+		request.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		apiErr := &Error{StatusCode: response.StatusCode, Body: string(data)}
+		var badRequest struct {
+			Error string
+			Msg   string
+		}
+		if json.Unmarshal(data, &badRequest) == nil {
+			apiErr.Msg = badRequest.Msg
+			apiErr.Reason = badRequest.Error
+		}
+		return nil, apiErr
+	}
+
+	if response.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	return data, nil
+}
+
+type descriptionBody struct {
+	Description string `json:",omitempty"`
+}
+
+func (c *Client) AddBanner(ctx context.Context, description string) (types.Banner, error) {
+	var banner types.Banner
+	err := c.do(ctx, http.MethodPost, "/banners", nil, descriptionBody{description}, &banner)
+	return banner, err
+}
+
+func (c *Client) GetBanner(ctx context.Context, bannerID uuid.UUID) (types.Banner, error) {
+	var banner types.Banner
+	err := c.do(ctx, http.MethodGet, "/banners/"+bannerID.String(), nil, nil, &banner)
+	return banner, err
+}
+
+func (c *Client) ListBanners(ctx context.Context) ([]types.Banner, error) {
+	var banners []types.Banner
+	err := c.do(ctx, http.MethodGet, "/banners", nil, nil, &banners)
+	return banners, err
+}
+
+func (c *Client) DeleteBanner(ctx context.Context, bannerID uuid.UUID) error {
+	return c.do(ctx, http.MethodDelete, "/banners/"+bannerID.String(), nil, nil, nil)
+}
+
+func (c *Client) AddSlot(ctx context.Context, description string) (types.Slot, error) {
+	var slot types.Slot
+	err := c.do(ctx, http.MethodPost, "/slots", nil, descriptionBody{description}, &slot)
+	return slot, err
+}
+
+func (c *Client) GetSlot(ctx context.Context, slotID uuid.UUID) (types.Slot, error) {
+	var slot types.Slot
+	err := c.do(ctx, http.MethodGet, "/slots/"+slotID.String(), nil, nil, &slot)
+	return slot, err
+}
+
+func (c *Client) ListSlots(ctx context.Context) ([]types.Slot, error) {
+	var slots []types.Slot
+	err := c.do(ctx, http.MethodGet, "/slots", nil, nil, &slots)
+	return slots, err
+}
+
+func (c *Client) DeleteSlot(ctx context.Context, slotID uuid.UUID) error {
+	return c.do(ctx, http.MethodDelete, "/slots/"+slotID.String(), nil, nil, nil)
+}
+
+func (c *Client) AddGroup(ctx context.Context, description string) (types.Group, error) {
+	var group types.Group
+	err := c.do(ctx, http.MethodPost, "/groups", nil, descriptionBody{description}, &group)
+	return group, err
+}
+
+func (c *Client) GetGroup(ctx context.Context, groupID uuid.UUID) (types.Group, error) {
+	var group types.Group
+	err := c.do(ctx, http.MethodGet, "/groups/"+groupID.String(), nil, nil, &group)
+	return group, err
+}
+
+func (c *Client) ListGroups(ctx context.Context) ([]types.Group, error) {
+	var groups []types.Group
+	err := c.do(ctx, http.MethodGet, "/groups", nil, nil, &groups)
+	return groups, err
+}
+
+func (c *Client) DeleteGroup(ctx context.Context, groupID uuid.UUID) error {
+	return c.do(ctx, http.MethodDelete, "/groups/"+groupID.String(), nil, nil, nil)
+}
+
+func rotationPath(bannerID, slotID, groupID uuid.UUID) string {
+	return fmt.Sprintf("/group/%s/slots/%s/banners/%s", groupID, slotID, bannerID)
+}
+
+func (c *Client) AddRotation(ctx context.Context, bannerID, slotID, groupID uuid.UUID) (types.Rotation, error) {
+	var rotation types.Rotation
+	err := c.do(ctx, http.MethodPost, rotationPath(bannerID, slotID, groupID), nil, nil, &rotation)
+	return rotation, err
+}
+
+func (c *Client) GetRotation(ctx context.Context, bannerID, slotID, groupID uuid.UUID) (types.Rotation, error) {
+	var rotation types.Rotation
+	err := c.do(ctx, http.MethodGet, rotationPath(bannerID, slotID, groupID), nil, nil, &rotation)
+	return rotation, err
+}
+
+func (c *Client) ListRotations(ctx context.Context) ([]types.Rotation, error) {
+	var rotations []types.Rotation
+	err := c.do(ctx, http.MethodGet, "/rotations", nil, nil, &rotations)
+	return rotations, err
+}
+
+func (c *Client) DeleteRotation(ctx context.Context, bannerID, slotID, groupID uuid.UUID) error {
+	return c.do(ctx, http.MethodDelete, rotationPath(bannerID, slotID, groupID), nil, nil, nil)
+}
+
+// visitorQuery passes visitor identifier and attributes as query parameters.
+func visitorQuery(visitor types.Visitor) url.Values {
+	query := url.Values{}
+	for key, value := range visitor.Attributes {
+		query.Set(key, value)
+	}
+	if visitor.ID != "" {
+		query.Set("visitor_id", visitor.ID)
+	}
+	return query
+}
+
+func (c *Client) ChooseBanner(ctx context.Context, slotID, groupID uuid.UUID, visitor types.Visitor) (types.Impression, error) {
+	var impression types.Impression
+	path := fmt.Sprintf("/group/%s/slots/%s/banner", groupID, slotID)
+	err := c.do(ctx, http.MethodGet, path, visitorQuery(visitor), nil, &impression)
+	return impression, err
+}
+
+func (c *Client) RegisterClick(ctx context.Context, click types.Click) error {
+	query := visitorQuery(click.Visitor)
+	if click.ImpressionID != uuid.Nil {
+		query.Set("impression_id", click.ImpressionID.String())
+	}
+	path := rotationPath(click.BannerID, click.SlotID, click.GroupID) + "/click"
+	return c.do(ctx, http.MethodPost, path, query, nil, nil)
+}
+
+func (c *Client) GetStats(ctx context.Context, bannerID, slotID, groupID uuid.UUID) ([]types.Event, error) {
+	var events []types.Event
+	err := c.do(ctx, http.MethodGet, rotationPath(bannerID, slotID, groupID)+"/stats", nil, nil, &events)
+	return events, err
+}
+
+// ExportCatalogue returns catalogue encoded in format, either "json" or "csv".
+func (c *Client) ExportCatalogue(ctx context.Context, format string) ([]byte, error) {
+	return c.send(ctx, http.MethodGet, "/catalogue", url.Values{"format": {format}}, "", nil)
+}
+
+// ImportCatalogue imports catalogue encoded in format. When catalogue
+// conflicts with existing data report lists conflicts along with *Error.
+func (c *Client) ImportCatalogue(ctx context.Context, format string, data []byte, dryRun bool) (types.ImportReport, error) {
+	query := url.Values{"format": {format}}
+	if dryRun {
+		query.Set("dry_run", "true")
+	}
+
+	contentType := "application/json"
+	if format == catalogue.FormatCSV {
+		contentType = "text/csv"
+	}
+
+	var report types.ImportReport
+	response, err := c.send(ctx, http.MethodPost, "/catalogue", query, contentType, bytes.NewReader(data))
+	if err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+			// Conflicting entries are more useful than generic message
+			if json.Unmarshal([]byte(apiErr.Body), &report) == nil {
+				apiErr.Msg = "catalogue conflicts with existing data"
+				apiErr.Reason = fmt.Sprintf("%d conflicts", len(report.Conflicts))
+			}
+		}
+		return report, err
+	}
+
+	err = json.Unmarshal(response, &report)
+	return report, err
+}
+
+// CreateAPIKey creates key with role. Secret of the key is returned only once.
+func (c *Client) CreateAPIKey(ctx context.Context, name, role string) (types.APIKey, string, error) {
+	var created struct {
+		types.APIKey
+		Key string
+	}
+	body := struct {
+		Name string
+		Role string
+	}{Name: name, Role: role}
+	err := c.do(ctx, http.MethodPost, "/api-keys", nil, body, &created)
+	return created.APIKey, created.Key, err
+}
+
+func (c *Client) ListAPIKeys(ctx context.Context) ([]types.APIKey, error) {
+	var keys []types.APIKey
+	err := c.do(ctx, http.MethodGet, "/api-keys", nil, nil, &keys)
+	return keys, err
+}
+
+func (c *Client) RevokeAPIKey(ctx context.Context, keyID uuid.UUID) error {
+	return c.do(ctx, http.MethodDelete, "/api-keys/"+keyID.String(), nil, nil, nil)
+}
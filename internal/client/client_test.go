@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	bannerID, slotID, groupID := uuid.New(), uuid.New(), uuid.New()
+
+	var lastRequest *http.Request
+	var lastBody map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/banners", func(w http.ResponseWriter, r *http.Request) {
+		lastRequest = r
+		_ = json.NewDecoder(r.Body).Decode(&lastBody)
+		_ = json.NewEncoder(w).Encode(types.Banner{ID: bannerID, Description: "Summer sale"})
+	})
+	mux.HandleFunc("/group/", func(w http.ResponseWriter, r *http.Request) {
+		lastRequest = r
+		switch r.Method {
+		case http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]string{"Error": "no rotations available", "Msg": "no banner to show"})
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	mux.HandleFunc("/slots", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "db is down", http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/catalogue", func(w http.ResponseWriter, r *http.Request) {
+		lastRequest = r
+		w.WriteHeader(http.StatusConflict)
+		_ = json.NewEncoder(w).Encode(types.ImportReport{
+			Conflicts: []types.CatalogueConflict{{Kind: "banner", ID: bannerID.String(), Reason: "banner already exists"}},
+		})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := New(server.URL+"/", time.Second)
+
+	t.Run("check banner is created", func(t *testing.T) {
+		banner, err := c.AddBanner(ctx, "Summer sale")
+		require.NoError(t, err)
+		require.Equal(t, bannerID, banner.ID)
+		require.Equal(t, http.MethodPost, lastRequest.Method)
+		require.Equal(t, "Summer sale", lastBody["Description"])
+	})
+
+	t.Run("check API key is sent as bearer token", func(t *testing.T) {
+		keyed := New(server.URL, time.Second)
+		keyed.SetAPIKey("rot_secret")
+		_, err := keyed.AddBanner(ctx, "Summer sale")
+		require.NoError(t, err)
+		require.Equal(t, "Bearer rot_secret", lastRequest.Header.Get("Authorization"))
+
+		_, err = c.AddBanner(ctx, "Summer sale")
+		require.NoError(t, err)
+		require.Empty(t, lastRequest.Header.Get("Authorization"))
+	})
+
+	t.Run("check click is sent with impression and visitor", func(t *testing.T) {
+		impressionID := uuid.New()
+		err := c.RegisterClick(ctx, types.Click{
+			ImpressionID: impressionID,
+			BannerID:     bannerID,
+			SlotID:       slotID,
+			GroupID:      groupID,
+			Visitor:      types.Visitor{ID: "visitor"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, rotationPath(bannerID, slotID, groupID)+"/click", lastRequest.URL.Path)
+		require.Equal(t, impressionID.String(), lastRequest.URL.Query().Get("impression_id"))
+		require.Equal(t, "visitor", lastRequest.URL.Query().Get("visitor_id"))
+	})
+
+	t.Run("check bad request response becomes error", func(t *testing.T) {
+		_, err := c.ChooseBanner(ctx, slotID, groupID, types.Visitor{})
+		var apiErr *Error
+		require.True(t, errors.As(err, &apiErr))
+		require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		require.Equal(t, "no banner to show", apiErr.Msg)
+		require.Equal(t, "no rotations available", apiErr.Reason)
+	})
+
+	t.Run("check plain error response becomes error", func(t *testing.T) {
+		_, err := c.ListSlots(ctx)
+		var apiErr *Error
+		require.True(t, errors.As(err, &apiErr))
+		require.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+		require.Contains(t, apiErr.Error(), "db is down")
+	})
+
+	t.Run("check catalogue conflicts are returned with error", func(t *testing.T) {
+		report, err := c.ImportCatalogue(ctx, "csv", []byte("kind,id"), true)
+		var apiErr *Error
+		require.True(t, errors.As(err, &apiErr))
+		require.Equal(t, http.StatusConflict, apiErr.StatusCode)
+		require.Equal(t, "text/csv", lastRequest.Header.Get("Content-Type"))
+		require.Equal(t, "true", lastRequest.URL.Query().Get("dry_run"))
+		require.Len(t, report.Conflicts, 1)
+		require.Equal(t, bannerID.String(), report.Conflicts[0].ID)
+	})
+}
@@ -10,10 +10,98 @@ type Server struct {
 	Host    string
 	Port    string
 	Timeout string
+	// ShutdownTimeout limits time given to in-flight requests on shutdown.
+	ShutdownTimeout string `toml:"shutdown_timeout"`
+	// DisableAuth lets requests to API endpoints through without API key.
+	// Auth is enabled unless it is explicitly disabled.
+	DisableAuth bool      `toml:"disable_auth"`
+	RateLimit   RateLimit `toml:"rate_limit"`
+	Idempotency Idempotency
+	Admin       Admin
+}
+
+// Idempotency keeps responses to requests with Idempotency-Key header,
+// so that retries are answered without registering events again.
+type Idempotency struct {
+	// Backend is either "memory" or "postgres". Postgres backend shares keys
+	// between replicas.
+	Backend string
+	// TTL is how long responses are kept, "24h" by default.
+	TTL string `toml:"ttl"`
+}
+
+// RateLimit limits requests of every client identified by API key
+// or IP address if auth is disabled.
+type RateLimit struct {
+	// Backend is either "memory" or "postgres". Postgres backend shares limits
+	// between replicas.
+	Backend string
+	// ClientIPHeader is a header with client IP set by reverse proxy, e.g. "X-Real-IP".
+	// The last of comma separated addresses is used as it is appended by the proxy.
+	// Remote address of connection is used if it is empty.
+	ClientIPHeader string `toml:"client_ip_header"`
+	// Limits of banner choice, click and conversion registration and other endpoints.
+	Choose Limit
+	Click  Limit
+	Admin  Limit
+	// Auth limits requests of every IP address before API key is checked.
+	Auth Limit
+}
+
+// Limit allows Requests per duration Per on average and bursts of Burst requests.
+// Zero Requests disables the limit, zero Burst equals Requests.
+type Limit struct {
+	Requests int
+	Per      string
+	Burst    int
+}
+
+// Admin dashboard requires API key of admin role as basic auth password
+// if auth is enabled. Credentials below protect it only when auth is disabled,
+// in that case it is disabled unless both User and Password are set.
+type Admin struct {
+	User     string
+	Password string
 }
 
 type Storage struct {
 	DBConnectionString string `toml:"db_connection_string"`
+	// AutoMigrate applies pending migrations on startup.
+	AutoMigrate bool `toml:"auto_migrate"`
+}
+
+type Exposures struct {
+	// Backend is either "memory" or "postgres".
+	Backend string
+}
+
+// Clicks configures validation of clicks. Clicks which fail it are kept
+// apart and do not train rotators. Empty durations disable checks.
+type Clicks struct {
+	// RequireShow rejects clicks without prior show of the rotation.
+	RequireShow bool `toml:"require_show"`
+	// MinDelay rejects clicks made faster after the show, e.g. "500ms".
+	MinDelay string `toml:"min_delay"`
+	// DuplicateWindow rejects repeated clicks of impression or visitor during the window.
+	DuplicateWindow string `toml:"duplicate_window"`
+	// UserAgentDenylist lists substrings of bot user agents.
+	UserAgentDenylist []string `toml:"user_agent_denylist"`
+}
+
+type Rotator struct {
+	// Algorithm is one of "ucb1", "ucb1-conversions", "ucb1-revenue" or "linucb".
+	Algorithm string
+	// Alpha controls exploration of linucb algorithm.
+	Alpha float64
+}
+
+type Tracing struct {
+	// Exporter is one of "none", "stdout" or "otlp".
+	Exporter string
+	// Endpoint is host:port of OTLP HTTP receiver, "localhost:4318" by default.
+	Endpoint string
+	// Insecure sends spans to OTLP receiver without TLS.
+	Insecure bool
 }
 
 type Logger struct {
@@ -22,9 +110,13 @@ type Logger struct {
 }
 
 type Config struct {
-	Server  Server
-	Storage Storage
-	Log     Logger
+	Server    Server
+	Storage   Storage
+	Exposures Exposures
+	Clicks    Clicks
+	Rotator   Rotator
+	Tracing   Tracing
+	Log       Logger
 }
 
 func ReadConfig(configPath string) (Config, error) {
@@ -0,0 +1,168 @@
+package evaluation
+
+import (
+	"errors"
+	"math"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/features"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+var ErrNoShows = errors.New("no logged shows to evaluate on")
+
+// Evaluate estimates CTR which policy would have had on logged shows.
+//
+// Shows are processed in order. For every show policy is loaded with
+// rotation counters accumulated from previous logged shows and asked for
+// its choice, so policy learns from the same history the logging rotator had.
+//
+// Inverse propensity score (IPS) estimate weights clicks of shows where
+// policy agrees with the logged choice by inverse logged propensity.
+// Doubly robust (DR) estimate additionally uses per-banner CTR observed
+// in the log as a reward model, which lowers variance of the estimate.
+// Both are unbiased only if every banner policy may choose had non-zero
+// probability to be logged, which is not the case for deterministic rotators.
+func Evaluate(policy types.Rotator, events []types.RotationEvent) (types.PolicyEstimate, error) {
+	clicked := make(map[uuid.UUID]bool)
+	for _, e := range events {
+		if e.Type == types.EventTypeClick && e.ImpressionID != uuid.Nil {
+			clicked[e.ImpressionID] = true
+		}
+	}
+
+	var (
+		shows     = make(map[uuid.UUID]int)
+		clicks    = make(map[uuid.UUID]int)
+		rotations []types.Rotation
+	)
+	for _, e := range events {
+		if e.Type != types.EventTypeShow {
+			continue
+		}
+		if _, ok := shows[e.BannerID]; !ok {
+			rotations = append(rotations, types.Rotation{BannerID: e.BannerID, SlotID: e.SlotID, GroupID: e.GroupID})
+		}
+		shows[e.BannerID]++
+		if clicked[e.ImpressionID] {
+			clicks[e.BannerID]++
+		}
+	}
+
+	if len(rotations) == 0 {
+		return types.PolicyEstimate{}, ErrNoShows
+	}
+
+	// Reward model of doubly robust estimate
+	ctrs := make(map[uuid.UUID]float64, len(shows))
+	for bannerID, bannerShows := range shows {
+		ctrs[bannerID] = float64(clicks[bannerID]) / float64(bannerShows)
+	}
+
+	contextual, isContextual := policy.(types.ContextualRotator)
+	if isContextual {
+		// Visitor features are not logged, so contextual policy learns without them
+		_ = contextual.UnmarshalState(nil)
+	}
+	x := features.Bias()
+
+	var (
+		ips, dr      accumulator
+		matches      int
+		loggedClicks int
+		trials       int64
+	)
+	for _, e := range events {
+		if e.Type != types.EventTypeShow {
+			continue
+		}
+
+		logged := indexOf(rotations, e.BannerID)
+		reward := 0.0
+		if clicked[e.ImpressionID] {
+			reward = 1
+			loggedClicks++
+		}
+
+		propensity := e.Propensity
+		if propensity <= 0 {
+			propensity = 1
+		}
+
+		policy.Load(rotations, trials)
+		chosen := policy.Rotate().BannerID
+
+		var weighted float64
+		if chosen == e.BannerID {
+			matches++
+			weighted = reward / propensity
+			dr.add(ctrs[chosen] + (reward-ctrs[chosen])/propensity)
+		} else {
+			dr.add(ctrs[chosen])
+		}
+		ips.add(weighted)
+
+		trials++
+		rotations[logged].Shows++
+		if reward > 0 {
+			rotations[logged].Clicks++
+		}
+		if isContextual {
+			contextual.Observe(rotations[logged], x)
+			if reward > 0 {
+				contextual.Reward(rotations[logged], x, reward)
+			}
+		}
+	}
+
+	return types.PolicyEstimate{
+		Shows:     ips.n,
+		Matches:   matches,
+		LoggedCTR: float64(loggedClicks) / float64(ips.n),
+		IPS:       ips.mean(),
+		IPSStdErr: ips.stdErr(),
+		DR:        dr.mean(),
+		DRStdErr:  dr.stdErr(),
+	}, nil
+}
+
+// accumulator keeps running mean and variance of samples.
+type accumulator struct {
+	n    int
+	sum  float64
+	sum2 float64
+}
+
+func (a *accumulator) add(sample float64) {
+	a.n++
+	a.sum += sample
+	a.sum2 += sample * sample
+}
+
+func (a *accumulator) mean() float64 {
+	if a.n == 0 {
+		return 0
+	}
+	return a.sum / float64(a.n)
+}
+
+func (a *accumulator) stdErr() float64 {
+	if a.n < 2 {
+		return 0
+	}
+	mean := a.mean()
+	variance := (a.sum2 - float64(a.n)*mean*mean) / float64(a.n-1)
+	if variance < 0 {
+		variance = 0
+	}
+	return math.Sqrt(variance / float64(a.n))
+}
+
+func indexOf(rotations []types.Rotation, bannerID uuid.UUID) int {
+	for i, rotation := range rotations {
+		if rotation.BannerID == bannerID {
+			return i
+		}
+	}
+	return -1
+}
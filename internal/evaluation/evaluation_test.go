@@ -0,0 +1,103 @@
+package evaluation
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/rotators/mab"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedPolicy always chooses the same banner.
+type fixedPolicy struct {
+	bannerID  uuid.UUID
+	rotations []types.Rotation
+}
+
+func (p *fixedPolicy) Load(rotations []types.Rotation, _ int64) {
+	p.rotations = rotations
+}
+
+func (p *fixedPolicy) Rotate() types.Rotation {
+	for _, rotation := range p.rotations {
+		if rotation.BannerID == p.bannerID {
+			return rotation
+		}
+	}
+	return types.Rotation{}
+}
+
+// uniformLog logs shows of banners chosen uniformly at random along with their propensities.
+func uniformLog(ctrs []float64, steps int, seed int64) ([]uuid.UUID, []types.RotationEvent) {
+	rnd := rand.New(rand.NewSource(seed))
+	slotID, groupID := uuid.New(), uuid.New()
+	stamp := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	banners := make([]uuid.UUID, len(ctrs))
+	for i := range banners {
+		banners[i] = uuid.New()
+	}
+
+	var events []types.RotationEvent
+	for i := 0; i < steps; i++ {
+		arm := rnd.Intn(len(banners))
+		impressionID := uuid.New()
+		stamp = stamp.Add(time.Second)
+
+		events = append(events, types.RotationEvent{
+			BannerID: banners[arm],
+			SlotID:   slotID,
+			GroupID:  groupID,
+			Event: types.Event{
+				Type:         types.EventTypeShow,
+				Timestamp:    stamp,
+				ImpressionID: impressionID,
+				Propensity:   1 / float64(len(banners)),
+			},
+		})
+		if rnd.Float64() < ctrs[arm] {
+			events = append(events, types.RotationEvent{
+				BannerID: banners[arm],
+				SlotID:   slotID,
+				GroupID:  groupID,
+				Event:    types.Event{Type: types.EventTypeClick, Timestamp: stamp, ImpressionID: impressionID},
+			})
+		}
+	}
+	return banners, events
+}
+
+func TestEvaluate(t *testing.T) {
+	ctrs := []float64{0.01, 0.02, 0.03, 0.10}
+	banners, events := uniformLog(ctrs, 40000, 42)
+
+	t.Run("check estimates of fixed policy", func(t *testing.T) {
+		for i, bannerID := range banners {
+			estimate, err := Evaluate(&fixedPolicy{bannerID: bannerID}, events)
+			require.NoError(t, err)
+
+			require.Equal(t, 40000, estimate.Shows)
+			require.InDelta(t, 0.04, estimate.LoggedCTR, 0.005)
+			require.InDelta(t, ctrs[i], estimate.IPS, 3*estimate.IPSStdErr+0.001)
+			require.InDelta(t, ctrs[i], estimate.DR, 3*estimate.DRStdErr+0.001)
+			require.LessOrEqual(t, estimate.DRStdErr, estimate.IPSStdErr)
+		}
+	})
+
+	t.Run("check learning policy beats logging policy", func(t *testing.T) {
+		estimate, err := Evaluate(&mab.MultiArmedBandit{}, events)
+		require.NoError(t, err)
+
+		require.Greater(t, estimate.Matches, 0)
+		require.Greater(t, estimate.IPS, estimate.LoggedCTR)
+		require.Greater(t, estimate.DR, estimate.LoggedCTR)
+	})
+
+	t.Run("check log without shows", func(t *testing.T) {
+		_, err := Evaluate(&mab.MultiArmedBandit{}, nil)
+		require.ErrorIs(t, err, ErrNoShows)
+	})
+}
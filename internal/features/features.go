@@ -0,0 +1,91 @@
+package features
+
+import (
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+// Layout of the feature vector.
+const (
+	biasOffset      = 0
+	timeOffset      = biasOffset + 1
+	deviceOffset    = timeOffset + 2
+	attributeOffset = deviceOffset + len(devices) + 1
+
+	// AttributeBuckets is amount of buckets used for hashing arbitrary attributes.
+	AttributeBuckets = 8
+	// Dim is length of the feature vector.
+	Dim = attributeOffset + AttributeBuckets
+)
+
+var devices = [...]string{"desktop", "mobile", "tablet"}
+
+// Extract builds feature vector describing visitor at the moment now.
+// Vector consists of bias, time of day encoded as a point on a circle,
+// one-hot encoded device type and hashed visitor attributes.
+func Extract(visitor types.Visitor, now time.Time) []float64 {
+	features := make([]float64, Dim)
+	features[biasOffset] = 1
+
+	dayShare := (float64(now.Hour()) + float64(now.Minute())/60) / 24
+	features[timeOffset] = math.Sin(2 * math.Pi * dayShare)
+	features[timeOffset+1] = math.Cos(2 * math.Pi * dayShare)
+
+	features[deviceOffset+deviceIndex(visitor.Attributes[types.AttributeDevice])] = 1
+
+	var hashed int
+	for key, value := range visitor.Attributes {
+		if key == types.AttributeDevice {
+			continue
+		}
+		if key == types.AttributeAge {
+			value = ageBucket(value)
+		}
+
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(key + "=" + strings.ToLower(value)))
+		features[attributeOffset+int(h.Sum32()%AttributeBuckets)]++
+		hashed++
+	}
+
+	// Keep hashed part of unit length regardless of attributes amount
+	if hashed > 0 {
+		norm := 1 / math.Sqrt(float64(hashed))
+		for i := attributeOffset; i < Dim; i++ {
+			features[i] *= norm
+		}
+	}
+
+	return features
+}
+
+// Bias returns feature vector which carries no information about visitor.
+func Bias() []float64 {
+	features := make([]float64, Dim)
+	features[biasOffset] = 1
+	return features
+}
+
+func deviceIndex(device string) int {
+	for i, known := range devices {
+		if strings.EqualFold(device, known) {
+			return i
+		}
+	}
+	// Unknown device
+	return len(devices)
+}
+
+// ageBucket groups ages by decades so that close ages share the same feature.
+func ageBucket(value string) string {
+	age, err := strconv.Atoi(value)
+	if err != nil {
+		return value
+	}
+	return strconv.Itoa(age/10*10) + "s"
+}
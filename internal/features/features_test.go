@@ -0,0 +1,33 @@
+package features
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtract(t *testing.T) {
+	noon := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("check vector layout", func(t *testing.T) {
+		features := Extract(types.Visitor{}, noon)
+		require.Len(t, features, Dim)
+		require.Equal(t, 1.0, features[biasOffset])
+		require.InDelta(t, -1.0, features[timeOffset+1], 1e-9)
+		// Unknown device
+		require.Equal(t, 1.0, features[deviceOffset+len(devices)])
+	})
+
+	t.Run("check device is one-hot encoded", func(t *testing.T) {
+		features := Extract(types.Visitor{Attributes: map[string]string{"device": "Mobile"}}, noon)
+		require.Equal(t, []float64{0, 1, 0, 0}, features[deviceOffset:attributeOffset])
+	})
+
+	t.Run("check close ages share features", func(t *testing.T) {
+		young := Extract(types.Visitor{Attributes: map[string]string{"age": "21"}}, noon)
+		younger := Extract(types.Visitor{Attributes: map[string]string{"age": "24"}}, noon)
+		require.Equal(t, young, younger)
+	})
+}
@@ -70,3 +70,9 @@ func (l Logger) ChildLogger(name string) types.Logger {
 
 	return Logger{logger: log}
 }
+
+// CheckLevel returns error for unknown level. New ignores such level with a warning.
+func CheckLevel(level string) error {
+	_, err := zerolog.ParseLevel(strings.ToLower(level))
+	return err
+}
@@ -0,0 +1,112 @@
+// Package metrics defines Prometheus metrics of the service.
+// Names of metrics are part of the service API and listed in README.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "rotator"
+
+var (
+	// Registry holds all metrics of the service along with Go runtime and process ones.
+	Registry = prometheus.NewRegistry()
+
+	HTTPRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Amount of HTTP requests by method, route and status code.",
+		},
+		[]string{"method", "route", "code"},
+	)
+	HTTPRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time spent serving HTTP requests by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"method", "route"},
+	)
+
+	Shows = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "shows_total",
+			Help:      "Amount of registered banner shows by slot and group.",
+		},
+		[]string{"slot_id", "group_id"},
+	)
+	Clicks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "clicks_total",
+			Help:      "Amount of registered banner clicks by slot and group.",
+		},
+		[]string{"slot_id", "group_id"},
+	)
+
+	RejectedClicks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rejected_clicks_total",
+			Help:      "Amount of clicks which failed validation by rejection reason.",
+		},
+		[]string{"reason"},
+	)
+
+	DecisionDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "decision_duration_seconds",
+			Help:      "Time spent by rotator to choose rotation to show.",
+			Buckets:   []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1},
+		},
+	)
+
+	StorageQueryDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_query_duration_seconds",
+			Help:      "Time spent in storage calls by storage method.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"method"},
+	)
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		Shows,
+		Clicks,
+		RejectedClicks,
+		DecisionDuration,
+		StorageQueryDuration,
+	)
+}
+
+// Handler serves metrics of Registry in Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveDecision records time since begin as rotator decision latency.
+func ObserveDecision(begin time.Time) {
+	DecisionDuration.Observe(time.Since(begin).Seconds())
+}
+
+// RegisterDBStats exposes connection pool stats reported by stats.
+func RegisterDBStats(stats func() sql.DBStats) error {
+	return Registry.Register(newDBStatsCollector(stats))
+}
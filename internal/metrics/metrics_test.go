@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStorage struct {
+	types.Storager
+}
+
+func (fs fakeStorage) Ping(context.Context) error {
+	return nil
+}
+
+func TestInstrumentStorage(t *testing.T) {
+	store := InstrumentStorage(fakeStorage{})
+
+	before := testutil.CollectAndCount(StorageQueryDuration)
+	err := store.Ping(context.Background())
+	require.NoError(t, err)
+
+	require.Equal(t, before+1, testutil.CollectAndCount(StorageQueryDuration))
+	require.Contains(t, scrape(t), `rotator_storage_query_duration_seconds_count{method="Ping"} 1`)
+}
+
+func TestDBStatsCollector(t *testing.T) {
+	collector := newDBStatsCollector(func() sql.DBStats {
+		return sql.DBStats{
+			MaxOpenConnections: 10,
+			OpenConnections:    3,
+			InUse:              1,
+			Idle:               2,
+			WaitCount:          5,
+			WaitDuration:       1500 * time.Millisecond,
+		}
+	})
+
+	expected := `
+# HELP rotator_db_pool_open_connections Amount of established connections both in use and idle.
+# TYPE rotator_db_pool_open_connections gauge
+rotator_db_pool_open_connections 3
+# HELP rotator_db_pool_wait_duration_seconds_total Total time blocked waiting for a new connection.
+# TYPE rotator_db_pool_wait_duration_seconds_total counter
+rotator_db_pool_wait_duration_seconds_total 1.5
+`
+	err := testutil.CollectAndCompare(
+		collector,
+		strings.NewReader(expected),
+		"rotator_db_pool_open_connections",
+		"rotator_db_pool_wait_duration_seconds_total",
+	)
+	require.NoError(t, err)
+	require.Equal(t, 9, testutil.CollectAndCount(collector))
+}
+
+func scrape(t *testing.T) string {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	return recorder.Body.String()
+}
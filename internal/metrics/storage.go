@@ -0,0 +1,370 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/tracing"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentedStorage records latency of every storage call except
+// opening and closing connection and traces it as a child span.
+type instrumentedStorage struct {
+	types.Storager
+}
+
+// InstrumentStorage wraps storage to report latency of its methods.
+func InstrumentStorage(storage types.Storager) types.Storager {
+	return instrumentedStorage{Storager: storage}
+}
+
+// startQuery starts span of storage method.
+// Returned function ends the span and records latency of the call.
+func startQuery(ctx context.Context, method string) (context.Context, func()) {
+	begin := time.Now()
+	ctx, span := tracing.StartKind(ctx, "Storage."+method, trace.SpanKindClient)
+	return ctx, func() {
+		span.End()
+		StorageQueryDuration.WithLabelValues(method).Observe(time.Since(begin).Seconds())
+	}
+}
+
+func (is instrumentedStorage) Ping(ctx context.Context) error {
+	ctx, done := startQuery(ctx, "Ping")
+	defer done()
+	return is.Storager.Ping(ctx)
+}
+
+func (is instrumentedStorage) PendingMigrations(ctx context.Context) (int, error) {
+	ctx, done := startQuery(ctx, "PendingMigrations")
+	defer done()
+	return is.Storager.PendingMigrations(ctx)
+}
+
+func (is instrumentedStorage) AddBanner(ctx context.Context, banner types.Banner) error {
+	ctx, done := startQuery(ctx, "AddBanner")
+	defer done()
+	return is.Storager.AddBanner(ctx, banner)
+}
+
+func (is instrumentedStorage) GetBanner(ctx context.Context, bannerID uuid.UUID) (types.Banner, error) {
+	ctx, done := startQuery(ctx, "GetBanner")
+	defer done()
+	return is.Storager.GetBanner(ctx, bannerID)
+}
+
+func (is instrumentedStorage) DeleteBanner(ctx context.Context, bannerID uuid.UUID) error {
+	ctx, done := startQuery(ctx, "DeleteBanner")
+	defer done()
+	return is.Storager.DeleteBanner(ctx, bannerID)
+}
+
+func (is instrumentedStorage) SetBannerCampaign(ctx context.Context, bannerID, campaignID uuid.UUID) error {
+	ctx, done := startQuery(ctx, "SetBannerCampaign")
+	defer done()
+	return is.Storager.SetBannerCampaign(ctx, bannerID, campaignID)
+}
+
+func (is instrumentedStorage) GetAllBanners(ctx context.Context) ([]types.Banner, error) {
+	ctx, done := startQuery(ctx, "GetAllBanners")
+	defer done()
+	return is.Storager.GetAllBanners(ctx)
+}
+
+func (is instrumentedStorage) AddAdvertiser(ctx context.Context, advertiser types.Advertiser) error {
+	ctx, done := startQuery(ctx, "AddAdvertiser")
+	defer done()
+	return is.Storager.AddAdvertiser(ctx, advertiser)
+}
+
+func (is instrumentedStorage) GetAdvertiser(ctx context.Context, advertiserID uuid.UUID) (types.Advertiser, error) {
+	ctx, done := startQuery(ctx, "GetAdvertiser")
+	defer done()
+	return is.Storager.GetAdvertiser(ctx, advertiserID)
+}
+
+func (is instrumentedStorage) DeleteAdvertiser(ctx context.Context, advertiserID uuid.UUID) error {
+	ctx, done := startQuery(ctx, "DeleteAdvertiser")
+	defer done()
+	return is.Storager.DeleteAdvertiser(ctx, advertiserID)
+}
+
+func (is instrumentedStorage) AddCampaign(ctx context.Context, campaign types.Campaign) error {
+	ctx, done := startQuery(ctx, "AddCampaign")
+	defer done()
+	return is.Storager.AddCampaign(ctx, campaign)
+}
+
+func (is instrumentedStorage) GetCampaign(ctx context.Context, campaignID uuid.UUID) (types.Campaign, error) {
+	ctx, done := startQuery(ctx, "GetCampaign")
+	defer done()
+	return is.Storager.GetCampaign(ctx, campaignID)
+}
+
+func (is instrumentedStorage) DeleteCampaign(ctx context.Context, campaignID uuid.UUID) error {
+	ctx, done := startQuery(ctx, "DeleteCampaign")
+	defer done()
+	return is.Storager.DeleteCampaign(ctx, campaignID)
+}
+
+func (is instrumentedStorage) SetCampaignBudget(ctx context.Context, campaignID uuid.UUID, budget types.CampaignBudget) error {
+	ctx, done := startQuery(ctx, "SetCampaignBudget")
+	defer done()
+	return is.Storager.SetCampaignBudget(ctx, campaignID, budget)
+}
+
+func (is instrumentedStorage) GetCampaignConsumption(ctx context.Context, campaignID uuid.UUID) (types.CampaignConsumption, error) {
+	ctx, done := startQuery(ctx, "GetCampaignConsumption")
+	defer done()
+	return is.Storager.GetCampaignConsumption(ctx, campaignID)
+}
+
+func (is instrumentedStorage) GetCampaignsConsumption(
+	ctx context.Context,
+	campaignIDs []uuid.UUID,
+) ([]types.CampaignConsumption, error) {
+	ctx, done := startQuery(ctx, "GetCampaignsConsumption")
+	defer done()
+	return is.Storager.GetCampaignsConsumption(ctx, campaignIDs)
+}
+
+func (is instrumentedStorage) AddSlot(ctx context.Context, slot types.Slot) error {
+	ctx, done := startQuery(ctx, "AddSlot")
+	defer done()
+	return is.Storager.AddSlot(ctx, slot)
+}
+
+func (is instrumentedStorage) GetSlot(ctx context.Context, slotID uuid.UUID) (types.Slot, error) {
+	ctx, done := startQuery(ctx, "GetSlot")
+	defer done()
+	return is.Storager.GetSlot(ctx, slotID)
+}
+
+func (is instrumentedStorage) DeleteSlot(ctx context.Context, slotID uuid.UUID) error {
+	ctx, done := startQuery(ctx, "DeleteSlot")
+	defer done()
+	return is.Storager.DeleteSlot(ctx, slotID)
+}
+
+func (is instrumentedStorage) SetSlotSettings(ctx context.Context, slotID uuid.UUID, settings types.SlotSettings) error {
+	ctx, done := startQuery(ctx, "SetSlotSettings")
+	defer done()
+	return is.Storager.SetSlotSettings(ctx, slotID, settings)
+}
+
+func (is instrumentedStorage) GetAllSlots(ctx context.Context) ([]types.Slot, error) {
+	ctx, done := startQuery(ctx, "GetAllSlots")
+	defer done()
+	return is.Storager.GetAllSlots(ctx)
+}
+
+func (is instrumentedStorage) GetAssignment(ctx context.Context, visitorID string, slotID, groupID uuid.UUID) (uuid.UUID, error) {
+	ctx, done := startQuery(ctx, "GetAssignment")
+	defer done()
+	return is.Storager.GetAssignment(ctx, visitorID, slotID, groupID)
+}
+
+func (is instrumentedStorage) SaveAssignment(ctx context.Context, visitorID string, bannerID, slotID, groupID uuid.UUID, ttl time.Duration) error {
+	ctx, done := startQuery(ctx, "SaveAssignment")
+	defer done()
+	return is.Storager.SaveAssignment(ctx, visitorID, bannerID, slotID, groupID, ttl)
+}
+
+func (is instrumentedStorage) AddGroup(ctx context.Context, group types.Group) error {
+	ctx, done := startQuery(ctx, "AddGroup")
+	defer done()
+	return is.Storager.AddGroup(ctx, group)
+}
+
+func (is instrumentedStorage) GetGroup(ctx context.Context, groupID uuid.UUID) (types.Group, error) {
+	ctx, done := startQuery(ctx, "GetGroup")
+	defer done()
+	return is.Storager.GetGroup(ctx, groupID)
+}
+
+func (is instrumentedStorage) DeleteGroup(ctx context.Context, groupID uuid.UUID) error {
+	ctx, done := startQuery(ctx, "DeleteGroup")
+	defer done()
+	return is.Storager.DeleteGroup(ctx, groupID)
+}
+
+func (is instrumentedStorage) GetAllGroups(ctx context.Context) ([]types.Group, error) {
+	ctx, done := startQuery(ctx, "GetAllGroups")
+	defer done()
+	return is.Storager.GetAllGroups(ctx)
+}
+
+func (is instrumentedStorage) AddGroupRule(ctx context.Context, rule types.GroupRule) (types.GroupRule, error) {
+	ctx, done := startQuery(ctx, "AddGroupRule")
+	defer done()
+	return is.Storager.AddGroupRule(ctx, rule)
+}
+
+func (is instrumentedStorage) GetGroupRules(ctx context.Context, groupID uuid.UUID) ([]types.GroupRule, error) {
+	ctx, done := startQuery(ctx, "GetGroupRules")
+	defer done()
+	return is.Storager.GetGroupRules(ctx, groupID)
+}
+
+func (is instrumentedStorage) GetAllGroupRules(ctx context.Context) ([]types.GroupRule, error) {
+	ctx, done := startQuery(ctx, "GetAllGroupRules")
+	defer done()
+	return is.Storager.GetAllGroupRules(ctx)
+}
+
+func (is instrumentedStorage) DeleteGroupRule(ctx context.Context, groupID uuid.UUID, ruleID int) error {
+	ctx, done := startQuery(ctx, "DeleteGroupRule")
+	defer done()
+	return is.Storager.DeleteGroupRule(ctx, groupID, ruleID)
+}
+
+func (is instrumentedStorage) AddRotation(ctx context.Context, bannerID, slotID, groupID uuid.UUID) (types.Rotation, error) {
+	ctx, done := startQuery(ctx, "AddRotation")
+	defer done()
+	return is.Storager.AddRotation(ctx, bannerID, slotID, groupID)
+}
+
+func (is instrumentedStorage) DeleteRotation(ctx context.Context, bannerID, slotID, groupID uuid.UUID) error {
+	ctx, done := startQuery(ctx, "DeleteRotation")
+	defer done()
+	return is.Storager.DeleteRotation(ctx, bannerID, slotID, groupID)
+}
+
+func (is instrumentedStorage) GetRotation(ctx context.Context, bannerID, slotID, groupID uuid.UUID) (types.Rotation, error) {
+	ctx, done := startQuery(ctx, "GetRotation")
+	defer done()
+	return is.Storager.GetRotation(ctx, bannerID, slotID, groupID)
+}
+
+func (is instrumentedStorage) SetFrequencyCap(ctx context.Context, bannerID, slotID, groupID uuid.UUID, frequencyCap types.FrequencyCap) error {
+	ctx, done := startQuery(ctx, "SetFrequencyCap")
+	defer done()
+	return is.Storager.SetFrequencyCap(ctx, bannerID, slotID, groupID, frequencyCap)
+}
+
+func (is instrumentedStorage) AddShow(ctx context.Context, impression types.Impression) error {
+	ctx, done := startQuery(ctx, "AddShow")
+	defer done()
+	return is.Storager.AddShow(ctx, impression)
+}
+
+func (is instrumentedStorage) AddShows(ctx context.Context, impressions []types.Impression) error {
+	ctx, done := startQuery(ctx, "AddShows")
+	defer done()
+	return is.Storager.AddShows(ctx, impressions)
+}
+
+func (is instrumentedStorage) AddClick(ctx context.Context, click types.Click, duplicateWindow time.Duration) error {
+	ctx, done := startQuery(ctx, "AddClick")
+	defer done()
+	return is.Storager.AddClick(ctx, click, duplicateWindow)
+}
+
+func (is instrumentedStorage) AddConversion(ctx context.Context, conversion types.Conversion) error {
+	ctx, done := startQuery(ctx, "AddConversion")
+	defer done()
+	return is.Storager.AddConversion(ctx, conversion)
+}
+
+func (is instrumentedStorage) SinceLastEvent(ctx context.Context, click types.Click, eventType string) (time.Duration, error) {
+	ctx, done := startQuery(ctx, "SinceLastEvent")
+	defer done()
+	return is.Storager.SinceLastEvent(ctx, click, eventType)
+}
+
+func (is instrumentedStorage) AddRejectedClick(ctx context.Context, click types.Click, reason string) error {
+	ctx, done := startQuery(ctx, "AddRejectedClick")
+	defer done()
+	return is.Storager.AddRejectedClick(ctx, click, reason)
+}
+
+func (is instrumentedStorage) GetAllRotations(ctx context.Context) ([]types.Rotation, error) {
+	ctx, done := startQuery(ctx, "GetAllRotations")
+	defer done()
+	return is.Storager.GetAllRotations(ctx)
+}
+
+func (is instrumentedStorage) GetRotationStats(ctx context.Context, bannerID, slotID, groupID uuid.UUID) ([]types.Event, error) {
+	ctx, done := startQuery(ctx, "GetRotationStats")
+	defer done()
+	return is.Storager.GetRotationStats(ctx, bannerID, slotID, groupID)
+}
+
+func (is instrumentedStorage) GetEvents(ctx context.Context, slotID, groupID uuid.UUID, from, to time.Time) ([]types.RotationEvent, error) {
+	ctx, done := startQuery(ctx, "GetEvents")
+	defer done()
+	return is.Storager.GetEvents(ctx, slotID, groupID, from, to)
+}
+
+func (is instrumentedStorage) SetRotationState(ctx context.Context, bannerID, slotID, groupID uuid.UUID, state string) error {
+	ctx, done := startQuery(ctx, "SetRotationState")
+	defer done()
+	return is.Storager.SetRotationState(ctx, bannerID, slotID, groupID, state)
+}
+
+func (is instrumentedStorage) SetRotationOverride(ctx context.Context, bannerID, slotID, groupID uuid.UUID, override types.RotationOverride) error {
+	ctx, done := startQuery(ctx, "SetRotationOverride")
+	defer done()
+	return is.Storager.SetRotationOverride(ctx, bannerID, slotID, groupID, override)
+}
+
+func (is instrumentedStorage) GetArmStats(ctx context.Context, slotID uuid.UUID, from, to time.Time) ([]types.ArmStats, error) {
+	ctx, done := startQuery(ctx, "GetArmStats")
+	defer done()
+	return is.Storager.GetArmStats(ctx, slotID, from, to)
+}
+
+func (is instrumentedStorage) ImportCatalogue(ctx context.Context, catalogue types.Catalogue, dryRun bool) (types.ImportReport, error) {
+	ctx, done := startQuery(ctx, "ImportCatalogue")
+	defer done()
+	return is.Storager.ImportCatalogue(ctx, catalogue, dryRun)
+}
+
+func (is instrumentedStorage) GetTotalShows(ctx context.Context) (int64, error) {
+	ctx, done := startQuery(ctx, "GetTotalShows")
+	defer done()
+	return is.Storager.GetTotalShows(ctx)
+}
+
+func (is instrumentedStorage) GetRotatorState(ctx context.Context, slotID, groupID uuid.UUID) ([]byte, error) {
+	ctx, done := startQuery(ctx, "GetRotatorState")
+	defer done()
+	return is.Storager.GetRotatorState(ctx, slotID, groupID)
+}
+
+func (is instrumentedStorage) UpdateRotatorState(
+	ctx context.Context,
+	slotID, groupID uuid.UUID,
+	update func(state []byte) ([]byte, error),
+) error {
+	ctx, done := startQuery(ctx, "UpdateRotatorState")
+	defer done()
+	return is.Storager.UpdateRotatorState(ctx, slotID, groupID, update)
+}
+
+func (is instrumentedStorage) AddAPIKey(ctx context.Context, key types.APIKey, keyHash string) error {
+	ctx, done := startQuery(ctx, "AddAPIKey")
+	defer done()
+	return is.Storager.AddAPIKey(ctx, key, keyHash)
+}
+
+func (is instrumentedStorage) GetAPIKeyByHash(ctx context.Context, keyHash string) (types.APIKey, error) {
+	ctx, done := startQuery(ctx, "GetAPIKeyByHash")
+	defer done()
+	return is.Storager.GetAPIKeyByHash(ctx, keyHash)
+}
+
+func (is instrumentedStorage) GetAllAPIKeys(ctx context.Context) ([]types.APIKey, error) {
+	ctx, done := startQuery(ctx, "GetAllAPIKeys")
+	defer done()
+	return is.Storager.GetAllAPIKeys(ctx)
+}
+
+func (is instrumentedStorage) DeleteAPIKey(ctx context.Context, keyID uuid.UUID) error {
+	ctx, done := startQuery(ctx, "DeleteAPIKey")
+	defer done()
+	return is.Storager.DeleteAPIKey(ctx, keyID)
+}
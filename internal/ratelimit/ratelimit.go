@@ -0,0 +1,30 @@
+// Package ratelimit implements token bucket shared by rate limiter backends.
+package ratelimit
+
+import (
+	"math"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+// Take refills bucket holding tokens with tokens earned during elapsed time
+// and takes one token from it. It returns tokens left in the bucket and zero
+// retryAfter if token was taken, otherwise time until the next token.
+func Take(tokens float64, elapsed time.Duration, limit types.RateLimit) (left float64, retryAfter time.Duration) {
+	if elapsed > 0 {
+		tokens += elapsed.Seconds() * limit.Rate
+	}
+	tokens = math.Min(tokens, float64(limit.Burst))
+
+	if tokens >= 1 {
+		return tokens - 1, 0
+	}
+	return tokens, time.Duration(math.Ceil((1 - tokens) / limit.Rate * float64(time.Second)))
+}
+
+// FullAfter returns time it takes to refill empty bucket. Bucket which was not
+// used for that long is the same as a new one and may be forgotten.
+func FullAfter(limit types.RateLimit) time.Duration {
+	return time.Duration(float64(limit.Burst) / limit.Rate * float64(time.Second))
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTake(t *testing.T) {
+	limit := types.RateLimit{Rate: 2, Burst: 3}
+
+	t.Run("check full bucket allows burst", func(t *testing.T) {
+		tokens := float64(limit.Burst)
+		for i := 0; i < limit.Burst; i++ {
+			var retryAfter time.Duration
+			tokens, retryAfter = Take(tokens, 0, limit)
+			require.Zero(t, retryAfter)
+		}
+
+		left, retryAfter := Take(tokens, 0, limit)
+		require.Equal(t, tokens, left)
+		require.Equal(t, 500*time.Millisecond, retryAfter)
+	})
+
+	t.Run("check bucket is refilled with time", func(t *testing.T) {
+		left, retryAfter := Take(0, 250*time.Millisecond, limit)
+		require.InDelta(t, 0.5, left, 1e-9)
+		require.Equal(t, 250*time.Millisecond, retryAfter)
+
+		left, retryAfter = Take(0, time.Second, limit)
+		require.InDelta(t, 1, left, 1e-9)
+		require.Zero(t, retryAfter)
+	})
+
+	t.Run("check bucket is not refilled over burst", func(t *testing.T) {
+		left, retryAfter := Take(0, time.Hour, limit)
+		require.InDelta(t, 2, left, 1e-9)
+		require.Zero(t, retryAfter)
+	})
+
+	t.Run("check clock going back does not take tokens", func(t *testing.T) {
+		left, retryAfter := Take(1, -time.Second, limit)
+		require.Zero(t, left)
+		require.Zero(t, retryAfter)
+	})
+
+	t.Run("check bucket is full after burst over rate", func(t *testing.T) {
+		require.Equal(t, 1500*time.Millisecond, FullAfter(limit))
+	})
+}
@@ -0,0 +1,183 @@
+package linucb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/features"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+// LinUCB is a contextual bandit with disjoint linear models.
+// Each banner has its own ridge regression of reward on features.
+// Banner with the highest upper confidence bound of expected reward is chosen.
+// See http://rob.schapire.net/papers/www10.pdf for details.
+type LinUCB struct {
+	// Alpha controls exploration. Bigger values lead to more exploration.
+	Alpha float64
+	Dim   int
+
+	arms      map[uuid.UUID]*arm
+	rotations []types.Rotation
+	trials    int64
+}
+
+type arm struct {
+	// AInv is inverse of features covariance matrix plus identity.
+	// It is kept inverted to make updates and predictions cheap.
+	AInv [][]float64
+	// B is sum of features weighted by rewards.
+	B []float64
+}
+
+type state struct {
+	Dim  int
+	Arms map[uuid.UUID]*arm
+}
+
+func New(alpha float64, dim int) *LinUCB {
+	return &LinUCB{
+		Alpha: alpha,
+		Dim:   dim,
+		arms:  make(map[uuid.UUID]*arm),
+	}
+}
+
+func newArm(dim int) *arm {
+	aInv := make([][]float64, dim)
+	for i := range aInv {
+		aInv[i] = make([]float64, dim)
+		aInv[i][i] = 1
+	}
+	return &arm{AInv: aInv, B: make([]float64, dim)}
+}
+
+// Rotator implementation.
+func (l *LinUCB) Load(rotations []types.Rotation, trials int64) {
+	l.rotations = rotations
+	l.trials = trials
+}
+
+// Rotate chooses rotation without any knowledge about visitor.
+func (l *LinUCB) Rotate() types.Rotation {
+	return l.RotateContext(features.Bias())
+}
+
+// Scores returns scores of rotations without any knowledge about visitor.
+func (l *LinUCB) Scores() []types.RotationScore {
+	return l.ScoresContext(features.Bias())
+}
+
+// ContextualRotator implementation.
+func (l *LinUCB) RotateContext(x []float64) types.Rotation {
+	var (
+		maxBound       = math.Inf(-1)
+		rotationToShow types.Rotation
+	)
+
+	for _, score := range l.ScoresContext(x) {
+		if score.Score > maxBound {
+			maxBound = score.Score
+			rotationToShow = score.Rotation
+		}
+	}
+
+	return rotationToShow
+}
+
+// ScoresContext returns upper confidence bounds of expected reward for visitor with features x.
+func (l *LinUCB) ScoresContext(x []float64) []types.RotationScore {
+	scores := make([]types.RotationScore, 0, len(l.rotations))
+	for _, rotation := range l.rotations {
+		mean, bonus := l.upperBound(l.arm(rotation.BannerID), x)
+		scores = append(scores, types.RotationScore{
+			Rotation: rotation,
+			Mean:     mean,
+			Bonus:    bonus,
+			Score:    mean + bonus,
+		})
+	}
+	return scores
+}
+
+// Observe accounts show of rotation to visitor with features x.
+func (l *LinUCB) Observe(rotation types.Rotation, x []float64) {
+	a := l.arm(rotation.BannerID)
+
+	// Sherman-Morrison update of AInv for A = A + x * x^T
+	aInvX := mulVec(a.AInv, x)
+	denominator := 1 + dot(x, aInvX)
+	for i := range a.AInv {
+		for j := range a.AInv[i] {
+			a.AInv[i][j] -= aInvX[i] * aInvX[j] / denominator
+		}
+	}
+}
+
+// Reward accounts reward received after show of rotation to visitor with features x.
+func (l *LinUCB) Reward(rotation types.Rotation, x []float64, reward float64) {
+	a := l.arm(rotation.BannerID)
+	for i := range a.B {
+		a.B[i] += reward * x[i]
+	}
+}
+
+func (l *LinUCB) MarshalState() ([]byte, error) {
+	return json.Marshal(state{Dim: l.Dim, Arms: l.arms})
+}
+
+func (l *LinUCB) UnmarshalState(data []byte) error {
+	l.arms = make(map[uuid.UUID]*arm)
+	if len(data) == 0 {
+		return nil
+	}
+
+	var s state
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+
+	if s.Dim != l.Dim {
+		return fmt.Errorf("model dimension %d differs from expected %d", s.Dim, l.Dim)
+	}
+
+	if s.Arms != nil {
+		l.arms = s.Arms
+	}
+	return nil
+}
+
+func (l *LinUCB) arm(bannerID uuid.UUID) *arm {
+	a, ok := l.arms[bannerID]
+	if !ok {
+		a = newArm(l.Dim)
+		l.arms[bannerID] = a
+	}
+	return a
+}
+
+// upperBound returns expected reward for features x and its confidence bonus.
+func (l *LinUCB) upperBound(a *arm, x []float64) (mean, bonus float64) {
+	theta := mulVec(a.AInv, a.B)
+	aInvX := mulVec(a.AInv, x)
+	return dot(theta, x), l.Alpha * math.Sqrt(math.Max(dot(x, aInvX), 0))
+}
+
+func mulVec(m [][]float64, v []float64) []float64 {
+	result := make([]float64, len(m))
+	for i, row := range m {
+		result[i] = dot(row, v)
+	}
+	return result
+}
+
+func dot(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
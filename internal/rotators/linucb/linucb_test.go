@@ -0,0 +1,81 @@
+package linucb
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/features"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinUCB(t *testing.T) {
+	slotID, groupID := uuid.New(), uuid.New()
+	mobileBanner := types.Rotation{BannerID: uuid.New(), SlotID: slotID, GroupID: groupID}
+	desktopBanner := types.Rotation{BannerID: uuid.New(), SlotID: slotID, GroupID: groupID}
+	rotations := []types.Rotation{mobileBanner, desktopBanner}
+
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	mobile := features.Extract(types.Visitor{Attributes: map[string]string{"device": "mobile"}}, now)
+	desktop := features.Extract(types.Visitor{Attributes: map[string]string{"device": "desktop"}}, now)
+
+	// Mobile visitors click only mobile banner and desktop visitors click only desktop banner
+	clickProbability := func(rotation types.Rotation, isMobile bool) float64 {
+		if (rotation.BannerID == mobileBanner.BannerID) == isMobile {
+			return 0.3
+		}
+		return 0.01
+	}
+
+	rotator := New(1, features.Dim)
+	rotator.Load(rotations, 0)
+	rnd := rand.New(rand.NewSource(42))
+
+	for i := 0; i < 3000; i++ {
+		isMobile := i%2 == 0
+		x := desktop
+		if isMobile {
+			x = mobile
+		}
+
+		rotation := rotator.RotateContext(x)
+		rotator.Observe(rotation, x)
+		if rnd.Float64() < clickProbability(rotation, isMobile) {
+			rotator.Reward(rotation, x, 1)
+		}
+	}
+
+	t.Run("check banners are chosen by context", func(t *testing.T) {
+		require.Equal(t, mobileBanner, rotator.RotateContext(mobile))
+		require.Equal(t, desktopBanner, rotator.RotateContext(desktop))
+	})
+
+	t.Run("check state survives restart", func(t *testing.T) {
+		state, err := rotator.MarshalState()
+		require.NoError(t, err)
+
+		restored := New(1, features.Dim)
+		err = restored.UnmarshalState(state)
+		require.NoError(t, err)
+		restored.Load(rotations, 0)
+
+		require.Equal(t, mobileBanner, restored.RotateContext(mobile))
+		require.Equal(t, desktopBanner, restored.RotateContext(desktop))
+	})
+
+	t.Run("check state of other dimension is rejected", func(t *testing.T) {
+		state, err := rotator.MarshalState()
+		require.NoError(t, err)
+
+		err = New(1, features.Dim+1).UnmarshalState(state)
+		require.Error(t, err)
+	})
+
+	t.Run("check new banner is explored", func(t *testing.T) {
+		newBanner := types.Rotation{BannerID: uuid.New(), SlotID: slotID, GroupID: groupID}
+		rotator.Load(append(rotations, newBanner), 0)
+		require.Equal(t, newBanner, rotator.RotateContext(mobile))
+	})
+}
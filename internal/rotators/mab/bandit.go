@@ -15,23 +15,47 @@ func (mab *MultiArmedBandit) Rotate() (rotation types.Rotation) {
 	return UCB1(mab.BannersDatas, mab.Trials)
 }
 
+func (mab *MultiArmedBandit) Scores() []types.RotationScore {
+	return UCB1Scores(mab.BannersDatas, mab.Trials)
+}
+
 func (mab *MultiArmedBandit) Load(rotations []types.Rotation, trials int64) {
 	mab.Trials = trials
 	mab.BannersDatas = rotations
 }
 
 func UCB1(rotations []types.Rotation, trials int64) types.Rotation {
+	return Best(UCB1Scores(rotations, trials))
+}
+
+// UCB1Scores returns upper confidence bounds of rotations CTR.
+func UCB1Scores(rotations []types.Rotation, trials int64) []types.RotationScore {
+	scores := make([]types.RotationScore, 0, len(rotations))
+	for _, rotation := range rotations {
+		meanClicks := float64(rotation.Clicks) / float64(rotation.Shows+1)
+		bonus := math.Sqrt(2 * math.Log(float64(trials+1)) / float64(rotation.Shows+1))
+		scores = append(scores, types.RotationScore{
+			Rotation: rotation,
+			Mean:     meanClicks,
+			Bonus:    bonus,
+			Score:    meanClicks + bonus,
+		})
+	}
+	return scores
+}
+
+// Best returns rotation with the highest score.
+// The last one wins if there are several of them.
+func Best(scores []types.RotationScore) types.Rotation {
 	var (
-		maxConfidence  float64
+		maxScore       float64
 		rotationToShow types.Rotation
 	)
 
-	for _, rotation := range rotations {
-		meanClicks := float64(rotation.Clicks) / float64(rotation.Shows+1)
-		confidence := meanClicks + math.Sqrt(2*math.Log(float64(trials+1))/float64(rotation.Shows+1))
-		if confidence >= maxConfidence {
-			maxConfidence = confidence
-			rotationToShow = rotation
+	for _, score := range scores {
+		if score.Score >= maxScore {
+			maxScore = score.Score
+			rotationToShow = score.Rotation
 		}
 	}
 
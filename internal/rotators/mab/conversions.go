@@ -0,0 +1,85 @@
+package mab
+
+import (
+	"math"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+// RewardFunc returns total reward collected by rotation.
+type RewardFunc func(rotation types.Rotation) float64
+
+func ConversionsReward(rotation types.Rotation) float64 {
+	return float64(rotation.Conversions)
+}
+
+func RevenueReward(rotation types.Rotation) float64 {
+	return rotation.Revenue
+}
+
+// ConversionBandit optimises expected conversions per show
+// or, if ByValue is set, expected conversion value per show.
+type ConversionBandit struct {
+	BannersDatas []types.Rotation
+	Trials       int64
+	ByValue      bool
+}
+
+func (cb *ConversionBandit) Rotate() types.Rotation {
+	return Best(cb.Scores())
+}
+
+func (cb *ConversionBandit) Scores() []types.RotationScore {
+	if cb.ByValue {
+		return UCB1RewardScores(cb.BannersDatas, cb.Trials, RevenueReward, MaxConversionValue(cb.BannersDatas))
+	}
+	return UCB1RewardScores(cb.BannersDatas, cb.Trials, ConversionsReward, 1)
+}
+
+func (cb *ConversionBandit) Load(rotations []types.Rotation, trials int64) {
+	cb.Trials = trials
+	cb.BannersDatas = rotations
+}
+
+// UCB1Reward is UCB1 for an arbitrary non-negative reward.
+// UCB1 expects reward of a single show to be in [0, 1], so rewards are divided by maxReward.
+func UCB1Reward(rotations []types.Rotation, trials int64, reward RewardFunc, maxReward float64) types.Rotation {
+	return Best(UCB1RewardScores(rotations, trials, reward, maxReward))
+}
+
+// UCB1RewardScores returns upper confidence bounds of rotations normalised reward per show.
+func UCB1RewardScores(
+	rotations []types.Rotation,
+	trials int64,
+	reward RewardFunc,
+	maxReward float64,
+) []types.RotationScore {
+	if maxReward <= 0 {
+		maxReward = 1
+	}
+
+	scores := make([]types.RotationScore, 0, len(rotations))
+	for _, rotation := range rotations {
+		meanReward := reward(rotation) / maxReward / float64(rotation.Shows+1)
+		bonus := math.Sqrt(2 * math.Log(float64(trials+1)) / float64(rotation.Shows+1))
+		scores = append(scores, types.RotationScore{
+			Rotation: rotation,
+			Mean:     meanReward,
+			Bonus:    bonus,
+			Score:    meanReward + bonus,
+		})
+	}
+	return scores
+}
+
+// MaxConversionValue returns the biggest average conversion value among rotations.
+func MaxConversionValue(rotations []types.Rotation) float64 {
+	var maxValue float64
+	for _, rotation := range rotations {
+		if rotation.Conversions == 0 {
+			continue
+		}
+		maxValue = math.Max(maxValue, rotation.Revenue/float64(rotation.Conversions))
+	}
+	return maxValue
+}
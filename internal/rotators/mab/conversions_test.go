@@ -0,0 +1,34 @@
+package mab
+
+import (
+	"testing"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConversionBandit(t *testing.T) {
+	// Banner with many cheap conversions and banner with a few expensive ones.
+	// Both have the same amount of shows and the same CTR.
+	cheap := types.Rotation{BannerID: uuid.New(), Shows: 1000, Clicks: 100, Conversions: 50, Revenue: 50}
+	expensive := types.Rotation{BannerID: uuid.New(), Shows: 1000, Clicks: 100, Conversions: 10, Revenue: 500}
+	rotations := []types.Rotation{cheap, expensive}
+
+	t.Run("check conversions are optimised", func(t *testing.T) {
+		bandit := &ConversionBandit{}
+		bandit.Load(rotations, 2000)
+		require.Equal(t, cheap.BannerID, bandit.Rotate().BannerID)
+	})
+
+	t.Run("check conversion value is optimised", func(t *testing.T) {
+		bandit := &ConversionBandit{ByValue: true}
+		bandit.Load(rotations, 2000)
+		require.Equal(t, expensive.BannerID, bandit.Rotate().BannerID)
+	})
+
+	t.Run("check max conversion value", func(t *testing.T) {
+		require.Equal(t, 50.0, MaxConversionValue(rotations))
+		require.Zero(t, MaxConversionValue([]types.Rotation{{Shows: 10}}))
+	})
+}
@@ -0,0 +1,39 @@
+package rotators
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/features"
+	"github.com/FedoseevAlex/banner-rotation/internal/rotators/linucb"
+	"github.com/FedoseevAlex/banner-rotation/internal/rotators/mab"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+const defaultAlpha = 1.0
+
+var ErrUnknownAlgorithm = errors.New("unknown rotator algorithm")
+
+// Algorithms lists names of all supported rotator algorithms.
+var Algorithms = []string{"ucb1", "ucb1-conversions", "ucb1-revenue", "linucb"}
+
+// New creates rotator using algorithm from config.
+func New(cfg config.Rotator) (types.Rotator, error) {
+	switch cfg.Algorithm {
+	case "", "ucb1":
+		return &mab.MultiArmedBandit{}, nil
+	case "ucb1-conversions":
+		return &mab.ConversionBandit{}, nil
+	case "ucb1-revenue":
+		return &mab.ConversionBandit{ByValue: true}, nil
+	case "linucb":
+		alpha := cfg.Alpha
+		if alpha <= 0 {
+			alpha = defaultAlpha
+		}
+		return linucb.New(alpha, features.Dim), nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownAlgorithm, cfg.Algorithm)
+	}
+}
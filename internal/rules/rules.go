@@ -0,0 +1,91 @@
+package rules
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+// Resolve returns the first rule matching visitor attributes.
+// Rules are checked in ascending priority order, rules with equal priority are checked in ID order.
+func Resolve(groupRules []types.GroupRule, attributes map[string]string) (types.GroupRule, bool) {
+	sorted := make([]types.GroupRule, len(groupRules))
+	copy(sorted, groupRules)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority < sorted[j].Priority
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	for _, rule := range sorted {
+		if Match(rule, attributes) {
+			return rule, true
+		}
+	}
+
+	return types.GroupRule{}, false
+}
+
+// Match checks whether visitor attributes satisfy all rule conditions.
+func Match(rule types.GroupRule, attributes map[string]string) bool {
+	if !matchAge(rule, attributes[types.AttributeAge]) {
+		return false
+	}
+
+	if rule.Gender != "" && !strings.EqualFold(rule.Gender, attributes[types.AttributeGender]) {
+		return false
+	}
+
+	if rule.Device != "" && !strings.EqualFold(rule.Device, attributes[types.AttributeDevice]) {
+		return false
+	}
+
+	if rule.Locale != "" && !matchLocale(rule.Locale, attributes[types.AttributeLocale]) {
+		return false
+	}
+
+	for key, value := range rule.Attributes {
+		if attributes[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchAge(rule types.GroupRule, value string) bool {
+	if rule.MinAge == 0 && rule.MaxAge == 0 {
+		return true
+	}
+
+	age, err := strconv.Atoi(value)
+	if err != nil {
+		return false
+	}
+
+	if rule.MinAge != 0 && age < rule.MinAge {
+		return false
+	}
+
+	if rule.MaxAge != 0 && age > rule.MaxAge {
+		return false
+	}
+
+	return true
+}
+
+// matchLocale compares locales ignoring case and treating "-" and "_" as the same separator.
+// Rule without region matches any region of the language.
+func matchLocale(ruleLocale, visitorLocale string) bool {
+	normalize := func(locale string) string {
+		return strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	}
+
+	ruleLocale = normalize(ruleLocale)
+	visitorLocale = normalize(visitorLocale)
+
+	return visitorLocale == ruleLocale || strings.HasPrefix(visitorLocale, ruleLocale+"-")
+}
@@ -0,0 +1,104 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		rule       types.GroupRule
+		attributes map[string]string
+		match      bool
+	}{
+		{
+			name:       "empty rule matches anyone",
+			rule:       types.GroupRule{},
+			attributes: map[string]string{},
+			match:      true,
+		},
+		{
+			name:       "age inside range",
+			rule:       types.GroupRule{MinAge: 18, MaxAge: 25},
+			attributes: map[string]string{"age": "18"},
+			match:      true,
+		},
+		{
+			name:       "age outside range",
+			rule:       types.GroupRule{MinAge: 18, MaxAge: 25},
+			attributes: map[string]string{"age": "26"},
+			match:      false,
+		},
+		{
+			name:       "age is required by range",
+			rule:       types.GroupRule{MinAge: 18},
+			attributes: map[string]string{},
+			match:      false,
+		},
+		{
+			name:       "gender ignores case",
+			rule:       types.GroupRule{Gender: "female"},
+			attributes: map[string]string{"gender": "Female"},
+			match:      true,
+		},
+		{
+			name:       "language matches regional locale",
+			rule:       types.GroupRule{Locale: "en"},
+			attributes: map[string]string{"locale": "en_US"},
+			match:      true,
+		},
+		{
+			name:       "language does not match other language",
+			rule:       types.GroupRule{Locale: "en"},
+			attributes: map[string]string{"locale": "es-ES"},
+			match:      false,
+		},
+		{
+			name:       "device mismatch",
+			rule:       types.GroupRule{Device: "mobile"},
+			attributes: map[string]string{"device": "desktop"},
+			match:      false,
+		},
+		{
+			name:       "all custom attributes are required",
+			rule:       types.GroupRule{Attributes: map[string]string{"interest": "sport", "city": "Moscow"}},
+			attributes: map[string]string{"interest": "sport"},
+			match:      false,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.match, Match(tc.rule, tc.attributes))
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	teenagers := types.GroupRule{ID: 1, GroupID: uuid.New(), Priority: 10, MaxAge: 19}
+	mobile := types.GroupRule{ID: 2, GroupID: uuid.New(), Priority: 20, Device: "mobile"}
+	everyone := types.GroupRule{ID: 3, GroupID: uuid.New(), Priority: 100}
+	groupRules := []types.GroupRule{everyone, mobile, teenagers}
+
+	t.Run("check rule with lower priority value wins", func(t *testing.T) {
+		rule, ok := Resolve(groupRules, map[string]string{"age": "16", "device": "mobile"})
+		require.True(t, ok)
+		require.Equal(t, teenagers.GroupID, rule.GroupID)
+	})
+
+	t.Run("check fallback rule", func(t *testing.T) {
+		rule, ok := Resolve(groupRules, map[string]string{"age": "40", "device": "desktop"})
+		require.True(t, ok)
+		require.Equal(t, everyone.GroupID, rule.GroupID)
+	})
+
+	t.Run("check no match", func(t *testing.T) {
+		_, ok := Resolve([]types.GroupRule{teenagers}, map[string]string{"age": "40"})
+		require.False(t, ok)
+	})
+}
@@ -0,0 +1,477 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+//go:embed admin
+var adminFiles embed.FS
+
+var adminTemplates = template.Must(
+	template.New("admin").
+		Funcs(template.FuncMap{
+			"percent": func(value float64) string {
+				return fmt.Sprintf("%.2f%%", 100*value)
+			},
+		}).
+		ParseFS(adminFiles, "admin/*.html"),
+)
+
+// adminChartDays is amount of days shown on rotation CTR chart.
+const adminChartDays = 30
+
+// registerAdmin adds admin dashboard routes. Dashboard is built on
+// application methods. If auth is enabled, it requires API key of admin role
+// sent as password of HTTP basic auth. Otherwise it is protected by HTTP basic
+// auth with credentials from config and is disabled if they are not set.
+func (s *Server) registerAdmin(mux *httprouter.Router, cfg config.Admin, logger types.Logger) {
+	guard := func(handler httprouter.Handle) httprouter.Handle {
+		return s.authorize(types.RoleAdmin, handler)
+	}
+	if !s.authEnabled {
+		if cfg.User == "" || cfg.Password == "" {
+			return
+		}
+		guard = func(handler httprouter.Handle) httprouter.Handle {
+			return basicAuth(handler, cfg)
+		}
+	}
+
+	handle := func(method, path string, handler httprouter.Handle) {
+		if method != http.MethodGet {
+			handler = sameOrigin(handler)
+		}
+		mux.Handle(method, path, loggingMiddleware(path, guard(s.rateLimit(limitAdmin, handler)), logger))
+	}
+
+	static, err := fs.Sub(adminFiles, "admin")
+	if err != nil {
+		panic(err)
+	}
+	fileServer := http.StripPrefix("/admin/static/", http.FileServer(http.FS(static)))
+
+	handle(http.MethodGet, "/admin", s.adminIndexHandler)
+	handle(http.MethodGet, "/admin/static/*filepath", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		fileServer.ServeHTTP(w, r)
+	})
+	handle(http.MethodGet, "/admin/rotation", s.adminRotationHandler)
+
+	handle(http.MethodPost, "/admin/banners", s.adminCreateHandler(func(ctx context.Context, description string) error {
+		_, err := s.app.AddBanner(ctx, description)
+		return err
+	}))
+	handle(http.MethodPost, "/admin/slots", s.adminCreateHandler(func(ctx context.Context, description string) error {
+		_, err := s.app.AddSlot(ctx, description)
+		return err
+	}))
+	handle(http.MethodPost, "/admin/groups", s.adminCreateHandler(func(ctx context.Context, description string) error {
+		_, err := s.app.AddGroup(ctx, description)
+		return err
+	}))
+	handle(http.MethodPost, "/admin/banners/:banner_id/delete", s.adminDeleteHandler("banner_id", s.app.DeleteBanner))
+	handle(http.MethodPost, "/admin/slots/:slot_id/delete", s.adminDeleteHandler("slot_id", s.app.DeleteSlot))
+	handle(http.MethodPost, "/admin/groups/:group_id/delete", s.adminDeleteHandler("group_id", s.app.DeleteGroup))
+
+	handle(http.MethodPost, "/admin/rotations", s.adminRotationFormHandler(func(ctx context.Context, b, sl, g uuid.UUID) error {
+		_, err := s.app.AddRotation(ctx, b, sl, g)
+		return err
+	}))
+	handle(http.MethodPost, "/admin/rotations/delete", s.adminRotationFormHandler(s.app.DeleteRotation))
+}
+
+// basicAuth lets request through only with credentials of admin.
+func basicAuth(next httprouter.Handle, cfg config.Admin) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		user, password, ok := r.BasicAuth()
+		userMatches := subtle.ConstantTimeCompare([]byte(user), []byte(cfg.User)) == 1
+		passwordMatches := subtle.ConstantTimeCompare([]byte(password), []byte(cfg.Password)) == 1
+		if !ok || !userMatches || !passwordMatches {
+			w.Header().Set("WWW-Authenticate", `Basic realm="banner rotation admin"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next(w, r, params)
+	}
+}
+
+// sameOrigin rejects requests sent from other sites as browsers
+// attach basic auth credentials to them as well.
+func sameOrigin(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			origin = r.Header.Get("Referer")
+		}
+		if origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || u.Host != r.Host {
+				http.Error(w, "cross-origin request", http.StatusForbidden)
+				return
+			}
+		}
+		next(w, r, params)
+	}
+}
+
+func renderAdmin(w http.ResponseWriter, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := adminTemplates.ExecuteTemplate(w, name, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// adminRotation is rotation along with descriptions of its banner, slot and group.
+type adminRotation struct {
+	types.Rotation
+	Banner string
+	Slot   string
+	Group  string
+	CTR    float64
+	// BarPercent is CTR relative to the best rotation CTR
+	BarPercent float64
+}
+
+type adminIndex struct {
+	Banners   []types.Banner
+	Slots     []types.Slot
+	Groups    []types.Group
+	Rotations []adminRotation
+}
+
+func (s *Server) adminIndexHandler(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	var (
+		page adminIndex
+		err  error
+	)
+
+	page.Banners, err = s.app.ListBanners(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page.Slots, err = s.app.ListSlots(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page.Groups, err = s.app.ListGroups(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rotations, err := s.app.ListRotations(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	page.Rotations = describeRotations(rotations, page.Banners, page.Slots, page.Groups)
+	renderAdmin(w, "index.html", page)
+}
+
+// describeRotations adds descriptions and CTR to rotations
+// and orders them by group, slot and banner.
+func describeRotations(
+	rotations []types.Rotation,
+	banners []types.Banner,
+	slots []types.Slot,
+	groups []types.Group,
+) []adminRotation {
+	descriptions := make(map[uuid.UUID]string, len(banners)+len(slots)+len(groups))
+	for _, banner := range banners {
+		descriptions[banner.ID] = banner.Description
+	}
+	for _, slot := range slots {
+		descriptions[slot.ID] = slot.Description
+	}
+	for _, group := range groups {
+		descriptions[group.ID] = group.Description
+	}
+
+	var maxCTR float64
+	described := make([]adminRotation, 0, len(rotations))
+	for _, rotation := range rotations {
+		r := adminRotation{
+			Rotation: rotation,
+			Banner:   descriptions[rotation.BannerID],
+			Slot:     descriptions[rotation.SlotID],
+			Group:    descriptions[rotation.GroupID],
+			CTR:      ctr(rotation.Shows, rotation.Clicks),
+		}
+		if r.CTR > maxCTR {
+			maxCTR = r.CTR
+		}
+		described = append(described, r)
+	}
+
+	for i := range described {
+		if maxCTR > 0 {
+			described[i].BarPercent = 100 * described[i].CTR / maxCTR
+		}
+	}
+
+	sort.SliceStable(described, func(i, j int) bool {
+		a, b := described[i], described[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Slot != b.Slot {
+			return a.Slot < b.Slot
+		}
+		return a.Banner < b.Banner
+	})
+
+	return described
+}
+
+func ctr(shows, clicks int) float64 {
+	if shows == 0 {
+		return 0
+	}
+	return float64(clicks) / float64(shows)
+}
+
+// ctrDay is amount of shows and clicks of rotation during a day.
+type ctrDay struct {
+	Day    time.Time
+	Shows  int
+	Clicks int
+	CTR    float64
+}
+
+// dailyCTR counts shows and clicks of the last days up to now.
+func dailyCTR(events []types.Event, days int, now time.Time) []ctrDay {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	first := today.AddDate(0, 0, 1-days)
+
+	result := make([]ctrDay, days)
+	for i := range result {
+		result[i].Day = first.AddDate(0, 0, i)
+	}
+
+	for _, event := range events {
+		stamp := event.Timestamp.In(now.Location())
+		if stamp.Before(first) {
+			continue
+		}
+		day := int(stamp.Sub(first).Hours() / 24)
+		if day >= days {
+			continue
+		}
+
+		switch event.Type {
+		case types.EventTypeShow:
+			result[day].Shows++
+		case types.EventTypeClick:
+			result[day].Clicks++
+		}
+	}
+
+	for i := range result {
+		result[i].CTR = ctr(result[i].Shows, result[i].Clicks)
+	}
+	return result
+}
+
+type chartBar struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+	Title  string
+}
+
+type ctrChart struct {
+	Width  float64
+	Height float64
+	Bars   []chartBar
+}
+
+// newCTRChart draws a bar per day with height proportional to its CTR.
+func newCTRChart(days []ctrDay, width, height float64) ctrChart {
+	chart := ctrChart{Width: width, Height: height}
+	if len(days) == 0 {
+		return chart
+	}
+
+	var maxCTR float64
+	for _, day := range days {
+		if day.CTR > maxCTR {
+			maxCTR = day.CTR
+		}
+	}
+
+	barWidth := width / float64(len(days))
+	for i, day := range days {
+		var barHeight float64
+		if maxCTR > 0 {
+			barHeight = height * day.CTR / maxCTR
+		}
+		chart.Bars = append(chart.Bars, chartBar{
+			X:      float64(i)*barWidth + 1,
+			Y:      height - barHeight,
+			Width:  barWidth - 2,
+			Height: barHeight,
+			Title:  fmt.Sprintf("%s: %.2f%%", day.Day.Format("2006-01-02"), 100*day.CTR),
+		})
+	}
+	return chart
+}
+
+type adminRotationPage struct {
+	Rotation adminRotation
+	Days     []ctrDay
+	Chart    ctrChart
+}
+
+func (s *Server) adminRotationHandler(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+	bannerID, slotID, groupID, err := rotationIDs(request.URL.Query().Get)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	rotation, err := s.app.GetRotation(ctx, bannerID, slotID, groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	banner, err := s.app.GetBanner(ctx, bannerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slot, err := s.app.GetSlot(ctx, slotID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	group, err := s.app.GetGroup(ctx, groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	events, err := s.app.GetStats(ctx, bannerID, slotID, groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	described := describeRotations(
+		[]types.Rotation{rotation},
+		[]types.Banner{banner},
+		[]types.Slot{slot},
+		[]types.Group{group},
+	)
+	days := dailyCTR(events, adminChartDays, time.Now())
+
+	renderAdmin(w, "rotation.html", adminRotationPage{
+		Rotation: described[0],
+		Days:     days,
+		Chart:    newCTRChart(days, 600, 200),
+	})
+}
+
+// rotationIDs parses banner, slot and group uuids from form or query values.
+func rotationIDs(get func(key string) string) (bannerID, slotID, groupID uuid.UUID, err error) {
+	bannerID, err = uuid.Parse(get("banner_id"))
+	if err != nil {
+		return bannerID, slotID, groupID, fmt.Errorf("failed to parse banner uuid: %w", err)
+	}
+	slotID, err = uuid.Parse(get("slot_id"))
+	if err != nil {
+		return bannerID, slotID, groupID, fmt.Errorf("failed to parse slot uuid: %w", err)
+	}
+	groupID, err = uuid.Parse(get("group_id"))
+	if err != nil {
+		return bannerID, slotID, groupID, fmt.Errorf("failed to parse group uuid: %w", err)
+	}
+	return bannerID, slotID, groupID, nil
+}
+
+func redirectToAdmin(w http.ResponseWriter, request *http.Request) {
+	http.Redirect(w, request, "/admin", http.StatusSeeOther)
+}
+
+func (s *Server) adminCreateHandler(create func(ctx context.Context, description string) error) httprouter.Handle {
+	return func(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+		ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+		defer cancel()
+
+		err := create(ctx, request.PostFormValue("description"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		redirectToAdmin(w, request)
+	}
+}
+
+func (s *Server) adminDeleteHandler(param string, remove func(ctx context.Context, id uuid.UUID) error) httprouter.Handle {
+	return func(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		id, err := uuid.Parse(params.ByName(param))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+		defer cancel()
+
+		err = remove(ctx, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		redirectToAdmin(w, request)
+	}
+}
+
+func (s *Server) adminRotationFormHandler(
+	apply func(ctx context.Context, bannerID, slotID, groupID uuid.UUID) error,
+) httprouter.Handle {
+	return func(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+		err := request.ParseForm()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		bannerID, slotID, groupID, err := rotationIDs(request.PostForm.Get)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+		defer cancel()
+
+		err = apply(ctx, bannerID, slotID, groupID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		redirectToAdmin(w, request)
+	}
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/logger"
+	"github.com/FedoseevAlex/banner-rotation/internal/storage/memory"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeApp keeps banners in memory. Calling methods it does not
+// implement panics, so unexpected application calls fail the test.
+type fakeApp struct {
+	types.Application
+	banners   []types.Banner
+	readiness types.Readiness
+	// API keys by secret
+	keys        map[string]types.APIKey
+	limiter     types.RateLimiter
+	idempotency types.IdempotencyStorer
+	// clicks is updated atomically as requests are served concurrently
+	clicks int32
+}
+
+func (fa *fakeApp) GetLogger(string) types.Logger {
+	return logger.New("error", os.DevNull)
+}
+
+func (fa *fakeApp) AddBanner(_ context.Context, description string) (types.Banner, error) {
+	banner := types.Banner{ID: uuid.New(), Description: description}
+	fa.banners = append(fa.banners, banner)
+	return banner, nil
+}
+
+func (fa *fakeApp) ListBanners(context.Context) ([]types.Banner, error) {
+	return fa.banners, nil
+}
+
+func (fa *fakeApp) ListSlots(context.Context) ([]types.Slot, error) {
+	return nil, nil
+}
+
+func (fa *fakeApp) ListGroups(context.Context) ([]types.Group, error) {
+	return nil, nil
+}
+
+func (fa *fakeApp) ListRotations(context.Context) ([]types.Rotation, error) {
+	return nil, nil
+}
+
+func newTestServer(t *testing.T, application types.Application, admin config.Admin) http.Handler {
+	t.Helper()
+
+	srv, err := NewServer(application, config.Server{Timeout: "1s", DisableAuth: true, Admin: admin})
+	require.NoError(t, err)
+	return srv.httpServer.Handler
+}
+
+func TestAdmin(t *testing.T) {
+	application := &fakeApp{}
+	admin := config.Admin{User: "admin", Password: "secret"}
+	handler := newTestServer(t, application, admin)
+
+	t.Run("check credentials are required", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+		request.SetBasicAuth("admin", "wrong")
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("check banner is created and listed", func(t *testing.T) {
+		form := url.Values{"description": {"Summer sale"}}
+		request := httptest.NewRequest(http.MethodPost, "/admin/banners", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.SetBasicAuth(admin.User, admin.Password)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusSeeOther, recorder.Code)
+		require.Len(t, application.banners, 1)
+
+		request = httptest.NewRequest(http.MethodGet, "/admin", nil)
+		request.SetBasicAuth(admin.User, admin.Password)
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Contains(t, recorder.Body.String(), "Summer sale")
+	})
+
+	t.Run("check cross-origin form is rejected", func(t *testing.T) {
+		form := url.Values{"description": {"Spam"}}
+		request := httptest.NewRequest(http.MethodPost, "/admin/banners", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Origin", "http://evil.example.com")
+		request.SetBasicAuth(admin.User, admin.Password)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusForbidden, recorder.Code)
+		require.Len(t, application.banners, 1)
+	})
+
+	t.Run("check static files are served", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/admin/static/style.css", nil)
+		request.SetBasicAuth(admin.User, admin.Password)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("check dashboard is disabled without credentials", func(t *testing.T) {
+		handler := newTestServer(t, application, config.Admin{})
+		request := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+}
+
+func TestAdminWithAPIKeys(t *testing.T) {
+	application := &fakeApp{
+		keys: map[string]types.APIKey{
+			"analyst-key": {Name: "reports", Role: types.RoleAnalyst},
+			"admin-key":   {Name: "ops", Role: types.RoleAdmin},
+		},
+	}
+	// Credentials from config are ignored once API keys are required
+	srv, err := NewServer(application, config.Server{
+		Timeout: "1s",
+		Admin:   config.Admin{User: "admin", Password: "secret"},
+	})
+	require.NoError(t, err)
+	handler := srv.httpServer.Handler
+
+	for _, tc := range []struct {
+		name     string
+		password string
+		status   int
+	}{
+		{name: "no key", status: http.StatusUnauthorized},
+		{name: "config password", password: "secret", status: http.StatusUnauthorized},
+		{name: "analyst key", password: "analyst-key", status: http.StatusForbidden},
+		{name: "admin key", password: "admin-key", status: http.StatusOK},
+	} {
+		tc := tc
+		t.Run("check dashboard with "+tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tc.password != "" {
+				request.SetBasicAuth("admin", tc.password)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			require.Equal(t, tc.status, recorder.Code)
+			if tc.status == http.StatusUnauthorized {
+				require.Contains(t, recorder.Header().Values("WWW-Authenticate"), `Basic realm="banner-rotation"`)
+			}
+		})
+	}
+}
+
+func TestAdminRateLimit(t *testing.T) {
+	application := &fakeApp{
+		keys:    map[string]types.APIKey{"admin-key": {ID: [16]byte{1}, Role: types.RoleAdmin}},
+		limiter: memory.NewRateLimiter(),
+	}
+	srv, err := NewServer(application, config.Server{
+		Timeout:   "1s",
+		RateLimit: config.RateLimit{Admin: config.Limit{Requests: 1, Per: "1h"}},
+	})
+	require.NoError(t, err)
+	handler := srv.httpServer.Handler
+
+	send := func(request *http.Request) int {
+		request.SetBasicAuth("admin", "admin-key")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	require.Equal(t, http.StatusOK, send(httptest.NewRequest(http.MethodGet, "/admin", nil)))
+
+	form := url.Values{"description": {"Summer sale"}}
+	request := httptest.NewRequest(http.MethodPost, "/admin/banners", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	require.Equal(t, http.StatusTooManyRequests, send(request))
+	require.Empty(t, application.banners)
+}
+
+func TestDailyCTR(t *testing.T) {
+	now := time.Date(2021, 6, 10, 15, 0, 0, 0, time.UTC)
+	events := []types.Event{
+		{Type: types.EventTypeShow, Timestamp: now.AddDate(0, 0, -10)},
+		{Type: types.EventTypeShow, Timestamp: now.AddDate(0, 0, -1)},
+		{Type: types.EventTypeShow, Timestamp: now.AddDate(0, 0, -1)},
+		{Type: types.EventTypeClick, Timestamp: now.AddDate(0, 0, -1)},
+		{Type: types.EventTypeShow, Timestamp: now.Add(-time.Hour)},
+		{Type: types.EventTypeConversion, Timestamp: now.Add(-time.Hour)},
+	}
+
+	days := dailyCTR(events, 3, now)
+	require.Len(t, days, 3)
+	require.Equal(t, time.Date(2021, 6, 8, 0, 0, 0, 0, time.UTC), days[0].Day)
+
+	require.Zero(t, days[0].Shows)
+	require.Equal(t, 2, days[1].Shows)
+	require.Equal(t, 1, days[1].Clicks)
+	require.Equal(t, 0.5, days[1].CTR)
+	require.Equal(t, 1, days[2].Shows)
+	require.Zero(t, days[2].CTR)
+
+	chart := newCTRChart(days, 300, 100)
+	require.Len(t, chart.Bars, 3)
+	require.Equal(t, 100.0, chart.Bars[1].Height)
+	require.Zero(t, chart.Bars[2].Height)
+
+	t.Run("check rotation page renders", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		renderAdmin(recorder, "rotation.html", adminRotationPage{
+			Rotation: adminRotation{Banner: "Summer sale", Slot: "Top", Group: "Teenagers"},
+			Days:     days,
+			Chart:    chart,
+		})
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Contains(t, recorder.Body.String(), "Summer sale in Top for Teenagers")
+		require.Contains(t, recorder.Body.String(), "2021-06-09: 50.00%")
+	})
+}
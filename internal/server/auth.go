@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/auth"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/julienschmidt/httprouter"
+)
+
+// apiKeyHeader is alternative to Authorization header for clients unable to send bearer tokens.
+const apiKeyHeader = "X-API-Key"
+
+// apiKeyFromRequest reads API key from Authorization bearer token, password of
+// basic auth which browsers send to admin dashboard or X-API-Key header.
+func apiKeyFromRequest(request *http.Request) string {
+	if _, password, ok := request.BasicAuth(); ok {
+		return password
+	}
+	if authorization := request.Header.Get("Authorization"); authorization != "" {
+		const prefix = "Bearer "
+		if len(authorization) > len(prefix) && strings.EqualFold(authorization[:len(prefix)], prefix) {
+			return strings.TrimSpace(authorization[len(prefix):])
+		}
+		return ""
+	}
+	return request.Header.Get(apiKeyHeader)
+}
+
+// authorize lets request through only if it carries API key of role allowed
+// to call endpoint which requires role. Authenticated key is recorded as caller
+// of the request. Requests are limited by IP address before the key is looked up.
+// Nothing is checked if auth is disabled in config.
+func (s *Server) authorize(role string, next httprouter.Handle) httprouter.Handle {
+	if !s.authEnabled {
+		return next
+	}
+
+	return s.rateLimit(limitAuth, func(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+		defer cancel()
+
+		key, err := s.app.Authenticate(ctx, apiKeyFromRequest(request))
+		switch {
+		case errors.Is(err, types.ErrInvalidAPIKey):
+			w.Header().Set("WWW-Authenticate", `Bearer realm="banner-rotation"`)
+			w.Header().Add("WWW-Authenticate", `Basic realm="banner-rotation"`)
+			jsonResponse(
+				w,
+				http.StatusUnauthorized,
+				BadRequestResponse{
+					Error: err.Error(),
+					Msg:   "API key is required",
+				},
+			)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		setCaller(request.Context(), key)
+
+		if !auth.Allows(key.Role, role) {
+			jsonResponse(
+				w,
+				http.StatusForbidden,
+				BadRequestResponse{
+					Error: "role " + key.Role + " is not allowed to call this endpoint",
+					Msg:   "forbidden",
+				},
+			)
+			return
+		}
+
+		next(w, request, params)
+	})
+}
+
+func (s *Server) createAPIKeyHandler(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+	body := APIKeyBody{}
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to decode request body",
+			},
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	key, secret, err := s.app.CreateAPIKey(ctx, body.Name, body.Role)
+	switch {
+	case errors.Is(err, types.ErrUnknownRole):
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "role must be serve, analyst or admin",
+			},
+		)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, CreatedAPIKeyResponse{APIKey: key, Key: secret})
+}
+
+func (s *Server) listAPIKeysHandler(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	keys, err := s.app.ListAPIKeys(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, keys)
+}
+
+func (s *Server) revokeAPIKeyHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	keyID, ok := parseUUIDParam(w, params, "key_id", "API key")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	err := s.app.RevokeAPIKey(ctx, keyID)
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+		jsonResponse(
+			w,
+			http.StatusNotFound,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "no such API key",
+			},
+		)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func (fa *fakeApp) Authenticate(_ context.Context, secret string) (types.APIKey, error) {
+	key, ok := fa.keys[secret]
+	if !ok {
+		return types.APIKey{}, types.ErrInvalidAPIKey
+	}
+	return key, nil
+}
+
+func TestAuthorize(t *testing.T) {
+	application := &fakeApp{
+		keys: map[string]types.APIKey{
+			"serve-key":   {Name: "frontend", Role: types.RoleServe},
+			"analyst-key": {Name: "reports", Role: types.RoleAnalyst},
+			"admin-key":   {Name: "ops", Role: types.RoleAdmin},
+		},
+	}
+	srv, err := NewServer(application, config.Server{Timeout: "1s"})
+	require.NoError(t, err)
+	handler := srv.httpServer.Handler
+
+	send := func(method, target string, header http.Header) int {
+		request := httptest.NewRequest(method, target, nil)
+		for name, values := range header {
+			request.Header[name] = values
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+	bearer := func(key string) http.Header {
+		return http.Header{"Authorization": {"Bearer " + key}}
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		header http.Header
+		code   int
+	}{
+		{name: "key is required", method: http.MethodGet, target: "/banners", code: http.StatusUnauthorized},
+		{name: "unknown key is rejected", method: http.MethodGet, target: "/banners", header: bearer("wrong"), code: http.StatusUnauthorized},
+		{name: "analyst reads", method: http.MethodGet, target: "/banners", header: bearer("analyst-key"), code: http.StatusOK},
+		{name: "key in X-API-Key header", method: http.MethodGet, target: "/banners", header: http.Header{"X-Api-Key": {"analyst-key"}}, code: http.StatusOK},
+		{name: "serve does not read", method: http.MethodGet, target: "/banners", header: bearer("serve-key"), code: http.StatusForbidden},
+		{name: "analyst does not delete", method: http.MethodDelete, target: "/banners/not-uuid", header: bearer("analyst-key"), code: http.StatusForbidden},
+		{name: "analyst does not choose", method: http.MethodGet, target: "/slots/not-uuid/banner", header: bearer("analyst-key"), code: http.StatusForbidden},
+		{name: "serve chooses", method: http.MethodGet, target: "/slots/not-uuid/banner", header: bearer("serve-key"), code: http.StatusBadRequest},
+		{name: "admin deletes", method: http.MethodDelete, target: "/banners/not-uuid", header: bearer("admin-key"), code: http.StatusBadRequest},
+		{name: "admin reads", method: http.MethodGet, target: "/banners", header: bearer("admin-key"), code: http.StatusOK},
+		{name: "version is open", method: http.MethodGet, target: "/version", code: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run("check "+tc.name, func(t *testing.T) {
+			require.Equal(t, tc.code, send(tc.method, tc.target, tc.header))
+		})
+	}
+}
@@ -1,5 +1,10 @@
 package server
 
+import (
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
 type DescriptionBody struct {
 	Description string `json:",omitempty"`
 }
@@ -8,3 +13,69 @@ type BadRequestResponse struct {
 	Error string
 	Msg   string
 }
+
+type BannerCampaignBody struct {
+	// Empty CampaignID takes banner out of campaigns
+	CampaignID string
+}
+
+type CampaignBudgetBody struct {
+	TotalShows  int
+	TotalClicks int
+	DailyShows  int
+	DailyClicks int
+}
+
+type CampaignBody struct {
+	Description string `json:",omitempty"`
+	Budget      CampaignBudgetBody
+}
+
+type FrequencyCapBody struct {
+	MaxShows int
+	Window   string
+}
+
+type RotationStateBody struct {
+	State string
+}
+
+type RotationOverrideBody struct {
+	Pinned          bool
+	MinSharePercent float64
+}
+
+type SlotSettingsBody struct {
+	Sticky         bool
+	StickyTTL      string `json:",omitempty"`
+	HoldoutPercent float64
+}
+
+type GroupRuleBody struct {
+	Priority   int
+	MinAge     int
+	MaxAge     int
+	Gender     string
+	Locale     string
+	Device     string
+	Attributes map[string]string
+}
+
+type PageBody struct {
+	SlotIDs []uuid.UUID
+}
+
+type ConversionBody struct {
+	Value float64
+}
+
+type APIKeyBody struct {
+	Name string
+	Role string
+}
+
+// CreatedAPIKeyResponse carries secret of API key which is shown only once.
+type CreatedAPIKeyResponse struct {
+	types.APIKey
+	Key string
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/catalogue"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/julienschmidt/httprouter"
+)
+
+var catalogueContentTypes = map[string]string{
+	catalogue.FormatJSON: "application/json",
+	catalogue.FormatCSV:  "text/csv",
+}
+
+// catalogueFormat reads format query parameter which defaults to JSON.
+// On failure it writes bad request response and returns false.
+func catalogueFormat(w http.ResponseWriter, request *http.Request) (string, bool) {
+	format := request.URL.Query().Get("format")
+	if format == "" {
+		format = catalogue.FormatJSON
+	}
+
+	if _, ok := catalogueContentTypes[format]; !ok {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: "format must be json or csv",
+				Msg:   "unknown catalogue format",
+			},
+		)
+		return "", false
+	}
+	return format, true
+}
+
+func (s *Server) exportCatalogueHandler(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+	format, ok := catalogueFormat(w, request)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	exported, err := s.app.ExportCatalogue(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Encode into buffer first to be able to report failure with status code
+	var buf bytes.Buffer
+	err = catalogue.Write(&buf, format, exported)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-type", catalogueContentTypes[format])
+	w.WriteHeader(http.StatusOK)
+	_, err = buf.WriteTo(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) importCatalogueHandler(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+	format, ok := catalogueFormat(w, request)
+	if !ok {
+		return
+	}
+
+	var dryRun bool
+	if rawDryRun := request.URL.Query().Get("dry_run"); rawDryRun != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(rawDryRun)
+		if err != nil {
+			jsonResponse(
+				w,
+				http.StatusBadRequest,
+				BadRequestResponse{
+					Error: err.Error(),
+					Msg:   "failed to parse dry_run",
+				},
+			)
+			return
+		}
+	}
+
+	imported, err := catalogue.Read(request.Body, format)
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to decode catalogue",
+			},
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	report, err := s.app.ImportCatalogue(ctx, imported, dryRun)
+	switch {
+	case errors.Is(err, types.ErrCatalogueConflicts):
+		jsonResponse(w, http.StatusConflict, report)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, report)
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func (fa *fakeApp) ExportCatalogue(context.Context) (types.Catalogue, error) {
+	return types.Catalogue{Banners: fa.banners}, nil
+}
+
+// ImportCatalogue of fakeApp reports conflict for banners it already has.
+func (fa *fakeApp) ImportCatalogue(_ context.Context, catalogue types.Catalogue, dryRun bool) (types.ImportReport, error) {
+	report := types.ImportReport{DryRun: dryRun}
+	for _, imported := range catalogue.Banners {
+		for _, banner := range fa.banners {
+			if banner.ID == imported.ID {
+				report.Conflicts = append(report.Conflicts, types.CatalogueConflict{
+					Kind:   "banner",
+					ID:     banner.ID.String(),
+					Reason: "banner already exists",
+				})
+			}
+		}
+	}
+	if len(report.Conflicts) > 0 {
+		return report, types.ErrCatalogueConflicts
+	}
+
+	report.Banners = len(catalogue.Banners)
+	if !dryRun {
+		fa.banners = append(fa.banners, catalogue.Banners...)
+	}
+	return report, nil
+}
+
+func TestCatalogue(t *testing.T) {
+	application := &fakeApp{}
+	banner, err := application.AddBanner(context.Background(), "Summer sale")
+	require.NoError(t, err)
+	handler := newTestServer(t, application, config.Admin{})
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	t.Run("check csv export", func(t *testing.T) {
+		recorder := serve(http.MethodGet, "/catalogue?format=csv", "")
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, "text/csv", recorder.Header().Get("Content-type"))
+		require.Contains(t, recorder.Body.String(), "banner,"+banner.ID.String()+",Summer sale")
+	})
+
+	t.Run("check unknown format", func(t *testing.T) {
+		recorder := serve(http.MethodGet, "/catalogue?format=xml", "")
+		require.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("check conflicting import", func(t *testing.T) {
+		exported := serve(http.MethodGet, "/catalogue", "").Body.String()
+
+		recorder := serve(http.MethodPost, "/catalogue", exported)
+		require.Equal(t, http.StatusConflict, recorder.Code)
+
+		var report types.ImportReport
+		err := json.NewDecoder(recorder.Body).Decode(&report)
+		require.NoError(t, err)
+		require.Len(t, report.Conflicts, 1)
+		require.Equal(t, banner.ID.String(), report.Conflicts[0].ID)
+	})
+
+	t.Run("check dry run import", func(t *testing.T) {
+		body := `{"Banners": [{"ID": "8b4e7e4e-0d6b-4d0e-9a55-2f3c9e1b7a10", "Description": "Winter sale"}]}`
+		recorder := serve(http.MethodPost, "/catalogue?dry_run=true", body)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.JSONEq(t, `{"DryRun": true, "Banners": 1, "Slots": 0, "Groups": 0, "Rules": 0, "Rotations": 0}`, recorder.Body.String())
+		require.Len(t, application.banners, 1)
+	})
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// Every show of fake app is already converted.
+func (fa *fakeApp) RegisterConversion(context.Context, types.Conversion) error {
+	return types.ErrDuplicateConversion
+}
+
+func TestRegisterConversion(t *testing.T) {
+	handler := newTestServer(t, &fakeApp{}, config.Admin{})
+	target := "/impressions/" + uuid.NewString() + "/conversions"
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "negative value", body: `{"Value": -1}`, status: http.StatusBadRequest},
+		{name: "repeated conversion", body: `{"Value": 1}`, status: http.StatusConflict},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run("check "+tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(tc.body))
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			require.Equal(t, tc.status, recorder.Code, recorder.Body.String())
+		})
+	}
+}
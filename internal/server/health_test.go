@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func (fa *fakeApp) Readiness(context.Context) types.Readiness {
+	return fa.readiness
+}
+
+func TestProbes(t *testing.T) {
+	application := &fakeApp{}
+	handler := newTestServer(t, application, config.Admin{})
+
+	get := func(target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		return recorder
+	}
+
+	t.Run("check liveness", func(t *testing.T) {
+		recorder := get("/healthz")
+		require.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("check ready", func(t *testing.T) {
+		application.readiness = types.Readiness{
+			Ready:  true,
+			Checks: []types.HealthCheck{{Name: "database", Status: types.CheckStatusOK}},
+		}
+		recorder := get("/readyz")
+		require.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("check not ready", func(t *testing.T) {
+		application.readiness = types.Readiness{
+			Checks: []types.HealthCheck{{Name: "database", Status: types.CheckStatusFailed, Error: "connection refused"}},
+		}
+		recorder := get("/readyz")
+		require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+		var readiness types.Readiness
+		err := json.NewDecoder(recorder.Body).Decode(&readiness)
+		require.NoError(t, err)
+		require.Equal(t, application.readiness, readiness)
+	})
+}
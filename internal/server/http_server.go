@@ -3,12 +3,21 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/FedoseevAlex/banner-rotation/internal/common"
 	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/evaluation"
+	"github.com/FedoseevAlex/banner-rotation/internal/metrics"
+	"github.com/FedoseevAlex/banner-rotation/internal/rotators"
 	"github.com/FedoseevAlex/banner-rotation/internal/types"
 	"github.com/google/uuid"
 	"github.com/julienschmidt/httprouter"
@@ -18,6 +27,13 @@ type Server struct {
 	app        types.Application
 	httpServer *http.Server
 	timeout    time.Duration
+	// authEnabled requires API key on API endpoints
+	authEnabled bool
+	// limits of endpoint classes and header to read client IP from
+	limits         map[string]types.RateLimit
+	clientIPHeader string
+	// idempotencyTTL is how long responses are replayed to retries
+	idempotencyTTL time.Duration
 }
 
 func NewServer(application types.Application, cfg config.Server) (*Server, error) {
@@ -32,77 +48,279 @@ func NewServer(application types.Application, cfg config.Server) (*Server, error
 		return nil, err
 	}
 
+	limits, err := parseLimits(cfg.RateLimit)
+	if err != nil {
+		return nil, fmt.Errorf("rate limit of %w", err)
+	}
+
+	idempotencyTTL, err := parseIdempotencyTTL(cfg.Idempotency.TTL)
+	if err != nil {
+		return nil, err
+	}
+
 	server := &Server{
-		app:        application,
-		httpServer: &httpServer,
-		timeout:    timeout,
+		app:            application,
+		httpServer:     &httpServer,
+		timeout:        timeout,
+		authEnabled:    !cfg.DisableAuth,
+		limits:         limits,
+		clientIPHeader: cfg.RateLimit.ClientIPHeader,
+		idempotencyTTL: idempotencyTTL,
 	}
 	requestLogger := server.app.GetLogger("request info")
 
 	// Banners
 	mux.Handle(http.MethodPost, "/banners", loggingMiddleware(
-		server.addBannerHandler,
+		"/banners",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.addBannerHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodGet, "/banners", loggingMiddleware(
+		"/banners",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.listBannersHandler)),
 		requestLogger,
 	))
 	mux.Handle(http.MethodDelete, "/banners/:banner_id", loggingMiddleware(
-		server.deleteBannerHandler,
+		"/banners/:banner_id",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.deleteBannerHandler)),
 		requestLogger,
 	))
 	mux.Handle(http.MethodGet, "/banners/:banner_id", loggingMiddleware(
-		server.getBannerHandler,
+		"/banners/:banner_id",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.getBannerHandler)),
+		requestLogger,
+	))
+
+	mux.Handle(http.MethodPut, "/banners/:banner_id/campaign", loggingMiddleware(
+		"/banners/:banner_id/campaign",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.setBannerCampaignHandler)),
+		requestLogger,
+	))
+
+	// Advertisers and campaigns
+	mux.Handle(http.MethodPost, "/advertisers", loggingMiddleware(
+		"/advertisers",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.addAdvertiserHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodDelete, "/advertisers/:advertiser_id", loggingMiddleware(
+		"/advertisers/:advertiser_id",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.deleteAdvertiserHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodGet, "/advertisers/:advertiser_id", loggingMiddleware(
+		"/advertisers/:advertiser_id",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.getAdvertiserHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodPost, "/advertisers/:advertiser_id/campaigns", loggingMiddleware(
+		"/advertisers/:advertiser_id/campaigns",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.addCampaignHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodDelete, "/campaigns/:campaign_id", loggingMiddleware(
+		"/campaigns/:campaign_id",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.deleteCampaignHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodGet, "/campaigns/:campaign_id", loggingMiddleware(
+		"/campaigns/:campaign_id",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.getCampaignHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodPut, "/campaigns/:campaign_id/budget", loggingMiddleware(
+		"/campaigns/:campaign_id/budget",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.setCampaignBudgetHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodGet, "/campaigns/:campaign_id/consumption", loggingMiddleware(
+		"/campaigns/:campaign_id/consumption",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.getCampaignConsumptionHandler)),
 		requestLogger,
 	))
 
 	// Slots
 	mux.Handle(http.MethodPost, "/slots", loggingMiddleware(
-		server.addSlotHandler,
+		"/slots",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.addSlotHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodGet, "/slots", loggingMiddleware(
+		"/slots",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.listSlotsHandler)),
 		requestLogger,
 	))
 	mux.Handle(http.MethodDelete, "/slots/:slot_id", loggingMiddleware(
-		server.deleteSlotHandler,
+		"/slots/:slot_id",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.deleteSlotHandler)),
 		requestLogger,
 	))
 	mux.Handle(http.MethodGet, "/slots/:slot_id", loggingMiddleware(
-		server.getSlotHandler,
+		"/slots/:slot_id",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.getSlotHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodPut, "/slots/:slot_id/settings", loggingMiddleware(
+		"/slots/:slot_id/settings",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.setSlotSettingsHandler)),
 		requestLogger,
 	))
 
 	// Groups
 	mux.Handle(http.MethodPost, "/groups", loggingMiddleware(
-		server.addGroupHandler,
+		"/groups",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.addGroupHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodGet, "/groups", loggingMiddleware(
+		"/groups",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.listGroupsHandler)),
 		requestLogger,
 	))
 	mux.Handle(http.MethodDelete, "/groups/:group_id", loggingMiddleware(
-		server.deleteGroupHandler,
+		"/groups/:group_id",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.deleteGroupHandler)),
 		requestLogger,
 	))
 	mux.Handle(http.MethodGet, "/groups/:group_id", loggingMiddleware(
-		server.getGroupHandler,
+		"/groups/:group_id",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.getGroupHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodPost, "/groups/:group_id/rules", loggingMiddleware(
+		"/groups/:group_id/rules",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.addGroupRuleHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodGet, "/groups/:group_id/rules", loggingMiddleware(
+		"/groups/:group_id/rules",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.getGroupRulesHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodDelete, "/groups/:group_id/rules/:rule_id", loggingMiddleware(
+		"/groups/:group_id/rules/:rule_id",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.deleteGroupRuleHandler)),
 		requestLogger,
 	))
 
 	// Rotations
+	mux.Handle(http.MethodGet, "/rotations", loggingMiddleware(
+		"/rotations",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.listRotationsHandler)),
+		requestLogger,
+	))
 	mux.Handle(http.MethodPost, "/group/:group_id/slots/:slot_id/banners/:banner_id", loggingMiddleware(
-		server.addRotationHandler,
+		"/group/:group_id/slots/:slot_id/banners/:banner_id",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.addRotationHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodGet, "/group/:group_id/slots/:slot_id/banners/:banner_id", loggingMiddleware(
+		"/group/:group_id/slots/:slot_id/banners/:banner_id",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.getRotationHandler)),
 		requestLogger,
 	))
 	mux.Handle(http.MethodDelete, "/group/:group_id/slots/:slot_id/banners/:banner_id", loggingMiddleware(
-		server.addRotationHandler,
+		"/group/:group_id/slots/:slot_id/banners/:banner_id",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.deleteRotationHandler)),
 		requestLogger,
 	))
 	mux.Handle(http.MethodPost, "/group/:group_id/slots/:slot_id/banners/:banner_id/click", loggingMiddleware(
-		server.registerClickHandler,
+		"/group/:group_id/slots/:slot_id/banners/:banner_id/click",
+		server.authorize(types.RoleServe, server.rateLimit(limitClick, server.idempotent(server.registerClickHandler))),
 		requestLogger,
 	))
 	mux.Handle(http.MethodGet, "/group/:group_id/slots/:slot_id/banners/:banner_id/stats", loggingMiddleware(
-		server.getStatsHandler,
+		"/group/:group_id/slots/:slot_id/banners/:banner_id/stats",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.getStatsHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodPut, "/group/:group_id/slots/:slot_id/banners/:banner_id/cap", loggingMiddleware(
+		"/group/:group_id/slots/:slot_id/banners/:banner_id/cap",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.setFrequencyCapHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodPut, "/group/:group_id/slots/:slot_id/banners/:banner_id/state", loggingMiddleware(
+		"/group/:group_id/slots/:slot_id/banners/:banner_id/state",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.setRotationStateHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodPut, "/group/:group_id/slots/:slot_id/banners/:banner_id/override", loggingMiddleware(
+		"/group/:group_id/slots/:slot_id/banners/:banner_id/override",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.setRotationOverrideHandler)),
 		requestLogger,
 	))
 	mux.Handle(http.MethodGet, "/group/:group_id/slots/:slot_id/banner", loggingMiddleware(
-		server.chooseBannerHandler,
+		"/group/:group_id/slots/:slot_id/banner",
+		server.authorize(types.RoleServe, server.rateLimit(limitChoose, server.chooseBannerHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodGet, "/group/:group_id/slots/:slot_id/explain", loggingMiddleware(
+		"/group/:group_id/slots/:slot_id/explain",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.explainChoiceHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodPost, "/group/:group_id/banners", loggingMiddleware(
+		"/group/:group_id/banners",
+		server.authorize(types.RoleServe, server.rateLimit(limitChoose, server.idempotent(server.chooseBannersHandler))),
+		requestLogger,
+	))
+	mux.Handle(http.MethodGet, "/slots/:slot_id/banner", loggingMiddleware(
+		"/slots/:slot_id/banner",
+		server.authorize(types.RoleServe, server.rateLimit(limitChoose, server.chooseBannerForVisitorHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodGet, "/slots/:slot_id/holdout", loggingMiddleware(
+		"/slots/:slot_id/holdout",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.getHoldoutReportHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodGet, "/group/:group_id/slots/:slot_id/evaluation", loggingMiddleware(
+		"/group/:group_id/slots/:slot_id/evaluation",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.evaluateRotatorsHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodPost, "/impressions/:impression_id/conversions", loggingMiddleware(
+		"/impressions/:impression_id/conversions",
+		server.authorize(types.RoleServe, server.rateLimit(limitClick, server.registerConversionHandler)),
+		requestLogger,
+	))
+
+	// API keys
+	mux.Handle(http.MethodPost, "/api-keys", loggingMiddleware(
+		"/api-keys",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.createAPIKeyHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodGet, "/api-keys", loggingMiddleware(
+		"/api-keys",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.listAPIKeysHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodDelete, "/api-keys/:key_id", loggingMiddleware(
+		"/api-keys/:key_id",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.revokeAPIKeyHandler)),
+		requestLogger,
+	))
+
+	// Catalogue
+	mux.Handle(http.MethodGet, "/catalogue", loggingMiddleware(
+		"/catalogue",
+		server.authorize(types.RoleAnalyst, server.rateLimit(limitAdmin, server.exportCatalogueHandler)),
+		requestLogger,
+	))
+	mux.Handle(http.MethodPost, "/catalogue", loggingMiddleware(
+		"/catalogue",
+		server.authorize(types.RoleAdmin, server.rateLimit(limitAdmin, server.importCatalogueHandler)),
 		requestLogger,
 	))
+
 	mux.Handle(http.MethodGet, "/version", server.versionHandler)
+	// Probes and metrics are not logged as they are requested every few seconds
+	mux.Handle(http.MethodGet, "/healthz", server.healthzHandler)
+	mux.Handle(http.MethodGet, "/readyz", server.readyzHandler)
+	mux.Handler(http.MethodGet, "/metrics", metrics.Handler())
+
+	server.registerAdmin(mux, cfg.Admin, requestLogger)
 
 	return server, nil
 }
@@ -124,6 +342,51 @@ func jsonResponse(w http.ResponseWriter, code int, body interface{}) {
 	}
 }
 
+// parseUUIDParam parses uuid from path parameter.
+// On failure it writes bad request response and returns false.
+func parseUUIDParam(w http.ResponseWriter, params httprouter.Params, name, entity string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(params.ByName(name))
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to parse " + entity + " uuid",
+			},
+		)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// reservedQueryParams are query parameters which are not visitor attributes.
+var reservedQueryParams = map[string]bool{
+	"visitor_id":    true,
+	"impression_id": true,
+}
+
+// visitorFromRequest extracts optional visitor identifier
+// from X-Visitor-ID header or visitor_id query parameter.
+// All other query parameters are treated as visitor attributes.
+func visitorFromRequest(request *http.Request) types.Visitor {
+	query := request.URL.Query()
+
+	visitorID := request.Header.Get("X-Visitor-ID")
+	if visitorID == "" {
+		visitorID = query.Get("visitor_id")
+	}
+
+	attributes := make(map[string]string, len(query))
+	for key := range query {
+		if !reservedQueryParams[key] {
+			attributes[key] = query.Get(key)
+		}
+	}
+
+	return types.Visitor{ID: visitorID, Attributes: attributes}
+}
+
 func (s *Server) versionHandler(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
 	w.Header().Add("Content-type", "application/json")
 	if err := common.PrintVersion(w); err != nil {
@@ -132,6 +395,25 @@ func (s *Server) versionHandler(w http.ResponseWriter, request *http.Request, _
 	}
 }
 
+// healthzHandler reports that process is alive and serves requests.
+func (s *Server) healthzHandler(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+	jsonResponse(w, http.StatusOK, types.HealthCheck{Name: "process", Status: types.CheckStatusOK})
+}
+
+// readyzHandler reports whether dependencies needed to serve banners are available.
+func (s *Server) readyzHandler(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	readiness := s.app.Readiness(ctx)
+	if !readiness.Ready {
+		jsonResponse(w, http.StatusServiceUnavailable, readiness)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, readiness)
+}
+
 // Banner handlers.
 func (s *Server) addBannerHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
 	body := DescriptionBody{}
@@ -160,6 +442,19 @@ func (s *Server) addBannerHandler(w http.ResponseWriter, request *http.Request,
 	jsonResponse(w, http.StatusOK, banner)
 }
 
+func (s *Server) listBannersHandler(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	banners, err := s.app.ListBanners(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, banners)
+}
+
 func (s *Server) deleteBannerHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
 	bannerID, err := uuid.Parse(params.ByName("banner_id"))
 	if err != nil {
@@ -212,9 +507,13 @@ func (s *Server) getBannerHandler(w http.ResponseWriter, request *http.Request,
 	jsonResponse(w, http.StatusOK, banner)
 }
 
-// Slot handlers.
-func (s *Server) addSlotHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
-	body := DescriptionBody{}
+func (s *Server) setBannerCampaignHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	bannerID, ok := parseUUIDParam(w, params, "banner_id", "banner")
+	if !ok {
+		return
+	}
+
+	body := BannerCampaignBody{}
 	err := json.NewDecoder(request.Body).Decode(&body)
 	if err != nil {
 		jsonResponse(
@@ -228,27 +527,45 @@ func (s *Server) addSlotHandler(w http.ResponseWriter, request *http.Request, pa
 		return
 	}
 
+	campaignID := uuid.Nil
+	if body.CampaignID != "" {
+		campaignID, err = uuid.Parse(body.CampaignID)
+		if err != nil {
+			jsonResponse(
+				w,
+				http.StatusBadRequest,
+				BadRequestResponse{
+					Error: err.Error(),
+					Msg:   "failed to parse campaign uuid",
+				},
+			)
+			return
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
 	defer cancel()
 
-	slot, err := s.app.AddSlot(ctx, body.Description)
+	err = s.app.SetBannerCampaign(ctx, bannerID, campaignID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	jsonResponse(w, http.StatusOK, slot)
+	jsonResponse(w, http.StatusNoContent, nil)
 }
 
-func (s *Server) deleteSlotHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
-	slotID, err := uuid.Parse(params.ByName("slot_id"))
+// Advertiser and campaign handlers.
+func (s *Server) addAdvertiserHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	body := DescriptionBody{}
+	err := json.NewDecoder(request.Body).Decode(&body)
 	if err != nil {
 		jsonResponse(
 			w,
 			http.StatusBadRequest,
 			BadRequestResponse{
 				Error: err.Error(),
-				Msg:   "failed to parse slot uuid",
+				Msg:   "failed to decode request body",
 			},
 		)
 		return
@@ -257,218 +574,176 @@ func (s *Server) deleteSlotHandler(w http.ResponseWriter, request *http.Request,
 	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
 	defer cancel()
 
-	err = s.app.DeleteSlot(ctx, slotID)
+	advertiser, err := s.app.AddAdvertiser(ctx, body.Description)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	jsonResponse(w, http.StatusNoContent, nil)
+	jsonResponse(w, http.StatusOK, advertiser)
 }
 
-func (s *Server) getSlotHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
-	slotID, err := uuid.Parse(params.ByName("slot_id"))
-	if err != nil {
-		jsonResponse(
-			w,
-			http.StatusBadRequest,
-			BadRequestResponse{
-				Error: err.Error(),
-				Msg:   "failed to parse slot uuid",
-			},
-		)
+func (s *Server) deleteAdvertiserHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	advertiserID, ok := parseUUIDParam(w, params, "advertiser_id", "advertiser")
+	if !ok {
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
 	defer cancel()
 
-	slot, err := s.app.GetSlot(ctx, slotID)
+	err := s.app.DeleteAdvertiser(ctx, advertiserID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	jsonResponse(w, http.StatusOK, slot)
+	jsonResponse(w, http.StatusNoContent, nil)
 }
 
-// Group handlers.
-func (s *Server) addGroupHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
-	body := DescriptionBody{}
-	err := json.NewDecoder(request.Body).Decode(&body)
-	if err != nil {
-		jsonResponse(
-			w,
-			http.StatusBadRequest,
-			BadRequestResponse{
-				Error: err.Error(),
-				Msg:   "failed to decode request body",
-			},
-		)
+func (s *Server) getAdvertiserHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	advertiserID, ok := parseUUIDParam(w, params, "advertiser_id", "advertiser")
+	if !ok {
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
 	defer cancel()
 
-	group, err := s.app.AddGroup(ctx, body.Description)
+	advertiser, err := s.app.GetAdvertiser(ctx, advertiserID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	jsonResponse(w, http.StatusOK, group)
+	jsonResponse(w, http.StatusOK, advertiser)
 }
 
-func (s *Server) deleteGroupHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
-	groupID, err := uuid.Parse(params.ByName("group_id"))
-	if err != nil {
+// parseCampaignBudget validates budget from request body.
+// On failure it writes bad request response and returns false.
+func parseCampaignBudget(w http.ResponseWriter, body CampaignBudgetBody) (types.CampaignBudget, bool) {
+	if body.TotalShows < 0 || body.TotalClicks < 0 || body.DailyShows < 0 || body.DailyClicks < 0 {
 		jsonResponse(
 			w,
 			http.StatusBadRequest,
 			BadRequestResponse{
-				Error: err.Error(),
-				Msg:   "failed to parse group uuid",
+				Error: "budgets must not be negative",
+				Msg:   "invalid campaign budget",
 			},
 		)
-		return
+		return types.CampaignBudget{}, false
 	}
 
-	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
-	defer cancel()
+	return types.CampaignBudget{
+		TotalShows:  body.TotalShows,
+		TotalClicks: body.TotalClicks,
+		DailyShows:  body.DailyShows,
+		DailyClicks: body.DailyClicks,
+	}, true
+}
 
-	err = s.app.DeleteGroup(ctx, groupID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+func (s *Server) addCampaignHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	advertiserID, ok := parseUUIDParam(w, params, "advertiser_id", "advertiser")
+	if !ok {
 		return
 	}
 
-	jsonResponse(w, http.StatusNoContent, nil)
-}
-
-func (s *Server) getGroupHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
-	groupID, err := uuid.Parse(params.ByName("group_id"))
+	body := CampaignBody{}
+	err := json.NewDecoder(request.Body).Decode(&body)
 	if err != nil {
 		jsonResponse(
 			w,
 			http.StatusBadRequest,
 			BadRequestResponse{
 				Error: err.Error(),
-				Msg:   "failed to parse group uuid",
+				Msg:   "failed to decode request body",
 			},
 		)
 		return
 	}
 
+	budget, ok := parseCampaignBudget(w, body.Budget)
+	if !ok {
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
 	defer cancel()
 
-	group, err := s.app.GetGroup(ctx, groupID)
+	campaign, err := s.app.AddCampaign(ctx, advertiserID, body.Description, budget)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	jsonResponse(w, http.StatusOK, group)
+	jsonResponse(w, http.StatusOK, campaign)
 }
 
-// Rotation handlers.
-func (s *Server) addRotationHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) { //nolint:dupl
-	bannerID, err := uuid.Parse(params.ByName("banner_id"))
-	if err != nil {
-		jsonResponse(
-			w,
-			http.StatusBadRequest,
-			BadRequestResponse{
-				Error: err.Error(),
-				Msg:   "failed to parse banner uuid",
-			},
-		)
+func (s *Server) deleteCampaignHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	campaignID, ok := parseUUIDParam(w, params, "campaign_id", "campaign")
+	if !ok {
 		return
 	}
 
-	slotID, err := uuid.Parse(params.ByName("slot_id"))
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	err := s.app.DeleteCampaign(ctx, campaignID)
 	if err != nil {
-		jsonResponse(
-			w,
-			http.StatusBadRequest,
-			BadRequestResponse{
-				Error: err.Error(),
-				Msg:   "failed to parse slot uuid",
-			},
-		)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	groupID, err := uuid.Parse(params.ByName("group_id"))
-	if err != nil {
-		jsonResponse(
-			w,
-			http.StatusBadRequest,
-			BadRequestResponse{
-				Error: err.Error(),
-				Msg:   "failed to parse group uuid",
-			},
-		)
+	jsonResponse(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) getCampaignHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	campaignID, ok := parseUUIDParam(w, params, "campaign_id", "campaign")
+	if !ok {
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
 	defer cancel()
 
-	rotation, err := s.app.AddRotation(ctx, bannerID, slotID, groupID)
+	campaign, err := s.app.GetCampaign(ctx, campaignID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	jsonResponse(w, http.StatusOK, rotation)
+	jsonResponse(w, http.StatusOK, campaign)
 }
 
-func (s *Server) registerClickHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
-	bannerID, err := uuid.Parse(params.ByName("banner_id"))
-	if err != nil {
-		jsonResponse(
-			w,
-			http.StatusBadRequest,
-			BadRequestResponse{
-				Error: err.Error(),
-				Msg:   "failed to parse banner uuid",
-			},
-		)
+func (s *Server) setCampaignBudgetHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	campaignID, ok := parseUUIDParam(w, params, "campaign_id", "campaign")
+	if !ok {
 		return
 	}
 
-	slotID, err := uuid.Parse(params.ByName("slot_id"))
+	body := CampaignBudgetBody{}
+	err := json.NewDecoder(request.Body).Decode(&body)
 	if err != nil {
 		jsonResponse(
 			w,
 			http.StatusBadRequest,
 			BadRequestResponse{
 				Error: err.Error(),
-				Msg:   "failed to parse slot uuid",
+				Msg:   "failed to decode request body",
 			},
 		)
 		return
 	}
 
-	groupID, err := uuid.Parse(params.ByName("group_id"))
-	if err != nil {
-		jsonResponse(
-			w,
-			http.StatusBadRequest,
-			BadRequestResponse{
-				Error: err.Error(),
-				Msg:   "failed to parse group uuid",
-			},
-		)
+	budget, ok := parseCampaignBudget(w, body)
+	if !ok {
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
 	defer cancel()
 
-	err = s.app.RegisterClick(ctx, bannerID, slotID, groupID)
+	err = s.app.SetCampaignBudget(ctx, campaignID, budget)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -477,7 +752,645 @@ func (s *Server) registerClickHandler(w http.ResponseWriter, request *http.Reque
 	jsonResponse(w, http.StatusNoContent, nil)
 }
 
-func (s *Server) getStatsHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) { //nolint:dupl
+func (s *Server) getCampaignConsumptionHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	campaignID, ok := parseUUIDParam(w, params, "campaign_id", "campaign")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	consumption, err := s.app.GetCampaignConsumption(ctx, campaignID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, consumption)
+}
+
+// Slot handlers.
+func (s *Server) addSlotHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	body := DescriptionBody{}
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to decode request body",
+			},
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	slot, err := s.app.AddSlot(ctx, body.Description)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, slot)
+}
+
+func (s *Server) listSlotsHandler(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	slots, err := s.app.ListSlots(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, slots)
+}
+
+func (s *Server) deleteSlotHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	slotID, err := uuid.Parse(params.ByName("slot_id"))
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to parse slot uuid",
+			},
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	err = s.app.DeleteSlot(ctx, slotID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) getSlotHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	slotID, err := uuid.Parse(params.ByName("slot_id"))
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to parse slot uuid",
+			},
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	slot, err := s.app.GetSlot(ctx, slotID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, slot)
+}
+
+func (s *Server) setSlotSettingsHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	slotID, ok := parseUUIDParam(w, params, "slot_id", "slot")
+	if !ok {
+		return
+	}
+
+	body := SlotSettingsBody{}
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to decode request body",
+			},
+		)
+		return
+	}
+
+	if body.HoldoutPercent < 0 || body.HoldoutPercent > 100 {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: "holdout percent must be between 0 and 100",
+				Msg:   "invalid holdout percent",
+			},
+		)
+		return
+	}
+
+	settings := types.SlotSettings{Sticky: body.Sticky, HoldoutPercent: body.HoldoutPercent}
+	if body.StickyTTL != "" {
+		settings.StickyTTL, err = time.ParseDuration(body.StickyTTL)
+		if err == nil && settings.StickyTTL < 0 {
+			err = errors.New("sticky ttl must not be negative")
+		}
+		if err != nil {
+			jsonResponse(
+				w,
+				http.StatusBadRequest,
+				BadRequestResponse{
+					Error: err.Error(),
+					Msg:   "failed to parse sticky ttl",
+				},
+			)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	err = s.app.SetSlotSettings(ctx, slotID, settings)
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+		jsonResponse(
+			w,
+			http.StatusNotFound,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "no such slot",
+			},
+		)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusNoContent, nil)
+}
+
+// Group handlers.
+func (s *Server) addGroupHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	body := DescriptionBody{}
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to decode request body",
+			},
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	group, err := s.app.AddGroup(ctx, body.Description)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, group)
+}
+
+func (s *Server) listGroupsHandler(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	groups, err := s.app.ListGroups(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, groups)
+}
+
+func (s *Server) deleteGroupHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	groupID, err := uuid.Parse(params.ByName("group_id"))
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to parse group uuid",
+			},
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	err = s.app.DeleteGroup(ctx, groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) getGroupHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	groupID, err := uuid.Parse(params.ByName("group_id"))
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to parse group uuid",
+			},
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	group, err := s.app.GetGroup(ctx, groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, group)
+}
+
+func (s *Server) addGroupRuleHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	groupID, ok := parseUUIDParam(w, params, "group_id", "group")
+	if !ok {
+		return
+	}
+
+	body := GroupRuleBody{}
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to decode request body",
+			},
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	rule, err := s.app.AddGroupRule(ctx, types.GroupRule{
+		GroupID:    groupID,
+		Priority:   body.Priority,
+		MinAge:     body.MinAge,
+		MaxAge:     body.MaxAge,
+		Gender:     body.Gender,
+		Locale:     body.Locale,
+		Device:     body.Device,
+		Attributes: body.Attributes,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, rule)
+}
+
+func (s *Server) getGroupRulesHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	groupID, ok := parseUUIDParam(w, params, "group_id", "group")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	groupRules, err := s.app.GetGroupRules(ctx, groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, groupRules)
+}
+
+func (s *Server) deleteGroupRuleHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	groupID, ok := parseUUIDParam(w, params, "group_id", "group")
+	if !ok {
+		return
+	}
+
+	ruleID, err := strconv.Atoi(params.ByName("rule_id"))
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to parse rule id",
+			},
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	err = s.app.DeleteGroupRule(ctx, groupID, ruleID)
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+		jsonResponse(
+			w,
+			http.StatusNotFound,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "no such group rule",
+			},
+		)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusNoContent, nil)
+}
+
+// Rotation handlers.
+func (s *Server) addRotationHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) { //nolint:dupl
+	bannerID, err := uuid.Parse(params.ByName("banner_id"))
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to parse banner uuid",
+			},
+		)
+		return
+	}
+
+	slotID, err := uuid.Parse(params.ByName("slot_id"))
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to parse slot uuid",
+			},
+		)
+		return
+	}
+
+	groupID, err := uuid.Parse(params.ByName("group_id"))
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to parse group uuid",
+			},
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	rotation, err := s.app.AddRotation(ctx, bannerID, slotID, groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, rotation)
+}
+
+func (s *Server) getRotationHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	bannerID, ok := parseUUIDParam(w, params, "banner_id", "banner")
+	if !ok {
+		return
+	}
+	slotID, ok := parseUUIDParam(w, params, "slot_id", "slot")
+	if !ok {
+		return
+	}
+	groupID, ok := parseUUIDParam(w, params, "group_id", "group")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	rotation, err := s.app.GetRotation(ctx, bannerID, slotID, groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, rotation)
+}
+
+func (s *Server) deleteRotationHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	bannerID, ok := parseUUIDParam(w, params, "banner_id", "banner")
+	if !ok {
+		return
+	}
+	slotID, ok := parseUUIDParam(w, params, "slot_id", "slot")
+	if !ok {
+		return
+	}
+	groupID, ok := parseUUIDParam(w, params, "group_id", "group")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	err := s.app.DeleteRotation(ctx, bannerID, slotID, groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) listRotationsHandler(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	rotations, err := s.app.ListRotations(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, rotations)
+}
+
+func (s *Server) registerClickHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	bannerID, err := uuid.Parse(params.ByName("banner_id"))
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to parse banner uuid",
+			},
+		)
+		return
+	}
+
+	slotID, err := uuid.Parse(params.ByName("slot_id"))
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to parse slot uuid",
+			},
+		)
+		return
+	}
+
+	groupID, err := uuid.Parse(params.ByName("group_id"))
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to parse group uuid",
+			},
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	var impressionID uuid.UUID
+	if rawImpressionID := request.URL.Query().Get("impression_id"); rawImpressionID != "" {
+		impressionID, err = uuid.Parse(rawImpressionID)
+		if err != nil {
+			jsonResponse(
+				w,
+				http.StatusBadRequest,
+				BadRequestResponse{
+					Error: err.Error(),
+					Msg:   "failed to parse impression uuid",
+				},
+			)
+			return
+		}
+	}
+
+	err = s.app.RegisterClick(ctx, types.Click{
+		ImpressionID: impressionID,
+		BannerID:     bannerID,
+		SlotID:       slotID,
+		GroupID:      groupID,
+		Visitor:      visitorFromRequest(request),
+		UserAgent:    request.UserAgent(),
+		IP:           s.clientIP(request),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) registerConversionHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	impressionID, ok := parseUUIDParam(w, params, "impression_id", "impression")
+	if !ok {
+		return
+	}
+
+	body := ConversionBody{}
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil && !errors.Is(err, io.EOF) {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to decode request body",
+			},
+		)
+		return
+	}
+
+	if body.Value < 0 {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: "negative value",
+				Msg:   "conversion value must not be negative",
+			},
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	err = s.app.RegisterConversion(ctx, types.Conversion{ImpressionID: impressionID, Value: body.Value})
+	switch {
+	case errors.Is(err, types.ErrNoImpression):
+		jsonResponse(
+			w,
+			http.StatusNotFound,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "no show with such impression",
+			},
+		)
+		return
+	case errors.Is(err, types.ErrDuplicateConversion):
+		jsonResponse(
+			w,
+			http.StatusConflict,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "conversion of the show is already registered",
+			},
+		)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) getStatsHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) { //nolint:dupl
 	bannerID, err := uuid.Parse(params.ByName("banner_id"))
 	if err != nil {
 		jsonResponse(
@@ -485,33 +1398,224 @@ func (s *Server) getStatsHandler(w http.ResponseWriter, request *http.Request, p
 			http.StatusBadRequest,
 			BadRequestResponse{
 				Error: err.Error(),
-				Msg:   "failed to parse banner uuid",
+				Msg:   "failed to parse banner uuid",
+			},
+		)
+		return
+	}
+
+	slotID, err := uuid.Parse(params.ByName("slot_id"))
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to parse slot uuid",
+			},
+		)
+		return
+	}
+
+	groupID, err := uuid.Parse(params.ByName("group_id"))
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to parse group uuid",
+			},
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	stats, err := s.app.GetStats(ctx, bannerID, slotID, groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, stats)
+}
+
+func (s *Server) setFrequencyCapHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	bannerID, ok := parseUUIDParam(w, params, "banner_id", "banner")
+	if !ok {
+		return
+	}
+	slotID, ok := parseUUIDParam(w, params, "slot_id", "slot")
+	if !ok {
+		return
+	}
+	groupID, ok := parseUUIDParam(w, params, "group_id", "group")
+	if !ok {
+		return
+	}
+
+	body := FrequencyCapBody{}
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to decode request body",
+			},
+		)
+		return
+	}
+
+	var window time.Duration
+	if body.MaxShows > 0 {
+		window, err = time.ParseDuration(body.Window)
+		if err == nil && window <= 0 {
+			err = errors.New("window must be positive")
+		}
+		if err != nil {
+			jsonResponse(
+				w,
+				http.StatusBadRequest,
+				BadRequestResponse{
+					Error: err.Error(),
+					Msg:   "failed to parse frequency cap window",
+				},
+			)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	err = s.app.SetFrequencyCap(
+		ctx,
+		bannerID,
+		slotID,
+		groupID,
+		types.FrequencyCap{MaxShows: body.MaxShows, Window: window},
+	)
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+		jsonResponse(
+			w,
+			http.StatusNotFound,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "no such rotation",
+			},
+		)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) setRotationStateHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	bannerID, ok := parseUUIDParam(w, params, "banner_id", "banner")
+	if !ok {
+		return
+	}
+	slotID, ok := parseUUIDParam(w, params, "slot_id", "slot")
+	if !ok {
+		return
+	}
+	groupID, ok := parseUUIDParam(w, params, "group_id", "group")
+	if !ok {
+		return
+	}
+
+	body := RotationStateBody{}
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to decode request body",
 			},
 		)
 		return
 	}
 
-	slotID, err := uuid.Parse(params.ByName("slot_id"))
-	if err != nil {
+	if body.State != types.RotationStateActive && body.State != types.RotationStatePaused {
 		jsonResponse(
 			w,
 			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: "state must be either active or paused",
+				Msg:   "invalid rotation state",
+			},
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	err = s.app.SetRotationState(ctx, bannerID, slotID, groupID, body.State)
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+		jsonResponse(
+			w,
+			http.StatusNotFound,
 			BadRequestResponse{
 				Error: err.Error(),
-				Msg:   "failed to parse slot uuid",
+				Msg:   "no such rotation",
 			},
 		)
 		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	groupID, err := uuid.Parse(params.ByName("group_id"))
+	jsonResponse(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) setRotationOverrideHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	bannerID, ok := parseUUIDParam(w, params, "banner_id", "banner")
+	if !ok {
+		return
+	}
+	slotID, ok := parseUUIDParam(w, params, "slot_id", "slot")
+	if !ok {
+		return
+	}
+	groupID, ok := parseUUIDParam(w, params, "group_id", "group")
+	if !ok {
+		return
+	}
+
+	body := RotationOverrideBody{}
+	err := json.NewDecoder(request.Body).Decode(&body)
 	if err != nil {
 		jsonResponse(
 			w,
 			http.StatusBadRequest,
 			BadRequestResponse{
 				Error: err.Error(),
-				Msg:   "failed to parse group uuid",
+				Msg:   "failed to decode request body",
+			},
+		)
+		return
+	}
+
+	if body.MinSharePercent < 0 || body.MinSharePercent > 100 {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: "minimal share percent must be between 0 and 100",
+				Msg:   "invalid minimal share percent",
 			},
 		)
 		return
@@ -520,13 +1624,30 @@ func (s *Server) getStatsHandler(w http.ResponseWriter, request *http.Request, p
 	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
 	defer cancel()
 
-	stats, err := s.app.GetStats(ctx, bannerID, slotID, groupID)
-	if err != nil {
+	err = s.app.SetRotationOverride(
+		ctx,
+		bannerID,
+		slotID,
+		groupID,
+		types.RotationOverride{Pinned: body.Pinned, MinSharePercent: body.MinSharePercent},
+	)
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+		jsonResponse(
+			w,
+			http.StatusNotFound,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "no such rotation",
+			},
+		)
+		return
+	case err != nil:
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	jsonResponse(w, http.StatusOK, stats)
+	jsonResponse(w, http.StatusNoContent, nil)
 }
 
 func (s *Server) chooseBannerHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
@@ -559,19 +1680,306 @@ func (s *Server) chooseBannerHandler(w http.ResponseWriter, request *http.Reques
 	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
 	defer cancel()
 
-	rotation, err := s.app.ChooseBanner(ctx, slotID, groupID)
+	impression, err := s.app.ChooseBanner(ctx, slotID, groupID, visitorFromRequest(request))
+	switch {
+	case errors.Is(err, types.ErrNoRotations):
+		jsonResponse(
+			w,
+			http.StatusNotFound,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "no banner to show",
+			},
+		)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, impression)
+}
+
+func (s *Server) explainChoiceHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	slotID, ok := parseUUIDParam(w, params, "slot_id", "slot")
+	if !ok {
+		return
+	}
+	groupID, ok := parseUUIDParam(w, params, "group_id", "group")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	explanation, err := s.app.ExplainChoice(ctx, slotID, groupID, visitorFromRequest(request))
+	switch {
+	case errors.Is(err, types.ErrNoRotations):
+		jsonResponse(
+			w,
+			http.StatusNotFound,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "no rotations in slot for group",
+			},
+		)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, explanation)
+}
+
+func (s *Server) chooseBannersHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	groupID, ok := parseUUIDParam(w, params, "group_id", "group")
+	if !ok {
+		return
+	}
+
+	body := PageBody{}
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to decode request body",
+			},
+		)
+		return
+	}
+
+	if len(body.SlotIDs) == 0 {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: "no slots given",
+				Msg:   "invalid page slots",
+			},
+		)
+		return
+	}
+
+	seen := make(map[uuid.UUID]bool, len(body.SlotIDs))
+	for _, slotID := range body.SlotIDs {
+		if seen[slotID] {
+			jsonResponse(
+				w,
+				http.StatusBadRequest,
+				BadRequestResponse{
+					Error: fmt.Sprintf("slot %s is given more than once", slotID),
+					Msg:   "invalid page slots",
+				},
+			)
+			return
+		}
+		seen[slotID] = true
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	impressions, err := s.app.ChooseBanners(ctx, body.SlotIDs, groupID, visitorFromRequest(request))
+	switch {
+	case errors.Is(err, types.ErrNoRotations):
+		jsonResponse(
+			w,
+			http.StatusNotFound,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "no banner to show",
+			},
+		)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, impressions)
+}
+
+// defaultReportPeriod is used when start of report time range is not set.
+const defaultReportPeriod = 30 * 24 * time.Hour
+
+// parseTimeRange parses optional from and to query parameters in RFC3339.
+// Range defaults to defaultReportPeriod until now.
+func parseTimeRange(query url.Values) (from, to time.Time, err error) {
+	to = time.Now()
+	if rawTo := query.Get("to"); rawTo != "" {
+		to, err = time.Parse(time.RFC3339, rawTo)
+		if err != nil {
+			return from, to, err
+		}
+	}
+
+	from = to.Add(-defaultReportPeriod)
+	if rawFrom := query.Get("from"); rawFrom != "" {
+		from, err = time.Parse(time.RFC3339, rawFrom)
+		if err != nil {
+			return from, to, err
+		}
+	}
+
+	if !from.Before(to) {
+		return from, to, errors.New("start of time range must be before its end")
+	}
+
+	return from, to, nil
+}
+
+func (s *Server) getHoldoutReportHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	slotID, ok := parseUUIDParam(w, params, "slot_id", "slot")
+	if !ok {
+		return
+	}
+
+	from, to, err := parseTimeRange(request.URL.Query())
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to parse time range",
+			},
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	report, err := s.app.GetHoldoutReport(ctx, slotID, from, to)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	jsonResponse(w, http.StatusOK, rotation)
+	jsonResponse(w, http.StatusOK, report)
+}
+
+func (s *Server) evaluateRotatorsHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	slotID, ok := parseUUIDParam(w, params, "slot_id", "slot")
+	if !ok {
+		return
+	}
+	groupID, ok := parseUUIDParam(w, params, "group_id", "group")
+	if !ok {
+		return
+	}
+
+	query := request.URL.Query()
+	from, to, err := parseTimeRange(query)
+	if err != nil {
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to parse time range",
+			},
+		)
+		return
+	}
+
+	algorithms := rotators.Algorithms
+	if rawAlgorithms := query.Get("rotators"); rawAlgorithms != "" {
+		algorithms = strings.Split(rawAlgorithms, ",")
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	estimates, err := s.app.EvaluateRotators(ctx, slotID, groupID, from, to, algorithms)
+	switch {
+	case errors.Is(err, rotators.ErrUnknownAlgorithm):
+		jsonResponse(
+			w,
+			http.StatusBadRequest,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "failed to create rotator",
+			},
+		)
+		return
+	case errors.Is(err, evaluation.ErrNoShows):
+		jsonResponse(
+			w,
+			http.StatusNotFound,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "no shows logged in time range",
+			},
+		)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, estimates)
+}
+
+func (s *Server) chooseBannerForVisitorHandler(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	slotID, ok := parseUUIDParam(w, params, "slot_id", "slot")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+	defer cancel()
+
+	impression, err := s.app.ChooseBannerForVisitor(ctx, slotID, visitorFromRequest(request))
+	switch {
+	case errors.Is(err, types.ErrNoGroupMatched):
+		jsonResponse(
+			w,
+			http.StatusNotFound,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "no group for visitor",
+			},
+		)
+		return
+	case errors.Is(err, types.ErrNoRotations):
+		jsonResponse(
+			w,
+			http.StatusNotFound,
+			BadRequestResponse{
+				Error: err.Error(),
+				Msg:   "no banner to show",
+			},
+		)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, impression)
 }
 
+// Start serves requests until Stop is called.
 func (s *Server) Start() error {
-	return s.httpServer.ListenAndServe()
+	err := s.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
-func (s *Server) Stop() error {
-	return s.httpServer.Shutdown(context.Background())
+// Stop stops accepting connections and waits for in-flight requests
+// until ctx is done. Connections left after that are closed.
+func (s *Server) Stop(ctx context.Context) error {
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		s.httpServer.Close()
+	}
+	return err
 }
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// replayedHeader marks response replayed from the first request with the key
+	replayedHeader          = "Idempotent-Replayed"
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodySize limits body read into memory to be hashed
+	maxIdempotentBodySize = 1 << 20
+	// idempotencyPollInterval is how often retry checks if the first request is finished
+	idempotencyPollInterval = 50 * time.Millisecond
+	defaultIdempotencyTTL   = 24 * time.Hour
+)
+
+// parseIdempotencyTTL parses how long responses are kept for retries.
+func parseIdempotencyTTL(ttl string) (time.Duration, error) {
+	if ttl == "" {
+		return defaultIdempotencyTTL, nil
+	}
+	return time.ParseDuration(ttl)
+}
+
+// CheckIdempotencyTTL reports invalid TTL of idempotency keys.
+func CheckIdempotencyTTL(ttl string) error {
+	_, err := parseIdempotencyTTL(ttl)
+	return err
+}
+
+// responseRecorder passes response through and keeps it to be stored.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(statusCode int) {
+	if rr.status == 0 {
+		rr.status = statusCode
+	}
+	rr.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rr *responseRecorder) Write(data []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	rr.body.Write(data)
+	return rr.ResponseWriter.Write(data)
+}
+
+// idempotent processes request with Idempotency-Key header once. Retries with
+// the same key get the stored response, concurrent retries wait for the first
+// request to finish. Key reused with another body is answered with 422.
+// Responses with 5xx status are not stored, so that request may be retried.
+// Requests without the header are passed as is.
+func (s *Server) idempotent(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		idempotencyKey := request.Header.Get(idempotencyKeyHeader)
+		if idempotencyKey == "" {
+			next(w, request, params)
+			return
+		}
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			jsonResponse(
+				w,
+				http.StatusBadRequest,
+				BadRequestResponse{
+					Error: "idempotency key is too long",
+					Msg:   "idempotency key must be at most 255 characters",
+				},
+			)
+			return
+		}
+		key := idempotencyScope(request) + idempotencyKey
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, request.Body, maxIdempotentBodySize))
+		switch {
+		case err != nil && len(body) >= maxIdempotentBodySize:
+			jsonResponse(
+				w,
+				http.StatusRequestEntityTooLarge,
+				BadRequestResponse{
+					Error: err.Error(),
+					Msg:   "request body must be at most 1 MiB",
+				},
+			)
+			return
+		case err != nil:
+			jsonResponse(
+				w,
+				http.StatusBadRequest,
+				BadRequestResponse{
+					Error: err.Error(),
+					Msg:   "failed to read request body",
+				},
+			)
+			return
+		}
+		request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		bodyHash := sha256.Sum256(body)
+
+		ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+		defer cancel()
+
+		for {
+			// Lease covers timeout of the handler which starts after the claim
+			response, claimed, err := s.app.BeginIdempotentRequest(ctx, key, hex.EncodeToString(bodyHash[:]), 2*s.timeout)
+			switch {
+			case errors.Is(err, types.ErrIdempotencyKeyReused):
+				jsonResponse(
+					w,
+					http.StatusUnprocessableEntity,
+					BadRequestResponse{
+						Error: err.Error(),
+						Msg:   "idempotency key must be retried with the same request",
+					},
+				)
+				return
+			case err != nil:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			case claimed:
+				s.serveIdempotent(w, request, params, key, next)
+				return
+			case response != nil:
+				if response.ContentType != "" {
+					w.Header().Set("Content-Type", response.ContentType)
+				}
+				w.Header().Set(replayedHeader, "true")
+				w.WriteHeader(response.Status)
+				_, _ = w.Write(response.Body)
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				jsonResponse(
+					w,
+					http.StatusConflict,
+					BadRequestResponse{
+						Error: "request with the same idempotency key is in progress",
+						Msg:   "retry later",
+					},
+				)
+				return
+			case <-time.After(idempotencyPollInterval):
+			}
+		}
+	}
+}
+
+func (s *Server) serveIdempotent(
+	w http.ResponseWriter,
+	request *http.Request,
+	params httprouter.Params,
+	key string,
+	next httprouter.Handle,
+) {
+	recorder := &responseRecorder{ResponseWriter: w}
+	next(recorder, request, params)
+
+	// Client may be gone by now, yet the response must be stored for its retry
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	if recorder.status >= http.StatusInternalServerError {
+		_ = s.app.AbandonIdempotentRequest(ctx, key)
+		return
+	}
+
+	status := recorder.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	_ = s.app.CompleteIdempotentRequest(
+		ctx,
+		key,
+		types.IdempotentResponse{
+			Status:      status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		},
+		s.idempotencyTTL,
+	)
+}
+
+// idempotencyScope keeps keys of different clients and endpoints apart.
+// Body is not a part of the scope, it is checked against hash stored with the key.
+func idempotencyScope(request *http.Request) string {
+	var scope string
+	if caller, ok := request.Context().Value(callerKey{}).(*types.APIKey); ok && caller.ID != uuid.Nil {
+		scope = caller.ID.String() + " "
+	}
+	return scope + request.Method + " " + request.URL.RequestURI() + " "
+}
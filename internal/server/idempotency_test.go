@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/storage/memory"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func (fa *fakeApp) BeginIdempotentRequest(
+	ctx context.Context,
+	key, bodyHash string,
+	lease time.Duration,
+) (*types.IdempotentResponse, bool, error) {
+	return fa.idempotency.BeginRequest(ctx, key, bodyHash, lease)
+}
+
+func (fa *fakeApp) CompleteIdempotentRequest(
+	ctx context.Context,
+	key string,
+	response types.IdempotentResponse,
+	ttl time.Duration,
+) error {
+	return fa.idempotency.CompleteRequest(ctx, key, response, ttl)
+}
+
+func (fa *fakeApp) AbandonIdempotentRequest(ctx context.Context, key string) error {
+	return fa.idempotency.AbandonRequest(ctx, key)
+}
+
+// RegisterClick is slow enough for retries to arrive while click is registered.
+func (fa *fakeApp) RegisterClick(context.Context, types.Click) error {
+	atomic.AddInt32(&fa.clicks, 1)
+	time.Sleep(50 * time.Millisecond)
+	return nil
+}
+
+// ChooseBanners registers a show with new impression for every slot.
+func (fa *fakeApp) ChooseBanners(
+	_ context.Context,
+	slotIDs []uuid.UUID,
+	groupID uuid.UUID,
+	_ types.Visitor,
+) ([]types.Impression, error) {
+	impressions := make([]types.Impression, 0, len(slotIDs))
+	for _, slotID := range slotIDs {
+		impressions = append(impressions, types.Impression{
+			ImpressionID: uuid.New(),
+			Rotation:     types.Rotation{BannerID: uuid.New(), SlotID: slotID, GroupID: groupID},
+		})
+	}
+	return impressions, nil
+}
+
+func TestIdempotency(t *testing.T) {
+	application := &fakeApp{idempotency: memory.NewIdempotencyStore()}
+	srv, err := NewServer(application, config.Server{Timeout: "1s", DisableAuth: true})
+	require.NoError(t, err)
+	handler := srv.httpServer.Handler
+
+	send := func(method, target, body, key string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		if key != "" {
+			request.Header.Set(idempotencyKeyHeader, key)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+	clickTarget := "/group/" + uuid.NewString() + "/slots/" + uuid.NewString() + "/banners/" + uuid.NewString() + "/click"
+
+	t.Run("check concurrent retries register click once", func(t *testing.T) {
+		atomic.StoreInt32(&application.clicks, 0)
+
+		const retries = 10
+		codes := make([]int, retries)
+		var wg sync.WaitGroup
+		for i := 0; i < retries; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				codes[i] = send(http.MethodPost, clickTarget, "", "concurrent").Code
+			}(i)
+		}
+		wg.Wait()
+
+		require.Equal(t, int32(1), atomic.LoadInt32(&application.clicks))
+		for _, code := range codes {
+			require.Equal(t, http.StatusNoContent, code)
+		}
+	})
+
+	t.Run("check retry after response is replayed", func(t *testing.T) {
+		atomic.StoreInt32(&application.clicks, 0)
+
+		first := send(http.MethodPost, clickTarget, "", "sequential")
+		require.Equal(t, http.StatusNoContent, first.Code)
+		require.Empty(t, first.Header().Get(replayedHeader))
+
+		retry := send(http.MethodPost, clickTarget, "", "sequential")
+		require.Equal(t, http.StatusNoContent, retry.Code)
+		require.Equal(t, "true", retry.Header().Get(replayedHeader))
+		require.Equal(t, int32(1), atomic.LoadInt32(&application.clicks))
+	})
+
+	t.Run("check requests without key are not deduplicated", func(t *testing.T) {
+		atomic.StoreInt32(&application.clicks, 0)
+
+		for i := 0; i < 2; i++ {
+			require.Equal(t, http.StatusNoContent, send(http.MethodPost, clickTarget, "", "").Code)
+		}
+		require.Equal(t, int32(2), atomic.LoadInt32(&application.clicks))
+	})
+
+	t.Run("check batch show retry gets the same impressions", func(t *testing.T) {
+		target := "/group/" + uuid.NewString() + "/banners"
+		body := `{"SlotIDs": ["` + uuid.NewString() + `", "` + uuid.NewString() + `"]}`
+
+		first := send(http.MethodPost, target, body, "batch")
+		require.Equal(t, http.StatusOK, first.Code, first.Body.String())
+		retry := send(http.MethodPost, target, body, "batch")
+		require.Equal(t, http.StatusOK, retry.Code)
+		require.Equal(t, first.Header().Get("Content-Type"), retry.Header().Get("Content-Type"))
+		require.Equal(t, first.Body.String(), retry.Body.String())
+
+		var impressions []types.Impression
+		require.NoError(t, json.Unmarshal(retry.Body.Bytes(), &impressions))
+		require.Len(t, impressions, 2)
+
+		other := send(http.MethodPost, target, body, "other batch")
+		require.NotEqual(t, first.Body.String(), other.Body.String())
+	})
+
+	t.Run("check key reused with another body is rejected", func(t *testing.T) {
+		target := "/group/" + uuid.NewString() + "/banners"
+		body := `{"SlotIDs": ["` + uuid.NewString() + `"]}`
+		require.Equal(t, http.StatusOK, send(http.MethodPost, target, body, "reused").Code)
+
+		other := `{"SlotIDs": ["` + uuid.NewString() + `"]}`
+		response := send(http.MethodPost, target, other, "reused")
+		require.Equal(t, http.StatusUnprocessableEntity, response.Code)
+		require.Empty(t, response.Header().Get(replayedHeader))
+
+		retry := send(http.MethodPost, target, body, "reused")
+		require.Equal(t, http.StatusOK, retry.Code)
+		require.Equal(t, "true", retry.Header().Get(replayedHeader))
+	})
+
+	t.Run("check too large body is rejected", func(t *testing.T) {
+		target := "/group/" + uuid.NewString() + "/banners"
+		body := `{"SlotIDs": [], "Padding": "` + strings.Repeat("x", maxIdempotentBodySize) + `"}`
+		response := send(http.MethodPost, target, body, "large")
+		require.Equal(t, http.StatusRequestEntityTooLarge, response.Code)
+	})
+
+	t.Run("check too long key is rejected", func(t *testing.T) {
+		response := send(http.MethodPost, clickTarget, "", strings.Repeat("k", maxIdempotencyKeyLength+1))
+		require.Equal(t, http.StatusBadRequest, response.Code)
+	})
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestMetrics(t *testing.T) {
+	handler := newTestServer(t, &fakeApp{}, config.Admin{})
+
+	requests := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/banners/:banner_id", "400")
+	before := testutil.ToFloat64(requests)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/banners/not-uuid", nil))
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	require.Equal(t, before+1, testutil.ToFloat64(requests))
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(
+		t,
+		recorder.Body.String(),
+		`rotator_http_request_duration_seconds_count{method="GET",route="/banners/:banner_id"}`,
+	)
+}
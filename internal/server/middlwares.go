@@ -1,11 +1,20 @@
 package server
 
 import (
+	"context"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/FedoseevAlex/banner-rotation/internal/metrics"
+	"github.com/FedoseevAlex/banner-rotation/internal/tracing"
 	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
 	"github.com/julienschmidt/httprouter"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type responseWriterWrapper struct {
@@ -18,24 +27,60 @@ func (rw *responseWriterWrapper) WriteHeader(statusCode int) {
 	rw.ResponseWriter.WriteHeader(statusCode)
 }
 
-func loggingMiddleware(next httprouter.Handle, logger types.Logger) httprouter.Handle {
+type callerKey struct{}
+
+// setCaller records API key authenticated by authorize as caller of request,
+// so that loggingMiddleware can log it.
+func setCaller(ctx context.Context, key types.APIKey) {
+	if caller, ok := ctx.Value(callerKey{}).(*types.APIKey); ok {
+		*caller = key
+	}
+}
+
+// loggingMiddleware logs request and reports it to metrics under route it was matched with.
+// Request is traced in a span which continues trace from W3C trace context headers.
+func loggingMiddleware(route string, next httprouter.Handle, logger types.Logger) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 		wrappedResponseWriter := &responseWriterWrapper{ResponseWriter: w, status: http.StatusOK}
 
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.StartKind(
+			ctx,
+			r.Method+" "+route,
+			trace.SpanKindServer,
+			semconv.HTTPServerAttributesFromHTTPRequest("", route, r)...,
+		)
+
+		var caller types.APIKey
+		ctx = context.WithValue(ctx, callerKey{}, &caller)
+
 		begin := time.Now()
-		next(wrappedResponseWriter, r, params)
+		next(wrappedResponseWriter, r.WithContext(ctx), params)
 		duration := time.Since(begin)
 
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(wrappedResponseWriter.status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(wrappedResponseWriter.status, trace.SpanKindServer))
+		span.End()
+
+		metrics.HTTPRequests.WithLabelValues(r.Method, route, strconv.Itoa(wrappedResponseWriter.status)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(r.Method, route).Observe(duration.Seconds())
+
 		info := map[string]interface{}{
 			"ip":          r.RemoteAddr,
 			"timestamp":   begin.Format(time.RFC822Z),
 			"method":      r.Method,
 			"path":        r.URL.Path,
+			"route":       route,
 			"HTTP ver.":   r.Proto,
 			"status code": wrappedResponseWriter.status,
 			"latency":     duration.String(),
 			"user agent":  r.UserAgent(),
 		}
+		if caller.ID != uuid.Nil {
+			info["caller"] = caller.Name
+			info["caller id"] = caller.ID.String()
+			info["caller role"] = caller.Role
+		}
 		logger.Trace("Request", info)
 	}
 }
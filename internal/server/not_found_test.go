@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func (fa *fakeApp) SetSlotSettings(context.Context, uuid.UUID, types.SlotSettings) error {
+	return types.ErrNotFound
+}
+
+func (fa *fakeApp) SetFrequencyCap(context.Context, uuid.UUID, uuid.UUID, uuid.UUID, types.FrequencyCap) error {
+	return types.ErrNotFound
+}
+
+func (fa *fakeApp) SetRotationState(context.Context, uuid.UUID, uuid.UUID, uuid.UUID, string) error {
+	return types.ErrNotFound
+}
+
+func (fa *fakeApp) SetRotationOverride(context.Context, uuid.UUID, uuid.UUID, uuid.UUID, types.RotationOverride) error {
+	return types.ErrNotFound
+}
+
+func (fa *fakeApp) DeleteGroupRule(context.Context, uuid.UUID, int) error {
+	return types.ErrNotFound
+}
+
+func (fa *fakeApp) RevokeAPIKey(context.Context, uuid.UUID) error {
+	return types.ErrNotFound
+}
+
+func TestNotFound(t *testing.T) {
+	handler := newTestServer(t, &fakeApp{}, config.Admin{})
+	rotationPath := "/group/" + uuid.NewString() + "/slots/" + uuid.NewString() + "/banners/" + uuid.NewString()
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+	}{
+		{name: "slot settings", method: http.MethodPut, target: "/slots/" + uuid.NewString() + "/settings", body: `{}`},
+		{name: "frequency cap", method: http.MethodPut, target: rotationPath + "/cap", body: `{"MaxShows": 0}`},
+		{name: "rotation state", method: http.MethodPut, target: rotationPath + "/state", body: `{"State": "paused"}`},
+		{name: "rotation override", method: http.MethodPut, target: rotationPath + "/override", body: `{"Pinned": true}`},
+		{name: "group rule", method: http.MethodDelete, target: "/groups/" + uuid.NewString() + "/rules/1"},
+		{name: "API key", method: http.MethodDelete, target: "/api-keys/" + uuid.NewString()},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run("check missing "+tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			require.Equal(t, http.StatusNotFound, recorder.Code, recorder.Body.String())
+		})
+	}
+}
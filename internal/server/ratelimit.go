@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Endpoints are limited in classes, every class has its own bucket per client.
+const (
+	limitChoose = "choose"
+	limitClick  = "click"
+	limitAdmin  = "admin"
+	// limitAuth is checked by IP address before API key is authenticated,
+	// so that requests with invalid keys are limited too.
+	limitAuth = "auth"
+)
+
+// parseLimits converts limits from config to token buckets.
+// Classes with zero requests are not limited.
+func parseLimits(cfg config.RateLimit) (map[string]types.RateLimit, error) {
+	limits := make(map[string]types.RateLimit)
+	for _, class := range []struct {
+		name  string
+		limit config.Limit
+	}{
+		{name: limitChoose, limit: cfg.Choose},
+		{name: limitClick, limit: cfg.Click},
+		{name: limitAdmin, limit: cfg.Admin},
+		{name: limitAuth, limit: cfg.Auth},
+	} {
+		rateLimit, err := parseLimit(class.limit)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", class.name, err)
+		}
+		if rateLimit.Rate > 0 {
+			limits[class.name] = rateLimit
+		}
+	}
+	return limits, nil
+}
+
+// CheckRateLimits reports the first invalid limit in config.
+func CheckRateLimits(cfg config.RateLimit) error {
+	_, err := parseLimits(cfg)
+	return err
+}
+
+func parseLimit(limit config.Limit) (types.RateLimit, error) {
+	if limit.Requests == 0 {
+		return types.RateLimit{}, nil
+	}
+	if limit.Requests < 0 {
+		return types.RateLimit{}, fmt.Errorf("requests must not be negative, got %d", limit.Requests)
+	}
+
+	per, err := time.ParseDuration(limit.Per)
+	if err != nil {
+		return types.RateLimit{}, err
+	}
+	if per <= 0 {
+		return types.RateLimit{}, fmt.Errorf("per must be positive, got %s", limit.Per)
+	}
+
+	burst := limit.Burst
+	switch {
+	case burst < 0:
+		return types.RateLimit{}, fmt.Errorf("burst must not be negative, got %d", burst)
+	case burst == 0:
+		burst = limit.Requests
+	}
+
+	return types.RateLimit{
+		Rate:  float64(limit.Requests) / per.Seconds(),
+		Burst: burst,
+	}, nil
+}
+
+// rateLimit rejects requests of client which exceeded limit of endpoint class
+// with 429 status and Retry-After header. Nothing is checked if class is not limited.
+func (s *Server) rateLimit(class string, next httprouter.Handle) httprouter.Handle {
+	limit, ok := s.limits[class]
+	if !ok {
+		return next
+	}
+
+	return func(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		ctx, cancel := context.WithTimeout(request.Context(), s.timeout)
+		defer cancel()
+
+		retryAfter, err := s.app.AllowRequest(ctx, class+":"+s.clientKey(request), limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if retryAfter > 0 {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			jsonResponse(
+				w,
+				http.StatusTooManyRequests,
+				BadRequestResponse{
+					Error: fmt.Sprintf("rate limit of %s requests exceeded", class),
+					Msg:   "too many requests",
+				},
+			)
+			return
+		}
+
+		next(w, request, params)
+	}
+}
+
+// clientKey identifies client by API key authenticated by authorize
+// or by IP address before authentication or if auth is disabled.
+func (s *Server) clientKey(request *http.Request) string {
+	if caller, ok := request.Context().Value(callerKey{}).(*types.APIKey); ok && caller.ID != uuid.Nil {
+		return "key:" + caller.ID.String()
+	}
+	return "ip:" + s.clientIP(request)
+}
+
+// clientIP returns IP address of client from header set by reverse proxy
+// if it is configured or from remote address of connection.
+func (s *Server) clientIP(request *http.Request) string {
+	if s.clientIPHeader != "" {
+		// Proxies append addresses, so only the last one is added by our proxy.
+		// Entries before it are sent by client and can be spoofed.
+		if forwarded := request.Header.Get(s.clientIPHeader); forwarded != "" {
+			parts := strings.Split(forwarded, ",")
+			return strings.TrimSpace(parts[len(parts)-1])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/storage/memory"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func (fa *fakeApp) AllowRequest(ctx context.Context, key string, limit types.RateLimit) (time.Duration, error) {
+	return fa.limiter.TakeToken(ctx, key, limit)
+}
+
+func TestRateLimit(t *testing.T) {
+	newHandler := func(t *testing.T, cfg config.Server) http.Handler {
+		t.Helper()
+		application := &fakeApp{
+			keys: map[string]types.APIKey{
+				"first-key":  {ID: [16]byte{1}, Role: types.RoleAdmin},
+				"second-key": {ID: [16]byte{2}, Role: types.RoleAdmin},
+			},
+			limiter: memory.NewRateLimiter(),
+		}
+		cfg.Timeout = "1s"
+		srv, err := NewServer(application, cfg)
+		require.NoError(t, err)
+		return srv.httpServer.Handler
+	}
+	send := func(handler http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, nil)
+		request.RemoteAddr = "192.0.2.1:1234"
+		for name, values := range header {
+			request.Header[name] = values
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	limits := config.RateLimit{
+		Choose: config.Limit{Requests: 1, Per: "1m"},
+		Admin:  config.Limit{Requests: 2, Per: "1h", Burst: 2},
+	}
+
+	t.Run("check client exceeding limit gets retry after", func(t *testing.T) {
+		handler := newHandler(t, config.Server{DisableAuth: true, RateLimit: limits})
+
+		for i := 0; i < 2; i++ {
+			require.Equal(t, http.StatusOK, send(handler, http.MethodGet, "/banners", nil).Code)
+		}
+
+		response := send(handler, http.MethodGet, "/banners", nil)
+		require.Equal(t, http.StatusTooManyRequests, response.Code)
+		require.Equal(t, "1800", response.Header().Get("Retry-After"))
+	})
+
+	t.Run("check classes have separate limits", func(t *testing.T) {
+		handler := newHandler(t, config.Server{DisableAuth: true, RateLimit: limits})
+
+		require.Equal(t, http.StatusBadRequest, send(handler, http.MethodGet, "/slots/not-uuid/banner", nil).Code)
+		require.Equal(t, http.StatusTooManyRequests, send(handler, http.MethodGet, "/slots/not-uuid/banner", nil).Code)
+		require.Equal(t, http.StatusOK, send(handler, http.MethodGet, "/banners", nil).Code)
+	})
+
+	t.Run("check unlimited class is not limited", func(t *testing.T) {
+		handler := newHandler(t, config.Server{DisableAuth: true, RateLimit: limits})
+
+		for i := 0; i < 5; i++ {
+			require.Equal(t, http.StatusBadRequest, send(handler, http.MethodPost, "/impressions/not-uuid/conversions", nil).Code)
+		}
+	})
+
+	t.Run("check clients are told apart by client IP header", func(t *testing.T) {
+		cfg := config.Server{DisableAuth: true, RateLimit: limits}
+		cfg.RateLimit.ClientIPHeader = "X-Forwarded-For"
+		handler := newHandler(t, cfg)
+
+		first := http.Header{"X-Forwarded-For": {"198.51.100.1"}}
+		spoofed := http.Header{"X-Forwarded-For": {"203.0.113.7, 198.51.100.1"}}
+		second := http.Header{"X-Forwarded-For": {"203.0.113.7, 198.51.100.2"}}
+		require.Equal(t, http.StatusBadRequest, send(handler, http.MethodGet, "/slots/not-uuid/banner", first).Code)
+		require.Equal(t, http.StatusTooManyRequests, send(handler, http.MethodGet, "/slots/not-uuid/banner", spoofed).Code)
+		require.Equal(t, http.StatusBadRequest, send(handler, http.MethodGet, "/slots/not-uuid/banner", second).Code)
+	})
+
+	t.Run("check clients are told apart by API key", func(t *testing.T) {
+		handler := newHandler(t, config.Server{RateLimit: limits})
+
+		first := http.Header{"Authorization": {"Bearer first-key"}}
+		second := http.Header{"Authorization": {"Bearer second-key"}}
+		require.Equal(t, http.StatusBadRequest, send(handler, http.MethodGet, "/slots/not-uuid/banner", first).Code)
+		require.Equal(t, http.StatusTooManyRequests, send(handler, http.MethodGet, "/slots/not-uuid/banner", first).Code)
+		require.Equal(t, http.StatusBadRequest, send(handler, http.MethodGet, "/slots/not-uuid/banner", second).Code)
+	})
+
+	t.Run("check invalid API keys are limited by IP", func(t *testing.T) {
+		cfg := config.Server{RateLimit: config.RateLimit{Auth: config.Limit{Requests: 2, Per: "1h"}}}
+		handler := newHandler(t, cfg)
+
+		invalid := http.Header{"Authorization": {"Bearer guessed-key"}}
+		for i := 0; i < 2; i++ {
+			require.Equal(t, http.StatusUnauthorized, send(handler, http.MethodGet, "/banners", invalid).Code)
+		}
+		require.Equal(t, http.StatusTooManyRequests, send(handler, http.MethodGet, "/banners", invalid).Code)
+		valid := http.Header{"Authorization": {"Bearer first-key"}}
+		require.Equal(t, http.StatusTooManyRequests, send(handler, http.MethodGet, "/banners", valid).Code)
+	})
+
+	t.Run("check invalid limits are rejected", func(t *testing.T) {
+		for _, limit := range []config.Limit{
+			{Requests: 1, Per: "minute"},
+			{Requests: 1, Per: "0s"},
+			{Requests: -1, Per: "1s"},
+			{Requests: 1, Per: "1s", Burst: -1},
+		} {
+			_, err := NewServer(&fakeApp{}, config.Server{Timeout: "1s", RateLimit: config.RateLimit{Click: limit}})
+			require.Error(t, err, "limit %+v", limit)
+		}
+	})
+}
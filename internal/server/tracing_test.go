@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/FedoseevAlex/banner-rotation/internal/tracing"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRequestSpan(t *testing.T) {
+	exporter, restore := tracing.UseInMemory()
+	defer restore()
+
+	handler := newTestServer(t, &fakeApp{}, config.Admin{})
+
+	request := httptest.NewRequest(http.MethodGet, "/banners/not-uuid", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+
+	span := spans[0]
+	require.Equal(t, "GET /banners/:banner_id", span.Name)
+	require.Equal(t, trace.SpanKindServer, span.SpanKind)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+	require.True(t, span.Parent.IsRemote())
+	// Client errors are not errors of the server
+	require.Equal(t, codes.Unset, span.Status.Code)
+}
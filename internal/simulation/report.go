@@ -0,0 +1,140 @@
+package simulation
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// Point is a snapshot of simulation metrics after Step steps.
+type Point struct {
+	Step             int
+	CumulativeRegret float64
+	BestArmShare     float64
+	CTR              float64
+}
+
+type Report struct {
+	Steps  int
+	Clicks int
+	// CumulativeRegret is expected amount of clicks lost
+	// compared to always showing the best banner.
+	CumulativeRegret float64
+	// BestArmShare is share of shows given to the banner with the highest CTR.
+	BestArmShare float64
+	CTR          float64
+	BestBannerID uuid.UUID
+	// LoggedShows is amount of logged shows replayed. It is set only for replays.
+	LoggedShows int `json:",omitempty"`
+	Series      []Point
+}
+
+func (r Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteCSV writes report series, one point per line.
+func (r Report) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"step", "cumulative_regret", "best_arm_share", "ctr"})
+	if err != nil {
+		return err
+	}
+
+	for _, point := range r.Series {
+		err = writer.Write([]string{
+			strconv.Itoa(point.Step),
+			strconv.FormatFloat(point.CumulativeRegret, 'f', -1, 64),
+			strconv.FormatFloat(point.BestArmShare, 'f', -1, 64),
+			strconv.FormatFloat(point.CTR, 'f', -1, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// tracker accumulates metrics of simulation step by step.
+type tracker struct {
+	ctrs         map[uuid.UUID]float64
+	bestBannerID uuid.UUID
+	bestCTR      float64
+	reportEvery  int
+
+	steps         int
+	clicks        int
+	bestArmShows  int
+	regret        float64
+	series        []Point
+	lastSeriesAdd int
+}
+
+func newTracker(ctrs map[uuid.UUID]float64, reportEvery int) *tracker {
+	if reportEvery <= 0 {
+		reportEvery = defaultReportEvery
+	}
+
+	t := &tracker{ctrs: ctrs, reportEvery: reportEvery, bestCTR: math.Inf(-1)}
+	for bannerID, ctr := range ctrs {
+		// Ties are broken by uuid to keep reports reproducible
+		if ctr > t.bestCTR || (ctr == t.bestCTR && bannerID.String() < t.bestBannerID.String()) {
+			t.bestCTR = ctr
+			t.bestBannerID = bannerID
+		}
+	}
+	return t
+}
+
+func (t *tracker) track(bannerID uuid.UUID, clicked bool) {
+	t.steps++
+	if clicked {
+		t.clicks++
+	}
+	if t.ctrs[bannerID] == t.bestCTR {
+		t.bestArmShows++
+	}
+	t.regret += t.bestCTR - t.ctrs[bannerID]
+
+	if t.steps%t.reportEvery == 0 {
+		t.series = append(t.series, t.point())
+		t.lastSeriesAdd = t.steps
+	}
+}
+
+func (t *tracker) point() Point {
+	if t.steps == 0 {
+		return Point{}
+	}
+	return Point{
+		Step:             t.steps,
+		CumulativeRegret: t.regret,
+		BestArmShare:     float64(t.bestArmShows) / float64(t.steps),
+		CTR:              float64(t.clicks) / float64(t.steps),
+	}
+}
+
+func (t *tracker) report() Report {
+	last := t.point()
+	if t.steps != t.lastSeriesAdd {
+		t.series = append(t.series, last)
+	}
+
+	return Report{
+		Steps:            t.steps,
+		Clicks:           t.clicks,
+		CumulativeRegret: last.CumulativeRegret,
+		BestArmShare:     last.BestArmShare,
+		CTR:              last.CTR,
+		BestBannerID:     t.bestBannerID,
+		Series:           t.series,
+	}
+}
@@ -0,0 +1,177 @@
+package simulation
+
+import (
+	"errors"
+	"math/rand"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+const defaultReportEvery = 100
+
+var ErrNoArms = errors.New("scenario has no arms")
+
+// Arm is a banner with known probability to be clicked.
+type Arm struct {
+	BannerID uuid.UUID
+	CTR      float64
+}
+
+// Scenario describes synthetic traffic.
+type Scenario struct {
+	Arms  []Arm
+	Steps int
+	Seed  int64
+	// ReportEvery is amount of steps between points of report series.
+	ReportEvery int
+}
+
+// Run replays synthetic traffic of scenario through rotator.
+// Each step rotator chooses an arm and the arm is clicked with its CTR.
+func Run(rotator types.Rotator, scenario Scenario) (Report, error) {
+	if len(scenario.Arms) == 0 {
+		return Report{}, ErrNoArms
+	}
+
+	rnd := rand.New(rand.NewSource(scenario.Seed)) //nolint:gosec
+	slotID, groupID := uuid.New(), uuid.New()
+
+	ctrs := make(map[uuid.UUID]float64, len(scenario.Arms))
+	rotations := make([]types.Rotation, 0, len(scenario.Arms))
+	for _, arm := range scenario.Arms {
+		if arm.BannerID == uuid.Nil {
+			arm.BannerID = uuid.New()
+		}
+		ctrs[arm.BannerID] = arm.CTR
+		rotations = append(rotations, types.Rotation{BannerID: arm.BannerID, SlotID: slotID, GroupID: groupID})
+	}
+
+	tracker := newTracker(ctrs, scenario.ReportEvery)
+	for step := 0; step < scenario.Steps; step++ {
+		rotator.Load(rotations, int64(step))
+		chosen := indexOf(rotations, rotator.Rotate().BannerID)
+		if chosen < 0 {
+			return Report{}, errors.New("rotator has chosen unknown banner")
+		}
+
+		clicked := rnd.Float64() < ctrs[rotations[chosen].BannerID]
+		rotations[chosen].Shows++
+		if clicked {
+			rotations[chosen].Clicks++
+		}
+		tracker.track(rotations[chosen].BannerID, clicked)
+	}
+
+	return tracker.report(), nil
+}
+
+// Replay evaluates rotator on logged shows using rejection sampling:
+// only shows of banners the rotator would have chosen itself are taken into account.
+// Clicks are attributed to shows by impression, so shows logged without impression are never clicked.
+// Per-banner CTR observed in the log is used as the true CTR to estimate regret.
+// The estimate is unbiased if logged banners were chosen uniformly at random.
+func Replay(rotator types.Rotator, events []types.RotationEvent, reportEvery int) (Report, error) {
+	clicked := make(map[uuid.UUID]bool)
+	for _, e := range events {
+		if e.Type == types.EventTypeClick && e.ImpressionID != uuid.Nil {
+			clicked[e.ImpressionID] = true
+		}
+	}
+
+	var (
+		shows     = make(map[uuid.UUID]int)
+		clicks    = make(map[uuid.UUID]int)
+		rotations []types.Rotation
+	)
+	for _, e := range events {
+		if e.Type != types.EventTypeShow {
+			continue
+		}
+		if _, ok := shows[e.BannerID]; !ok {
+			rotations = append(rotations, types.Rotation{BannerID: e.BannerID, SlotID: e.SlotID, GroupID: e.GroupID})
+		}
+		shows[e.BannerID]++
+		if clicked[e.ImpressionID] {
+			clicks[e.BannerID]++
+		}
+	}
+
+	if len(rotations) == 0 {
+		return Report{}, ErrNoArms
+	}
+
+	ctrs := make(map[uuid.UUID]float64, len(shows))
+	for bannerID, bannerShows := range shows {
+		ctrs[bannerID] = float64(clicks[bannerID]) / float64(bannerShows)
+	}
+
+	var (
+		tracker     = newTracker(ctrs, reportEvery)
+		trials      int64
+		loggedShows int
+	)
+	for _, e := range events {
+		if e.Type != types.EventTypeShow {
+			continue
+		}
+		loggedShows++
+
+		rotator.Load(rotations, trials)
+		chosen := indexOf(rotations, rotator.Rotate().BannerID)
+		if chosen < 0 || rotations[chosen].BannerID != e.BannerID {
+			continue
+		}
+
+		trials++
+		rotations[chosen].Shows++
+		if clicked[e.ImpressionID] {
+			rotations[chosen].Clicks++
+		}
+		tracker.track(e.BannerID, clicked[e.ImpressionID])
+	}
+
+	report := tracker.report()
+	report.LoggedShows = loggedShows
+	return report, nil
+}
+
+// ScenarioFromEvents makes synthetic scenario with per-banner CTRs observed in logged events.
+func ScenarioFromEvents(events []types.RotationEvent, steps int, seed int64) Scenario {
+	var (
+		shows   = make(map[uuid.UUID]int)
+		clicks  = make(map[uuid.UUID]int)
+		banners []uuid.UUID
+	)
+	for _, e := range events {
+		switch e.Type {
+		case types.EventTypeShow:
+			if _, ok := shows[e.BannerID]; !ok {
+				banners = append(banners, e.BannerID)
+			}
+			shows[e.BannerID]++
+		case types.EventTypeClick:
+			clicks[e.BannerID]++
+		}
+	}
+
+	scenario := Scenario{Steps: steps, Seed: seed}
+	for _, bannerID := range banners {
+		ctr := float64(clicks[bannerID]) / float64(shows[bannerID])
+		if ctr > 1 {
+			ctr = 1
+		}
+		scenario.Arms = append(scenario.Arms, Arm{BannerID: bannerID, CTR: ctr})
+	}
+
+	return scenario
+}
+
+func indexOf(rotations []types.Rotation, bannerID uuid.UUID) int {
+	for i, rotation := range rotations {
+		if rotation.BannerID == bannerID {
+			return i
+		}
+	}
+	return -1
+}
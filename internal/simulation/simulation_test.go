@@ -0,0 +1,142 @@
+package simulation
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/rotators/mab"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func testScenario() Scenario {
+	return Scenario{
+		Arms: []Arm{
+			{BannerID: uuid.New(), CTR: 0.01},
+			{BannerID: uuid.New(), CTR: 0.02},
+			{BannerID: uuid.New(), CTR: 0.03},
+			{BannerID: uuid.New(), CTR: 0.10},
+		},
+		Steps:       20000,
+		Seed:        42,
+		ReportEvery: 1000,
+	}
+}
+
+// UCB1 regression tests. Thresholds are loose enough to survive harmless
+// changes of the algorithm but catch it degrading to uniform choice.
+func TestUCB1(t *testing.T) {
+	scenario := testScenario()
+
+	report, err := Run(&mab.MultiArmedBandit{}, scenario)
+	require.NoError(t, err)
+
+	t.Run("check best banner gets most shows", func(t *testing.T) {
+		require.Equal(t, scenario.Arms[3].BannerID, report.BestBannerID)
+		require.Greater(t, report.BestArmShare, 0.5)
+	})
+
+	t.Run("check regret is far below uniform choice", func(t *testing.T) {
+		// Uniform choice loses (0.10 - 0.04) clicks per step on average
+		uniformRegret := 0.06 * float64(scenario.Steps)
+		require.Less(t, report.CumulativeRegret, uniformRegret/2)
+	})
+
+	t.Run("check regret grows sublinearly", func(t *testing.T) {
+		require.Len(t, report.Series, scenario.Steps/scenario.ReportEvery)
+
+		half := report.Series[len(report.Series)/2-1]
+		secondHalfRegret := report.CumulativeRegret - half.CumulativeRegret
+		require.Less(t, secondHalfRegret, half.CumulativeRegret)
+	})
+
+	t.Run("check run is reproducible", func(t *testing.T) {
+		again, err := Run(&mab.MultiArmedBandit{}, scenario)
+		require.NoError(t, err)
+		require.Equal(t, report, again)
+	})
+}
+
+func TestRunWithoutArms(t *testing.T) {
+	_, err := Run(&mab.MultiArmedBandit{}, Scenario{Steps: 10})
+	require.ErrorIs(t, err, ErrNoArms)
+}
+
+// uniformLog logs shows of banners chosen uniformly at random.
+func uniformLog(scenario Scenario) []types.RotationEvent {
+	rnd := rand.New(rand.NewSource(scenario.Seed))
+	slotID, groupID := uuid.New(), uuid.New()
+	stamp := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	var events []types.RotationEvent
+	for i := 0; i < scenario.Steps; i++ {
+		arm := scenario.Arms[rnd.Intn(len(scenario.Arms))]
+		impressionID := uuid.New()
+		stamp = stamp.Add(time.Second)
+
+		events = append(events, types.RotationEvent{
+			BannerID: arm.BannerID,
+			SlotID:   slotID,
+			GroupID:  groupID,
+			Event:    types.Event{Type: types.EventTypeShow, Timestamp: stamp, ImpressionID: impressionID},
+		})
+		if rnd.Float64() < arm.CTR {
+			events = append(events, types.RotationEvent{
+				BannerID: arm.BannerID,
+				SlotID:   slotID,
+				GroupID:  groupID,
+				Event:    types.Event{Type: types.EventTypeClick, Timestamp: stamp, ImpressionID: impressionID},
+			})
+		}
+	}
+	return events
+}
+
+func TestReplay(t *testing.T) {
+	scenario := testScenario()
+	scenario.Steps = 40000
+	events := uniformLog(scenario)
+
+	report, err := Replay(&mab.MultiArmedBandit{}, events, 1000)
+	require.NoError(t, err)
+
+	require.Equal(t, scenario.Steps, report.LoggedShows)
+	require.Greater(t, report.Steps, 0)
+	require.Equal(t, scenario.Arms[3].BannerID, report.BestBannerID)
+	require.Greater(t, report.BestArmShare, 0.5)
+
+	t.Run("check scenario from events", func(t *testing.T) {
+		fromEvents := ScenarioFromEvents(events, 100, 1)
+		require.Len(t, fromEvents.Arms, len(scenario.Arms))
+		for _, arm := range fromEvents.Arms {
+			require.InDelta(t, ctrOf(scenario, arm.BannerID), arm.CTR, 0.02)
+		}
+	})
+}
+
+func ctrOf(scenario Scenario, bannerID uuid.UUID) float64 {
+	for _, arm := range scenario.Arms {
+		if arm.BannerID == bannerID {
+			return arm.CTR
+		}
+	}
+	return -1
+}
+
+func TestReportCSV(t *testing.T) {
+	report, err := Run(&mab.MultiArmedBandit{}, testScenario())
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = report.WriteCSV(&buf)
+	require.NoError(t, err)
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, len(report.Series)+1)
+	require.Equal(t, []string{"step", "cumulative_regret", "best_arm_share", "ctr"}, records[0])
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+type apiKey struct {
+	ID        uuid.UUID    `db:"id"`
+	Name      string       `db:"name"`
+	Role      string       `db:"role"`
+	KeyHash   string       `db:"key_hash"`
+	CreatedAt time.Time    `db:"created_at"`
+	Deleted   bool         `db:"deleted"`
+	DeletedAt sql.NullTime `db:"deleted_at"`
+}
+
+func (k apiKey) toAPIKey() types.APIKey {
+	return types.APIKey{
+		ID:        k.ID,
+		Name:      k.Name,
+		Role:      k.Role,
+		CreatedAt: k.CreatedAt,
+	}
+}
+
+func (s *Storage) AddAPIKey(ctx context.Context, key types.APIKey, keyHash string) error {
+	query := `
+	INSERT INTO api_keys (id, name, role, key_hash, created_at)
+	VALUES (:id, :name, :role, :key_hash, :created_at);
+	`
+
+	dbKey := apiKey{
+		ID:        key.ID,
+		Name:      key.Name,
+		Role:      key.Role,
+		KeyHash:   keyHash,
+		CreatedAt: key.CreatedAt,
+	}
+	_, err := s.db.NamedExecContext(ctx, query, dbKey)
+	return err
+}
+
+// GetAPIKeyByHash returns key which is not revoked.
+// sql.ErrNoRows is returned if there is no such key.
+func (s *Storage) GetAPIKeyByHash(ctx context.Context, keyHash string) (types.APIKey, error) {
+	query := `
+	SELECT * FROM api_keys WHERE key_hash=$1 AND deleted=FALSE
+	`
+	row := s.db.QueryRowxContext(ctx, query, keyHash)
+	if row.Err() != nil {
+		return types.APIKey{}, row.Err()
+	}
+
+	var dbKey apiKey
+	err := row.StructScan(&dbKey)
+	if err != nil {
+		return types.APIKey{}, err
+	}
+
+	return dbKey.toAPIKey(), nil
+}
+
+// GetAllAPIKeys returns keys which are not revoked ordered by creation time.
+func (s *Storage) GetAllAPIKeys(ctx context.Context) ([]types.APIKey, error) {
+	query := `
+	SELECT * FROM api_keys WHERE deleted=FALSE ORDER BY created_at, id
+	`
+
+	rows, err := s.db.QueryxContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []types.APIKey
+	for rows.Next() {
+		var k apiKey
+
+		err := rows.StructScan(&k)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, k.toAPIKey())
+	}
+
+	return keys, rows.Err()
+}
+
+func (s *Storage) DeleteAPIKey(ctx context.Context, keyID uuid.UUID) error {
+	query := `
+	UPDATE api_keys SET deleted=TRUE, deleted_at=now()
+	WHERE id=$1 AND deleted=FALSE
+	`
+	res, err := s.db.ExecContext(ctx, query, keyID)
+	if err != nil {
+		return err
+	}
+
+	rowsUpdated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsUpdated == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GetAssignment returns banner assigned to visitor in slot for group.
+// sql.ErrNoRows is returned if there is no actual assignment.
+func (s *Storage) GetAssignment(ctx context.Context, visitorID string, slotID, groupID uuid.UUID) (uuid.UUID, error) {
+	query := `
+	SELECT banner_id FROM assignments
+	WHERE
+	visitor_id=$1 AND slot_id=$2 AND group_id=$3
+	AND (expires_at IS NULL OR expires_at > now())
+	`
+
+	row := s.db.QueryRowxContext(ctx, query, visitorID, slotID, groupID)
+	if row.Err() != nil {
+		return uuid.Nil, row.Err()
+	}
+
+	var bannerID uuid.UUID
+	err := row.Scan(&bannerID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return bannerID, nil
+}
+
+// SaveAssignment assigns banner to visitor in slot for group replacing previous assignment.
+// Zero ttl means the assignment never expires.
+func (s *Storage) SaveAssignment(
+	ctx context.Context,
+	visitorID string,
+	bannerID, slotID, groupID uuid.UUID,
+	ttl time.Duration,
+) error {
+	query := `
+	INSERT INTO assignments (visitor_id, slot_id, group_id, banner_id, assigned_at, expires_at)
+	VALUES (
+		$1, $2, $3, $4, now(),
+		CASE WHEN $5::bigint > 0 THEN now() + $5::bigint * interval '1 second' END
+	)
+	ON CONFLICT (visitor_id, slot_id, group_id) DO UPDATE SET
+	banner_id=EXCLUDED.banner_id,
+	assigned_at=EXCLUDED.assigned_at,
+	expires_at=EXCLUDED.expires_at
+	`
+
+	_, err := s.db.ExecContext(ctx, query, visitorID, slotID, groupID, bannerID, int64(ttl.Seconds()))
+	return err
+}
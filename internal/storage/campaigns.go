@@ -0,0 +1,296 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+func (s *Storage) SetBannerCampaign(ctx context.Context, bannerID, campaignID uuid.UUID) error {
+	query := `
+	UPDATE banners SET campaign_id=$1 WHERE id=$2 AND deleted=FALSE
+	`
+
+	res, err := s.db.ExecContext(ctx, query, nullUUID(campaignID), bannerID)
+	if err != nil {
+		return err
+	}
+
+	rowsUpdated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsUpdated == 0 {
+		return errors.New("no banner was updated")
+	}
+
+	return nil
+}
+
+func (s *Storage) AddAdvertiser(ctx context.Context, advertiserInfo types.Advertiser) error {
+	query := `
+	INSERT INTO advertisers (id, description) VALUES (:id, :description);
+	`
+
+	dbAdvertiser := advertiser{
+		ID:          advertiserInfo.ID,
+		Description: advertiserInfo.Description,
+	}
+	_, err := s.db.NamedExecContext(ctx, query, dbAdvertiser)
+	return err
+}
+
+func (s *Storage) GetAdvertiser(ctx context.Context, advertiserID uuid.UUID) (types.Advertiser, error) {
+	query := `
+	SELECT * FROM advertisers WHERE id=$1 AND deleted=FALSE
+	`
+	row := s.db.QueryRowxContext(ctx, query, advertiserID)
+	if row.Err() != nil {
+		return types.Advertiser{}, row.Err()
+	}
+
+	var dbAdvertiser advertiser
+	err := row.StructScan(&dbAdvertiser)
+	if err != nil {
+		return types.Advertiser{}, err
+	}
+
+	return types.Advertiser{ID: dbAdvertiser.ID, Description: dbAdvertiser.Description}, nil
+}
+
+// DeleteAdvertiser deletes advertiser along with its campaigns.
+// Banners of the campaigns are taken out of campaigns.
+func (s *Storage) DeleteAdvertiser(ctx context.Context, advertiserID uuid.UUID) error {
+	deleteAdvertiserQuery := `
+	UPDATE advertisers SET deleted=TRUE, deleted_at=now()
+	WHERE id=$1 AND deleted=FALSE
+	`
+	releaseBannersQuery := `
+	UPDATE banners SET campaign_id=NULL
+	WHERE campaign_id IN (SELECT id FROM campaigns WHERE advertiser_id=$1)
+	`
+	deleteCampaignsQuery := `
+	UPDATE campaigns SET deleted=TRUE, deleted_at=now()
+	WHERE advertiser_id=$1 AND deleted=FALSE
+	`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = execTxQuery(tx, deleteAdvertiserQuery, advertiserID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, query := range []string{releaseBannersQuery, deleteCampaignsQuery} {
+		err = execTxQuery(tx, query, advertiserID)
+		switch {
+		case errors.Is(err, ErrNoRowWasAffected):
+		case err == nil:
+		default:
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Storage) AddCampaign(ctx context.Context, campaignInfo types.Campaign) error {
+	query := `
+	INSERT INTO campaigns (
+		id, advertiser_id, description,
+		total_shows_budget, total_clicks_budget, daily_shows_budget, daily_clicks_budget
+	)
+	VALUES (
+		:id, :advertiser_id, :description,
+		:total_shows_budget, :total_clicks_budget, :daily_shows_budget, :daily_clicks_budget
+	);
+	`
+
+	dbCampaign := campaign{
+		ID:                campaignInfo.ID,
+		AdvertiserID:      campaignInfo.AdvertiserID,
+		Description:       campaignInfo.Description,
+		TotalShowsBudget:  campaignInfo.Budget.TotalShows,
+		TotalClicksBudget: campaignInfo.Budget.TotalClicks,
+		DailyShowsBudget:  campaignInfo.Budget.DailyShows,
+		DailyClicksBudget: campaignInfo.Budget.DailyClicks,
+	}
+	_, err := s.db.NamedExecContext(ctx, query, dbCampaign)
+	return err
+}
+
+func (s *Storage) GetCampaign(ctx context.Context, campaignID uuid.UUID) (types.Campaign, error) {
+	query := `
+	SELECT * FROM campaigns WHERE id=$1 AND deleted=FALSE
+	`
+	row := s.db.QueryRowxContext(ctx, query, campaignID)
+	if row.Err() != nil {
+		return types.Campaign{}, row.Err()
+	}
+
+	var dbCampaign campaign
+	err := row.StructScan(&dbCampaign)
+	if err != nil {
+		return types.Campaign{}, err
+	}
+
+	return dbCampaign.toCampaign(), nil
+}
+
+// DeleteCampaign deletes campaign and takes its banners out of it.
+func (s *Storage) DeleteCampaign(ctx context.Context, campaignID uuid.UUID) error {
+	deleteCampaignQuery := `
+	UPDATE campaigns SET deleted=TRUE, deleted_at=now()
+	WHERE id=$1 AND deleted=FALSE
+	`
+	releaseBannersQuery := `
+	UPDATE banners SET campaign_id=NULL
+	WHERE campaign_id=$1
+	`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = execTxQuery(tx, deleteCampaignQuery, campaignID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = execTxQuery(tx, releaseBannersQuery, campaignID)
+	switch {
+	case errors.Is(err, ErrNoRowWasAffected):
+	case err == nil:
+	default:
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Storage) SetCampaignBudget(ctx context.Context, campaignID uuid.UUID, budget types.CampaignBudget) error {
+	query := `
+	UPDATE campaigns SET
+	total_shows_budget=$1, total_clicks_budget=$2, daily_shows_budget=$3, daily_clicks_budget=$4
+	WHERE id=$5 AND deleted=FALSE
+	`
+
+	res, err := s.db.ExecContext(
+		ctx,
+		query,
+		budget.TotalShows,
+		budget.TotalClicks,
+		budget.DailyShows,
+		budget.DailyClicks,
+		campaignID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsUpdated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsUpdated == 0 {
+		return errors.New("no campaign was updated")
+	}
+
+	return nil
+}
+
+// selectCampaignConsumption counts total shows and clicks of campaign banners
+// by rotations counters and shows and clicks since start of the current day by events.
+// day_elapsed is elapsed part of the current day used for pacing.
+const selectCampaignConsumption = `
+	SELECT
+		c.id AS campaign_id,
+		c.total_shows_budget,
+		c.total_clicks_budget,
+		c.daily_shows_budget,
+		c.daily_clicks_budget,
+		coalesce(total.shows, 0) AS total_shows,
+		coalesce(total.clicks, 0) AS total_clicks,
+		coalesce(daily.shows, 0) AS daily_shows,
+		coalesce(daily.clicks, 0) AS daily_clicks,
+		(extract(EPOCH FROM now() - date_trunc('day', now())) / 86400)::DOUBLE PRECISION AS day_elapsed
+	FROM campaigns c
+	LEFT JOIN LATERAL (
+		SELECT sum(r.shows) AS shows, sum(r.clicks) AS clicks
+		FROM banners b
+		JOIN rotations r ON r.banner_id = b.id
+		WHERE b.campaign_id = c.id
+	) total ON TRUE
+	LEFT JOIN LATERAL (
+		SELECT
+			count(e.id) FILTER (WHERE e.event_type = 'show') AS shows,
+			count(e.id) FILTER (WHERE e.event_type = 'click') AS clicks
+		FROM banners b
+		JOIN rotations r ON r.banner_id = b.id
+		JOIN events e ON e.rotation_id = r.id
+		WHERE b.campaign_id = c.id AND e.stamp >= date_trunc('day', now())
+	) daily ON TRUE
+`
+
+func (s *Storage) GetCampaignConsumption(ctx context.Context, campaignID uuid.UUID) (types.CampaignConsumption, error) {
+	consumptions, err := s.GetCampaignsConsumption(ctx, []uuid.UUID{campaignID})
+	if err != nil {
+		return types.CampaignConsumption{}, err
+	}
+
+	if len(consumptions) == 0 {
+		return types.CampaignConsumption{}, sql.ErrNoRows
+	}
+
+	return consumptions[0], nil
+}
+
+// GetCampaignsConsumption returns consumption of campaigns which are not deleted.
+func (s *Storage) GetCampaignsConsumption(ctx context.Context, campaignIDs []uuid.UUID) ([]types.CampaignConsumption, error) {
+	query := selectCampaignConsumption + `
+	WHERE c.id = ANY(string_to_array($1, ',')::uuid[]) AND c.deleted = FALSE
+	`
+
+	ids := make([]string, 0, len(campaignIDs))
+	for _, campaignID := range campaignIDs {
+		ids = append(ids, campaignID.String())
+	}
+
+	rows, err := s.db.QueryxContext(ctx, query, strings.Join(ids, ","))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	var consumptions []types.CampaignConsumption
+	for rows.Next() {
+		var consumption campaignConsumption
+
+		err := rows.StructScan(&consumption)
+		if err != nil {
+			return nil, err
+		}
+
+		consumptions = append(consumptions, consumption.toCampaignConsumption())
+	}
+
+	return consumptions, nil
+}
@@ -0,0 +1,316 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+// ImportCatalogue inserts catalogue entries in a single transaction.
+// Banners, slots and groups must not exist yet, even deleted ones,
+// and rotations must not exist for the same banner, slot and group.
+// Rotations and group rules may refer to entities which exist in database
+// or in catalogue. Group rules get new ids and may be added to existing group
+// only if it has no rules yet. Campaigns of banners and rotations are not
+// imported. Imported statistics of rotations
+// have no logged events, so they are kept as baseline of rotation counters.
+// If anything conflicts nothing is inserted and report lists conflicts
+// along with types.ErrCatalogueConflicts. Dry run rolls inserts back.
+func (s *Storage) ImportCatalogue(
+	ctx context.Context,
+	catalogue types.Catalogue,
+	dryRun bool,
+) (types.ImportReport, error) {
+	report := types.ImportReport{DryRun: dryRun}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	conflicts, err := catalogueConflicts(ctx, tx, catalogue)
+	if err != nil {
+		return report, err
+	}
+	if len(conflicts) > 0 {
+		report.Conflicts = conflicts
+		return report, types.ErrCatalogueConflicts
+	}
+
+	for _, b := range catalogue.Banners {
+		err = execTxQuery(
+			tx,
+			`INSERT INTO banners (id, description) VALUES ($1, $2)`,
+			b.ID, b.Description,
+		)
+		if err != nil {
+			return report, err
+		}
+		report.Banners++
+	}
+
+	for _, sl := range catalogue.Slots {
+		err = execTxQuery(
+			tx,
+			`
+			INSERT INTO slots (id, description, sticky, sticky_ttl_seconds, holdout_percent)
+			VALUES ($1, $2, $3, $4, $5)
+			`,
+			sl.ID,
+			sl.Description,
+			sl.Settings.Sticky,
+			int64(sl.Settings.StickyTTL.Seconds()),
+			sl.Settings.HoldoutPercent,
+		)
+		if err != nil {
+			return report, err
+		}
+		report.Slots++
+	}
+
+	for _, g := range catalogue.Groups {
+		err = execTxQuery(
+			tx,
+			`INSERT INTO groups (id, description) VALUES ($1, $2)`,
+			g.ID, g.Description,
+		)
+		if err != nil {
+			return report, err
+		}
+		report.Groups++
+	}
+
+	for _, rule := range catalogue.Rules {
+		attributes := rule.Attributes
+		if attributes == nil {
+			attributes = map[string]string{}
+		}
+		attributesJSON, err := json.Marshal(attributes)
+		if err != nil {
+			return report, err
+		}
+
+		err = execTxQuery(
+			tx,
+			`
+			INSERT INTO group_rules (group_id, priority, min_age, max_age, gender, locale, device, attributes)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8::jsonb)
+			`,
+			rule.GroupID,
+			rule.Priority,
+			rule.MinAge,
+			rule.MaxAge,
+			rule.Gender,
+			rule.Locale,
+			rule.Device,
+			string(attributesJSON),
+		)
+		if err != nil {
+			return report, err
+		}
+		report.Rules++
+	}
+
+	for _, r := range catalogue.Rotations {
+		state := r.State
+		if state == "" {
+			state = types.RotationStateActive
+		}
+
+		err = execTxQuery(
+			tx,
+			`
+			WITH rotation AS (
+				INSERT INTO rotations (
+					banner_id, slot_id, group_id, shows, clicks, conversions, revenue,
+					cap_shows, cap_window_seconds, state, pinned, min_share_percent
+				)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+				RETURNING id, shows, clicks, conversions, revenue
+			)
+			INSERT INTO rotation_baselines (rotation_id, shows, clicks, conversions, revenue)
+			SELECT id, shows, clicks, conversions, revenue FROM rotation
+			`,
+			r.BannerID,
+			r.SlotID,
+			r.GroupID,
+			r.Shows,
+			r.Clicks,
+			r.Conversions,
+			r.Revenue,
+			r.Cap.MaxShows,
+			int64(r.Cap.Window.Seconds()),
+			state,
+			r.Override.Pinned,
+			r.Override.MinSharePercent,
+		)
+		if err != nil {
+			return report, err
+		}
+		report.Rotations++
+	}
+
+	if dryRun {
+		return report, tx.Rollback()
+	}
+
+	return report, tx.Commit()
+}
+
+// catalogueConflicts checks catalogue against data in database.
+func catalogueConflicts(
+	ctx context.Context,
+	tx *sql.Tx,
+	catalogue types.Catalogue,
+) ([]types.CatalogueConflict, error) {
+	var conflicts []types.CatalogueConflict
+
+	tables := []struct {
+		kind  string
+		table string
+		ids   []uuid.UUID
+	}{
+		{kind: "banner", table: "banners"},
+		{kind: "slot", table: "slots"},
+		{kind: "group", table: "groups"},
+	}
+	for _, b := range catalogue.Banners {
+		tables[0].ids = append(tables[0].ids, b.ID)
+	}
+	for _, sl := range catalogue.Slots {
+		tables[1].ids = append(tables[1].ids, sl.ID)
+	}
+	for _, g := range catalogue.Groups {
+		tables[2].ids = append(tables[2].ids, g.ID)
+	}
+
+	// Entities rotations and rules may refer to
+	known := make([]map[uuid.UUID]bool, len(tables))
+	for i, t := range tables {
+		known[i] = make(map[uuid.UUID]bool, len(t.ids))
+		for _, id := range t.ids {
+			known[i][id] = true
+
+			deleted, found, err := entityDeleted(ctx, tx, t.table, id)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				continue
+			}
+
+			reason := t.kind + " already exists"
+			if deleted {
+				reason = t.kind + " with this id was deleted"
+			}
+			conflicts = append(conflicts, types.CatalogueConflict{Kind: t.kind, ID: id.String(), Reason: reason})
+		}
+	}
+
+	for i, rule := range catalogue.Rules {
+		if known[2][rule.GroupID] {
+			continue
+		}
+		id := fmt.Sprintf("%s/%d", rule.GroupID, i)
+
+		deleted, found, err := entityDeleted(ctx, tx, "groups", rule.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		if !found || deleted {
+			conflicts = append(conflicts, types.CatalogueConflict{
+				Kind:   "rule",
+				ID:     id,
+				Reason: fmt.Sprintf("group %s does not exist", rule.GroupID),
+			})
+			continue
+		}
+
+		var hasRules bool
+		err = tx.QueryRowContext(
+			ctx,
+			`SELECT EXISTS(SELECT 1 FROM group_rules WHERE group_id=$1 AND deleted=FALSE)`,
+			rule.GroupID,
+		).Scan(&hasRules)
+		if err != nil {
+			return nil, err
+		}
+		if hasRules {
+			conflicts = append(conflicts, types.CatalogueConflict{
+				Kind:   "rule",
+				ID:     id,
+				Reason: "group already has rules",
+			})
+		}
+	}
+
+	for _, r := range catalogue.Rotations {
+		id := fmt.Sprintf("%s/%s/%s", r.BannerID, r.SlotID, r.GroupID)
+
+		refs := []uuid.UUID{r.BannerID, r.SlotID, r.GroupID}
+		missing := false
+		for i, ref := range refs {
+			if known[i][ref] {
+				continue
+			}
+			deleted, found, err := entityDeleted(ctx, tx, tables[i].table, ref)
+			if err != nil {
+				return nil, err
+			}
+			if !found || deleted {
+				conflicts = append(conflicts, types.CatalogueConflict{
+					Kind:   "rotation",
+					ID:     id,
+					Reason: fmt.Sprintf("%s %s does not exist", tables[i].kind, ref),
+				})
+				missing = true
+				break
+			}
+		}
+		if missing {
+			continue
+		}
+
+		var exists bool
+		err := tx.QueryRowContext(
+			ctx,
+			`SELECT EXISTS(SELECT 1 FROM rotations WHERE banner_id=$1 AND slot_id=$2 AND group_id=$3)`,
+			r.BannerID, r.SlotID, r.GroupID,
+		).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			conflicts = append(conflicts, types.CatalogueConflict{
+				Kind:   "rotation",
+				ID:     id,
+				Reason: "rotation already exists",
+			})
+		}
+	}
+
+	return conflicts, nil
+}
+
+// entityDeleted tells whether banner, slot or group with id
+// is present in table and whether it is deleted.
+func entityDeleted(ctx context.Context, tx *sql.Tx, table string, id uuid.UUID) (deleted, found bool, err error) {
+	// Table is one of the fixed names above so it is safe to format
+	query := fmt.Sprintf(`SELECT deleted FROM %s WHERE id=$1`, table)
+
+	var isDeleted sql.NullBool
+	err = tx.QueryRowContext(ctx, query, id).Scan(&isDeleted)
+	if err == sql.ErrNoRows {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	return isDeleted.Bool, true, nil
+}
@@ -3,6 +3,10 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/FedoseevAlex/banner-rotation/internal/types"
 	"github.com/google/uuid"
@@ -14,13 +18,20 @@ import (
 )
 
 const (
-	EventTypeClick string = "click"
-	EventTypeShow  string = "show"
+	EventTypeClick      = types.EventTypeClick
+	EventTypeShow       = types.EventTypeShow
+	EventTypeConversion = types.EventTypeConversion
+	EventTypeState      = types.EventTypeState
+	EventTypeOverride   = types.EventTypeOverride
 )
 
 type Storage struct {
 	db      *sqlx.DB
 	connStr string
+
+	// sweepMu guards lastSweep of expired rows by sweep query
+	sweepMu   sync.Mutex
+	lastSweep map[string]time.Time
 }
 
 func New(connStr string) *Storage {
@@ -55,6 +66,36 @@ func (s *Storage) CleanDB() error {
 		return err
 	}
 
+	cleanRejectedEvents := `DELETE FROM rejected_events`
+	_, err = s.db.Exec(cleanRejectedEvents)
+	if err != nil {
+		return err
+	}
+
+	cleanRotatorStates := `DELETE FROM rotator_states`
+	_, err = s.db.Exec(cleanRotatorStates)
+	if err != nil {
+		return err
+	}
+
+	cleanAssignments := `DELETE FROM assignments`
+	_, err = s.db.Exec(cleanAssignments)
+	if err != nil {
+		return err
+	}
+
+	cleanExposures := `DELETE FROM exposures`
+	_, err = s.db.Exec(cleanExposures)
+	if err != nil {
+		return err
+	}
+
+	cleanRotationBaselines := `DELETE FROM rotation_baselines`
+	_, err = s.db.Exec(cleanRotationBaselines)
+	if err != nil {
+		return err
+	}
+
 	cleanRotations := `DELETE FROM rotations`
 	_, err = s.db.Exec(cleanRotations)
 	if err != nil {
@@ -67,46 +108,96 @@ func (s *Storage) CleanDB() error {
 		return err
 	}
 
-	cleanSlots := `DELETE FROM slots`
-	_, err = s.db.Exec(cleanSlots)
+	cleanCampaigns := `DELETE FROM campaigns`
+	_, err = s.db.Exec(cleanCampaigns)
 	if err != nil {
 		return err
 	}
 
-	cleanGroups := `DELETE FROM groups`
-	_, err = s.db.Exec(cleanGroups)
+	cleanAdvertisers := `DELETE FROM advertisers`
+	_, err = s.db.Exec(cleanAdvertisers)
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	cleanAPIKeys := `DELETE FROM api_keys`
+	_, err = s.db.Exec(cleanAPIKeys)
+	if err != nil {
+		return err
+	}
 
-func (s *Storage) AddEventTimestamp(ctx context.Context, eventType string, rotationID int) error {
-	query := ` INSERT INTO events(rotation_id, stamp, event_type)
-	VALUES (:rotation_id, now(), :event_type)
-	`
-	eventStamp := event{
-		RotationID: rotationID,
-		Type:       eventType,
+	cleanIdempotencyKeys := `DELETE FROM idempotency_keys`
+	_, err = s.db.Exec(cleanIdempotencyKeys)
+	if err != nil {
+		return err
 	}
 
-	res, err := s.db.NamedExecContext(ctx, query, eventStamp)
+	cleanRateLimitBuckets := `DELETE FROM rate_limit_buckets`
+	_, err = s.db.Exec(cleanRateLimitBuckets)
 	if err != nil {
 		return err
 	}
-	rowsAffected, err := res.RowsAffected()
+
+	cleanSlots := `DELETE FROM slots`
+	_, err = s.db.Exec(cleanSlots)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return ErrNoRowWasAffected
+	cleanGroupRules := `DELETE FROM group_rules`
+	_, err = s.db.Exec(cleanGroupRules)
+	if err != nil {
+		return err
+	}
+
+	cleanGroups := `DELETE FROM groups`
+	_, err = s.db.Exec(cleanGroups)
+	if err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// addEvent stores event within transaction.
+func addEvent(tx *sql.Tx, e event) error {
+	query := `
+	INSERT INTO events (rotation_id, stamp, event_type, impression_id, visitor_id, value, propensity, arm, details)
+	VALUES ($1, now(), $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	// Propensity and arm make sense only for shows, other events keep the defaults
+	propensity := e.Propensity
+	if propensity == 0 {
+		propensity = 1
+	}
+	arm := e.Arm
+	if arm == "" {
+		arm = types.ArmOptimised
+	}
+
+	return execTxQuery(
+		tx,
+		query,
+		e.RotationID,
+		e.Type,
+		nullUUID(e.ImpressionID),
+		e.VisitorID,
+		e.Value,
+		propensity,
+		arm,
+		e.Details,
+	)
+}
+
+// nullUUID makes uuid.Nil to be stored as NULL.
+func nullUUID(id uuid.UUID) interface{} {
+	if id == uuid.Nil {
+		return nil
+	}
+	return id
+}
+
 func (s *Storage) GetRotationID(ctx context.Context, bannerID, slotID, groupID uuid.UUID) (int, error) {
 	query := `
 	SELECT id FROM rotations
@@ -138,6 +229,15 @@ func (s *Storage) Close() error {
 	return s.db.Close()
 }
 
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Stats returns connection pool statistics.
+func (s *Storage) Stats() sql.DBStats {
+	return s.db.Stats()
+}
+
 func (s *Storage) AddBanner(ctx context.Context, bannerInfo types.Banner) error {
 	insertBannerQuery := `
 	INSERT INTO banners (id, description) VALUES (:id, :description);
@@ -166,12 +266,7 @@ func (s *Storage) GetBanner(ctx context.Context, bannerID uuid.UUID) (types.Bann
 		return types.Banner{}, err
 	}
 
-	resultBanner := types.Banner{
-		ID:          dbBanner.ID,
-		Description: dbBanner.Description,
-	}
-
-	return resultBanner, nil
+	return dbBanner.toBanner(), nil
 }
 
 func (s *Storage) DeleteBanner(ctx context.Context, bannerID uuid.UUID) error {
@@ -234,11 +329,36 @@ func (s *Storage) GetSlot(ctx context.Context, slotID uuid.UUID) (types.Slot, er
 		return types.Slot{}, err
 	}
 
-	resultSlot := types.Slot{
-		ID:          dbSlot.ID,
-		Description: dbSlot.Description,
+	return dbSlot.toSlot(), nil
+}
+
+func (s *Storage) SetSlotSettings(ctx context.Context, slotID uuid.UUID, settings types.SlotSettings) error {
+	query := `
+	UPDATE slots SET sticky=$1, sticky_ttl_seconds=$2, holdout_percent=$3
+	WHERE id=$4 AND deleted=FALSE
+	`
+	res, err := s.db.ExecContext(
+		ctx,
+		query,
+		settings.Sticky,
+		int64(settings.StickyTTL.Seconds()),
+		settings.HoldoutPercent,
+		slotID,
+	)
+	if err != nil {
+		return err
 	}
-	return resultSlot, nil
+
+	rowsUpdated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsUpdated == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
 }
 
 func (s *Storage) DeleteSlot(ctx context.Context, slotID uuid.UUID) error {
@@ -301,12 +421,7 @@ func (s *Storage) GetGroup(ctx context.Context, groupID uuid.UUID) (types.Group,
 		return types.Group{}, err
 	}
 
-	resultGroup := types.Group{
-		ID:          dbGroup.ID,
-		Description: dbGroup.Description,
-	}
-
-	return resultGroup, nil
+	return dbGroup.toGroup(), nil
 }
 
 func (s *Storage) DeleteGroup(ctx context.Context, groupID uuid.UUID) error {
@@ -405,20 +520,48 @@ func (s *Storage) GetRotation(ctx context.Context, bannerID, slotID, groupID uui
 	if err != nil {
 		return types.Rotation{}, err
 	}
-	resultRotation := types.Rotation{
-		BannerID: dbRotation.BannerID,
-		SlotID:   dbRotation.SlotID,
-		GroupID:  dbRotation.GroupID,
-		Shows:    dbRotation.Shows,
-		Clicks:   dbRotation.Clicks,
+
+	return dbRotation.toRotation(), nil
+}
+
+func (s *Storage) SetFrequencyCap(
+	ctx context.Context,
+	bannerID, slotID, groupID uuid.UUID,
+	frequencyCap types.FrequencyCap,
+) error {
+	query := `
+	UPDATE rotations SET cap_shows=$1, cap_window_seconds=$2
+	WHERE
+	banner_id=$3 AND slot_id=$4 AND group_id=$5 AND deleted=FALSE
+	`
+	res, err := s.db.ExecContext(
+		ctx,
+		query,
+		frequencyCap.MaxShows,
+		int64(frequencyCap.Window.Seconds()),
+		bannerID,
+		slotID,
+		groupID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsUpdated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsUpdated == 0 {
+		return sql.ErrNoRows
 	}
 
-	return resultRotation, nil
+	return nil
 }
 
 func (s *Storage) GetRotationStats(ctx context.Context, bannerID, slotID, groupID uuid.UUID) ([]types.Event, error) {
 	query := `
-	SELECT * FROM events WHERE rotation_id=$1
+	SELECT * FROM events WHERE rotation_id=$1 ORDER BY stamp, id
 	`
 	rotationID, err := s.GetRotationID(ctx, bannerID, slotID, groupID)
 	if err != nil {
@@ -443,19 +586,51 @@ func (s *Storage) GetRotationStats(ctx context.Context, bannerID, slotID, groupI
 			return nil, err
 		}
 
-		events = append(
-			events,
-			types.Event{
-				Type:      dbEvent.Type,
-				Timestamp: dbEvent.Timestamp,
-			},
-		)
+		events = append(events, dbEvent.toEvent())
 	}
 	return events, err
 }
 
-func (s *Storage) AddShow(ctx context.Context, bannerID, slotID, groupID uuid.UUID) error {
-	rotationID, err := s.GetRotationID(ctx, bannerID, slotID, groupID)
+func (s *Storage) GetEvents(
+	ctx context.Context,
+	slotID, groupID uuid.UUID,
+	from, to time.Time,
+) ([]types.RotationEvent, error) {
+	query := `
+	SELECT r.banner_id, r.slot_id, r.group_id, e.*
+	FROM events e
+	JOIN rotations r ON r.id=e.rotation_id
+	WHERE r.slot_id=$1 AND r.group_id=$2 AND e.stamp >= $3 AND e.stamp < $4
+	ORDER BY e.stamp, e.id
+	`
+
+	rows, err := s.db.QueryxContext(ctx, query, slotID, groupID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	var events []types.RotationEvent
+	for rows.Next() {
+		var e rotationEvent
+
+		err := rows.StructScan(&e)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, e.toRotationEvent())
+	}
+
+	return events, nil
+}
+
+func (s *Storage) AddShow(ctx context.Context, impression types.Impression) error {
+	rotationID, err := s.GetRotationID(ctx, impression.BannerID, impression.SlotID, impression.GroupID)
 	if err != nil {
 		return err
 	}
@@ -463,55 +638,257 @@ func (s *Storage) AddShow(ctx context.Context, bannerID, slotID, groupID uuid.UU
 	query := `
 	UPDATE rotations SET shows=shows+1 WHERE id=$1
 	`
-	res, err := s.db.ExecContext(ctx, query, rotationID)
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	rowsUpdated, err := res.RowsAffected()
+	err = execTxQuery(tx, query, rotationID)
 	if err != nil {
+		tx.Rollback()
 		return err
 	}
 
-	if rowsUpdated == 0 {
-		return errors.New("no rotation was updated")
+	err = addEvent(tx, event{
+		RotationID:   rotationID,
+		Type:         EventTypeShow,
+		ImpressionID: impression.ImpressionID,
+		VisitorID:    impression.VisitorID,
+		Propensity:   impression.Propensity,
+		Arm:          impression.Arm,
+	})
+	if err != nil {
+		tx.Rollback()
+		return err
 	}
 
-	err = s.AddEventTimestamp(ctx, EventTypeShow, rotationID)
+	return tx.Commit()
+}
 
-	return err
+// AddShows registers shows of several rotations with a single statement.
+// Nothing is registered and sql.ErrNoRows is returned if any rotation does not exist.
+func (s *Storage) AddShows(ctx context.Context, impressions []types.Impression) error {
+	if len(impressions) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(impressions))
+	args := make([]interface{}, 0, 7*len(impressions)+1)
+	for i, impression := range impressions {
+		n := 7 * i
+		values = append(values, fmt.Sprintf(
+			"($%d::uuid, $%d::uuid, $%d::uuid, $%d::uuid, $%d::text, $%d::double precision, $%d::text)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7,
+		))
+
+		propensity := impression.Propensity
+		if propensity == 0 {
+			propensity = 1
+		}
+		arm := impression.Arm
+		if arm == "" {
+			arm = types.ArmOptimised
+		}
+
+		args = append(
+			args,
+			impression.BannerID,
+			impression.SlotID,
+			impression.GroupID,
+			impression.ImpressionID,
+			impression.VisitorID,
+			propensity,
+			arm,
+		)
+	}
+	args = append(args, EventTypeShow)
+
+	query := fmt.Sprintf(`
+	WITH shown (banner_id, slot_id, group_id, impression_id, visitor_id, propensity, arm) AS (
+		VALUES %s
+	), updated AS (
+		UPDATE rotations r SET shows=r.shows+1
+		FROM shown
+		WHERE r.banner_id=shown.banner_id AND r.slot_id=shown.slot_id
+			AND r.group_id=shown.group_id AND r.deleted=FALSE
+		RETURNING r.id, r.banner_id, r.slot_id, r.group_id
+	)
+	INSERT INTO events (rotation_id, stamp, event_type, impression_id, visitor_id, value, propensity, arm, details)
+	SELECT u.id, now(), $%d, shown.impression_id, shown.visitor_id, 0, shown.propensity, shown.arm, ''
+	FROM shown
+	JOIN updated u ON u.banner_id=shown.banner_id AND u.slot_id=shown.slot_id AND u.group_id=shown.group_id
+	`, strings.Join(values, ", "), len(args))
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if affected != int64(len(impressions)) {
+		tx.Rollback()
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
 }
 
-func (s *Storage) AddClick(ctx context.Context, bannerID, slotID, groupID uuid.UUID) error {
-	rotationID, err := s.GetRotationID(ctx, bannerID, slotID, groupID)
+// AddClick registers click unless the same impression or visitor clicked
+// the rotation during duplicateWindow. Rotation is locked while the click
+// is checked, so that concurrent duplicates are not both registered.
+func (s *Storage) AddClick(ctx context.Context, click types.Click, duplicateWindow time.Duration) error {
+	rotationID, err := s.GetRotationID(ctx, click.BannerID, click.SlotID, click.GroupID)
 	if err != nil {
 		return err
 	}
 
+	lockQuery := `
+	SELECT id FROM rotations WHERE id=$1 FOR UPDATE
+	`
+	duplicateQuery := `
+	SELECT EXISTS (
+		SELECT 1 FROM events
+		WHERE rotation_id=$1 AND event_type=$2
+		AND stamp > now() - $3::double precision * interval '1 second'
+		AND (impression_id=$4 OR ($5 <> '' AND visitor_id=$5))
+	)
+	`
 	query := `
 	UPDATE rotations SET clicks=clicks+1 WHERE id=$1
 	`
-	res, err := s.db.ExecContext(ctx, query, rotationID)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if duplicateWindow > 0 && (click.ImpressionID != uuid.Nil || click.Visitor.ID != "") {
+		err = tx.QueryRowContext(ctx, lockQuery, rotationID).Scan(&rotationID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		var duplicate bool
+		err = tx.QueryRowContext(
+			ctx,
+			duplicateQuery,
+			rotationID,
+			EventTypeClick,
+			duplicateWindow.Seconds(),
+			nullUUID(click.ImpressionID),
+			click.Visitor.ID,
+		).Scan(&duplicate)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if duplicate {
+			tx.Rollback()
+			return types.ErrDuplicateClick
+		}
+	}
+
+	err = execTxQuery(tx, query, rotationID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = addEvent(tx, event{
+		RotationID:   rotationID,
+		Type:         EventTypeClick,
+		ImpressionID: click.ImpressionID,
+		VisitorID:    click.Visitor.ID,
+	})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AddConversion attributes conversion to rotation which made the impression.
+// sql.ErrNoRows is returned if there was no such impression.
+func (s *Storage) AddConversion(ctx context.Context, conversion types.Conversion) error {
+	// Show is locked so that concurrent conversions of it are checked one after another
+	impressionQuery := `
+	SELECT rotation_id, visitor_id FROM events
+	WHERE impression_id=$1 AND event_type=$2
+	FOR UPDATE
+	`
+	duplicateQuery := `
+	SELECT EXISTS (SELECT 1 FROM events WHERE impression_id=$1 AND event_type=$2)
+	`
+	updateQuery := `
+	UPDATE rotations SET conversions=conversions+1, revenue=revenue+$1 WHERE id=$2
+	`
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	rowsUpdated, err := res.RowsAffected()
+	var (
+		rotationID int
+		visitorID  string
+	)
+	err = tx.QueryRowContext(ctx, impressionQuery, conversion.ImpressionID, EventTypeShow).Scan(&rotationID, &visitorID)
 	if err != nil {
+		tx.Rollback()
 		return err
 	}
 
-	if rowsUpdated == 0 {
-		return errors.New("no row was updated")
+	var converted bool
+	err = tx.QueryRowContext(ctx, duplicateQuery, conversion.ImpressionID, EventTypeConversion).Scan(&converted)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if converted {
+		tx.Rollback()
+		return types.ErrDuplicateConversion
+	}
+
+	err = execTxQuery(tx, updateQuery, conversion.Value, rotationID)
+	if err != nil {
+		tx.Rollback()
+		return err
 	}
 
-	err = s.AddEventTimestamp(ctx, EventTypeClick, rotationID)
+	err = addEvent(tx, event{
+		RotationID:   rotationID,
+		Type:         EventTypeConversion,
+		ImpressionID: conversion.ImpressionID,
+		VisitorID:    visitorID,
+		Value:        conversion.Value,
+	})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 
-	return err
+	return tx.Commit()
 }
 
 func (s *Storage) GetAllRotations(ctx context.Context) ([]types.Rotation, error) {
-	query := `SELECT * FROM rotations WHERE deleted=FALSE`
+	query := `
+	SELECT r.*, b.campaign_id FROM rotations r
+	JOIN banners b ON b.id=r.banner_id
+	WHERE r.deleted=FALSE
+	`
 
 	rows, err := s.db.QueryxContext(ctx, query)
 	if err != nil {
@@ -532,16 +909,7 @@ func (s *Storage) GetAllRotations(ctx context.Context) ([]types.Rotation, error)
 			return nil, err
 		}
 
-		rotations = append(
-			rotations,
-			types.Rotation{
-				BannerID: r.BannerID,
-				SlotID:   r.SlotID,
-				GroupID:  r.GroupID,
-				Shows:    r.Shows,
-				Clicks:   r.Clicks,
-			},
-		)
+		rotations = append(rotations, r.toRotation())
 	}
 
 	return rotations, nil
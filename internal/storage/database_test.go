@@ -1,10 +1,15 @@
 package storage_test
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"io/ioutil"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/FedoseevAlex/banner-rotation/internal/storage"
 	"github.com/FedoseevAlex/banner-rotation/internal/types"
@@ -156,6 +161,17 @@ func createTestRotation(ctx context.Context, t *testing.T, r testRotationInfo) {
 	require.NoError(t, err)
 }
 
+func testImpression(r testRotationInfo) types.Impression {
+	return types.Impression{
+		ImpressionID: uuid.New(),
+		Rotation: types.Rotation{
+			BannerID: r.banner.ID,
+			SlotID:   r.slot.ID,
+			GroupID:  r.group.ID,
+		},
+	}
+}
+
 func TestRotations(t *testing.T) { //nolint:funlen
 	if connectionString == "" {
 		t.Skipf("Skipping TestRotations as env var '%s' is not set", dbConnEnvVar)
@@ -194,7 +210,12 @@ func TestRotations(t *testing.T) { //nolint:funlen
 
 			dbRotation, err := store.GetRotation(ctx, r.banner.ID, r.slot.ID, r.group.ID)
 			require.NoError(t, err)
-			require.Equal(t, types.Rotation{BannerID: r.banner.ID, SlotID: r.slot.ID, GroupID: r.group.ID}, dbRotation)
+			require.Equal(t, types.Rotation{
+				BannerID: r.banner.ID,
+				SlotID:   r.slot.ID,
+				GroupID:  r.group.ID,
+				State:    types.RotationStateActive,
+			}, dbRotation)
 		}
 	})
 
@@ -212,7 +233,7 @@ func TestRotations(t *testing.T) { //nolint:funlen
 		testShows := 10
 		for _, r := range rotations {
 			for i := 0; i < testShows; i++ {
-				err := store.AddShow(ctx, r.banner.ID, r.slot.ID, r.group.ID)
+				err := store.AddShow(ctx, testImpression(r))
 				require.NoError(t, err)
 			}
 		}
@@ -227,12 +248,11 @@ func TestRotations(t *testing.T) { //nolint:funlen
 		testRotation := rotations[0]
 
 		for i := 0; i < testClicks; i++ {
-			err := store.AddClick(
-				ctx,
-				testRotation.banner.ID,
-				testRotation.slot.ID,
-				testRotation.group.ID,
-			)
+			err := store.AddClick(ctx, types.Click{
+				BannerID: testRotation.banner.ID,
+				SlotID:   testRotation.slot.ID,
+				GroupID:  testRotation.group.ID,
+			}, 0)
 			require.NoError(t, err)
 		}
 
@@ -319,3 +339,1314 @@ func TestRotations(t *testing.T) { //nolint:funlen
 		require.ErrorIs(t, err, sql.ErrNoRows)
 	})
 }
+
+func TestFrequencyCaps(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestFrequencyCaps as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := testRotationInfo{
+		banner: types.Banner{ID: uuid.New(), Description: "Some banner"},
+		slot:   types.Slot{ID: uuid.New(), Description: "Main slot"},
+		group:  types.Group{ID: uuid.New(), Description: "Teenagers"},
+	}
+	createTestRotation(ctx, t, r)
+
+	t.Run("check set frequency cap", func(t *testing.T) {
+		frequencyCap := types.FrequencyCap{MaxShows: 3, Window: time.Hour}
+		err := store.SetFrequencyCap(ctx, r.banner.ID, r.slot.ID, r.group.ID, frequencyCap)
+		require.NoError(t, err)
+
+		rotation, err := store.GetRotation(ctx, r.banner.ID, r.slot.ID, r.group.ID)
+		require.NoError(t, err)
+		require.Equal(t, frequencyCap, rotation.Cap)
+	})
+
+	t.Run("check exposures count", func(t *testing.T) {
+		frequencyCap := types.FrequencyCap{MaxShows: 3, Window: time.Hour}
+		for i := 0; i < 2; i++ {
+			added, err := store.AddExposure(ctx, "visitor", r.banner.ID, r.slot.ID, r.group.ID, frequencyCap)
+			require.NoError(t, err)
+			require.True(t, added)
+		}
+
+		count, err := store.CountExposures(ctx, "visitor", r.banner.ID, r.slot.ID, r.group.ID, time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, 2, count)
+
+		count, err = store.CountExposures(ctx, "other visitor", r.banner.ID, r.slot.ID, r.group.ID, time.Hour)
+		require.NoError(t, err)
+		require.Zero(t, count)
+
+		err = store.RemoveExposure(ctx, "visitor", r.banner.ID, r.slot.ID, r.group.ID)
+		require.NoError(t, err)
+		count, err = store.CountExposures(ctx, "visitor", r.banner.ID, r.slot.ID, r.group.ID, time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+	})
+
+	t.Run("check concurrent exposures do not exceed cap", func(t *testing.T) {
+		frequencyCap := types.FrequencyCap{MaxShows: 3, Window: time.Hour}
+		const requests = 10
+		var (
+			wg    sync.WaitGroup
+			added = make([]bool, requests)
+			errs  = make([]error, requests)
+		)
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				added[i], errs[i] = store.AddExposure(ctx, "racer", r.banner.ID, r.slot.ID, r.group.ID, frequencyCap)
+			}(i)
+		}
+		wg.Wait()
+
+		var count int
+		for i := range added {
+			require.NoError(t, errs[i])
+			if added[i] {
+				count++
+			}
+		}
+		require.Equal(t, frequencyCap.MaxShows, count)
+	})
+
+	t.Run("check cap of missing rotation is not set", func(t *testing.T) {
+		err := store.SetFrequencyCap(ctx, uuid.New(), r.slot.ID, r.group.ID, types.FrequencyCap{MaxShows: 1, Window: time.Hour})
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
+
+func TestStickyAssignments(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestStickyAssignments as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := testRotationInfo{
+		banner: types.Banner{ID: uuid.New(), Description: "Some banner"},
+		slot:   types.Slot{ID: uuid.New(), Description: "Main slot"},
+		group:  types.Group{ID: uuid.New(), Description: "Teenagers"},
+	}
+	createTestRotation(ctx, t, r)
+
+	t.Run("check set slot settings", func(t *testing.T) {
+		settings := types.SlotSettings{Sticky: true, StickyTTL: time.Hour}
+		err := store.SetSlotSettings(ctx, r.slot.ID, settings)
+		require.NoError(t, err)
+
+		slot, err := store.GetSlot(ctx, r.slot.ID)
+		require.NoError(t, err)
+		require.Equal(t, settings, slot.Settings)
+	})
+
+	t.Run("check no assignment", func(t *testing.T) {
+		_, err := store.GetAssignment(ctx, "visitor", r.slot.ID, r.group.ID)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("check save assignment", func(t *testing.T) {
+		err := store.SaveAssignment(ctx, "visitor", r.banner.ID, r.slot.ID, r.group.ID, time.Hour)
+		require.NoError(t, err)
+
+		bannerID, err := store.GetAssignment(ctx, "visitor", r.slot.ID, r.group.ID)
+		require.NoError(t, err)
+		require.Equal(t, r.banner.ID, bannerID)
+	})
+}
+
+func TestGroupRules(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestGroupRules as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	group := types.Group{ID: uuid.New(), Description: "Teenagers"}
+	err = store.AddGroup(ctx, group)
+	require.NoError(t, err)
+
+	rule := types.GroupRule{
+		GroupID:    group.ID,
+		Priority:   10,
+		MinAge:     13,
+		MaxAge:     19,
+		Locale:     "ru",
+		Attributes: map[string]string{"interest": "games"},
+	}
+
+	t.Run("check add group rule", func(t *testing.T) {
+		rule, err = store.AddGroupRule(ctx, rule)
+		require.NoError(t, err)
+		require.NotZero(t, rule.ID)
+
+		groupRules, err := store.GetGroupRules(ctx, group.ID)
+		require.NoError(t, err)
+		require.Equal(t, []types.GroupRule{rule}, groupRules)
+	})
+
+	t.Run("check rules of deleted group are skipped", func(t *testing.T) {
+		groupRules, err := store.GetAllGroupRules(ctx)
+		require.NoError(t, err)
+		require.Len(t, groupRules, 1)
+
+		err = store.DeleteGroup(ctx, group.ID)
+		require.NoError(t, err)
+
+		groupRules, err = store.GetAllGroupRules(ctx)
+		require.NoError(t, err)
+		require.Empty(t, groupRules)
+	})
+
+	t.Run("check delete group rule", func(t *testing.T) {
+		err := store.DeleteGroupRule(ctx, uuid.New(), rule.ID)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+
+		err = store.DeleteGroupRule(ctx, group.ID, rule.ID)
+		require.NoError(t, err)
+
+		err = store.DeleteGroupRule(ctx, group.ID, rule.ID)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+
+		groupRules, err := store.GetGroupRules(ctx, group.ID)
+		require.NoError(t, err)
+		require.Empty(t, groupRules)
+	})
+}
+
+func TestConversions(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestConversions as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := testRotationInfo{
+		banner: types.Banner{ID: uuid.New(), Description: "Some banner"},
+		slot:   types.Slot{ID: uuid.New(), Description: "Main slot"},
+		group:  types.Group{ID: uuid.New(), Description: "Teenagers"},
+	}
+	createTestRotation(ctx, t, r)
+
+	impressions := []types.Impression{testImpression(r), testImpression(r)}
+	for _, impression := range impressions {
+		err = store.AddShow(ctx, impression)
+		require.NoError(t, err)
+	}
+
+	t.Run("check conversions are attributed to impression", func(t *testing.T) {
+		for i, value := range []float64{10, 2.5} {
+			err := store.AddConversion(ctx, types.Conversion{ImpressionID: impressions[i].ImpressionID, Value: value})
+			require.NoError(t, err)
+		}
+
+		rotation, err := store.GetRotation(ctx, r.banner.ID, r.slot.ID, r.group.ID)
+		require.NoError(t, err)
+		require.Equal(t, 2, rotation.Conversions)
+		require.Equal(t, 12.5, rotation.Revenue)
+	})
+
+	t.Run("check repeated conversion is not counted", func(t *testing.T) {
+		err := store.AddConversion(ctx, types.Conversion{ImpressionID: impressions[0].ImpressionID, Value: 10})
+		require.ErrorIs(t, err, types.ErrDuplicateConversion)
+
+		rotation, err := store.GetRotation(ctx, r.banner.ID, r.slot.ID, r.group.ID)
+		require.NoError(t, err)
+		require.Equal(t, 2, rotation.Conversions)
+		require.Equal(t, 12.5, rotation.Revenue)
+	})
+
+	t.Run("check conversions appear in stats", func(t *testing.T) {
+		events, err := store.GetRotationStats(ctx, r.banner.ID, r.slot.ID, r.group.ID)
+		require.NoError(t, err)
+		require.Len(t, events, 4)
+		require.Equal(t, storage.EventTypeConversion, events[2].Type)
+		require.Equal(t, impressions[0].ImpressionID, events[2].ImpressionID)
+		require.Equal(t, 10.0, events[2].Value)
+	})
+
+	t.Run("check conversion of unknown impression", func(t *testing.T) {
+		err := store.AddConversion(ctx, types.Conversion{ImpressionID: uuid.New()})
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
+
+func TestPropensities(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestPropensities as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := testRotationInfo{
+		banner: types.Banner{ID: uuid.New(), Description: "Some banner"},
+		slot:   types.Slot{ID: uuid.New(), Description: "Main slot"},
+		group:  types.Group{ID: uuid.New(), Description: "Teenagers"},
+	}
+	createTestRotation(ctx, t, r)
+
+	impression := testImpression(r)
+	impression.Propensity = 0.25
+	err = store.AddShow(ctx, impression)
+	require.NoError(t, err)
+
+	err = store.AddClick(ctx, types.Click{
+		ImpressionID: impression.ImpressionID,
+		BannerID:     r.banner.ID,
+		SlotID:       r.slot.ID,
+		GroupID:      r.group.ID,
+	}, 0)
+	require.NoError(t, err)
+
+	events, err := store.GetEvents(ctx, r.slot.ID, r.group.ID, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	require.Equal(t, r.banner.ID, events[0].BannerID)
+	require.Equal(t, storage.EventTypeShow, events[0].Type)
+	require.Equal(t, 0.25, events[0].Propensity)
+
+	require.Equal(t, storage.EventTypeClick, events[1].Type)
+	require.Zero(t, events[1].Propensity)
+}
+
+func TestHoldout(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestHoldout as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := testRotationInfo{
+		banner: types.Banner{ID: uuid.New(), Description: "Some banner"},
+		slot:   types.Slot{ID: uuid.New(), Description: "Main slot"},
+		group:  types.Group{ID: uuid.New(), Description: "Teenagers"},
+	}
+	createTestRotation(ctx, t, r)
+
+	t.Run("check holdout percent is saved", func(t *testing.T) {
+		err := store.SetSlotSettings(ctx, r.slot.ID, types.SlotSettings{HoldoutPercent: 12.5})
+		require.NoError(t, err)
+
+		slot, err := store.GetSlot(ctx, r.slot.ID)
+		require.NoError(t, err)
+		require.Equal(t, 12.5, slot.Settings.HoldoutPercent)
+	})
+
+	t.Run("check shows and clicks are counted per arm", func(t *testing.T) {
+		arms := []string{types.ArmHoldout, types.ArmOptimised, types.ArmOptimised, types.ArmOptimised}
+		for i, arm := range arms {
+			impression := testImpression(r)
+			impression.Arm = arm
+			err := store.AddShow(ctx, impression)
+			require.NoError(t, err)
+
+			if i%2 == 1 {
+				err = store.AddClick(ctx, types.Click{
+					ImpressionID: impression.ImpressionID,
+					BannerID:     r.banner.ID,
+					SlotID:       r.slot.ID,
+					GroupID:      r.group.ID,
+				}, 0)
+				require.NoError(t, err)
+			}
+		}
+
+		stats, err := store.GetArmStats(ctx, r.slot.ID, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, []types.ArmStats{
+			{Arm: types.ArmHoldout, Shows: 1, Clicks: 0, CTR: 0},
+			{Arm: types.ArmOptimised, Shows: 3, Clicks: 2, CTR: 2.0 / 3},
+		}, stats)
+	})
+}
+
+func TestRotationOverrides(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestRotationOverrides as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := testRotationInfo{
+		banner: types.Banner{ID: uuid.New(), Description: "Some banner"},
+		slot:   types.Slot{ID: uuid.New(), Description: "Main slot"},
+		group:  types.Group{ID: uuid.New(), Description: "Teenagers"},
+	}
+	createTestRotation(ctx, t, r)
+
+	t.Run("check new rotation is active", func(t *testing.T) {
+		rotation, err := store.GetRotation(ctx, r.banner.ID, r.slot.ID, r.group.ID)
+		require.NoError(t, err)
+		require.Equal(t, types.RotationStateActive, rotation.State)
+		require.Equal(t, types.RotationOverride{}, rotation.Override)
+	})
+
+	t.Run("check state and override are saved", func(t *testing.T) {
+		err := store.SetRotationState(ctx, r.banner.ID, r.slot.ID, r.group.ID, types.RotationStatePaused)
+		require.NoError(t, err)
+
+		override := types.RotationOverride{Pinned: true, MinSharePercent: 20}
+		err = store.SetRotationOverride(ctx, r.banner.ID, r.slot.ID, r.group.ID, override)
+		require.NoError(t, err)
+
+		rotation, err := store.GetRotation(ctx, r.banner.ID, r.slot.ID, r.group.ID)
+		require.NoError(t, err)
+		require.Equal(t, types.RotationStatePaused, rotation.State)
+		require.Equal(t, override, rotation.Override)
+	})
+
+	t.Run("check changes are recorded in stats", func(t *testing.T) {
+		events, err := store.GetRotationStats(ctx, r.banner.ID, r.slot.ID, r.group.ID)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+
+		require.Equal(t, storage.EventTypeState, events[0].Type)
+		require.Equal(t, types.RotationStatePaused, events[0].Details)
+		require.Equal(t, storage.EventTypeOverride, events[1].Type)
+		require.JSONEq(t, `{"Pinned":true,"MinSharePercent":20}`, events[1].Details)
+	})
+
+	t.Run("check unknown rotation", func(t *testing.T) {
+		err := store.SetRotationState(ctx, uuid.New(), r.slot.ID, r.group.ID, types.RotationStatePaused)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+
+		err = store.SetRotationOverride(ctx, uuid.New(), r.slot.ID, r.group.ID, types.RotationOverride{Pinned: true})
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
+
+func TestCampaigns(t *testing.T) { //nolint:funlen
+	if connectionString == "" {
+		t.Skipf("Skipping TestCampaigns as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := testRotationInfo{
+		banner: types.Banner{ID: uuid.New(), Description: "Some banner"},
+		slot:   types.Slot{ID: uuid.New(), Description: "Main slot"},
+		group:  types.Group{ID: uuid.New(), Description: "Teenagers"},
+	}
+	createTestRotation(ctx, t, r)
+
+	advertiser := types.Advertiser{ID: uuid.New(), Description: "Shoes shop"}
+	campaign := types.Campaign{
+		ID:           uuid.New(),
+		AdvertiserID: advertiser.ID,
+		Description:  "Summer sale",
+		Budget:       types.CampaignBudget{TotalShows: 1000, DailyShows: 100},
+	}
+
+	t.Run("check advertiser and campaign are created", func(t *testing.T) {
+		err := store.AddAdvertiser(ctx, advertiser)
+		require.NoError(t, err)
+
+		err = store.AddCampaign(ctx, campaign)
+		require.NoError(t, err)
+
+		dbAdvertiser, err := store.GetAdvertiser(ctx, advertiser.ID)
+		require.NoError(t, err)
+		require.Equal(t, advertiser, dbAdvertiser)
+
+		dbCampaign, err := store.GetCampaign(ctx, campaign.ID)
+		require.NoError(t, err)
+		require.Equal(t, campaign, dbCampaign)
+	})
+
+	t.Run("check budget is updated", func(t *testing.T) {
+		campaign.Budget = types.CampaignBudget{TotalShows: 10, TotalClicks: 5, DailyShows: 4, DailyClicks: 2}
+		err := store.SetCampaignBudget(ctx, campaign.ID, campaign.Budget)
+		require.NoError(t, err)
+
+		dbCampaign, err := store.GetCampaign(ctx, campaign.ID)
+		require.NoError(t, err)
+		require.Equal(t, campaign.Budget, dbCampaign.Budget)
+	})
+
+	t.Run("check banner shows are consumed from campaign budget", func(t *testing.T) {
+		err := store.SetBannerCampaign(ctx, r.banner.ID, campaign.ID)
+		require.NoError(t, err)
+
+		impression := testImpression(r)
+		for i := 0; i < 2; i++ {
+			err = store.AddShow(ctx, impression)
+			require.NoError(t, err)
+		}
+		err = store.AddClick(ctx, types.Click{BannerID: r.banner.ID, SlotID: r.slot.ID, GroupID: r.group.ID}, 0)
+		require.NoError(t, err)
+
+		consumption, err := store.GetCampaignConsumption(ctx, campaign.ID)
+		require.NoError(t, err)
+		require.Equal(t, campaign.Budget, consumption.Budget)
+		require.Equal(t, 2, consumption.TotalShows)
+		require.Equal(t, 1, consumption.TotalClicks)
+		require.Equal(t, 2, consumption.DailyShows)
+		require.Equal(t, 1, consumption.DailyClicks)
+		require.True(t, consumption.DayElapsed >= 0 && consumption.DayElapsed < 1)
+
+		rotations, err := store.GetAllRotations(ctx)
+		require.NoError(t, err)
+		require.Len(t, rotations, 1)
+		require.Equal(t, campaign.ID, rotations[0].CampaignID)
+	})
+
+	t.Run("check deleted advertiser releases banners", func(t *testing.T) {
+		err := store.DeleteAdvertiser(ctx, advertiser.ID)
+		require.NoError(t, err)
+
+		_, err = store.GetCampaign(ctx, campaign.ID)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+
+		banner, err := store.GetBanner(ctx, r.banner.ID)
+		require.NoError(t, err)
+		require.Equal(t, uuid.Nil, banner.CampaignID)
+
+		consumptions, err := store.GetCampaignsConsumption(ctx, []uuid.UUID{campaign.ID})
+		require.NoError(t, err)
+		require.Empty(t, consumptions)
+
+		err = store.DeleteAdvertiser(ctx, advertiser.ID)
+		require.ErrorIs(t, err, storage.ErrNoRowWasAffected)
+	})
+}
+
+func TestAddShows(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestAddShows as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	group := types.Group{ID: uuid.New(), Description: "Teenagers"}
+	top := testRotationInfo{
+		banner: types.Banner{ID: uuid.New(), Description: "Top banner"},
+		slot:   types.Slot{ID: uuid.New(), Description: "Top slot"},
+		group:  group,
+	}
+	side := testRotationInfo{
+		banner: types.Banner{ID: uuid.New(), Description: "Side banner"},
+		slot:   types.Slot{ID: uuid.New(), Description: "Side slot"},
+		group:  group,
+	}
+	createTestRotation(ctx, t, top)
+
+	err = store.AddBanner(ctx, side.banner)
+	require.NoError(t, err)
+	err = store.AddSlot(ctx, side.slot)
+	require.NoError(t, err)
+
+	topImpression := testImpression(top)
+	topImpression.Propensity = 0.5
+	sideImpression := testImpression(side)
+
+	t.Run("check nothing is registered if rotation is missing", func(t *testing.T) {
+		err := store.AddShows(ctx, []types.Impression{topImpression, sideImpression})
+		require.ErrorIs(t, err, sql.ErrNoRows)
+
+		rotation, err := store.GetRotation(ctx, top.banner.ID, top.slot.ID, top.group.ID)
+		require.NoError(t, err)
+		require.Zero(t, rotation.Shows)
+	})
+
+	_, err = store.AddRotation(ctx, side.banner.ID, side.slot.ID, side.group.ID)
+	require.NoError(t, err)
+
+	t.Run("check all shows are registered", func(t *testing.T) {
+		err := store.AddShows(ctx, []types.Impression{topImpression, sideImpression})
+		require.NoError(t, err)
+
+		for _, r := range []testRotationInfo{top, side} {
+			rotation, err := store.GetRotation(ctx, r.banner.ID, r.slot.ID, r.group.ID)
+			require.NoError(t, err)
+			require.Equal(t, 1, rotation.Shows)
+		}
+
+		events, err := store.GetEvents(ctx, top.slot.ID, top.group.ID, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, topImpression.ImpressionID, events[0].ImpressionID)
+		require.Equal(t, 0.5, events[0].Propensity)
+		require.Equal(t, types.ArmOptimised, events[0].Arm)
+	})
+}
+
+func TestListing(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestListing as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := testRotationInfo{
+		banner: types.Banner{ID: uuid.New(), Description: "Some banner"},
+		slot:   types.Slot{ID: uuid.New(), Description: "Main slot"},
+		group:  types.Group{ID: uuid.New(), Description: "Teenagers"},
+	}
+	createTestRotation(ctx, t, r)
+
+	deleted := types.Banner{ID: uuid.New(), Description: "Deleted banner"}
+	err = store.AddBanner(ctx, deleted)
+	require.NoError(t, err)
+	err = store.DeleteBanner(ctx, deleted.ID)
+	require.NoError(t, err)
+
+	banners, err := store.GetAllBanners(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []types.Banner{r.banner}, banners)
+
+	slots, err := store.GetAllSlots(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []types.Slot{r.slot}, slots)
+
+	groups, err := store.GetAllGroups(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []types.Group{r.group}, groups)
+}
+
+func TestImportCatalogue(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestImportCatalogue as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	existing := testRotationInfo{
+		banner: types.Banner{ID: uuid.New(), Description: "Existing banner"},
+		slot:   types.Slot{ID: uuid.New(), Description: "Existing slot"},
+		group:  types.Group{ID: uuid.New(), Description: "Existing group"},
+	}
+	createTestRotation(ctx, t, existing)
+
+	banner := types.Banner{ID: uuid.New(), Description: "Imported banner"}
+	catalogue := types.Catalogue{
+		Banners: []types.Banner{banner},
+		Rotations: []types.Rotation{{
+			BannerID: banner.ID,
+			SlotID:   existing.slot.ID,
+			GroupID:  existing.group.ID,
+			Shows:    10,
+			Clicks:   3,
+			State:    types.RotationStatePaused,
+		}},
+	}
+
+	t.Run("check dry run", func(t *testing.T) {
+		report, err := store.ImportCatalogue(ctx, catalogue, true)
+		require.NoError(t, err)
+		require.Equal(t, types.ImportReport{DryRun: true, Banners: 1, Rotations: 1}, report)
+
+		_, err = store.GetBanner(ctx, banner.ID)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("check import", func(t *testing.T) {
+		report, err := store.ImportCatalogue(ctx, catalogue, false)
+		require.NoError(t, err)
+		require.Equal(t, types.ImportReport{Banners: 1, Rotations: 1}, report)
+
+		rotation, err := store.GetRotation(ctx, banner.ID, existing.slot.ID, existing.group.ID)
+		require.NoError(t, err)
+		require.Equal(t, 10, rotation.Shows)
+		require.Equal(t, 3, rotation.Clicks)
+		require.Equal(t, types.RotationStatePaused, rotation.State)
+	})
+
+	t.Run("check conflicts", func(t *testing.T) {
+		conflicting := catalogue
+		conflicting.Groups = []types.Group{{ID: uuid.New(), Description: "New group"}}
+
+		report, err := store.ImportCatalogue(ctx, conflicting, false)
+		require.ErrorIs(t, err, types.ErrCatalogueConflicts)
+		require.Len(t, report.Conflicts, 2)
+		require.Equal(t, "banner", report.Conflicts[0].Kind)
+		require.Equal(t, "rotation", report.Conflicts[1].Kind)
+
+		_, err = store.GetGroup(ctx, conflicting.Groups[0].ID)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("check group rules are imported and banner campaigns are not", func(t *testing.T) {
+		group := types.Group{ID: uuid.New(), Description: "Imported group"}
+		rule := types.GroupRule{
+			GroupID:    group.ID,
+			Priority:   1,
+			MinAge:     13,
+			MaxAge:     19,
+			Locale:     "en",
+			Attributes: map[string]string{"plan": "free"},
+		}
+		withRules := types.Catalogue{
+			Banners: []types.Banner{{ID: uuid.New(), Description: "Campaign banner", CampaignID: uuid.New()}},
+			Groups:  []types.Group{group},
+			Rules:   []types.GroupRule{rule},
+		}
+
+		report, err := store.ImportCatalogue(ctx, withRules, false)
+		require.NoError(t, err)
+		require.Equal(t, 1, report.Rules)
+
+		rules, err := store.GetGroupRules(ctx, group.ID)
+		require.NoError(t, err)
+		require.Len(t, rules, 1)
+		rule.ID = rules[0].ID
+		require.Equal(t, rule, rules[0])
+
+		imported, err := store.GetBanner(ctx, withRules.Banners[0].ID)
+		require.NoError(t, err)
+		require.Equal(t, uuid.Nil, imported.CampaignID)
+
+		// Importing rules for group which already has them would duplicate rules
+		again := types.Catalogue{Rules: []types.GroupRule{rule}}
+		report, err = store.ImportCatalogue(ctx, again, false)
+		require.ErrorIs(t, err, types.ErrCatalogueConflicts)
+		require.Equal(t, []types.CatalogueConflict{{
+			Kind:   "rule",
+			ID:     group.ID.String() + "/0",
+			Reason: "group already has rules",
+		}}, report.Conflicts)
+	})
+}
+
+func TestMigrations(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestMigrations as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("check concurrent up waits for lock", func(t *testing.T) {
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				errs <- store.MigrateUp(ctx, ioutil.Discard)
+			}()
+		}
+		require.NoError(t, <-errs)
+		require.NoError(t, <-errs)
+	})
+
+	t.Run("check status lists migrations", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := store.MigrationStatus(ctx, &buf)
+		require.NoError(t, err)
+		require.Contains(t, buf.String(), "20210419132738_initial.sql")
+		require.NotContains(t, buf.String(), "Pending")
+	})
+
+	t.Run("check no pending migrations", func(t *testing.T) {
+		pending, err := store.PendingMigrations(ctx)
+		require.NoError(t, err)
+		require.Zero(t, pending)
+	})
+
+	t.Run("check ping", func(t *testing.T) {
+		require.NoError(t, store.Ping(ctx))
+	})
+}
+
+func TestReconcileCounters(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestReconcileCounters as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := testRotationInfo{
+		banner: types.Banner{ID: uuid.New(), Description: "Some banner"},
+		slot:   types.Slot{ID: uuid.New(), Description: "Main slot"},
+		group:  types.Group{ID: uuid.New(), Description: "Teenagers"},
+	}
+	createTestRotation(ctx, t, r)
+	err = store.AddShow(ctx, testImpression(r))
+	require.NoError(t, err)
+
+	// Imported counters have no logged events
+	imported := types.Banner{ID: uuid.New(), Description: "Imported banner"}
+	_, err = store.ImportCatalogue(ctx, types.Catalogue{
+		Banners: []types.Banner{imported},
+		Rotations: []types.Rotation{{
+			BannerID: imported.ID,
+			SlotID:   r.slot.ID,
+			GroupID:  r.group.ID,
+			Shows:    10,
+			Clicks:   2,
+		}},
+	}, false)
+	require.NoError(t, err)
+
+	t.Run("check imported statistics are not a drift", func(t *testing.T) {
+		err := store.AddShow(ctx, types.Impression{
+			ImpressionID: uuid.New(),
+			Rotation:     types.Rotation{BannerID: imported.ID, SlotID: r.slot.ID, GroupID: r.group.ID},
+		})
+		require.NoError(t, err)
+
+		drifts, err := store.ReconcileCounters(ctx, false)
+		require.NoError(t, err)
+		require.Empty(t, drifts)
+	})
+
+	t.Run("check drifted counters are set to events", func(t *testing.T) {
+		db, err := sql.Open("pgx", connectionString)
+		require.NoError(t, err)
+		defer db.Close()
+
+		_, err = db.ExecContext(ctx, `UPDATE rotations SET shows=shows+5 WHERE banner_id=$1`, imported.ID)
+		require.NoError(t, err)
+
+		drifts, err := store.ReconcileCounters(ctx, false)
+		require.NoError(t, err)
+		require.Equal(t, []types.CounterDrift{{
+			BannerID:    imported.ID,
+			SlotID:      r.slot.ID,
+			GroupID:     r.group.ID,
+			Shows:       16,
+			EventShows:  11,
+			Clicks:      2,
+			EventClicks: 2,
+		}}, drifts)
+
+		drifts, err = store.ReconcileCounters(ctx, true)
+		require.NoError(t, err)
+		require.Len(t, drifts, 1)
+
+		rotation, err := store.GetRotation(ctx, imported.ID, r.slot.ID, r.group.ID)
+		require.NoError(t, err)
+		require.Equal(t, 11, rotation.Shows)
+		require.Equal(t, 2, rotation.Clicks)
+
+		drifts, err = store.ReconcileCounters(ctx, false)
+		require.NoError(t, err)
+		require.Empty(t, drifts)
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestAPIKeys as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	key := types.APIKey{
+		ID:        uuid.New(),
+		Name:      "frontend",
+		Role:      types.RoleServe,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	err = store.AddAPIKey(ctx, key, "hash")
+	require.NoError(t, err)
+
+	t.Run("check key is found by hash", func(t *testing.T) {
+		found, err := store.GetAPIKeyByHash(ctx, "hash")
+		require.NoError(t, err)
+		require.Equal(t, key, found)
+
+		keys, err := store.GetAllAPIKeys(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []types.APIKey{key}, keys)
+	})
+
+	t.Run("check hash is unique", func(t *testing.T) {
+		duplicate := key
+		duplicate.ID = uuid.New()
+		err := store.AddAPIKey(ctx, duplicate, "hash")
+		require.Error(t, err)
+	})
+
+	t.Run("check revoked key is not found", func(t *testing.T) {
+		err := store.DeleteAPIKey(ctx, key.ID)
+		require.NoError(t, err)
+
+		_, err = store.GetAPIKeyByHash(ctx, "hash")
+		require.ErrorIs(t, err, sql.ErrNoRows)
+
+		err = store.DeleteAPIKey(ctx, key.ID)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
+
+func TestRateLimitBuckets(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestRateLimitBuckets as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Slow refill keeps test independent of time spent on queries
+	limit := types.RateLimit{Rate: 0.001, Burst: 2}
+
+	t.Run("check burst is allowed and then limited", func(t *testing.T) {
+		for i := 0; i < limit.Burst; i++ {
+			retryAfter, err := store.TakeToken(ctx, "client", limit)
+			require.NoError(t, err)
+			require.Zero(t, retryAfter)
+		}
+
+		retryAfter, err := store.TakeToken(ctx, "client", limit)
+		require.NoError(t, err)
+		require.Greater(t, retryAfter, 900*time.Second)
+	})
+
+	t.Run("check clients have separate buckets", func(t *testing.T) {
+		retryAfter, err := store.TakeToken(ctx, "other client", limit)
+		require.NoError(t, err)
+		require.Zero(t, retryAfter)
+	})
+}
+
+func TestClickValidationEvents(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestClickValidationEvents as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := testRotationInfo{
+		banner: types.Banner{ID: uuid.New(), Description: "Some banner"},
+		slot:   types.Slot{ID: uuid.New(), Description: "Main slot"},
+		group:  types.Group{ID: uuid.New(), Description: "Teenagers"},
+	}
+	createTestRotation(ctx, t, r)
+
+	impression := testImpression(r)
+	impression.VisitorID = "visitor"
+	err = store.AddShow(ctx, impression)
+	require.NoError(t, err)
+
+	click := types.Click{
+		ImpressionID: impression.ImpressionID,
+		BannerID:     r.banner.ID,
+		SlotID:       r.slot.ID,
+		GroupID:      r.group.ID,
+		Visitor:      types.Visitor{ID: "visitor"},
+	}
+
+	t.Run("check show is found by impression and by visitor", func(t *testing.T) {
+		since, err := store.SinceLastEvent(ctx, click, storage.EventTypeShow)
+		require.NoError(t, err)
+		require.Less(t, since, time.Minute)
+
+		byVisitor := click
+		byVisitor.ImpressionID = uuid.Nil
+		_, err = store.SinceLastEvent(ctx, byVisitor, storage.EventTypeShow)
+		require.NoError(t, err)
+	})
+
+	t.Run("check unknown show is not found", func(t *testing.T) {
+		unknown := click
+		unknown.ImpressionID = uuid.New()
+		_, err := store.SinceLastEvent(ctx, unknown, storage.EventTypeShow)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+
+		anonymous := click
+		anonymous.ImpressionID, anonymous.Visitor = uuid.Nil, types.Visitor{}
+		_, err = store.SinceLastEvent(ctx, anonymous, storage.EventTypeShow)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("check registered click is found", func(t *testing.T) {
+		_, err := store.SinceLastEvent(ctx, click, storage.EventTypeClick)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+
+		err = store.AddClick(ctx, click, 0)
+		require.NoError(t, err)
+
+		_, err = store.SinceLastEvent(ctx, click, storage.EventTypeClick)
+		require.NoError(t, err)
+	})
+
+	t.Run("check rejected click is not counted", func(t *testing.T) {
+		click.UserAgent, click.IP = "Googlebot", "192.0.2.1"
+		err := store.AddRejectedClick(ctx, click, types.RejectReasonBot)
+		require.NoError(t, err)
+
+		rotation, err := store.GetRotation(ctx, r.banner.ID, r.slot.ID, r.group.ID)
+		require.NoError(t, err)
+		require.Equal(t, 1, rotation.Clicks)
+	})
+
+	t.Run("check duplicate is found by visitor despite other impression", func(t *testing.T) {
+		other := click
+		other.ImpressionID = uuid.New()
+		err := store.AddClick(ctx, other, time.Hour)
+		require.ErrorIs(t, err, types.ErrDuplicateClick)
+
+		anonymous := click
+		anonymous.ImpressionID, anonymous.Visitor = uuid.Nil, types.Visitor{}
+		err = store.AddClick(ctx, anonymous, time.Hour)
+		require.NoError(t, err)
+
+		rotation, err := store.GetRotation(ctx, r.banner.ID, r.slot.ID, r.group.ID)
+		require.NoError(t, err)
+		require.Equal(t, 2, rotation.Clicks)
+	})
+
+	t.Run("check concurrent duplicates are registered once", func(t *testing.T) {
+		const clicks = 10
+		var (
+			wg   sync.WaitGroup
+			errs = make([]error, clicks)
+		)
+		for i := 0; i < clicks; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = store.AddClick(ctx, types.Click{
+					BannerID: r.banner.ID,
+					SlotID:   r.slot.ID,
+					GroupID:  r.group.ID,
+					Visitor:  types.Visitor{ID: "another visitor"},
+				}, time.Hour)
+			}(i)
+		}
+		wg.Wait()
+
+		registered := 0
+		for _, err := range errs {
+			if err == nil {
+				registered++
+				continue
+			}
+			require.ErrorIs(t, err, types.ErrDuplicateClick)
+		}
+		require.Equal(t, 1, registered)
+
+		rotation, err := store.GetRotation(ctx, r.banner.ID, r.slot.ID, r.group.ID)
+		require.NoError(t, err)
+		require.Equal(t, 3, rotation.Clicks)
+	})
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestIdempotencyKeys as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	response := types.IdempotentResponse{Status: 200, ContentType: "application/json", Body: []byte(`[]`)}
+
+	t.Run("check concurrent requests claim key once", func(t *testing.T) {
+		const requests = 10
+		var (
+			wg      sync.WaitGroup
+			claimed = make([]bool, requests)
+			errs    = make([]error, requests)
+		)
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, claimed[i], errs[i] = store.BeginRequest(ctx, "key", "hash", time.Minute)
+			}(i)
+		}
+		wg.Wait()
+
+		var claims int
+		for i := range claimed {
+			require.NoError(t, errs[i])
+			if claimed[i] {
+				claims++
+			}
+		}
+		require.Equal(t, 1, claims)
+	})
+
+	t.Run("check response is replayed", func(t *testing.T) {
+		err := store.CompleteRequest(ctx, "key", response, time.Hour)
+		require.NoError(t, err)
+
+		stored, claimed, err := store.BeginRequest(ctx, "key", "hash", time.Minute)
+		require.NoError(t, err)
+		require.False(t, claimed)
+		require.Equal(t, &response, stored)
+	})
+
+	t.Run("check key is not reused with another body", func(t *testing.T) {
+		_, claimed, err := store.BeginRequest(ctx, "key", "other hash", time.Minute)
+		require.ErrorIs(t, err, types.ErrIdempotencyKeyReused)
+		require.False(t, claimed)
+	})
+
+	t.Run("check abandoned key is claimed again", func(t *testing.T) {
+		_, claimed, err := store.BeginRequest(ctx, "failed", "hash", time.Minute)
+		require.NoError(t, err)
+		require.True(t, claimed)
+
+		err = store.AbandonRequest(ctx, "failed")
+		require.NoError(t, err)
+
+		_, claimed, err = store.BeginRequest(ctx, "failed", "hash", time.Minute)
+		require.NoError(t, err)
+		require.True(t, claimed)
+	})
+
+	t.Run("check expired key is claimed again", func(t *testing.T) {
+		_, claimed, err := store.BeginRequest(ctx, "expired", "hash", -time.Second)
+		require.NoError(t, err)
+		require.True(t, claimed)
+
+		_, claimed, err = store.BeginRequest(ctx, "expired", "hash", time.Minute)
+		require.NoError(t, err)
+		require.True(t, claimed)
+	})
+}
+
+func TestRotatorStates(t *testing.T) {
+	if connectionString == "" {
+		t.Skipf("Skipping TestRotatorStates as env var '%s' is not set", dbConnEnvVar)
+	}
+	err := store.Connect()
+	require.NoError(t, err)
+	defer func() {
+		err := store.Close()
+		require.NoError(t, err, "failed to close db connection")
+	}()
+
+	defer func() {
+		err := store.CleanDB()
+		require.NoError(t, err, "failed to clean database after test")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := testRotationInfo{
+		banner: types.Banner{ID: uuid.New(), Description: "Some banner"},
+		slot:   types.Slot{ID: uuid.New(), Description: "Main slot"},
+		group:  types.Group{ID: uuid.New(), Description: "Teenagers"},
+	}
+	createTestRotation(ctx, t, r)
+
+	t.Run("check concurrent updates are not lost", func(t *testing.T) {
+		const requests = 10
+		var (
+			wg     sync.WaitGroup
+			errs   = make([]error, requests)
+			update = func(state []byte) ([]byte, error) {
+				return append(state, 'x'), nil
+			}
+		)
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = store.UpdateRotatorState(ctx, r.slot.ID, r.group.ID, update)
+			}(i)
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			require.NoError(t, err)
+		}
+
+		state, err := store.GetRotatorState(ctx, r.slot.ID, r.group.ID)
+		require.NoError(t, err)
+		require.Equal(t, strings.Repeat("x", requests), string(state))
+	})
+}
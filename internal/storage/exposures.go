@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+// ExposureStorer implementation. Concurrent exposures of visitor to rotation
+// are serialised with transaction level advisory lock, so that cap is not exceeded.
+func (s *Storage) AddExposure(
+	ctx context.Context,
+	visitorID string,
+	bannerID, slotID, groupID uuid.UUID,
+	cap types.FrequencyCap,
+) (bool, error) {
+	lockQuery := `
+	SELECT pg_advisory_xact_lock(hashtext($1 || $2::text || $3::text || $4::text))
+	`
+	cleanExpiredQuery := `
+	DELETE FROM exposures WHERE visitor_id=$1 AND expires_at < now()
+	`
+	countQuery := `
+	SELECT count(*) FROM exposures
+	WHERE
+	visitor_id=$1 AND banner_id=$2 AND slot_id=$3 AND group_id=$4
+	AND stamp > now() - $5::bigint * interval '1 second'
+	`
+	insertExposureQuery := `
+	INSERT INTO exposures (visitor_id, banner_id, slot_id, group_id, stamp, expires_at)
+	VALUES ($1, $2, $3, $4, now(), now() + $5::bigint * interval '1 second')
+	`
+
+	window := int64(cap.Window.Seconds())
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, lockQuery, visitorID, bannerID, slotID, groupID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, cleanExpiredQuery, visitorID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	var count int
+	err = tx.QueryRowContext(ctx, countQuery, visitorID, bannerID, slotID, groupID, window).Scan(&count)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if count >= cap.MaxShows {
+		tx.Rollback()
+		return false, nil
+	}
+
+	err = execTxQuery(tx, insertExposureQuery, visitorID, bannerID, slotID, groupID, window)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *Storage) RemoveExposure(
+	ctx context.Context,
+	visitorID string,
+	bannerID, slotID, groupID uuid.UUID,
+) error {
+	query := `
+	DELETE FROM exposures WHERE id = (
+		SELECT id FROM exposures
+		WHERE visitor_id=$1 AND banner_id=$2 AND slot_id=$3 AND group_id=$4
+		ORDER BY stamp DESC, id DESC
+		LIMIT 1
+	)
+	`
+
+	_, err := s.db.ExecContext(ctx, query, visitorID, bannerID, slotID, groupID)
+	return err
+}
+
+func (s *Storage) CountExposures(
+	ctx context.Context,
+	visitorID string,
+	bannerID, slotID, groupID uuid.UUID,
+	window time.Duration,
+) (int, error) {
+	query := `
+	SELECT count(*) FROM exposures
+	WHERE
+	visitor_id=$1 AND banner_id=$2 AND slot_id=$3 AND group_id=$4
+	AND stamp > now() - $5::bigint * interval '1 second'
+	`
+
+	row := s.db.QueryRowxContext(ctx, query, visitorID, bannerID, slotID, groupID, int64(window.Seconds()))
+	if row.Err() != nil {
+		return 0, row.Err()
+	}
+
+	var count int
+	err := row.Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+const selectGroupRuleColumns = `
+	r.id, r.group_id, r.priority, r.min_age, r.max_age,
+	r.gender, r.locale, r.device, r.attributes::text AS attributes,
+	r.deleted, r.deleted_at
+`
+
+func (s *Storage) AddGroupRule(ctx context.Context, rule types.GroupRule) (types.GroupRule, error) {
+	query := `
+	INSERT INTO group_rules (group_id, priority, min_age, max_age, gender, locale, device, attributes)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8::jsonb)
+	RETURNING id
+	`
+
+	attributes := rule.Attributes
+	if attributes == nil {
+		attributes = map[string]string{}
+	}
+	attributesJSON, err := json.Marshal(attributes)
+	if err != nil {
+		return types.GroupRule{}, err
+	}
+
+	row := s.db.QueryRowxContext(
+		ctx,
+		query,
+		rule.GroupID,
+		rule.Priority,
+		rule.MinAge,
+		rule.MaxAge,
+		rule.Gender,
+		rule.Locale,
+		rule.Device,
+		string(attributesJSON),
+	)
+	if row.Err() != nil {
+		return types.GroupRule{}, row.Err()
+	}
+
+	err = row.Scan(&rule.ID)
+	if err != nil {
+		return types.GroupRule{}, err
+	}
+	rule.Attributes = attributes
+
+	return rule, nil
+}
+
+func (s *Storage) GetGroupRules(ctx context.Context, groupID uuid.UUID) ([]types.GroupRule, error) {
+	query := `SELECT ` + selectGroupRuleColumns + `
+	FROM group_rules r
+	WHERE r.group_id=$1 AND r.deleted=FALSE
+	ORDER BY r.priority, r.id
+	`
+	return s.selectGroupRules(ctx, query, groupID)
+}
+
+func (s *Storage) GetAllGroupRules(ctx context.Context) ([]types.GroupRule, error) {
+	query := `SELECT ` + selectGroupRuleColumns + `
+	FROM group_rules r
+	JOIN groups g ON g.id=r.group_id
+	WHERE r.deleted=FALSE AND g.deleted=FALSE
+	ORDER BY r.priority, r.id
+	`
+	return s.selectGroupRules(ctx, query)
+}
+
+func (s *Storage) DeleteGroupRule(ctx context.Context, groupID uuid.UUID, ruleID int) error {
+	query := `
+	UPDATE group_rules SET deleted=TRUE, deleted_at=now()
+	WHERE id=$1 AND group_id=$2 AND deleted=FALSE
+	`
+	res, err := s.db.ExecContext(ctx, query, ruleID, groupID)
+	if err != nil {
+		return err
+	}
+
+	rowsUpdated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsUpdated == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *Storage) selectGroupRules(ctx context.Context, query string, args ...interface{}) ([]types.GroupRule, error) {
+	rows, err := s.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	var groupRules []types.GroupRule
+	for rows.Next() {
+		var r groupRule
+
+		err := rows.StructScan(&r)
+		if err != nil {
+			return nil, err
+		}
+
+		rule, err := r.toGroupRule()
+		if err != nil {
+			return nil, err
+		}
+
+		groupRules = append(groupRules, rule)
+	}
+
+	return groupRules, nil
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+// GetArmStats counts shows of slot in time range per holdout experiment arm.
+// Only clicks linked to shows by impression are counted.
+func (s *Storage) GetArmStats(ctx context.Context, slotID uuid.UUID, from, to time.Time) ([]types.ArmStats, error) {
+	query := `
+	SELECT
+		s.arm AS arm,
+		count(DISTINCT s.id) AS shows,
+		count(DISTINCT c.impression_id) AS clicks
+	FROM events s
+	JOIN rotations r ON r.id=s.rotation_id
+	LEFT JOIN events c ON c.impression_id=s.impression_id AND c.event_type=$2
+	WHERE
+	s.event_type=$1 AND r.slot_id=$3 AND s.stamp >= $4 AND s.stamp < $5
+	GROUP BY s.arm
+	ORDER BY s.arm
+	`
+
+	rows, err := s.db.QueryxContext(ctx, query, EventTypeShow, EventTypeClick, slotID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	var stats []types.ArmStats
+	for rows.Next() {
+		var armStats types.ArmStats
+
+		err := rows.Scan(&armStats.Arm, &armStats.Shows, &armStats.Clicks)
+		if err != nil {
+			return nil, err
+		}
+		if armStats.Shows > 0 {
+			armStats.CTR = float64(armStats.Clicks) / float64(armStats.Shows)
+		}
+
+		stats = append(stats, armStats)
+	}
+
+	return stats, nil
+}
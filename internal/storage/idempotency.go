@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+// IdempotencyStorer implementation. Key is claimed by inserting row,
+// so replicas sharing database do not process the same request twice.
+func (s *Storage) BeginRequest(
+	ctx context.Context,
+	key, bodyHash string,
+	lease time.Duration,
+) (*types.IdempotentResponse, bool, error) {
+	claimQuery := `
+	INSERT INTO idempotency_keys (key, status, content_type, body, body_hash, expires_at)
+	VALUES ($1, 0, '', NULL, $3, now() + $2::double precision * interval '1 second')
+	ON CONFLICT (key) DO UPDATE
+	SET status=0, content_type='', body=NULL, body_hash=EXCLUDED.body_hash, expires_at=EXCLUDED.expires_at
+	WHERE idempotency_keys.expires_at < now()
+	RETURNING key
+	`
+	responseQuery := `
+	SELECT status, content_type, body, body_hash FROM idempotency_keys WHERE key=$1
+	`
+
+	err := s.sweep(ctx, `DELETE FROM idempotency_keys WHERE expires_at < now()`)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var claimedKey string
+	err = s.db.QueryRowContext(ctx, claimQuery, key, lease.Seconds(), bodyHash).Scan(&claimedKey)
+	switch {
+	case err == nil:
+		return nil, true, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, false, err
+	}
+
+	var (
+		response    types.IdempotentResponse
+		claimedHash string
+	)
+	err = s.db.QueryRowContext(ctx, responseQuery, key).Scan(
+		&response.Status, &response.ContentType, &response.Body, &claimedHash,
+	)
+	switch {
+	// Key expired in between, request is treated as in progress to be retried
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, false, nil
+	case err != nil:
+		return nil, false, err
+	case claimedHash != bodyHash:
+		return nil, false, types.ErrIdempotencyKeyReused
+	case response.Status == 0:
+		return nil, false, nil
+	}
+
+	return &response, false, nil
+}
+
+func (s *Storage) CompleteRequest(
+	ctx context.Context,
+	key string,
+	response types.IdempotentResponse,
+	ttl time.Duration,
+) error {
+	query := `
+	UPDATE idempotency_keys
+	SET status=$2, content_type=$3, body=$4, expires_at=now() + $5::double precision * interval '1 second'
+	WHERE key=$1
+	`
+
+	res, err := s.db.ExecContext(ctx, query, key, response.Status, response.ContentType, response.Body, ttl.Seconds())
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("no idempotency key was completed")
+	}
+
+	return nil
+}
+
+func (s *Storage) AbandonRequest(ctx context.Context, key string) error {
+	query := `
+	DELETE FROM idempotency_keys WHERE key=$1 AND status=0
+	`
+
+	_, err := s.db.ExecContext(ctx, query, key)
+	return err
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+// GetAllBanners returns banners which are not deleted ordered by description.
+func (s *Storage) GetAllBanners(ctx context.Context) ([]types.Banner, error) {
+	query := `
+	SELECT * FROM banners WHERE deleted=FALSE ORDER BY description, id
+	`
+
+	rows, err := s.db.QueryxContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var banners []types.Banner
+	for rows.Next() {
+		var b banner
+
+		err := rows.StructScan(&b)
+		if err != nil {
+			return nil, err
+		}
+
+		banners = append(banners, b.toBanner())
+	}
+
+	return banners, rows.Err()
+}
+
+// GetAllSlots returns slots which are not deleted ordered by description.
+func (s *Storage) GetAllSlots(ctx context.Context) ([]types.Slot, error) {
+	query := `
+	SELECT * FROM slots WHERE deleted=FALSE ORDER BY description, id
+	`
+
+	rows, err := s.db.QueryxContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slots []types.Slot
+	for rows.Next() {
+		var sl slot
+
+		err := rows.StructScan(&sl)
+		if err != nil {
+			return nil, err
+		}
+
+		slots = append(slots, sl.toSlot())
+	}
+
+	return slots, rows.Err()
+}
+
+// GetAllGroups returns groups which are not deleted ordered by description.
+func (s *Storage) GetAllGroups(ctx context.Context) ([]types.Group, error) {
+	query := `
+	SELECT * FROM groups WHERE deleted=FALSE ORDER BY description, id
+	`
+
+	rows, err := s.db.QueryxContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []types.Group
+	for rows.Next() {
+		var g group
+
+		err := rows.StructScan(&g)
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, g.toGroup())
+	}
+
+	return groups, rows.Err()
+}
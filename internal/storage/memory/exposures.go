@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+type exposureKey struct {
+	visitorID string
+	bannerID  uuid.UUID
+	slotID    uuid.UUID
+	groupID   uuid.UUID
+}
+
+type exposure struct {
+	stamp     time.Time
+	expiresAt time.Time
+}
+
+// ExposureStore is an in-memory ExposureStorer implementation.
+// Exposures are lost on restart.
+type ExposureStore struct {
+	mu        sync.Mutex
+	exposures map[exposureKey][]exposure
+	now       func() time.Time
+}
+
+func NewExposureStore() *ExposureStore {
+	return &ExposureStore{
+		exposures: make(map[exposureKey][]exposure),
+		now:       time.Now,
+	}
+}
+
+func (es *ExposureStore) AddExposure(
+	_ context.Context,
+	visitorID string,
+	bannerID, slotID, groupID uuid.UUID,
+	cap types.FrequencyCap,
+) (bool, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	now := es.now()
+	key := exposureKey{visitorID: visitorID, bannerID: bannerID, slotID: slotID, groupID: groupID}
+
+	// Drop expired records to keep memory bounded
+	actual := es.exposures[key][:0]
+	for _, e := range es.exposures[key] {
+		if e.expiresAt.After(now) {
+			actual = append(actual, e)
+		}
+	}
+	es.exposures[key] = actual
+
+	if len(actual) >= cap.MaxShows {
+		return false, nil
+	}
+	es.exposures[key] = append(actual, exposure{stamp: now, expiresAt: now.Add(cap.Window)})
+
+	return true, nil
+}
+
+func (es *ExposureStore) RemoveExposure(
+	_ context.Context,
+	visitorID string,
+	bannerID, slotID, groupID uuid.UUID,
+) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	key := exposureKey{visitorID: visitorID, bannerID: bannerID, slotID: slotID, groupID: groupID}
+	if exposures := es.exposures[key]; len(exposures) > 0 {
+		es.exposures[key] = exposures[:len(exposures)-1]
+	}
+	return nil
+}
+
+func (es *ExposureStore) CountExposures(
+	_ context.Context,
+	visitorID string,
+	bannerID, slotID, groupID uuid.UUID,
+	window time.Duration,
+) (int, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	since := es.now().Add(-window)
+	key := exposureKey{visitorID: visitorID, bannerID: bannerID, slotID: slotID, groupID: groupID}
+
+	var count int
+	for _, e := range es.exposures[key] {
+		if e.stamp.After(since) {
+			count++
+		}
+	}
+
+	return count, nil
+}
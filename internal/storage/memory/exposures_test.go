@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExposureStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewExposureStore()
+	store.now = func() time.Time { return now }
+
+	bannerID, slotID, groupID := uuid.New(), uuid.New(), uuid.New()
+	window := time.Hour
+	frequencyCap := types.FrequencyCap{MaxShows: 3, Window: window}
+
+	t.Run("check unknown visitor has no exposures", func(t *testing.T) {
+		count, err := store.CountExposures(ctx, "unknown", bannerID, slotID, groupID, window)
+		require.NoError(t, err)
+		require.Zero(t, count)
+	})
+
+	t.Run("check exposures are counted per visitor", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			added, err := store.AddExposure(ctx, "visitor", bannerID, slotID, groupID, frequencyCap)
+			require.NoError(t, err)
+			require.True(t, added)
+		}
+		added, err := store.AddExposure(ctx, "other visitor", bannerID, slotID, groupID, frequencyCap)
+		require.NoError(t, err)
+		require.True(t, added)
+
+		count, err := store.CountExposures(ctx, "visitor", bannerID, slotID, groupID, window)
+		require.NoError(t, err)
+		require.Equal(t, 3, count)
+	})
+
+	t.Run("check exposure over cap is not added", func(t *testing.T) {
+		added, err := store.AddExposure(ctx, "visitor", bannerID, slotID, groupID, frequencyCap)
+		require.NoError(t, err)
+		require.False(t, added)
+
+		count, err := store.CountExposures(ctx, "visitor", bannerID, slotID, groupID, window)
+		require.NoError(t, err)
+		require.Equal(t, 3, count)
+	})
+
+	t.Run("check exposures leave the window", func(t *testing.T) {
+		now = now.Add(2 * window)
+
+		count, err := store.CountExposures(ctx, "visitor", bannerID, slotID, groupID, window)
+		require.NoError(t, err)
+		require.Zero(t, count)
+	})
+
+	t.Run("check removed exposure is not counted", func(t *testing.T) {
+		added, err := store.AddExposure(ctx, "visitor", bannerID, slotID, groupID, frequencyCap)
+		require.NoError(t, err)
+		require.True(t, added)
+
+		err = store.RemoveExposure(ctx, "visitor", bannerID, slotID, groupID)
+		require.NoError(t, err)
+
+		count, err := store.CountExposures(ctx, "visitor", bannerID, slotID, groupID, window)
+		require.NoError(t, err)
+		require.Zero(t, count)
+	})
+
+	t.Run("check expired exposures are dropped", func(t *testing.T) {
+		added, err := store.AddExposure(ctx, "visitor", bannerID, slotID, groupID, frequencyCap)
+		require.NoError(t, err)
+		require.True(t, added)
+
+		key := exposureKey{visitorID: "visitor", bannerID: bannerID, slotID: slotID, groupID: groupID}
+		require.Len(t, store.exposures[key], 1)
+	})
+}
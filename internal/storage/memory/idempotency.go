@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+type idempotentRequest struct {
+	// response is nil while request is in progress
+	response  *types.IdempotentResponse
+	bodyHash  string
+	expiresAt time.Time
+}
+
+// IdempotencyStore is an in-memory IdempotencyStorer implementation.
+// Responses are lost on restart and are not shared between replicas.
+type IdempotencyStore struct {
+	mu        sync.Mutex
+	requests  map[string]*idempotentRequest
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewIdempotencyStore() *IdempotencyStore {
+	return &IdempotencyStore{
+		requests: make(map[string]*idempotentRequest),
+		now:      time.Now,
+	}
+}
+
+func (is *IdempotencyStore) BeginRequest(
+	_ context.Context,
+	key, bodyHash string,
+	lease time.Duration,
+) (*types.IdempotentResponse, bool, error) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	now := is.now()
+	if now.Sub(is.lastSweep) > sweepInterval {
+		for k, request := range is.requests {
+			if now.After(request.expiresAt) {
+				delete(is.requests, k)
+			}
+		}
+		is.lastSweep = now
+	}
+
+	request, ok := is.requests[key]
+	if ok && now.Before(request.expiresAt) {
+		if request.bodyHash != bodyHash {
+			return nil, false, types.ErrIdempotencyKeyReused
+		}
+		return request.response, false, nil
+	}
+
+	is.requests[key] = &idempotentRequest{bodyHash: bodyHash, expiresAt: now.Add(lease)}
+	return nil, true, nil
+}
+
+func (is *IdempotencyStore) CompleteRequest(
+	_ context.Context,
+	key string,
+	response types.IdempotentResponse,
+	ttl time.Duration,
+) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	request, ok := is.requests[key]
+	if !ok {
+		request = &idempotentRequest{}
+		is.requests[key] = request
+	}
+	request.response, request.expiresAt = &response, is.now().Add(ttl)
+	return nil
+}
+
+func (is *IdempotencyStore) AbandonRequest(_ context.Context, key string) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	if request, ok := is.requests[key]; ok && request.response == nil {
+		delete(is.requests, key)
+	}
+	return nil
+}
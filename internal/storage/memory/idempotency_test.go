@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewIdempotencyStore()
+	store.now = func() time.Time { return now }
+
+	response := types.IdempotentResponse{Status: http.StatusOK, ContentType: "application/json", Body: []byte(`{}`)}
+
+	t.Run("check key is claimed once", func(t *testing.T) {
+		stored, claimed, err := store.BeginRequest(ctx, "key", "hash", time.Second)
+		require.NoError(t, err)
+		require.True(t, claimed)
+		require.Nil(t, stored)
+
+		stored, claimed, err = store.BeginRequest(ctx, "key", "hash", time.Second)
+		require.NoError(t, err)
+		require.False(t, claimed)
+		require.Nil(t, stored, "request is in progress")
+	})
+
+	t.Run("check response is replayed until ttl", func(t *testing.T) {
+		err := store.CompleteRequest(ctx, "key", response, time.Hour)
+		require.NoError(t, err)
+
+		stored, claimed, err := store.BeginRequest(ctx, "key", "hash", time.Second)
+		require.NoError(t, err)
+		require.False(t, claimed)
+		require.Equal(t, &response, stored)
+
+		err = store.AbandonRequest(ctx, "key")
+		require.NoError(t, err)
+		_, claimed, err = store.BeginRequest(ctx, "key", "hash", time.Second)
+		require.NoError(t, err)
+		require.False(t, claimed, "finished request is not abandoned")
+
+		now = now.Add(2 * time.Hour)
+		_, claimed, err = store.BeginRequest(ctx, "key", "hash", time.Second)
+		require.NoError(t, err)
+		require.True(t, claimed)
+	})
+
+	t.Run("check abandoned key is claimed again", func(t *testing.T) {
+		err := store.AbandonRequest(ctx, "key")
+		require.NoError(t, err)
+
+		_, claimed, err := store.BeginRequest(ctx, "key", "hash", time.Second)
+		require.NoError(t, err)
+		require.True(t, claimed)
+	})
+
+	t.Run("check key of request over lease is claimed again", func(t *testing.T) {
+		now = now.Add(2 * time.Second)
+		_, claimed, err := store.BeginRequest(ctx, "key", "hash", time.Second)
+		require.NoError(t, err)
+		require.True(t, claimed)
+	})
+
+	t.Run("check key is not reused with another body", func(t *testing.T) {
+		_, claimed, err := store.BeginRequest(ctx, "key", "other hash", time.Second)
+		require.ErrorIs(t, err, types.ErrIdempotencyKeyReused)
+		require.False(t, claimed)
+
+		err = store.CompleteRequest(ctx, "key", response, time.Hour)
+		require.NoError(t, err)
+		_, _, err = store.BeginRequest(ctx, "key", "other hash", time.Second)
+		require.ErrorIs(t, err, types.ErrIdempotencyKeyReused)
+	})
+}
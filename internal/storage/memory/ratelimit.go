@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/ratelimit"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+// sweepInterval is how often full buckets and expired idempotency keys are forgotten.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	fullAt    time.Time
+}
+
+// RateLimiter is an in-memory RateLimiter implementation.
+// Buckets are kept per process, so every replica limits clients on its own.
+type RateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (rl *RateLimiter) TakeToken(_ context.Context, key string, limit types.RateLimit) (time.Duration, error) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	if now.Sub(rl.lastSweep) > sweepInterval {
+		// Full buckets are the same as new ones
+		for k, b := range rl.buckets {
+			if now.After(b.fullAt) {
+				delete(rl.buckets, k)
+			}
+		}
+		rl.lastSweep = now
+	}
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updatedAt: now}
+		rl.buckets[key] = b
+	}
+
+	var retryAfter time.Duration
+	b.tokens, retryAfter = ratelimit.Take(b.tokens, now.Sub(b.updatedAt), limit)
+	b.updatedAt = now
+	b.fullAt = now.Add(ratelimit.FullAfter(limit))
+
+	return retryAfter, nil
+}
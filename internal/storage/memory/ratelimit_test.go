@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	limiter := NewRateLimiter()
+	limiter.now = func() time.Time { return now }
+
+	limit := types.RateLimit{Rate: 1, Burst: 2}
+
+	t.Run("check burst is allowed and then limited", func(t *testing.T) {
+		for i := 0; i < limit.Burst; i++ {
+			retryAfter, err := limiter.TakeToken(ctx, "client", limit)
+			require.NoError(t, err)
+			require.Zero(t, retryAfter)
+		}
+
+		retryAfter, err := limiter.TakeToken(ctx, "client", limit)
+		require.NoError(t, err)
+		require.Equal(t, time.Second, retryAfter)
+	})
+
+	t.Run("check clients have separate buckets", func(t *testing.T) {
+		retryAfter, err := limiter.TakeToken(ctx, "other client", limit)
+		require.NoError(t, err)
+		require.Zero(t, retryAfter)
+	})
+
+	t.Run("check token is available after retry after", func(t *testing.T) {
+		now = now.Add(time.Second)
+		retryAfter, err := limiter.TakeToken(ctx, "client", limit)
+		require.NoError(t, err)
+		require.Zero(t, retryAfter)
+	})
+
+	t.Run("check full buckets are forgotten", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		_, err := limiter.TakeToken(ctx, "client", limit)
+		require.NoError(t, err)
+		require.Len(t, limiter.buckets, 1)
+	})
+}
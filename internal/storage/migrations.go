@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"io/fs"
+	"log"
+	"sync"
+
+	"github.com/FedoseevAlex/banner-rotation/migrations"
+	"github.com/pressly/goose/v3"
+)
+
+// migrationsLockID is key of advisory lock held while migrations are applied
+// so replicas starting at the same time do not apply them concurrently.
+const migrationsLockID = 7315920441
+
+// Goose keeps its settings in package variables.
+var gooseMu sync.Mutex
+
+// migrate runs goose command with embedded migrations under advisory lock.
+// Goose progress is written to w.
+func (s *Storage) migrate(ctx context.Context, w io.Writer, run func() error) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationsLockID)
+	if err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationsLockID) //nolint:errcheck
+
+	gooseMu.Lock()
+	defer gooseMu.Unlock()
+
+	goose.SetBaseFS(migrations.FS)
+	goose.SetLogger(log.New(w, "", 0))
+	err = goose.SetDialect("postgres")
+	if err != nil {
+		return err
+	}
+
+	return run()
+}
+
+// MigrateUp applies all pending migrations.
+func (s *Storage) MigrateUp(ctx context.Context, w io.Writer) error {
+	return s.migrate(ctx, w, func() error {
+		return goose.Up(s.db.DB, ".")
+	})
+}
+
+// MigrateDown rolls back the latest applied migration.
+func (s *Storage) MigrateDown(ctx context.Context, w io.Writer) error {
+	return s.migrate(ctx, w, func() error {
+		return goose.Down(s.db.DB, ".")
+	})
+}
+
+// MigrationStatus writes applied and pending migrations to w.
+func (s *Storage) MigrationStatus(ctx context.Context, w io.Writer) error {
+	return s.migrate(ctx, w, func() error {
+		return goose.Status(s.db.DB, ".")
+	})
+}
+
+// PendingMigrations returns amount of embedded migrations
+// which are not applied to the database.
+func (s *Storage) PendingMigrations(ctx context.Context) (int, error) {
+	files, err := fs.Glob(migrations.FS, "*.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	var table sql.NullString
+	err = s.db.QueryRowxContext(ctx, `SELECT to_regclass($1)::TEXT`, goose.TableName()).Scan(&table)
+	if err != nil {
+		return 0, err
+	}
+	if !table.Valid {
+		// Nothing was ever migrated
+		return len(files), nil
+	}
+
+	// Goose appends row on every up and down, the latest row of version wins
+	query := `
+	SELECT DISTINCT ON (version_id) version_id, is_applied FROM ` + goose.TableName() + `
+	ORDER BY version_id, id DESC
+	`
+	rows, err := s.db.QueryxContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]bool)
+	for rows.Next() {
+		var (
+			version   int64
+			isApplied bool
+		)
+		err := rows.Scan(&version, &isApplied)
+		if err != nil {
+			return 0, err
+		}
+		applied[version] = isApplied
+	}
+	if rows.Err() != nil {
+		return 0, rows.Err()
+	}
+
+	pending := 0
+	for _, file := range files {
+		version, err := goose.NumericComponent(file)
+		if err != nil {
+			return 0, err
+		}
+		if !applied[version] {
+			pending++
+		}
+	}
+
+	return pending, nil
+}
@@ -2,8 +2,10 @@ package storage
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
 	"github.com/google/uuid"
 )
 
@@ -12,6 +14,78 @@ type banner struct {
 	Description string       `db:"description"`
 	Deleted     bool         `db:"deleted"`
 	DeletedAt   sql.NullTime `db:"deleted_at"`
+	CampaignID  uuid.UUID    `db:"campaign_id"`
+}
+
+func (b banner) toBanner() types.Banner {
+	return types.Banner{
+		ID:          b.ID,
+		Description: b.Description,
+		CampaignID:  b.CampaignID,
+	}
+}
+
+type advertiser struct {
+	ID          uuid.UUID    `db:"id"`
+	Description string       `db:"description"`
+	Deleted     bool         `db:"deleted"`
+	DeletedAt   sql.NullTime `db:"deleted_at"`
+}
+
+type campaign struct {
+	ID                uuid.UUID    `db:"id"`
+	AdvertiserID      uuid.UUID    `db:"advertiser_id"`
+	Description       string       `db:"description"`
+	TotalShowsBudget  int          `db:"total_shows_budget"`
+	TotalClicksBudget int          `db:"total_clicks_budget"`
+	DailyShowsBudget  int          `db:"daily_shows_budget"`
+	DailyClicksBudget int          `db:"daily_clicks_budget"`
+	Deleted           bool         `db:"deleted"`
+	DeletedAt         sql.NullTime `db:"deleted_at"`
+}
+
+func (c campaign) toCampaign() types.Campaign {
+	return types.Campaign{
+		ID:           c.ID,
+		AdvertiserID: c.AdvertiserID,
+		Description:  c.Description,
+		Budget: types.CampaignBudget{
+			TotalShows:  c.TotalShowsBudget,
+			TotalClicks: c.TotalClicksBudget,
+			DailyShows:  c.DailyShowsBudget,
+			DailyClicks: c.DailyClicksBudget,
+		},
+	}
+}
+
+type campaignConsumption struct {
+	CampaignID        uuid.UUID `db:"campaign_id"`
+	TotalShowsBudget  int       `db:"total_shows_budget"`
+	TotalClicksBudget int       `db:"total_clicks_budget"`
+	DailyShowsBudget  int       `db:"daily_shows_budget"`
+	DailyClicksBudget int       `db:"daily_clicks_budget"`
+	TotalShows        int       `db:"total_shows"`
+	TotalClicks       int       `db:"total_clicks"`
+	DailyShows        int       `db:"daily_shows"`
+	DailyClicks       int       `db:"daily_clicks"`
+	DayElapsed        float64   `db:"day_elapsed"`
+}
+
+func (c campaignConsumption) toCampaignConsumption() types.CampaignConsumption {
+	return types.CampaignConsumption{
+		CampaignID: c.CampaignID,
+		Budget: types.CampaignBudget{
+			TotalShows:  c.TotalShowsBudget,
+			TotalClicks: c.TotalClicksBudget,
+			DailyShows:  c.DailyShowsBudget,
+			DailyClicks: c.DailyClicksBudget,
+		},
+		TotalShows:  c.TotalShows,
+		TotalClicks: c.TotalClicks,
+		DailyShows:  c.DailyShows,
+		DailyClicks: c.DailyClicks,
+		DayElapsed:  c.DayElapsed,
+	}
 }
 
 type slot struct {
@@ -19,6 +93,22 @@ type slot struct {
 	Description string       `db:"description"`
 	Deleted     bool         `db:"deleted"`
 	DeletedAt   sql.NullTime `db:"deleted_at"`
+
+	Sticky           bool    `db:"sticky"`
+	StickyTTLSeconds int64   `db:"sticky_ttl_seconds"`
+	HoldoutPercent   float64 `db:"holdout_percent"`
+}
+
+func (s slot) toSlot() types.Slot {
+	return types.Slot{
+		ID:          s.ID,
+		Description: s.Description,
+		Settings: types.SlotSettings{
+			Sticky:         s.Sticky,
+			StickyTTL:      time.Duration(s.StickyTTLSeconds) * time.Second,
+			HoldoutPercent: s.HoldoutPercent,
+		},
+	}
 }
 
 type group struct {
@@ -28,6 +118,13 @@ type group struct {
 	DeletedAt   sql.NullTime `db:"deleted_at"`
 }
 
+func (g group) toGroup() types.Group {
+	return types.Group{
+		ID:          g.ID,
+		Description: g.Description,
+	}
+}
+
 type rotation struct {
 	ID        int          `db:"id"`
 	BannerID  uuid.UUID    `db:"banner_id"`
@@ -37,11 +134,117 @@ type rotation struct {
 	Clicks    int          `db:"clicks"`
 	Deleted   bool         `db:"deleted"`
 	DeletedAt sql.NullTime `db:"deleted_at"`
+
+	CapShows         int   `db:"cap_shows"`
+	CapWindowSeconds int64 `db:"cap_window_seconds"`
+
+	Conversions int     `db:"conversions"`
+	Revenue     float64 `db:"revenue"`
+
+	State           string  `db:"state"`
+	Pinned          bool    `db:"pinned"`
+	MinSharePercent float64 `db:"min_share_percent"`
+
+	// Campaign of rotation banner is selected along with rotation when needed
+	CampaignID uuid.UUID `db:"campaign_id"`
+}
+
+func (r rotation) toRotation() types.Rotation {
+	return types.Rotation{
+		BannerID: r.BannerID,
+		SlotID:   r.SlotID,
+		GroupID:  r.GroupID,
+		Shows:    r.Shows,
+		Clicks:   r.Clicks,
+		Cap: types.FrequencyCap{
+			MaxShows: r.CapShows,
+			Window:   time.Duration(r.CapWindowSeconds) * time.Second,
+		},
+		Conversions: r.Conversions,
+		Revenue:     r.Revenue,
+		State:       r.State,
+		Override: types.RotationOverride{
+			Pinned:          r.Pinned,
+			MinSharePercent: r.MinSharePercent,
+		},
+		CampaignID: r.CampaignID,
+	}
 }
 
 type event struct {
-	ID         int       `db:"id"`
-	RotationID int       `db:"rotation_id"`
-	Timestamp  time.Time `db:"stamp"`
-	Type       string    `db:"event_type"`
+	ID           int       `db:"id"`
+	RotationID   int       `db:"rotation_id"`
+	Timestamp    time.Time `db:"stamp"`
+	Type         string    `db:"event_type"`
+	ImpressionID uuid.UUID `db:"impression_id"`
+	VisitorID    string    `db:"visitor_id"`
+	Value        float64   `db:"value"`
+	Propensity   float64   `db:"propensity"`
+	Arm          string    `db:"arm"`
+	Details      string    `db:"details"`
+}
+
+func (e event) toEvent() types.Event {
+	converted := types.Event{
+		Type:         e.Type,
+		Timestamp:    e.Timestamp,
+		ImpressionID: e.ImpressionID,
+		Value:        e.Value,
+		Details:      e.Details,
+	}
+	if e.Type == types.EventTypeShow {
+		converted.Propensity = e.Propensity
+		converted.Arm = e.Arm
+	}
+	return converted
+}
+
+type rotationEvent struct {
+	BannerID uuid.UUID `db:"banner_id"`
+	SlotID   uuid.UUID `db:"slot_id"`
+	GroupID  uuid.UUID `db:"group_id"`
+	event
+}
+
+func (e rotationEvent) toRotationEvent() types.RotationEvent {
+	return types.RotationEvent{
+		BannerID: e.BannerID,
+		SlotID:   e.SlotID,
+		GroupID:  e.GroupID,
+		Event:    e.event.toEvent(),
+	}
+}
+
+type groupRule struct {
+	ID         int          `db:"id"`
+	GroupID    uuid.UUID    `db:"group_id"`
+	Priority   int          `db:"priority"`
+	MinAge     int          `db:"min_age"`
+	MaxAge     int          `db:"max_age"`
+	Gender     string       `db:"gender"`
+	Locale     string       `db:"locale"`
+	Device     string       `db:"device"`
+	Attributes string       `db:"attributes"`
+	Deleted    bool         `db:"deleted"`
+	DeletedAt  sql.NullTime `db:"deleted_at"`
+}
+
+func (r groupRule) toGroupRule() (types.GroupRule, error) {
+	attributes := make(map[string]string)
+	err := json.Unmarshal([]byte(r.Attributes), &attributes)
+	if err != nil {
+		return types.GroupRule{}, err
+	}
+
+	return types.GroupRule{
+		ID:         r.ID,
+		GroupID:    r.GroupID,
+		Priority:   r.Priority,
+		MinAge:     r.MinAge,
+		MaxAge:     r.MaxAge,
+		Gender:     r.Gender,
+		Locale:     r.Locale,
+		Device:     r.Device,
+		Attributes: attributes,
+	}, nil
 }
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+// SetRotationState changes state of rotation and records the change in rotation events.
+func (s *Storage) SetRotationState(ctx context.Context, bannerID, slotID, groupID uuid.UUID, state string) error {
+	query := `
+	UPDATE rotations SET state=$1 WHERE id=$2
+	`
+
+	return s.updateRotation(ctx, bannerID, slotID, groupID, event{Type: EventTypeState, Details: state}, query, state)
+}
+
+// SetRotationOverride replaces override of rotation and records the change in rotation events.
+func (s *Storage) SetRotationOverride(
+	ctx context.Context,
+	bannerID, slotID, groupID uuid.UUID,
+	override types.RotationOverride,
+) error {
+	query := `
+	UPDATE rotations SET pinned=$1, min_share_percent=$2 WHERE id=$3
+	`
+
+	details, err := json.Marshal(override)
+	if err != nil {
+		return err
+	}
+
+	return s.updateRotation(
+		ctx,
+		bannerID,
+		slotID,
+		groupID,
+		event{Type: EventTypeOverride, Details: string(details)},
+		query,
+		override.Pinned,
+		override.MinSharePercent,
+	)
+}
+
+// updateRotation runs update query of rotation along with adding event to it.
+// Rotation id is passed to query as the last argument.
+func (s *Storage) updateRotation(
+	ctx context.Context,
+	bannerID, slotID, groupID uuid.UUID,
+	e event,
+	query string,
+	args ...interface{},
+) error {
+	rotationID, err := s.GetRotationID(ctx, bannerID, slotID, groupID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = execTxQuery(tx, query, append(args, rotationID)...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	e.RotationID = rotationID
+	err = addEvent(tx, e)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/ratelimit"
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+// sweepInterval is how often expired rate limit buckets and idempotency keys are deleted.
+const sweepInterval = time.Minute
+
+// RateLimiter implementation. Bucket row is locked while token is taken,
+// so replicas sharing database share limits.
+func (s *Storage) TakeToken(ctx context.Context, key string, limit types.RateLimit) (time.Duration, error) {
+	// No-op update locks existing bucket, so that it is not swept by other replica
+	// in between, and returns it just like a new one
+	upsertBucketQuery := `
+	INSERT INTO rate_limit_buckets (key, tokens, updated_at, full_at)
+	VALUES ($1, $2, now(), now())
+	ON CONFLICT (key) DO UPDATE SET key=EXCLUDED.key
+	RETURNING tokens, EXTRACT(EPOCH FROM now() - updated_at)::double precision
+	`
+	updateBucketQuery := `
+	UPDATE rate_limit_buckets
+	SET tokens=$2, updated_at=now(), full_at=now() + $3::double precision * interval '1 second'
+	WHERE key=$1
+	`
+
+	// Full buckets are the same as new ones
+	err := s.sweep(ctx, `DELETE FROM rate_limit_buckets WHERE full_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var tokens, elapsed float64
+	err = tx.QueryRowContext(ctx, upsertBucketQuery, key, float64(limit.Burst)).Scan(&tokens, &elapsed)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	tokens, retryAfter := ratelimit.Take(tokens, time.Duration(elapsed*float64(time.Second)), limit)
+
+	err = execTxQuery(tx, updateBucketQuery, key, tokens, ratelimit.FullAfter(limit).Seconds())
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return retryAfter, nil
+}
+
+// sweep deletes expired rows with query at most once per sweepInterval.
+func (s *Storage) sweep(ctx context.Context, query string) error {
+	s.sweepMu.Lock()
+	if time.Since(s.lastSweep[query]) < sweepInterval {
+		s.sweepMu.Unlock()
+		return nil
+	}
+	if s.lastSweep == nil {
+		s.lastSweep = make(map[string]time.Time)
+	}
+	s.lastSweep[query] = time.Now()
+	s.sweepMu.Unlock()
+
+	_, err := s.db.ExecContext(ctx, query)
+	return err
+}
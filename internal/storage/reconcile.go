@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+type counterDrift struct {
+	BannerID         uuid.UUID `db:"banner_id"`
+	SlotID           uuid.UUID `db:"slot_id"`
+	GroupID          uuid.UUID `db:"group_id"`
+	Shows            int       `db:"shows"`
+	EventShows       int       `db:"event_shows"`
+	Clicks           int       `db:"clicks"`
+	EventClicks      int       `db:"event_clicks"`
+	Conversions      int       `db:"conversions"`
+	EventConversions int       `db:"event_conversions"`
+	Revenue          float64   `db:"revenue"`
+	EventRevenue     float64   `db:"event_revenue"`
+}
+
+// ReconcileCounters finds rotations whose shows, clicks, conversions
+// or revenue differ from their logged events along with statistics imported
+// with catalogue. With apply counters are set to these amounts and rotations
+// are locked against updates meanwhile so shows and clicks registered
+// concurrently are not lost.
+func (s *Storage) ReconcileCounters(ctx context.Context, apply bool) ([]types.CounterDrift, error) {
+	lockQuery := `LOCK TABLE rotations IN EXCLUSIVE MODE`
+	driftQuery := `
+	SELECT * FROM (
+		SELECT
+			r.banner_id, r.slot_id, r.group_id,
+			r.shows, r.clicks, r.conversions, r.revenue,
+			coalesce(b.shows, 0) + count(e.id) FILTER (WHERE e.event_type=$1) AS event_shows,
+			coalesce(b.clicks, 0) + count(e.id) FILTER (WHERE e.event_type=$2) AS event_clicks,
+			coalesce(b.conversions, 0) + count(e.id) FILTER (WHERE e.event_type=$3) AS event_conversions,
+			coalesce(b.revenue, 0) + coalesce(sum(e.value) FILTER (WHERE e.event_type=$3), 0) AS event_revenue
+		FROM rotations r
+		LEFT JOIN rotation_baselines b ON b.rotation_id=r.id
+		LEFT JOIN events e ON e.rotation_id=r.id
+		WHERE r.deleted=FALSE
+		GROUP BY r.id, b.rotation_id
+	) counters
+	WHERE
+	shows<>event_shows OR clicks<>event_clicks OR conversions<>event_conversions
+	OR abs(revenue-event_revenue) > 1e-9
+	ORDER BY banner_id, slot_id, group_id
+	`
+	updateQuery := `
+	UPDATE rotations SET shows=$1, clicks=$2, conversions=$3, revenue=$4
+	WHERE banner_id=$5 AND slot_id=$6 AND group_id=$7 AND deleted=FALSE
+	`
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Report alone is made of a single query and needs no lock
+	if apply {
+		_, err = tx.ExecContext(ctx, lockQuery)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	var drifts []counterDrift
+	err = tx.SelectContext(ctx, &drifts, driftQuery, EventTypeShow, EventTypeClick, EventTypeConversion)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	result := make([]types.CounterDrift, 0, len(drifts))
+	for _, d := range drifts {
+		result = append(result, types.CounterDrift(d))
+
+		if !apply {
+			continue
+		}
+		err = execTxQuery(
+			tx.Tx,
+			updateQuery,
+			d.EventShows,
+			d.EventClicks,
+			d.EventConversions,
+			d.EventRevenue,
+			d.BannerID,
+			d.SlotID,
+			d.GroupID,
+		)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	return result, tx.Commit()
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/google/uuid"
+)
+
+// SinceLastEvent looks event up by click impression if it is known
+// and by click visitor otherwise. Anonymous clicks without impression have no events.
+func (s *Storage) SinceLastEvent(ctx context.Context, click types.Click, eventType string) (time.Duration, error) {
+	byImpressionQuery := `
+	SELECT EXTRACT(EPOCH FROM now() - e.stamp)::double precision FROM events e
+	JOIN rotations r ON r.id=e.rotation_id
+	WHERE
+	r.banner_id=$1 AND r.slot_id=$2 AND r.group_id=$3
+	AND e.event_type=$4 AND e.impression_id=$5
+	ORDER BY e.stamp DESC
+	LIMIT 1
+	`
+	byVisitorQuery := `
+	SELECT EXTRACT(EPOCH FROM now() - e.stamp)::double precision FROM events e
+	JOIN rotations r ON r.id=e.rotation_id
+	WHERE
+	r.banner_id=$1 AND r.slot_id=$2 AND r.group_id=$3
+	AND e.event_type=$4 AND e.visitor_id=$5
+	ORDER BY e.stamp DESC
+	LIMIT 1
+	`
+
+	var row *sql.Row
+	switch {
+	case click.ImpressionID != uuid.Nil:
+		row = s.db.QueryRowContext(
+			ctx, byImpressionQuery, click.BannerID, click.SlotID, click.GroupID, eventType, click.ImpressionID,
+		)
+	case click.Visitor.ID != "":
+		row = s.db.QueryRowContext(
+			ctx, byVisitorQuery, click.BannerID, click.SlotID, click.GroupID, eventType, click.Visitor.ID,
+		)
+	default:
+		return 0, sql.ErrNoRows
+	}
+
+	var seconds float64
+	err := row.Scan(&seconds)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func (s *Storage) AddRejectedClick(ctx context.Context, click types.Click, reason string) error {
+	query := `
+	INSERT INTO rejected_events
+	(event_type, banner_id, slot_id, group_id, impression_id, visitor_id, user_agent, ip, reason, stamp)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now())
+	`
+
+	_, err := s.db.ExecContext(
+		ctx,
+		query,
+		EventTypeClick,
+		click.BannerID,
+		click.SlotID,
+		click.GroupID,
+		nullUUID(click.ImpressionID),
+		click.Visitor.ID,
+		click.UserAgent,
+		click.IP,
+		reason,
+	)
+	return err
+}
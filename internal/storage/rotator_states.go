@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// GetRotatorState returns learned state of rotator for slot and group.
+// sql.ErrNoRows is returned if nothing was learned yet.
+func (s *Storage) GetRotatorState(ctx context.Context, slotID, groupID uuid.UUID) ([]byte, error) {
+	query := `
+	SELECT state FROM rotator_states WHERE slot_id=$1 AND group_id=$2
+	`
+
+	row := s.db.QueryRowxContext(ctx, query, slotID, groupID)
+	if row.Err() != nil {
+		return nil, row.Err()
+	}
+
+	var state []byte
+	err := row.Scan(&state)
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// UpdateRotatorState passes learned state of rotator for slot and group to update
+// and saves the state it returns. State row stays locked meanwhile, so concurrent
+// updates of the same slot and group are applied one after another.
+// Empty state is passed if nothing was learned yet.
+func (s *Storage) UpdateRotatorState(
+	ctx context.Context,
+	slotID, groupID uuid.UUID,
+	update func(state []byte) ([]byte, error),
+) error {
+	ensureQuery := `
+	INSERT INTO rotator_states (slot_id, group_id, state, updated_at)
+	VALUES ($1, $2, ''::bytea, now())
+	ON CONFLICT (slot_id, group_id) DO NOTHING
+	`
+	selectQuery := `
+	SELECT state FROM rotator_states WHERE slot_id=$1 AND group_id=$2 FOR UPDATE
+	`
+	saveQuery := `
+	UPDATE rotator_states SET state=$3, updated_at=now()
+	WHERE slot_id=$1 AND group_id=$2
+	`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, ensureQuery, slotID, groupID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var state []byte
+	err = tx.QueryRowContext(ctx, selectQuery, slotID, groupID).Scan(&state)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	state, err = update(state)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = execTxQuery(tx, saveQuery, slotID, groupID, state)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
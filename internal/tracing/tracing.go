@@ -0,0 +1,125 @@
+// Package tracing sets up OpenTelemetry tracing of the service.
+// Spans are started through the global tracer provider, so nothing
+// is recorded unless Setup or UseInMemory installed one.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const (
+	serviceName = "banner-rotation"
+	tracerName  = "github.com/FedoseevAlex/banner-rotation"
+)
+
+var ErrUnknownExporter = errors.New("unknown tracing exporter")
+
+func init() {
+	// Trace context is propagated even if spans are not recorded
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+}
+
+// Start starts span as a child of span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartKind starts span of given kind as a child of span in ctx.
+func StartKind(
+	ctx context.Context,
+	name string,
+	kind trace.SpanKind,
+	attrs ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// CheckExporter reports whether exporter is known.
+func CheckExporter(exporter string) error {
+	switch exporter {
+	case "", ExporterNone, ExporterStdout, ExporterOTLP:
+		return nil
+	}
+	return fmt.Errorf("%w %q", ErrUnknownExporter, exporter)
+}
+
+// Setup installs tracer provider which sends spans to exporter from config.
+// Returned function flushes spans left in buffer and must be called on exit.
+func Setup(ctx context.Context, cfg config.Tracing) (func(context.Context) error, error) {
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		options := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	default:
+		err = CheckExporter(cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(serviceResource()),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// UseInMemory installs tracer provider which keeps finished spans in memory.
+// It is meant for tests. Returned function restores previous provider.
+func UseInMemory() (*tracetest.InMemoryExporter, func()) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exporter),
+		sdktrace.WithResource(serviceResource()),
+	)
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+
+	return exporter, func() {
+		otel.SetTracerProvider(previous)
+		_ = provider.Shutdown(context.Background())
+	}
+}
+
+func serviceResource() *resource.Resource {
+	return resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))
+}
@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUseInMemory(t *testing.T) {
+	exporter, restore := UseInMemory()
+	defer restore()
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	child.End()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	require.Equal(t, "child", spans[0].Name)
+	require.Equal(t, "parent", spans[1].Name)
+	require.Equal(t, spans[1].SpanContext.SpanID(), spans[0].Parent.SpanID())
+}
+
+func TestSetup(t *testing.T) {
+	for _, exporter := range []string{"", ExporterNone, ExporterStdout, ExporterOTLP} {
+		require.NoError(t, CheckExporter(exporter))
+	}
+
+	t.Run("check unknown exporter", func(t *testing.T) {
+		_, err := Setup(context.Background(), config.Tracing{Exporter: "jaeger"})
+		require.ErrorIs(t, err, ErrUnknownExporter)
+	})
+
+	t.Run("check no exporter", func(t *testing.T) {
+		shutdown, err := Setup(context.Background(), config.Tracing{Exporter: ExporterNone})
+		require.NoError(t, err)
+		require.NoError(t, shutdown(context.Background()))
+	})
+}
@@ -2,6 +2,7 @@ package types
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,11 +11,92 @@ import (
 type Banner struct {
 	ID          uuid.UUID
 	Description string
+	// CampaignID is uuid.Nil for banners out of campaigns.
+	CampaignID uuid.UUID
+}
+
+// Roles of API keys
+const (
+	// Choose banners and register clicks and conversions
+	RoleServe = "serve"
+	// Read entities and statistics
+	RoleAnalyst = "analyst"
+	// Everything
+	RoleAdmin = "admin"
+)
+
+// APIKey identifies caller of API. The key itself is not kept, only its hash is stored.
+type APIKey struct {
+	ID        uuid.UUID
+	Name      string
+	Role      string
+	CreatedAt time.Time
+}
+
+// RateLimit is a token bucket refilled with Rate tokens per second up to Burst tokens.
+// Every request takes one token.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// IdempotentResponse is response to request with idempotency key
+// which is replayed to retries of the request.
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+type Advertiser struct {
+	ID          uuid.UUID
+	Description string
+}
+
+type Campaign struct {
+	ID           uuid.UUID
+	AdvertiserID uuid.UUID
+	Description  string
+	Budget       CampaignBudget
+}
+
+// CampaignBudget limits shows and clicks of campaign banners in total and per day.
+// Daily budgets are paced evenly across the day. Zero budget means no limit.
+type CampaignBudget struct {
+	TotalShows  int
+	TotalClicks int
+	DailyShows  int
+	DailyClicks int
+}
+
+// CampaignConsumption is how much of its budget campaign has consumed.
+// Daily consumption is counted since start of the current day
+// and DayElapsed is elapsed part of the day.
+type CampaignConsumption struct {
+	CampaignID  uuid.UUID
+	Budget      CampaignBudget
+	TotalShows  int
+	TotalClicks int
+	DailyShows  int
+	DailyClicks int
+	DayElapsed  float64
 }
 
 type Slot struct {
 	ID          uuid.UUID
 	Description string
+	Settings    SlotSettings
+}
+
+type SlotSettings struct {
+	// Sticky slots keep showing the same banner to a visitor
+	// until the banner is deleted or StickyTTL expires.
+	// Zero StickyTTL means assignment never expires.
+	Sticky    bool
+	StickyTTL time.Duration
+	// HoldoutPercent of shows is served uniformly at random
+	// to compare rotator against random choice.
+	HoldoutPercent float64
 }
 
 type Group struct {
@@ -22,45 +104,429 @@ type Group struct {
 	Description string
 }
 
+// GroupRule describes visitors who belong to the group.
+// Empty or zero conditions match any visitor.
+type GroupRule struct {
+	ID       int
+	GroupID  uuid.UUID
+	Priority int
+	// Age bounds are inclusive. Zero means there is no bound.
+	MinAge int
+	MaxAge int
+	Gender string
+	// Locale "en" matches both "en" and "en-US" visitors.
+	Locale     string
+	Device     string
+	Attributes map[string]string
+}
+
 type Rotation struct {
 	BannerID uuid.UUID
 	SlotID   uuid.UUID
 	GroupID  uuid.UUID
 	Shows    int
 	Clicks   int
+	Cap      FrequencyCap
+	// Conversions is amount of conversions attributed to rotation shows.
+	// Revenue is sum of their values.
+	Conversions int
+	Revenue     float64
+	// Paused rotations are not shown but keep their history.
+	State    string
+	Override RotationOverride
+	// CampaignID is campaign of the rotation banner.
+	CampaignID uuid.UUID `json:",omitempty"`
+}
+
+const (
+	RotationStateActive string = "active"
+	RotationStatePaused string = "paused"
+)
+
+// RotationOverride takes precedence over the rotator when banner is chosen.
+// Pinned rotation is always shown while it is active and not capped.
+// Rotation with MinSharePercent is shown whenever its share of slot
+// and group shows falls below the percent.
+type RotationOverride struct {
+	Pinned          bool
+	MinSharePercent float64
+}
+
+// Impression is a single show of rotation to visitor.
+// Clicks and conversions are attributed to the show by ImpressionID.
+type Impression struct {
+	ImpressionID uuid.UUID
+	Rotation
+	VisitorID string `json:",omitempty"`
+	// Propensity is probability with which the rotation was chosen.
+	Propensity float64
+	// Arm is ArmHoldout for shows served at random and ArmOptimised otherwise.
+	Arm string
+}
+
+// FrequencyCap limits how many times a single visitor
+// may see a rotation during the sliding Window.
+// Zero MaxShows means the rotation is not capped.
+type FrequencyCap struct {
+	MaxShows int
+	Window   time.Duration
+}
+
+// Visitor describes who the banner is chosen for.
+// Empty ID means the visitor is anonymous.
+type Visitor struct {
+	ID         string
+	Attributes map[string]string
+}
+
+// Well known visitor attributes.
+const (
+	AttributeAge    = "age"
+	AttributeGender = "gender"
+	AttributeLocale = "locale"
+	AttributeDevice = "device"
+)
+
+// Click describes visitor's click on banner shown by rotation.
+type Click struct {
+	// ImpressionID is optional as clicks may come without knowing the show
+	ImpressionID uuid.UUID
+	BannerID     uuid.UUID
+	SlotID       uuid.UUID
+	GroupID      uuid.UUID
+	Visitor      Visitor
+	// UserAgent and IP of client are used to filter out bots
+	UserAgent string
+	IP        string
+}
+
+// ClickValidation lists checks click must pass to be registered.
+// Zero value registers every click.
+type ClickValidation struct {
+	// RequireShow rejects clicks without show of the rotation with the same
+	// impression or, if impression is unknown, to the same visitor.
+	RequireShow bool
+	// MinDelay rejects clicks made faster than humans do after the show.
+	MinDelay time.Duration
+	// DuplicateWindow rejects repeated clicks on the same impression or
+	// of the same visitor, if it is known, on rotation within the window.
+	DuplicateWindow time.Duration
+	// UserAgentDenylist rejects clicks with user agent containing any of
+	// the substrings, case is ignored.
+	UserAgentDenylist []string
+}
+
+// Reasons of click rejection.
+const (
+	RejectReasonBot       string = "bot_user_agent"
+	RejectReasonDuplicate string = "duplicate"
+	RejectReasonNoShow    string = "no_show"
+	RejectReasonTooFast   string = "too_fast"
+)
+
+// Conversion is a target action (e.g. sign-up) made by visitor after the impression.
+// Value is optional monetary value of the conversion.
+type Conversion struct {
+	ImpressionID uuid.UUID
+	Value        float64
 }
 
+const (
+	EventTypeClick      string = "click"
+	EventTypeShow       string = "show"
+	EventTypeConversion string = "conversion"
+	// State and override events record changes of rotation settings
+	EventTypeState    string = "state"
+	EventTypeOverride string = "override"
+)
+
 type Event struct {
-	Type      string
-	Timestamp time.Time
+	Type         string
+	Timestamp    time.Time
+	ImpressionID uuid.UUID `json:",omitempty"`
+	Value        float64   `json:",omitempty"`
+	Propensity   float64   `json:",omitempty"`
+	Arm          string    `json:",omitempty"`
+	Details      string    `json:",omitempty"`
+}
+
+// RotationEvent is an event along with rotation it happened to.
+type RotationEvent struct {
+	BannerID uuid.UUID
+	SlotID   uuid.UUID
+	GroupID  uuid.UUID
+	Event
+}
+
+// Arms of holdout experiment.
+const (
+	ArmHoldout   string = "holdout"
+	ArmOptimised string = "optimised"
+	// Shows forced by rotation overrides are out of the experiment
+	ArmOverride string = "override"
+)
+
+// ArmStats is amount of shows in holdout experiment arm and clicks on them.
+type ArmStats struct {
+	Arm    string
+	Shows  int
+	Clicks int
+	CTR    float64
+}
+
+// HoldoutReport compares CTR of shows chosen by rotator against random shows.
+type HoldoutReport struct {
+	SlotID    uuid.UUID
+	From      time.Time
+	To        time.Time
+	Holdout   ArmStats
+	Optimised ArmStats
+	// Uplift is absolute difference of optimised and holdout CTR,
+	// RelativeUplift is the difference relative to holdout CTR.
+	Uplift         float64
+	RelativeUplift float64
+	// Two-proportion z-test of CTR difference.
+	ZScore      float64
+	PValue      float64
+	Significant bool
+}
+
+// PolicyEstimate is an off-policy estimate of CTR which rotator
+// would have had on logged shows.
+type PolicyEstimate struct {
+	Rotator string
+	// Shows is amount of logged shows, Matches is amount of them
+	// where rotator would have chosen the logged banner.
+	Shows     int
+	Matches   int
+	LoggedCTR float64
+	// Inverse propensity score and doubly robust estimates
+	// along with their standard errors.
+	IPS       float64
+	IPSStdErr float64
+	DR        float64
+	DRStdErr  float64
+}
+
+// Catalogue is a snapshot of banners, slots, groups with their rules
+// and rotations along with rotation counters. It is used to move
+// configuration between installations.
+type Catalogue struct {
+	Banners   []Banner
+	Slots     []Slot
+	Groups    []Group
+	Rules     []GroupRule
+	Rotations []Rotation
+}
+
+// CatalogueConflict is a catalogue entry which can not be imported.
+type CatalogueConflict struct {
+	// Kind is one of "banner", "slot", "group", "rule" or "rotation".
+	Kind   string
+	ID     string
+	Reason string
+}
+
+// ImportReport tells how many entries were (or would be in dry run) imported.
+type ImportReport struct {
+	DryRun    bool
+	Banners   int
+	Slots     int
+	Groups    int
+	Rules     int
+	Rotations int
+	Conflicts []CatalogueConflict `json:",omitempty"`
+}
+
+// CounterDrift is rotation whose counters differ from amounts of its logged events.
+// Event amounts include statistics imported with catalogue as they have no events.
+type CounterDrift struct {
+	BannerID         uuid.UUID
+	SlotID           uuid.UUID
+	GroupID          uuid.UUID
+	Shows            int
+	EventShows       int
+	Clicks           int
+	EventClicks      int
+	Conversions      int
+	EventConversions int
+	Revenue          float64
+	EventRevenue     float64
+}
+
+const (
+	CheckStatusOK     = "ok"
+	CheckStatusFailed = "failed"
+)
+
+// HealthCheck is result of a single readiness check.
+type HealthCheck struct {
+	Name   string
+	Status string
+	Error  string `json:",omitempty"`
+	// Duration of the check in milliseconds
+	DurationMs int64
+}
+
+// Readiness tells whether service can serve banners. It is ready if all checks pass.
+type Readiness struct {
+	Ready  bool
+	Checks []HealthCheck
 }
 
+var (
+	ErrNoRotations    = errors.New("no rotations available")
+	ErrNoGroupMatched = errors.New("no group matches visitor")
+	ErrNoImpression   = errors.New("impression not found")
+	// Only the first conversion of a show is counted
+	ErrDuplicateConversion = errors.New("conversion already registered")
+	// Repeated click of impression or visitor within duplicate window is not counted
+	ErrDuplicateClick = errors.New("click already registered")
+	// Idempotency key may be retried only with the same request body
+	ErrIdempotencyKeyReused = errors.New("idempotency key is reused with another request body")
+	// Entity to update or delete does not exist
+	ErrNotFound = errors.New("not found")
+	// Nothing is imported if catalogue conflicts with existing data
+	ErrCatalogueConflicts = errors.New("catalogue conflicts with existing data")
+	ErrUnknownRole        = errors.New("unknown role")
+	// API key is unknown or revoked
+	ErrInvalidAPIKey = errors.New("invalid API key")
+)
+
 type Storager interface {
 	Connect() error
 	Close() error
+	// Check that database is reachable
+	Ping(ctx context.Context) error
+	// Amount of schema migrations which are not applied yet
+	PendingMigrations(ctx context.Context) (int, error)
 	// Banner operations
 	AddBanner(ctx context.Context, banner Banner) error
 	GetBanner(ctx context.Context, bannerID uuid.UUID) (Banner, error)
 	DeleteBanner(ctx context.Context, bannerID uuid.UUID) error
+	SetBannerCampaign(ctx context.Context, bannerID, campaignID uuid.UUID) error
+	// Get banners which are not deleted
+	GetAllBanners(ctx context.Context) ([]Banner, error)
+
+	AddAdvertiser(ctx context.Context, advertiser Advertiser) error
+	GetAdvertiser(ctx context.Context, advertiserID uuid.UUID) (Advertiser, error)
+	DeleteAdvertiser(ctx context.Context, advertiserID uuid.UUID) error
+
+	AddCampaign(ctx context.Context, campaign Campaign) error
+	GetCampaign(ctx context.Context, campaignID uuid.UUID) (Campaign, error)
+	DeleteCampaign(ctx context.Context, campaignID uuid.UUID) error
+	SetCampaignBudget(ctx context.Context, campaignID uuid.UUID, budget CampaignBudget) error
+	GetCampaignConsumption(ctx context.Context, campaignID uuid.UUID) (CampaignConsumption, error)
+	// Get consumption of campaigns which are not deleted among given ones
+	GetCampaignsConsumption(ctx context.Context, campaignIDs []uuid.UUID) ([]CampaignConsumption, error)
 	// Slot operations
 	AddSlot(ctx context.Context, slot Slot) error
 	GetSlot(ctx context.Context, slotID uuid.UUID) (Slot, error)
 	DeleteSlot(ctx context.Context, slotID uuid.UUID) error
+	SetSlotSettings(ctx context.Context, slotID uuid.UUID, settings SlotSettings) error
+	GetAllSlots(ctx context.Context) ([]Slot, error)
+	// Sticky assignments of banners to visitors
+	GetAssignment(ctx context.Context, visitorID string, slotID, groupID uuid.UUID) (bannerID uuid.UUID, err error)
+	SaveAssignment(ctx context.Context, visitorID string, bannerID, slotID, groupID uuid.UUID, ttl time.Duration) error
 	// Group operations
 	AddGroup(ctx context.Context, group Group) error
 	GetGroup(ctx context.Context, groupID uuid.UUID) (Group, error)
 	DeleteGroup(ctx context.Context, groupID uuid.UUID) error
+	GetAllGroups(ctx context.Context) ([]Group, error)
+	AddGroupRule(ctx context.Context, rule GroupRule) (GroupRule, error)
+	GetGroupRules(ctx context.Context, groupID uuid.UUID) ([]GroupRule, error)
+	// Get rules of all groups which are not deleted
+	GetAllGroupRules(ctx context.Context) ([]GroupRule, error)
+	// Delete rule of the group, sql.ErrNoRows is returned if group has no such rule
+	DeleteGroupRule(ctx context.Context, groupID uuid.UUID, ruleID int) error
 	// Rotation operations
 	AddRotation(ctx context.Context, bannerID, slotID, groupID uuid.UUID) (Rotation, error)
 	DeleteRotation(ctx context.Context, bannerID, slotID, groupID uuid.UUID) error
 	GetRotation(ctx context.Context, bannerID, slotID, groupID uuid.UUID) (Rotation, error)
+	SetFrequencyCap(ctx context.Context, bannerID, slotID, groupID uuid.UUID, frequencyCap FrequencyCap) error
 
-	AddShow(ctx context.Context, bannerID, slotID, groupID uuid.UUID) error
-	AddClick(ctx context.Context, bannerID, slotID, groupID uuid.UUID) error
+	AddShow(ctx context.Context, impression Impression) error
+	// Register shows of several rotations at once, either all or none of them
+	AddShows(ctx context.Context, impressions []Impression) error
+	// Register click unless the same impression or visitor clicked the rotation
+	// during duplicateWindow, ErrDuplicateClick is returned then. Zero window disables the check.
+	AddClick(ctx context.Context, click Click, duplicateWindow time.Duration) error
+	AddConversion(ctx context.Context, conversion Conversion) error
+	// Time passed since the last event of type which click could follow: show with
+	// click impression or, without impression, event of the click visitor on the rotation.
+	// sql.ErrNoRows is returned if there was no such event.
+	SinceLastEvent(ctx context.Context, click Click, eventType string) (time.Duration, error)
+	// Keep click which failed validation along with the reason
+	AddRejectedClick(ctx context.Context, click Click, reason string) error
 	GetAllRotations(ctx context.Context) ([]Rotation, error)
 	GetRotationStats(ctx context.Context, bannerID, slotID, groupID uuid.UUID) ([]Event, error)
+	// Get events of all slot rotations for group happened in [from, to) ordered by time
+	GetEvents(ctx context.Context, slotID, groupID uuid.UUID, from, to time.Time) ([]RotationEvent, error)
+	SetRotationState(ctx context.Context, bannerID, slotID, groupID uuid.UUID, state string) error
+	SetRotationOverride(ctx context.Context, bannerID, slotID, groupID uuid.UUID, override RotationOverride) error
+	// Shows of slot in time range and clicks on them per holdout experiment arm
+	GetArmStats(ctx context.Context, slotID uuid.UUID, from, to time.Time) ([]ArmStats, error)
+	// Import catalogue in a single transaction which is rolled back
+	// on conflicts or in dry run
+	ImportCatalogue(ctx context.Context, catalogue Catalogue, dryRun bool) (ImportReport, error)
 	// Get total amount of shows
 	GetTotalShows(ctx context.Context) (totalShows int64, err error)
+	// Learned state of contextual rotator for slot and group
+	GetRotatorState(ctx context.Context, slotID, groupID uuid.UUID) ([]byte, error)
+	// Replace state with the one returned by update while no one else can change it
+	UpdateRotatorState(
+		ctx context.Context,
+		slotID, groupID uuid.UUID,
+		update func(state []byte) ([]byte, error),
+	) error
+	// API keys are looked up by hash of the key
+	AddAPIKey(ctx context.Context, key APIKey, keyHash string) error
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (APIKey, error)
+	// Get API keys which are not revoked
+	GetAllAPIKeys(ctx context.Context) ([]APIKey, error)
+	DeleteAPIKey(ctx context.Context, keyID uuid.UUID) error
+}
+
+// ExposureStorer keeps track of how many times visitors have seen rotations.
+type ExposureStorer interface {
+	// AddExposure remembers a show of rotation to visitor unless visitor has already
+	// seen it cap.MaxShows times during cap.Window, in which case added is false.
+	// Check and record are atomic. The record may be forgotten once window has passed.
+	AddExposure(
+		ctx context.Context,
+		visitorID string,
+		bannerID, slotID, groupID uuid.UUID,
+		cap FrequencyCap,
+	) (added bool, err error)
+	// RemoveExposure forgets the latest show of rotation to visitor, so that exposure
+	// of show which failed to be registered does not count against the cap.
+	RemoveExposure(ctx context.Context, visitorID string, bannerID, slotID, groupID uuid.UUID) error
+	// CountExposures returns amount of shows of rotation to visitor during the last window.
+	CountExposures(ctx context.Context, visitorID string, bannerID, slotID, groupID uuid.UUID, window time.Duration) (int, error)
+}
+
+// RateLimiter keeps token buckets of clients.
+type RateLimiter interface {
+	// TakeToken takes token from bucket identified by key. If bucket is empty
+	// no token is taken and time until the next token is returned.
+	TakeToken(ctx context.Context, key string, limit RateLimit) (retryAfter time.Duration, err error)
+}
+
+// IdempotencyStorer keeps responses to requests by idempotency keys.
+type IdempotencyStorer interface {
+	// BeginRequest claims key for request with body of bodyHash for the lease. If key is
+	// already claimed claimed is false and response is that of finished request or nil
+	// while request is in progress. ErrIdempotencyKeyReused is returned if key was
+	// claimed by request with another body.
+	BeginRequest(
+		ctx context.Context,
+		key, bodyHash string,
+		lease time.Duration,
+	) (response *IdempotentResponse, claimed bool, err error)
+	// CompleteRequest keeps response of request which claimed key for ttl.
+	CompleteRequest(ctx context.Context, key string, response IdempotentResponse, ttl time.Duration) error
+	// AbandonRequest releases key of failed request, so that retry is processed anew.
+	AbandonRequest(ctx context.Context, key string) error
 }
 
 type (
@@ -80,26 +546,157 @@ type Rotator interface {
 	Load(rotations []Rotation, trials int64)
 }
 
+// RotationScore is how rotator values rotation. Score is Mean estimate
+// of rotation reward plus exploration Bonus.
+type RotationScore struct {
+	Rotation Rotation
+	Mean     float64
+	Bonus    float64
+	Score    float64
+}
+
+// Reasons for rotation to be left out of banner choice.
+const (
+	ExclusionPaused = "paused"
+	// Campaign of rotation banner has exhausted or is ahead of its budget
+	ExclusionOverBudget = "over_budget"
+	// Visitor has seen the banner as many times as frequency cap allows
+	ExclusionCapped = "frequency_capped"
+	// Visitor is assigned to another banner of sticky slot
+	ExclusionSticky = "sticky_assignment"
+	// Another rotation is pinned or falls below its minimal share
+	ExclusionOverridden = "overridden"
+)
+
+// RotationExplanation is rotation along with its score and
+// reasons it is excluded from the choice if any.
+type RotationExplanation struct {
+	Rotation
+	Mean       float64
+	Bonus      float64
+	Score      float64
+	Exclusions []string `json:",omitempty"`
+}
+
+// ChoiceExplanation describes how banner would be chosen for slot and group.
+type ChoiceExplanation struct {
+	SlotID    uuid.UUID
+	GroupID   uuid.UUID
+	VisitorID string `json:",omitempty"`
+	Trials    int64
+	// HoldoutPercent of shows is chosen at random regardless of scores
+	HoldoutPercent float64
+	// ChosenBannerID is banner which would be shown outside of holdout
+	ChosenBannerID uuid.UUID
+	Rotations      []RotationExplanation
+}
+
+// ScoringRotator is a Rotator which chooses rotation with the highest score.
+type ScoringRotator interface {
+	Rotator
+	// Scores returns scores of loaded rotations in the same order.
+	Scores() []RotationScore
+}
+
+// ContextualRotator is a Rotator which takes visitor features into account.
+type ContextualRotator interface {
+	Rotator
+	// RotateContext chooses rotation for visitor described by features.
+	RotateContext(features []float64) Rotation
+	// ScoresContext returns scores of loaded rotations for visitor described by features.
+	ScoresContext(features []float64) []RotationScore
+	// Observe accounts show of rotation to visitor described by features.
+	Observe(rotation Rotation, features []float64)
+	// Reward accounts reward received after show of rotation.
+	Reward(rotation Rotation, features []float64, reward float64)
+	// MarshalState and UnmarshalState are used to persist learned model.
+	// Empty state resets the model.
+	MarshalState() ([]byte, error)
+	UnmarshalState(state []byte) error
+}
+
 type Application interface {
 	AddBanner(ctx context.Context, description string) (Banner, error)
 	DeleteBanner(ctx context.Context, bannerID uuid.UUID) error
 	GetBanner(ctx context.Context, bannerID uuid.UUID) (Banner, error)
+	ListBanners(ctx context.Context) ([]Banner, error)
+	// Move banner to campaign, uuid.Nil takes banner out of campaigns
+	SetBannerCampaign(ctx context.Context, bannerID, campaignID uuid.UUID) error
+
+	AddAdvertiser(ctx context.Context, description string) (Advertiser, error)
+	DeleteAdvertiser(ctx context.Context, advertiserID uuid.UUID) error
+	GetAdvertiser(ctx context.Context, advertiserID uuid.UUID) (Advertiser, error)
+
+	AddCampaign(ctx context.Context, advertiserID uuid.UUID, description string, budget CampaignBudget) (Campaign, error)
+	DeleteCampaign(ctx context.Context, campaignID uuid.UUID) error
+	GetCampaign(ctx context.Context, campaignID uuid.UUID) (Campaign, error)
+	SetCampaignBudget(ctx context.Context, campaignID uuid.UUID, budget CampaignBudget) error
+	GetCampaignConsumption(ctx context.Context, campaignID uuid.UUID) (CampaignConsumption, error)
 
 	AddSlot(ctx context.Context, description string) (Slot, error)
 	DeleteSlot(ctx context.Context, slotID uuid.UUID) error
 	GetSlot(ctx context.Context, slotID uuid.UUID) (Slot, error)
+	SetSlotSettings(ctx context.Context, slotID uuid.UUID, settings SlotSettings) error
+	ListSlots(ctx context.Context) ([]Slot, error)
 
 	AddGroup(ctx context.Context, description string) (Group, error)
 	DeleteGroup(ctx context.Context, groupID uuid.UUID) error
 	GetGroup(ctx context.Context, groupID uuid.UUID) (Group, error)
+	ListGroups(ctx context.Context) ([]Group, error)
+	AddGroupRule(ctx context.Context, rule GroupRule) (GroupRule, error)
+	GetGroupRules(ctx context.Context, groupID uuid.UUID) ([]GroupRule, error)
+	DeleteGroupRule(ctx context.Context, groupID uuid.UUID, ruleID int) error
+	ResolveGroup(ctx context.Context, visitor Visitor) (Group, error)
 
 	AddRotation(ctx context.Context, bannerID, slotID, groupID uuid.UUID) (Rotation, error)
+	ListRotations(ctx context.Context) ([]Rotation, error)
 	DeleteRotation(ctx context.Context, bannerID, slotID, groupID uuid.UUID) error
 	GetRotation(ctx context.Context, bannerID, slotID, groupID uuid.UUID) (Rotation, error)
+	SetFrequencyCap(ctx context.Context, bannerID, slotID, groupID uuid.UUID, frequencyCap FrequencyCap) error
+	SetRotationState(ctx context.Context, bannerID, slotID, groupID uuid.UUID, state string) error
+	SetRotationOverride(ctx context.Context, bannerID, slotID, groupID uuid.UUID, override RotationOverride) error
 
-	RegisterClick(ctx context.Context, bannerID, slotID, groupID uuid.UUID) error
+	RegisterClick(ctx context.Context, click Click) error
+	RegisterConversion(ctx context.Context, conversion Conversion) error
 	GetStats(ctx context.Context, bannerID, slotID, groupID uuid.UUID) ([]Event, error)
-	ChooseBanner(ctx context.Context, slotID, groupID uuid.UUID) (Rotation, error)
+	// Compare CTR of holdout and optimised shows of slot in time range
+	GetHoldoutReport(ctx context.Context, slotID uuid.UUID, from, to time.Time) (HoldoutReport, error)
+	// Estimate CTR of rotators on shows logged in time range
+	EvaluateRotators(
+		ctx context.Context,
+		slotID, groupID uuid.UUID,
+		from, to time.Time,
+		algorithms []string,
+	) ([]PolicyEstimate, error)
+	ChooseBanner(ctx context.Context, slotID, groupID uuid.UUID, visitor Visitor) (Impression, error)
+	// Choose banner for group resolved from visitor attributes
+	ChooseBannerForVisitor(ctx context.Context, slotID uuid.UUID, visitor Visitor) (Impression, error)
+	// Export banners, slots, groups and rotations
+	ExportCatalogue(ctx context.Context) (Catalogue, error)
+	// Import catalogue, report lists conflicts with ErrCatalogueConflicts
+	ImportCatalogue(ctx context.Context, catalogue Catalogue, dryRun bool) (ImportReport, error)
+	// Explain banner choice for slot and group without registering a show
+	ExplainChoice(ctx context.Context, slotID, groupID uuid.UUID, visitor Visitor) (ChoiceExplanation, error)
+	// Choose banners for several slots of a page without repeats
+	ChooseBanners(ctx context.Context, slotIDs []uuid.UUID, groupID uuid.UUID, visitor Visitor) ([]Impression, error)
+	// Check dependencies required to serve banners
+	Readiness(ctx context.Context) Readiness
+	// Create API key with role. The key is returned only once, storage keeps its hash.
+	CreateAPIKey(ctx context.Context, name, role string) (key APIKey, secret string, err error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID uuid.UUID) error
+	// Find API key by secret, ErrInvalidAPIKey is returned for unknown or revoked keys
+	Authenticate(ctx context.Context, secret string) (APIKey, error)
+	// Take token from client bucket, request must be rejected if retryAfter is not zero
+	AllowRequest(ctx context.Context, key string, limit RateLimit) (retryAfter time.Duration, err error)
+	// Claim idempotency key, response of finished request with the key is returned if key is taken
+	BeginIdempotentRequest(
+		ctx context.Context,
+		key, bodyHash string,
+		lease time.Duration,
+	) (response *IdempotentResponse, claimed bool, err error)
+	CompleteIdempotentRequest(ctx context.Context, key string, response IdempotentResponse, ttl time.Duration) error
+	AbandonIdempotentRequest(ctx context.Context, key string) error
 
 	GetLogger(name string) Logger
 }
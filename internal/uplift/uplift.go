@@ -0,0 +1,51 @@
+package uplift
+
+import (
+	"math"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+)
+
+// SignificanceLevel is maximum p-value of significant CTR difference.
+const SignificanceLevel = 0.05
+
+// Compare compares CTR of optimised shows against holdout ones
+// with two-sided two-proportion z-test.
+func Compare(holdout, optimised types.ArmStats) types.HoldoutReport {
+	holdout.Arm, optimised.Arm = types.ArmHoldout, types.ArmOptimised
+	holdout.CTR, optimised.CTR = ctr(holdout), ctr(optimised)
+
+	report := types.HoldoutReport{
+		Holdout:   holdout,
+		Optimised: optimised,
+		Uplift:    optimised.CTR - holdout.CTR,
+		PValue:    1,
+	}
+
+	if holdout.CTR > 0 {
+		report.RelativeUplift = report.Uplift / holdout.CTR
+	}
+
+	if holdout.Shows == 0 || optimised.Shows == 0 {
+		return report
+	}
+
+	pooled := float64(holdout.Clicks+optimised.Clicks) / float64(holdout.Shows+optimised.Shows)
+	stdErr := math.Sqrt(pooled * (1 - pooled) * (1/float64(holdout.Shows) + 1/float64(optimised.Shows)))
+	if stdErr == 0 {
+		return report
+	}
+
+	report.ZScore = report.Uplift / stdErr
+	report.PValue = math.Erfc(math.Abs(report.ZScore) / math.Sqrt2)
+	report.Significant = report.PValue < SignificanceLevel
+
+	return report
+}
+
+func ctr(stats types.ArmStats) float64 {
+	if stats.Shows == 0 {
+		return 0
+	}
+	return float64(stats.Clicks) / float64(stats.Shows)
+}
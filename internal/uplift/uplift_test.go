@@ -0,0 +1,45 @@
+package uplift
+
+import (
+	"testing"
+
+	"github.com/FedoseevAlex/banner-rotation/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare(t *testing.T) {
+	t.Run("check significant uplift", func(t *testing.T) {
+		report := Compare(
+			types.ArmStats{Shows: 10000, Clicks: 400},
+			types.ArmStats{Shows: 10000, Clicks: 500},
+		)
+
+		require.Equal(t, types.ArmHoldout, report.Holdout.Arm)
+		require.Equal(t, 0.04, report.Holdout.CTR)
+		require.Equal(t, 0.05, report.Optimised.CTR)
+		require.InDelta(t, 0.01, report.Uplift, 1e-9)
+		require.InDelta(t, 0.25, report.RelativeUplift, 1e-9)
+		// Reference values of two-proportion z-test
+		require.InDelta(t, 3.41, report.ZScore, 0.01)
+		require.InDelta(t, 0.0006, report.PValue, 0.0001)
+		require.True(t, report.Significant)
+	})
+
+	t.Run("check insignificant difference", func(t *testing.T) {
+		report := Compare(
+			types.ArmStats{Shows: 1000, Clicks: 40},
+			types.ArmStats{Shows: 1000, Clicks: 45},
+		)
+
+		require.Greater(t, report.PValue, SignificanceLevel)
+		require.False(t, report.Significant)
+	})
+
+	t.Run("check empty arm", func(t *testing.T) {
+		report := Compare(types.ArmStats{}, types.ArmStats{Shows: 100, Clicks: 5})
+
+		require.Zero(t, report.ZScore)
+		require.Equal(t, 1.0, report.PValue)
+		require.False(t, report.Significant)
+	})
+}
@@ -49,7 +49,7 @@ CREATE TABLE IF NOT EXISTS events (
 
 -- +goose Down
 -- +goose StatementBegin
-DROP TABLE IF EXISTS event_timestamps;
+DROP TABLE IF EXISTS events;
 DROP TABLE IF EXISTS rotations;
 DROP TABLE IF EXISTS banners;
 DROP TABLE IF EXISTS slots;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE rotations
+    ADD COLUMN IF NOT EXISTS cap_shows          INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS cap_window_seconds BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS exposures (
+    id         SERIAL PRIMARY KEY,
+    visitor_id TEXT NOT NULL,
+    banner_id  UUID NOT NULL,
+    slot_id    UUID NOT NULL,
+    group_id   UUID NOT NULL,
+    stamp      TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS exposures_visitor_idx
+    ON exposures (visitor_id, banner_id, slot_id, group_id, stamp);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS exposures;
+ALTER TABLE rotations
+    DROP COLUMN IF EXISTS cap_shows,
+    DROP COLUMN IF EXISTS cap_window_seconds;
+-- +goose StatementEnd
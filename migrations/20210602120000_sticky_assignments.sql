@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE slots
+    ADD COLUMN IF NOT EXISTS sticky             BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS sticky_ttl_seconds BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS assignments (
+    visitor_id  TEXT NOT NULL,
+    slot_id     UUID NOT NULL,
+    group_id    UUID NOT NULL,
+    banner_id   UUID NOT NULL,
+    assigned_at TIMESTAMP NOT NULL,
+    expires_at  TIMESTAMP,
+
+    FOREIGN KEY (banner_id) REFERENCES banners(id),
+    FOREIGN KEY (slot_id)   REFERENCES slots(id),
+    FOREIGN KEY (group_id)  REFERENCES groups(id),
+    PRIMARY KEY (visitor_id, slot_id, group_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS assignments;
+ALTER TABLE slots
+    DROP COLUMN IF EXISTS sticky,
+    DROP COLUMN IF EXISTS sticky_ttl_seconds;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS group_rules (
+    id         SERIAL PRIMARY KEY,
+    group_id   UUID NOT NULL,
+    priority   INT NOT NULL DEFAULT 0,
+    min_age    INT NOT NULL DEFAULT 0,
+    max_age    INT NOT NULL DEFAULT 0,
+    gender     TEXT NOT NULL DEFAULT '',
+    locale     TEXT NOT NULL DEFAULT '',
+    device     TEXT NOT NULL DEFAULT '',
+    attributes JSONB NOT NULL DEFAULT '{}',
+    deleted    BOOLEAN DEFAULT FALSE,
+    deleted_at TIMESTAMP,
+
+    FOREIGN KEY (group_id) REFERENCES groups(id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS group_rules;
+-- +goose StatementEnd
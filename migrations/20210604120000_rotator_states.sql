@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS rotator_states (
+    slot_id    UUID NOT NULL,
+    group_id   UUID NOT NULL,
+    state      BYTEA NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+
+    FOREIGN KEY (slot_id)  REFERENCES slots(id),
+    FOREIGN KEY (group_id) REFERENCES groups(id),
+    PRIMARY KEY (slot_id, group_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS rotator_states;
+-- +goose StatementEnd
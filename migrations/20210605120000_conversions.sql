@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE rotations
+    ADD COLUMN IF NOT EXISTS conversions INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS revenue     DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS impression_id UUID,
+    ADD COLUMN IF NOT EXISTS visitor_id    TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS value         DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS events_impression_idx ON events (impression_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS events_impression_idx;
+ALTER TABLE events
+    DROP COLUMN IF EXISTS impression_id,
+    DROP COLUMN IF EXISTS visitor_id,
+    DROP COLUMN IF EXISTS value;
+ALTER TABLE rotations
+    DROP COLUMN IF EXISTS conversions,
+    DROP COLUMN IF EXISTS revenue;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Probability with which banner of a show was chosen. Shows logged before
+-- the column was added were chosen deterministically, so it defaults to 1.
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS propensity DOUBLE PRECISION NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE events
+    DROP COLUMN IF EXISTS propensity;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE slots
+    ADD COLUMN IF NOT EXISTS holdout_percent DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- Arm of experiment the show belongs to: holdout or optimised.
+-- Shows logged before holdout was introduced were all chosen by rotator.
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS arm TEXT NOT NULL DEFAULT 'optimised';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE events
+    DROP COLUMN IF EXISTS arm;
+ALTER TABLE slots
+    DROP COLUMN IF EXISTS holdout_percent;
+-- +goose StatementEnd
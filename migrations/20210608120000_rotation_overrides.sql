@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE rotations
+    ADD COLUMN IF NOT EXISTS state             TEXT NOT NULL DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS pinned            BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS min_share_percent DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- Details of state and override changes recorded as events
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS details TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE events
+    DROP COLUMN IF EXISTS details;
+ALTER TABLE rotations
+    DROP COLUMN IF EXISTS state,
+    DROP COLUMN IF EXISTS pinned,
+    DROP COLUMN IF EXISTS min_share_percent;
+-- +goose StatementEnd
@@ -0,0 +1,60 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS advertisers (
+    id          UUID PRIMARY KEY,
+    description TEXT,
+    deleted     BOOLEAN DEFAULT FALSE,
+    deleted_at  TIMESTAMP
+);
+
+-- Zero budget means the campaign is not limited
+CREATE TABLE IF NOT EXISTS campaigns (
+    id                  UUID PRIMARY KEY,
+    advertiser_id       UUID NOT NULL,
+    description         TEXT,
+    total_shows_budget  INT NOT NULL DEFAULT 0,
+    total_clicks_budget INT NOT NULL DEFAULT 0,
+    daily_shows_budget  INT NOT NULL DEFAULT 0,
+    daily_clicks_budget INT NOT NULL DEFAULT 0,
+    deleted             BOOLEAN DEFAULT FALSE,
+    deleted_at          TIMESTAMP,
+
+    FOREIGN KEY (advertiser_id) REFERENCES advertisers(id)
+);
+
+ALTER TABLE banners
+    ADD COLUMN IF NOT EXISTS campaign_id UUID REFERENCES campaigns(id);
+
+CREATE INDEX IF NOT EXISTS events_rotation_stamp_idx ON events (rotation_id, stamp);
+
+-- Shows and clicks of campaign banners in total and since start of the current day.
+-- day_elapsed is elapsed part of the current day used for pacing.
+CREATE OR REPLACE VIEW campaign_consumption AS
+SELECT
+    c.id AS campaign_id,
+    c.total_shows_budget,
+    c.total_clicks_budget,
+    c.daily_shows_budget,
+    c.daily_clicks_budget,
+    count(e.id) FILTER (WHERE e.event_type = 'show') AS total_shows,
+    count(e.id) FILTER (WHERE e.event_type = 'click') AS total_clicks,
+    count(e.id) FILTER (WHERE e.event_type = 'show' AND e.stamp >= date_trunc('day', now())) AS daily_shows,
+    count(e.id) FILTER (WHERE e.event_type = 'click' AND e.stamp >= date_trunc('day', now())) AS daily_clicks,
+    (extract(EPOCH FROM now() - date_trunc('day', now())) / 86400)::DOUBLE PRECISION AS day_elapsed
+FROM campaigns c
+LEFT JOIN banners b ON b.campaign_id = c.id
+LEFT JOIN rotations r ON r.banner_id = b.id
+LEFT JOIN events e ON e.rotation_id = r.id
+WHERE c.deleted = FALSE
+GROUP BY c.id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP VIEW IF EXISTS campaign_consumption;
+DROP INDEX IF EXISTS events_rotation_stamp_idx;
+ALTER TABLE banners
+    DROP COLUMN IF EXISTS campaign_id;
+DROP TABLE IF EXISTS campaigns;
+DROP TABLE IF EXISTS advertisers;
+-- +goose StatementEnd
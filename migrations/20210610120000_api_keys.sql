@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Only SHA-256 hash of API key is stored, the key itself is shown once on creation
+CREATE TABLE IF NOT EXISTS api_keys (
+    id         UUID PRIMARY KEY,
+    name       TEXT NOT NULL,
+    role       TEXT NOT NULL,
+    key_hash   TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    deleted    BOOLEAN DEFAULT FALSE,
+    deleted_at TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS api_keys;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Clicks which failed validation are kept apart from events, so that
+-- they are not counted in stats and do not train rotators
+CREATE TABLE IF NOT EXISTS rejected_events (
+    id            SERIAL PRIMARY KEY,
+    event_type    TEXT NOT NULL,
+    banner_id     UUID NOT NULL,
+    slot_id       UUID NOT NULL,
+    group_id      UUID NOT NULL,
+    impression_id UUID,
+    visitor_id    TEXT NOT NULL DEFAULT '',
+    user_agent    TEXT NOT NULL DEFAULT '',
+    ip            TEXT NOT NULL DEFAULT '',
+    reason        TEXT NOT NULL,
+    stamp         TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Shows and clicks of visitor are looked up to validate clicks without impression
+CREATE INDEX IF NOT EXISTS events_visitor_idx ON events (visitor_id, rotation_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS events_visitor_idx;
+DROP TABLE IF EXISTS rejected_events;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Responses to requests with Idempotency-Key header. Zero status marks request
+-- in progress, its key is claimed until expires_at
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key          TEXT PRIMARY KEY,
+    status       INT NOT NULL DEFAULT 0,
+    content_type TEXT NOT NULL DEFAULT '',
+    body         BYTEA,
+    expires_at   TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS idempotency_keys;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Consumption is counted only for campaigns of the request, see storage.GetCampaignsConsumption
+DROP VIEW IF EXISTS campaign_consumption;
+CREATE INDEX IF NOT EXISTS banners_campaign_idx ON banners (campaign_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS banners_campaign_idx;
+CREATE OR REPLACE VIEW campaign_consumption AS
+SELECT
+    c.id AS campaign_id,
+    c.total_shows_budget,
+    c.total_clicks_budget,
+    c.daily_shows_budget,
+    c.daily_clicks_budget,
+    count(e.id) FILTER (WHERE e.event_type = 'show') AS total_shows,
+    count(e.id) FILTER (WHERE e.event_type = 'click') AS total_clicks,
+    count(e.id) FILTER (WHERE e.event_type = 'show' AND e.stamp >= date_trunc('day', now())) AS daily_shows,
+    count(e.id) FILTER (WHERE e.event_type = 'click' AND e.stamp >= date_trunc('day', now())) AS daily_clicks,
+    (extract(EPOCH FROM now() - date_trunc('day', now())) / 86400)::DOUBLE PRECISION AS day_elapsed
+FROM campaigns c
+LEFT JOIN banners b ON b.campaign_id = c.id
+LEFT JOIN rotations r ON r.banner_id = b.id
+LEFT JOIN events e ON e.rotation_id = r.id
+WHERE c.deleted = FALSE
+GROUP BY c.id;
+-- +goose StatementEnd
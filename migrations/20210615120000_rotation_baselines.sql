@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Counters of rotations imported with catalogue, they have no logged events
+CREATE TABLE IF NOT EXISTS rotation_baselines (
+    rotation_id INT PRIMARY KEY REFERENCES rotations(id),
+    shows       INT NOT NULL DEFAULT 0,
+    clicks      INT NOT NULL DEFAULT 0,
+    conversions INT NOT NULL DEFAULT 0,
+    revenue     DOUBLE PRECISION NOT NULL DEFAULT 0
+);
+
+-- Rotations imported before have counters but no events at all
+INSERT INTO rotation_baselines (rotation_id, shows, clicks, conversions, revenue)
+SELECT r.id, r.shows, r.clicks, r.conversions, r.revenue
+FROM rotations r
+WHERE
+(r.shows<>0 OR r.clicks<>0 OR r.conversions<>0 OR r.revenue<>0)
+AND NOT EXISTS (SELECT 1 FROM events e WHERE e.rotation_id=r.id)
+ON CONFLICT (rotation_id) DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS rotation_baselines;
+-- +goose StatementEnd
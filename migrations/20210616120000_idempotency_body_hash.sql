@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Hash of request body which claimed the key, retries with another body are rejected
+ALTER TABLE idempotency_keys
+    ADD COLUMN IF NOT EXISTS body_hash TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE idempotency_keys
+    DROP COLUMN IF EXISTS body_hash;
+-- +goose StatementEnd
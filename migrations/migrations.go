@@ -0,0 +1,10 @@
+// Package migrations embeds goose SQL migrations of the database schema
+// so the service binary can apply them itself.
+package migrations
+
+import "embed"
+
+// FS holds migration files at its root.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	files, err := fs.Glob(FS, "*.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		data, err := FS.ReadFile(file)
+		require.NoError(t, err)
+
+		up := strings.Index(string(data), "-- +goose Up")
+		down := strings.Index(string(data), "-- +goose Down")
+		require.NotEqual(t, -1, up, "%s has no up migration", file)
+		require.Greater(t, down, up, "%s has no down migration after up", file)
+	}
+
+	t.Run("check initial down migration drops events", func(t *testing.T) {
+		data, err := FS.ReadFile(files[0])
+		require.NoError(t, err)
+
+		down := string(data)[strings.Index(string(data), "-- +goose Down"):]
+		require.Contains(t, down, "DROP TABLE IF EXISTS events;")
+		require.Less(t, strings.Index(down, "events"), strings.Index(down, "rotations"))
+	})
+}